@@ -0,0 +1,168 @@
+package metricsfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"time"
+)
+
+// AdminSettings are the settings shown and changed by the admin handler,
+// the fields of Config that UpdateConfig applies. In requests, fields left
+// out are not changed.
+type AdminSettings struct {
+	EnableLatencyMetrics   *bool `json:"enable_latency_metrics,omitempty"`
+	EnableBandwidthMetrics *bool `json:"enable_bandwidth_metrics,omitempty"`
+	EnablePathMetrics      *bool `json:"enable_path_metrics,omitempty"`
+	LatencySampleRate      *int  `json:"latency_sample_rate,omitempty"`
+
+	// SLOThresholds are durations in time.ParseDuration format, e.g. "20ms"
+	SLOThresholds map[string]string `json:"slo_thresholds,omitempty"`
+
+	// SlowOperationThreshold is a duration like SLOThresholds, "0s" to
+	// disable the slow operation log
+	SlowOperationThreshold *string `json:"slow_operation_threshold,omitempty"`
+
+	IncludePaths []string `json:"include_paths,omitempty"`
+	ExcludePaths []string `json:"exclude_paths,omitempty"`
+}
+
+// adminSettings returns the settings of config, all fields set.
+func adminSettings(config Config) AdminSettings {
+	slowThreshold := config.SlowOperationThreshold.String()
+	settings := AdminSettings{
+		EnableLatencyMetrics:   &config.EnableLatencyMetrics,
+		EnableBandwidthMetrics: &config.EnableBandwidthMetrics,
+		EnablePathMetrics:      &config.EnablePathMetrics,
+		LatencySampleRate:      &config.LatencySampleRate,
+		SLOThresholds:          make(map[string]string, len(config.SLOThresholds)),
+		SlowOperationThreshold: &slowThreshold,
+		IncludePaths:           config.IncludePaths,
+		ExcludePaths:           config.ExcludePaths,
+	}
+	for op, threshold := range config.SLOThresholds {
+		settings.SLOThresholds[op] = threshold.String()
+	}
+	return settings
+}
+
+// apply validates s and sets its fields in config.
+func (s AdminSettings) apply(config *Config) error {
+	if s.LatencySampleRate != nil && *s.LatencySampleRate < 0 {
+		return fmt.Errorf("latency_sample_rate must not be negative")
+	}
+	var thresholds map[string]time.Duration
+	if s.SLOThresholds != nil {
+		thresholds = make(map[string]time.Duration, len(s.SLOThresholds))
+		for op, value := range s.SLOThresholds {
+			threshold, err := time.ParseDuration(value)
+			if err != nil || threshold < 0 {
+				return fmt.Errorf("invalid slo_thresholds[%q]: %q", op, value)
+			}
+			thresholds[op] = threshold
+		}
+	}
+	var slowThreshold time.Duration
+	if s.SlowOperationThreshold != nil {
+		var err error
+		slowThreshold, err = time.ParseDuration(*s.SlowOperationThreshold)
+		if err != nil || slowThreshold < 0 {
+			return fmt.Errorf("invalid slow_operation_threshold: %q", *s.SlowOperationThreshold)
+		}
+	}
+	for _, pattern := range slices.Concat(s.IncludePaths, s.ExcludePaths) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+
+	if s.EnableLatencyMetrics != nil {
+		config.EnableLatencyMetrics = *s.EnableLatencyMetrics
+	}
+	if s.EnableBandwidthMetrics != nil {
+		config.EnableBandwidthMetrics = *s.EnableBandwidthMetrics
+	}
+	if s.EnablePathMetrics != nil {
+		config.EnablePathMetrics = *s.EnablePathMetrics
+	}
+	if s.LatencySampleRate != nil {
+		config.LatencySampleRate = *s.LatencySampleRate
+	}
+	if thresholds != nil {
+		config.SLOThresholds = thresholds
+	}
+	if s.SlowOperationThreshold != nil {
+		config.SlowOperationThreshold = slowThreshold
+	}
+	if s.IncludePaths != nil {
+		config.IncludePaths = s.IncludePaths
+	}
+	if s.ExcludePaths != nil {
+		config.ExcludePaths = s.ExcludePaths
+	}
+	return nil
+}
+
+// AdminState is the body of the admin handler's responses.
+type AdminState struct {
+	Settings AdminSettings   `json:"settings"`
+	Stats    Stats           `json:"stats"`
+	Warnings []ConfigWarning `json:"warnings,omitempty"`
+}
+
+// AdminHandler returns an http.Handler serving a control plane for the
+// collector. GET serves its AdminState as JSON: the runtime settings, live
+// stats and configuration warnings. POST with a JSON AdminSettings body
+// changes the settings it sets through UpdateConfig, if authorize approves
+// the request, and serves the resulting state; an empty list or map clears
+// the setting. Settings cannot be changed when authorize is nil.
+//
+// Reads are not authorized; mount the handler on an internal listener or
+// behind authentication if the settings or stats are sensitive.
+func (c *Collector) AdminHandler(authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			if authorize == nil || !authorize(r) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			var settings AdminSettings
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&settings); err != nil {
+				http.Error(w, "invalid settings: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			var err error
+			c.UpdateConfig(func(config *Config) { err = settings.apply(config) })
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		state := AdminState{
+			Settings: adminSettings(c.Config()),
+			Stats:    c.Stats(),
+			Warnings: c.Warnings(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(state)
+	})
+}
+
+// AdminHandler returns an http.Handler serving a control plane for the
+// filesystem's collector. See Collector.AdminHandler.
+func (m *MetricsFS) AdminHandler(authorize func(r *http.Request) bool) http.Handler {
+	return m.collector.AdminHandler(authorize)
+}
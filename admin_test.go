@@ -0,0 +1,121 @@
+package metricsfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// adminRequest serves a request to h and decodes the state it returns.
+func adminRequest(t *testing.T, h http.Handler, method, body string) (*httptest.ResponseRecorder, AdminState) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/admin", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var state AdminState
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+			t.Fatalf("Failed to decode state: %v", err)
+		}
+	}
+	return rec, state
+}
+
+func bearerSecret(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer secret"
+}
+
+func TestAdminHandlerGet(t *testing.T) {
+	config := DefaultConfig()
+	config.SLOThresholds = map[string]time.Duration{"read": 20 * time.Millisecond}
+	fs := NewWithConfig(newMockFS(), config)
+	fs.Stat("/a.txt")
+
+	rec, state := adminRequest(t, fs.AdminHandler(nil), http.MethodGet, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET = %d, want 200", rec.Code)
+	}
+	s := state.Settings
+	if s.EnableLatencyMetrics == nil || !*s.EnableLatencyMetrics || s.EnablePathMetrics == nil || *s.EnablePathMetrics {
+		t.Errorf("unexpected settings %+v", s)
+	}
+	if s.SLOThresholds["read"] != "20ms" {
+		t.Errorf("slo_thresholds = %v", s.SLOThresholds)
+	}
+	if state.Stats.Operations["stat"].Count != 1 {
+		t.Errorf("Expected the live stats, got %+v", state.Stats)
+	}
+}
+
+func TestAdminHandlerUpdate(t *testing.T) {
+	fs := NewWithConfig(newMockFS(), DefaultConfig())
+	h := fs.AdminHandler(bearerSecret)
+
+	rec, state := adminRequest(t, h, http.MethodPost, `{
+		"enable_path_metrics": true,
+		"latency_sample_rate": 10,
+		"slo_thresholds": {"stat": "1h"},
+		"slow_operation_threshold": "250ms",
+		"exclude_paths": ["/tmp"]
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST = %d: %s", rec.Code, rec.Body)
+	}
+	if s := state.Settings; !*s.EnablePathMetrics || *s.LatencySampleRate != 10 || !*s.EnableLatencyMetrics {
+		t.Errorf("unexpected settings %+v", s)
+	}
+	if got := *state.Settings.SlowOperationThreshold; got != "250ms" {
+		t.Errorf("slow_operation_threshold = %q, want 250ms", got)
+	}
+
+	c := fs.Collector()
+	fs.Stat("/a.txt")
+	fs.Stat("/tmp/b.txt")
+	if v := testutil.ToFloat64(c.pathAccessTotal.WithLabelValues("/a.txt", "stat")); v != 1 {
+		t.Errorf("Expected path metrics to be enabled, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.sloOperationsTotal.WithLabelValues("stat", "under")); v != 1 {
+		t.Errorf("Expected the excluded path not to be measured, got %v stats", v)
+	}
+
+	// An empty list clears a setting
+	if _, state = adminRequest(t, h, http.MethodPost, `{"exclude_paths": []}`); len(state.Settings.ExcludePaths) != 0 {
+		t.Errorf("Expected exclude_paths to be cleared, got %v", state.Settings.ExcludePaths)
+	}
+}
+
+func TestAdminHandlerRejects(t *testing.T) {
+	fs := New(newMockFS())
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		method  string
+		body    string
+		code    int
+	}{
+		{"read-only", fs.AdminHandler(nil), http.MethodPost, `{"enable_path_metrics": true}`, http.StatusForbidden},
+		{"unauthorized", fs.AdminHandler(func(*http.Request) bool { return false }), http.MethodPost, `{}`, http.StatusForbidden},
+		{"unknown field", fs.AdminHandler(bearerSecret), http.MethodPost, `{"namespace": "x"}`, http.StatusBadRequest},
+		{"bad duration", fs.AdminHandler(bearerSecret), http.MethodPost, `{"slo_thresholds": {"read": "fast"}}`, http.StatusBadRequest},
+		{"bad pattern", fs.AdminHandler(bearerSecret), http.MethodPost, `{"include_paths": ["/["]}`, http.StatusBadRequest},
+		{"bad threshold", fs.AdminHandler(bearerSecret), http.MethodPost, `{"slow_operation_threshold": "-1s"}`, http.StatusBadRequest},
+		{"negative rate", fs.AdminHandler(bearerSecret), http.MethodPost, `{"latency_sample_rate": -1}`, http.StatusBadRequest},
+		{"method", fs.AdminHandler(bearerSecret), http.MethodDelete, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec, _ := adminRequest(t, tt.handler, tt.method, tt.body); rec.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.code)
+		}
+	}
+	if fs.Collector().Config().EnablePathMetrics {
+		t.Error("Expected rejected requests not to change the settings")
+	}
+}
@@ -0,0 +1,129 @@
+package metricsfs
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// calibratedOperations are the operations whose allocations are measured,
+// those on the hot path of file I/O.
+var calibratedOperations = []Op{OpOpen, OpStat, OpRead, OpWrite, OpClose}
+
+// Calibration parameters: each operation is recorded calibrationRuns times
+// per sample, and the smallest of calibrationSamples samples is kept, as
+// allocations by other goroutines can only inflate a sample.
+const (
+	calibrationRuns    = 100
+	calibrationSamples = 3
+)
+
+// OperationAllocations returns the number of heap allocations the
+// instrumentation makes per operation, by operation name, as measured by
+// the most recent calibration. It returns nil unless EnableAllocationMetrics
+// is set, and until the first calibration has run.
+func (c *Collector) OperationAllocations() map[string]float64 {
+	if c.allocations == nil {
+		return nil
+	}
+
+	c.allocations.mu.Lock()
+	defer c.allocations.mu.Unlock()
+	if c.allocations.byOperation == nil {
+		return nil
+	}
+	allocations := make(map[string]float64, len(c.allocations.byOperation))
+	for op, n := range c.allocations.byOperation {
+		allocations[op] = n
+	}
+	return allocations
+}
+
+// allocationCalibration holds the result of the most recent calibration.
+type allocationCalibration struct {
+	mu          sync.Mutex
+	byOperation map[string]float64
+}
+
+// runAllocationCalibration calibrates the allocations per operation every
+// interval until the collector is closed.
+func (c *Collector) runAllocationCalibration(interval time.Duration) {
+	defer c.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.calibrateAllocations()
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// calibrateAllocations measures the allocations made to record each of the
+// calibrated operations. The operations are recorded on a collector of their
+// own with the same configuration, so that they do not show up in c's
+// metrics, and without callbacks, the audit log or checksum verification,
+// which run user code.
+func (c *Collector) calibrateAllocations() {
+	config := c.config
+	config.EnableAllocationMetrics = false
+	config.Audit = AuditConfig{}
+	config.Verify = VerifyConfig{}
+	config.CallbackQueueSize = 0
+	config.TrackedStateTTL = 0
+	config.OnOperation = nil
+	config.OnError = nil
+	config.OnHistogramOverflow = nil
+	config.OnModeTransition = nil
+	config.OnDegraded = nil
+	config.OnScopeEnd = nil
+	config.OnChecksumMismatch = nil
+	calibration := NewCollector(config)
+	defer calibration.Close()
+
+	ctx := context.Background()
+	allocations := make(map[string]float64, len(calibratedOperations))
+	for _, op := range calibratedOperations {
+		var bytesTransferred int64
+		if op == OpRead || op == OpWrite {
+			bytesTransferred = 4096
+		}
+		record := func() {
+			start := calibration.startOperation(op)
+			duration := calibration.finishOperation(op, start)
+			calibration.recordOperation(ctx, op, "/metricsfs/calibration", duration, bytesTransferred, nil)
+		}
+
+		n := allocsPerRun(calibrationRuns, record)
+		for i := 1; i < calibrationSamples; i++ {
+			n = min(n, allocsPerRun(calibrationRuns, record))
+		}
+		allocations[string(op)] = n
+		c.operationAllocations.WithLabelValues(string(op)).Set(n)
+	}
+
+	c.allocations.mu.Lock()
+	c.allocations.byOperation = allocations
+	c.allocations.mu.Unlock()
+}
+
+// allocsPerRun returns the average number of heap allocations made by a
+// call to f, after a warm-up call, like testing.AllocsPerRun. Allocations by
+// other goroutines running meanwhile are counted too.
+func allocsPerRun(runs int, f func()) float64 {
+	f()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < runs; i++ {
+		f()
+	}
+	runtime.ReadMemStats(&after)
+
+	return float64(after.Mallocs-before.Mallocs) / float64(runs)
+}
@@ -0,0 +1,64 @@
+package metricsfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var allocSink []byte
+
+func TestAllocsPerRun(t *testing.T) {
+	n := allocsPerRun(100, func() {
+		allocSink = make([]byte, 1024)
+	})
+	if n < 1 || n > 1.5 {
+		t.Errorf("Expected about 1 allocation per run, got %v", n)
+	}
+}
+
+func TestAllocationMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableAllocationMetrics = true
+	c := NewCollector(config)
+	defer c.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.OperationAllocations() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the first calibration")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	allocations := c.OperationAllocations()
+	for _, op := range calibratedOperations {
+		n, ok := allocations[string(op)]
+		if !ok {
+			t.Errorf("Expected allocations for %s", op)
+			continue
+		}
+		if got := testutil.ToFloat64(c.operationAllocations.WithLabelValues(string(op))); got != n {
+			t.Errorf("Expected operation_allocations{operation=%q} = %v, got %v", op, n, got)
+		}
+	}
+
+	// Calibration operations are not recorded by the collector itself
+	stats := c.Stats()
+	if len(stats.Operations) != 0 {
+		t.Errorf("Expected no recorded operations, got %v", stats.Operations)
+	}
+	if len(stats.Allocations) != len(calibratedOperations) {
+		t.Errorf("Expected Stats to report allocations, got %v", stats.Allocations)
+	}
+}
+
+func TestAllocationMetricsDisabled(t *testing.T) {
+	c := NewCollector(DefaultConfig())
+	defer c.Close()
+
+	if got := c.OperationAllocations(); got != nil {
+		t.Errorf("Expected no allocations when disabled, got %v", got)
+	}
+}
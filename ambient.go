@@ -0,0 +1,34 @@
+package metricsfs
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes of the events added to ambient spans.
+const (
+	ambientBytesAttribute    = attribute.Key("fs.bytes")
+	ambientDurationAttribute = attribute.Key("fs.duration_us")
+	ambientErrorAttribute    = attribute.Key("fs.error")
+)
+
+// addAmbientSpanEvent records an operation as an event named after op on
+// the span of ctx, if it is recording, carrying the bytes transferred, the
+// duration in microseconds and, for failures, the error type.
+func addAmbientSpanEvent(ctx context.Context, op Op, duration time.Duration, bytesTransferred int64, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := make([]attribute.KeyValue, 2, 3)
+	attrs[0] = ambientBytesAttribute.Int64(bytesTransferred)
+	attrs[1] = ambientDurationAttribute.Int64(duration.Microseconds())
+	if err != nil {
+		attrs = append(attrs, ambientErrorAttribute.String(categorizeError(err)))
+	}
+	span.AddEvent(string(op), trace.WithAttributes(attrs...))
+}
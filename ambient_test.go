@@ -0,0 +1,95 @@
+package metricsfs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// ambientSpan is a recording span that keeps its events.
+type ambientSpan struct {
+	tracenoop.Span
+	events []ambientEvent
+}
+
+type ambientEvent struct {
+	name       string
+	attributes map[attribute.Key]attribute.Value
+}
+
+func (s *ambientSpan) IsRecording() bool { return true }
+
+func (s *ambientSpan) AddEvent(name string, opts ...trace.EventOption) {
+	event := ambientEvent{name: name, attributes: make(map[attribute.Key]attribute.Value)}
+	config := trace.NewEventConfig(opts...)
+	for _, kv := range config.Attributes() {
+		event.attributes[kv.Key] = kv.Value
+	}
+	s.events = append(s.events, event)
+}
+
+func TestAmbientSpanEvents(t *testing.T) {
+	config := DefaultConfig()
+	config.AmbientSpanEvents = true
+	fs := NewWithConfig(newMockFS(), config)
+
+	span := &ambientSpan{}
+	ctxFS := fs.WithContext(trace.ContextWithSpan(context.Background(), span))
+
+	f, err := ctxFS.Create("/a")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+
+	// Operations without an ambient span add no events
+	fs.Stat("/a")
+
+	var names []string
+	for _, event := range span.events {
+		names = append(names, event.name)
+	}
+	if len(names) != 3 || names[0] != "create" || names[1] != "write" || names[2] != "close" {
+		t.Fatalf("Expected create, write and close events, got %v", names)
+	}
+	write := span.events[1]
+	if got := write.attributes["fs.bytes"].AsInt64(); got != 5 {
+		t.Errorf("Expected fs.bytes 5, got %d", got)
+	}
+	if _, ok := write.attributes["fs.duration_us"]; !ok {
+		t.Error("Expected fs.duration_us on the event")
+	}
+	if _, ok := write.attributes["fs.error"]; ok {
+		t.Error("Expected no fs.error on a successful write")
+	}
+}
+
+func TestOTelAmbientSpanEvents(t *testing.T) {
+	tp := &recordingTracerProvider{}
+	ofs, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:     noop.NewMeterProvider(),
+		TracerProvider:    tp,
+		AmbientSpanEvents: true,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	span := &ambientSpan{}
+	ctx := trace.ContextWithSpan(context.Background(), span)
+	ofs.StatWithContext(ctx, "/a")
+	ofs.ChmodWithContext(ctx, "/a", os.ModePerm)
+
+	if len(tp.spans) != 0 {
+		t.Errorf("Expected no spans, got %d", len(tp.spans))
+	}
+	if len(span.events) != 2 || span.events[0].name != "stat" || span.events[1].name != "chmod" {
+		t.Errorf("Expected stat and chmod events, got %+v", span.events)
+	}
+}
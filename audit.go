@@ -0,0 +1,323 @@
+package metricsfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditConfig configures the audit log, which records one JSON line per
+// operation. See Config.Audit.
+type AuditConfig struct {
+	// Writer receives the audit log, one JSON-encoded AuditEntry per line.
+	// Auditing is disabled when nil. See NewRotatingFile
+	Writer io.Writer
+
+	// Operations limits the log to these operations (default: all)
+	Operations []Op
+
+	// PathPrefixes limits the log to operations on these paths and the
+	// paths under them (default: all paths)
+	PathPrefixes []string
+
+	// SampleRate logs this fraction of the operations that pass the
+	// filters, from 0.0 to 1.0 (default: 0, all of them)
+	SampleRate float64
+
+	// IncludeContextLabels adds the instance and ContextLabelNames labels of
+	// each operation to its entry
+	IncludeContextLabels bool
+}
+
+// AuditEntry is one line of the audit log.
+type AuditEntry struct {
+	// Time is when the operation completed
+	Time time.Time `json:"time"`
+
+	// Instance identifies the filesystem the operation was issued on. See
+	// Collector.Instance
+	Instance string `json:"instance,omitempty"`
+
+	// Operation is the operation name, e.g. "write"
+	Operation Op `json:"op"`
+
+	// Path is the path of the operation, as recorded in path metrics
+	Path string `json:"path,omitempty"`
+
+	// Handle is the ID of the file handle the operation was issued on,
+	// with Config.EnableHandleIDs
+	Handle string `json:"handle,omitempty"`
+
+	// Flags are the flags a file was opened with, e.g. "O_WRONLY|O_APPEND"
+	Flags string `json:"flags,omitempty"`
+
+	// Bytes is the number of bytes transferred
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// Duration is the operation duration in nanoseconds
+	Duration time.Duration `json:"duration_ns"`
+
+	// Error is the error the operation failed with
+	Error string `json:"error,omitempty"`
+
+	// Labels are the operation's context labels, with IncludeContextLabels
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// auditLog writes the entries selected by an AuditConfig.
+type auditLog struct {
+	config     AuditConfig
+	operations map[Op]bool
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// newAuditLog creates an audit log writing to config.Writer.
+func newAuditLog(config AuditConfig) *auditLog {
+	a := &auditLog{config: config, enc: json.NewEncoder(config.Writer)}
+	if len(config.Operations) > 0 {
+		a.operations = make(map[Op]bool, len(config.Operations))
+		for _, op := range config.Operations {
+			a.operations[op] = true
+		}
+	}
+	return a
+}
+
+// matches reports whether an operation on path is to be logged.
+func (a *auditLog) matches(op Op, path string) bool {
+	if a.operations != nil && !a.operations[op] {
+		return false
+	}
+	if len(a.config.PathPrefixes) > 0 && !hasPathPrefix(path, a.config.PathPrefixes) {
+		return false
+	}
+	rate := a.config.SampleRate
+	return rate <= 0 || rate >= 1 || rand.Float64() < rate
+}
+
+// hasPathPrefix reports whether path is one of prefixes or lies under one.
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		dir := strings.TrimSuffix(prefix, "/")
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes entry as one line.
+func (a *auditLog) write(entry AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enc.Encode(entry)
+}
+
+// recordAudit logs an operation when it is selected by the audit
+// configuration. ctxValues are the operation's dynamic label values.
+func (c *Collector) recordAudit(ctx context.Context, op Op, path string, duration time.Duration, bytesTransferred int64, err error, ctxValues []string) {
+	a := c.audit
+	if a == nil || !a.matches(op, path) {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:      c.clock.Now(),
+		Instance:  c.instanceOf(ctx),
+		Operation: op,
+		Path:      path,
+		Handle:    HandleID(ctx),
+		Bytes:     bytesTransferred,
+		Duration:  duration,
+	}
+	if flag, ok := openFlagsFromContext(ctx); ok {
+		entry.Flags = openFlagsString(flag)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if a.config.IncludeContextLabels && len(ctxValues) > 0 {
+		entry.Labels = make(map[string]string, len(ctxValues))
+		for i, name := range c.dynamicLabels {
+			entry.Labels[name] = ctxValues[i]
+		}
+	}
+
+	if err := a.write(entry); err != nil {
+		c.auditErrorsTotal.WithLabelValues().Inc()
+	}
+}
+
+// openFlagsKey is the context key holding the flags of an open, for the
+// audit log.
+type openFlagsKey struct{}
+
+// withOpenFlags returns ctx carrying the flags of an open when the audit log
+// is enabled, and ctx unchanged otherwise.
+func (c *Collector) withOpenFlags(ctx context.Context, flag int) context.Context {
+	if c.audit == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, openFlagsKey{}, flag)
+}
+
+// openFlagsFromContext returns the flags carried by ctx, if any.
+func openFlagsFromContext(ctx context.Context) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	flag, ok := ctx.Value(openFlagsKey{}).(int)
+	return flag, ok
+}
+
+// openFlagNames lists the open flags by name, after the access mode.
+var openFlagNames = []struct {
+	flag int
+	name string
+}{
+	{os.O_APPEND, "O_APPEND"},
+	{os.O_CREATE, "O_CREATE"},
+	{os.O_EXCL, "O_EXCL"},
+	{os.O_SYNC, "O_SYNC"},
+	{os.O_TRUNC, "O_TRUNC"},
+}
+
+// openFlagsString formats open flags as e.g. "O_WRONLY|O_CREATE|O_TRUNC".
+func openFlagsString(flag int) string {
+	names := []string{"O_RDONLY"}
+	switch {
+	case flag&os.O_RDWR != 0:
+		names[0] = "O_RDWR"
+	case flag&os.O_WRONLY != 0:
+		names[0] = "O_WRONLY"
+	}
+	for _, f := range openFlagNames {
+		if flag&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// RotatingFile is an io.WriteCloser appending to a file that is rotated
+// once it reaches a maximum size, for use as AuditConfig.Writer. The current
+// file keeps its name; rotated files are renamed with a .1, .2, ... suffix,
+// .1 being the most recent, and the oldest beyond the backup count are
+// removed.
+type RotatingFile struct {
+	name       string
+	maxSize    int64
+	maxBackups int
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// NewRotatingFile opens name for appending, rotating it once a write would
+// take it beyond maxSize bytes and keeping maxBackups rotated files. A
+// maxSize of 0 or less never rotates the file.
+func NewRotatingFile(name string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{name: name, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current file.
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p to the current file, rotating it first if p would take it
+// beyond the maximum size. A write larger than the maximum size goes to a
+// file of its own. When the current file could not be reopened after a
+// rotation, Write tries to open it again.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file to .1, shifting the backups, and opens a
+// new current file. If the rename fails, the current file is reopened so
+// that the next write retries the rotation.
+func (r *RotatingFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err == nil {
+		err = r.shift()
+	}
+	if openErr := r.open(); err == nil {
+		err = openErr
+	}
+	return err
+}
+
+// shift renames the current file to .1, shifting the backups, or removes it
+// without backups.
+func (r *RotatingFile) shift() error {
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", r.name, i) }
+	if r.maxBackups <= 0 {
+		return os.Remove(r.name)
+	}
+	os.Remove(backup(r.maxBackups))
+	for i := r.maxBackups - 1; i > 0; i-- {
+		os.Rename(backup(i), backup(i+1))
+	}
+	return os.Rename(r.name, backup(1))
+}
+
+// Close closes the current file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return os.ErrClosed
+	}
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,258 @@
+package metricsfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// auditEntries decodes the audit log in buf.
+func auditEntries(t *testing.T, buf *bytes.Buffer) []AuditEntry {
+	t.Helper()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	config := DefaultConfig()
+	config.Audit = AuditConfig{
+		Writer:       &buf,
+		Operations:   []Op{OpOpen, OpWrite, OpRemove},
+		PathPrefixes: []string{"/secure/"},
+	}
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, err := fs.OpenFile("/secure/ledger", os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte("entry"))
+	f.Close()
+	fs.Remove("/secure")
+	fs.Remove("/public/file")
+	fs.Stat("/secure/ledger")
+
+	entries := auditEntries(t, &buf)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %d: %+v", len(entries), entries)
+	}
+
+	open := entries[0]
+	if open.Operation != OpOpen || open.Path != "/secure/ledger" || open.Flags != "O_WRONLY|O_APPEND" {
+		t.Errorf("Unexpected open entry: %+v", open)
+	}
+	if entries[1].Operation != OpWrite || entries[1].Bytes != 5 {
+		t.Errorf("Unexpected write entry: %+v", entries[1])
+	}
+	if entries[2].Operation != OpRemove || entries[2].Path != "/secure" {
+		t.Errorf("Unexpected remove entry: %+v", entries[2])
+	}
+	for _, entry := range entries {
+		if entry.Time.IsZero() {
+			t.Errorf("Expected a timestamp on %+v", entry)
+		}
+	}
+}
+
+func TestAuditLogErrorsAndLabels(t *testing.T) {
+	var buf bytes.Buffer
+	config := DefaultConfig()
+	config.ContextLabelNames = []string{"tenant_id"}
+	config.ContextLabels = func(ctx context.Context) prometheus.Labels {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return prometheus.Labels{"tenant_id": tenant}
+	}
+	config.Audit = AuditConfig{Writer: &buf, IncludeContextLabels: true}
+	fs := NewWithConfig(&errorMockFS{}, config)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	fs.WithContext(ctx).Stat("/test.txt")
+
+	entries := auditEntries(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+	if entries[0].Error == "" {
+		t.Errorf("Expected the entry to carry the error, got %+v", entries[0])
+	}
+	if entries[0].Labels["tenant_id"] != "acme" {
+		t.Errorf("Expected tenant_id=acme, got %v", entries[0].Labels)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestAuditLogWriteErrors(t *testing.T) {
+	config := DefaultConfig()
+	config.Audit = AuditConfig{Writer: failingWriter{}}
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.Stat("/a")
+	fs.Stat("/b")
+
+	if got := testutil.ToFloat64(fs.Collector().auditErrorsTotal.WithLabelValues()); got != 2 {
+		t.Errorf("Expected 2 audit errors, got %v", got)
+	}
+}
+
+func TestAuditLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	config := DefaultConfig()
+	config.Audit = AuditConfig{Writer: &buf, SampleRate: 0.1}
+	fs := NewWithConfig(newMockFS(), config)
+
+	for i := 0; i < 1000; i++ {
+		fs.Stat("/test.txt")
+	}
+
+	// 100 expected; the bounds are over 6 standard deviations away
+	if n := len(auditEntries(t, &buf)); n < 40 || n > 160 {
+		t.Errorf("Expected about 100 sampled entries, got %d", n)
+	}
+}
+
+func TestOpenFlagsString(t *testing.T) {
+	tests := []struct {
+		flag int
+		want string
+	}{
+		{os.O_RDONLY, "O_RDONLY"},
+		{os.O_RDWR | os.O_CREATE | os.O_TRUNC, "O_RDWR|O_CREATE|O_TRUNC"},
+		{os.O_WRONLY | os.O_CREATE | os.O_EXCL, "O_WRONLY|O_CREATE|O_EXCL"},
+	}
+	for _, tt := range tests {
+		if got := openFlagsString(tt.flag); got != tt.want {
+			t.Errorf("openFlagsString(%d) = %q, want %q", tt.flag, got, tt.want)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	r, err := NewRotatingFile(name, 10, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Each line rotates the previous one out; the oldest is removed
+	want := map[string]string{
+		name:        "dddddd\n",
+		name + ".1": "cccccc\n",
+		name + ".2": "bbbbbb\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("ReadFile(%s) failed: %v", file, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to hold %q, got %q", file, content, data)
+		}
+	}
+	if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected no third backup, got %v", err)
+	}
+	if _, err := r.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected os.ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestRotatingFileNoMaxSize(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	r, err := NewRotatingFile(name, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	r.Close()
+
+	if data, _ := os.ReadFile(name); string(data) != "a\nb\nc\n" {
+		t.Errorf("Expected every line to be kept, got %q", data)
+	}
+}
+
+func TestRotatingFileRotationFailure(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	r, err := NewRotatingFile(name, 4, 1)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+
+	// A non-empty directory in the way of the backup fails the rotation
+	if err := os.MkdirAll(filepath.Join(name+".1", "x"), 0700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	r.Write([]byte("aaa\n"))
+	if _, err := r.Write([]byte("bbb\n")); err == nil {
+		t.Fatal("Expected the rotation to fail")
+	}
+
+	// The current file stays open, and the next write retries the rotation
+	os.RemoveAll(name + ".1")
+	if _, err := r.Write([]byte("ccc\n")); err != nil {
+		t.Fatalf("Write after a failed rotation failed: %v", err)
+	}
+	if data, _ := os.ReadFile(name + ".1"); string(data) != "aaa\n" {
+		t.Errorf("Expected the backup to hold the first line, got %q", data)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "ccc\n" {
+		t.Errorf("Expected the current file to hold the last line, got %q", data)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(name, []byte("existing\n"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	r, err := NewRotatingFile(name, 1024, 1)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	r.Write([]byte("new\n"))
+	r.Close()
+
+	data, _ := os.ReadFile(name)
+	if !strings.HasPrefix(string(data), "existing\n") || !strings.HasSuffix(string(data), "new\n") {
+		t.Errorf("Expected the existing log to be appended to, got %q", data)
+	}
+}
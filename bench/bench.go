@@ -0,0 +1,255 @@
+// Package bench runs standardized filesystem workloads against any
+// absfs.FileSystem and reports their throughput and latency, for comparing
+// backends such as memfs, osfs and s3fs, with and without wrappers, on the
+// same footing.
+//
+// Each workload prepares its files on the filesystem under test, then runs
+// through a metricsfs wrapper whose collector counts its operations and
+// bytes and times each operation. The measuring wrapper adds the same small
+// overhead to every filesystem, so results are comparable with each other.
+package bench
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/metricsfs"
+)
+
+// Config sizes the workloads. Zero fields take their default.
+type Config struct {
+	// Dir is the directory the workloads create their files in; it is
+	// removed afterwards (default: "metricsfs-bench" in the filesystem's
+	// TempDir)
+	Dir string
+
+	// FileSize is the size of the file read by SequentialRead and
+	// RandomRead (default: 16 MiB)
+	FileSize int64
+
+	// BlockSize is the size of each read (default: 64 KiB)
+	BlockSize int
+
+	// RandomReads is the number of reads made by RandomRead at random
+	// offsets (default: 1024)
+	RandomReads int
+
+	// SmallFiles is the number of files created, written and removed by
+	// CreateDeleteStorm (default: 1000)
+	SmallFiles int
+
+	// SmallFileSize is the size of each of those files (default: 4 KiB)
+	SmallFileSize int
+
+	// WalkDirs and WalkFiles are the number of directories, and of files in
+	// each, walked by DirectoryWalk (default: 20 and 50)
+	WalkDirs  int
+	WalkFiles int
+
+	// Iterations is the number of times each workload is run; the result
+	// covers them all (default: 1)
+	Iterations int
+
+	// Seed seeds the offsets of RandomRead, so that runs read the same
+	// blocks (default: 1)
+	Seed uint64
+}
+
+// withDefaults returns c with its zero fields set to their default.
+func (c Config) withDefaults(fs absfs.FileSystem) Config {
+	if c.Dir == "" {
+		c.Dir = path.Join(fs.TempDir(), "metricsfs-bench")
+	}
+	if c.FileSize == 0 {
+		c.FileSize = 16 << 20
+	}
+	if c.BlockSize == 0 {
+		c.BlockSize = 64 << 10
+	}
+	if c.RandomReads == 0 {
+		c.RandomReads = 1024
+	}
+	if c.SmallFiles == 0 {
+		c.SmallFiles = 1000
+	}
+	if c.SmallFileSize == 0 {
+		c.SmallFileSize = 4 << 10
+	}
+	if c.WalkDirs == 0 {
+		c.WalkDirs = 20
+	}
+	if c.WalkFiles == 0 {
+		c.WalkFiles = 50
+	}
+	if c.Iterations == 0 {
+		c.Iterations = 1
+	}
+	if c.Seed == 0 {
+		c.Seed = 1
+	}
+	return c
+}
+
+// Result is the outcome of running a workload.
+type Result struct {
+	// Workload is the workload's name
+	Workload string `json:"workload"`
+
+	// Duration is the time spent running the workload, excluding setup
+	Duration time.Duration `json:"duration_ns"`
+
+	// Operations and Errors are the number of filesystem operations the
+	// workload made, and of those that failed
+	Operations int64 `json:"operations"`
+	Errors     int64 `json:"errors"`
+
+	// Bytes is the number of bytes read and written
+	Bytes int64 `json:"bytes"`
+
+	// OpsPerSecond and BytesPerSecond are the workload's throughput
+	OpsPerSecond   float64 `json:"ops_per_second"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+
+	// Latency summarizes the duration of each operation, by name
+	Latency map[string]Latency `json:"latency"`
+}
+
+// Latency summarizes the durations of an operation.
+type Latency struct {
+	Count int           `json:"count"`
+	Mean  time.Duration `json:"mean_ns"`
+	P50   time.Duration `json:"p50_ns"`
+	P90   time.Duration `json:"p90_ns"`
+	P99   time.Duration `json:"p99_ns"`
+	Max   time.Duration `json:"max_ns"`
+}
+
+// newLatency summarizes durations, sorting them.
+func newLatency(durations []time.Duration) Latency {
+	slices.Sort(durations)
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	quantile := func(q float64) time.Duration {
+		return durations[int(q*float64(len(durations)-1))]
+	}
+	return Latency{
+		Count: len(durations),
+		Mean:  sum / time.Duration(len(durations)),
+		P50:   quantile(0.5),
+		P90:   quantile(0.9),
+		P99:   quantile(0.99),
+		Max:   durations[len(durations)-1],
+	}
+}
+
+// Run runs w against fs and reports its throughput and latency. The
+// workload's files are removed afterwards.
+func Run(fs absfs.FileSystem, w Workload, config Config) (Result, error) {
+	config = config.withDefaults(fs)
+	dir := path.Join(config.Dir, w.Name)
+	if err := fs.MkdirAll(dir, 0o755); err != nil {
+		return Result{}, fmt.Errorf("bench: %s: %w", w.Name, err)
+	}
+	defer fs.RemoveAll(config.Dir)
+
+	if w.Setup != nil {
+		if err := w.Setup(fs, dir, config); err != nil {
+			return Result{}, fmt.Errorf("bench: %s: setup: %w", w.Name, err)
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		durations = make(map[string][]time.Duration)
+	)
+	metricsConfig := metricsfs.DefaultConfig()
+	metricsConfig.OnOperation = func(op metricsfs.Operation) {
+		mu.Lock()
+		durations[op.Name] = append(durations[op.Name], op.Duration)
+		mu.Unlock()
+	}
+	measured := metricsfs.NewWithConfig(fs, metricsConfig)
+	defer measured.Collector().Close()
+
+	start := time.Now()
+	for range config.Iterations {
+		if err := w.Run(measured, dir, config); err != nil {
+			return Result{}, fmt.Errorf("bench: %s: %w", w.Name, err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	stats := measured.Collector().Stats()
+	result := Result{
+		Workload: w.Name,
+		Duration: elapsed,
+		Bytes:    stats.BytesRead + stats.BytesWritten,
+		Latency:  make(map[string]Latency, len(durations)),
+	}
+	for _, op := range stats.Operations {
+		result.Operations += op.Count
+		result.Errors += op.Errors
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		result.OpsPerSecond = float64(result.Operations) / seconds
+		result.BytesPerSecond = float64(result.Bytes) / seconds
+	}
+	for name, d := range durations {
+		result.Latency[name] = newLatency(d)
+	}
+	return result, nil
+}
+
+// RunAll runs the standard Workloads against fs, stopping at the first
+// that fails.
+func RunAll(fs absfs.FileSystem, config Config) ([]Result, error) {
+	var results []Result
+	for _, w := range Workloads() {
+		result, err := Run(fs, w, config)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// WriteResults writes results to w as a table of the throughput of each
+// workload and the latency of its operations.
+func WriteResults(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "workload\top\tcount\tops/s\tMB/s\tmean\tp50\tp99\tmax\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.0f\t%.1f\t\t\t\t\t\n",
+			r.Workload, "(all)", r.Operations, r.OpsPerSecond, r.BytesPerSecond/1e6)
+
+		names := make([]string, 0, len(r.Latency))
+		for name := range r.Latency {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			l := r.Latency[name]
+			fmt.Fprintf(tw, "\t%s\t%d\t\t\t%v\t%v\t%v\t%v\t\n",
+				name, l.Count, round(l.Mean), round(l.P50), round(l.P99), round(l.Max))
+		}
+	}
+	return tw.Flush()
+}
+
+// round rounds d for display.
+func round(d time.Duration) time.Duration {
+	if d > time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d
+}
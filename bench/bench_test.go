@@ -0,0 +1,97 @@
+package bench
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/osfs"
+)
+
+// smallConfig keeps the workloads quick.
+func smallConfig(t *testing.T) Config {
+	return Config{
+		Dir:           t.TempDir() + "/bench",
+		FileSize:      64 << 10,
+		BlockSize:     4 << 10,
+		RandomReads:   32,
+		SmallFiles:    20,
+		SmallFileSize: 128,
+		WalkDirs:      3,
+		WalkFiles:     4,
+		Iterations:    2,
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := smallConfig(t)
+
+	results, err := RunAll(base, config)
+	if err != nil {
+		t.Fatalf("RunAll failed: %v", err)
+	}
+	if len(results) != len(Workloads()) {
+		t.Fatalf("Expected %d results, got %d", len(Workloads()), len(results))
+	}
+
+	byName := make(map[string]Result)
+	for _, r := range results {
+		byName[r.Workload] = r
+		if r.Operations == 0 || r.Errors != 0 || r.Duration <= 0 || r.OpsPerSecond <= 0 {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+
+	// Two iterations of 16 sequential reads of 4 KiB, and one at EOF
+	if l := byName["sequential-read"].Latency["read"]; l.Count != 34 || l.P50 > l.Max {
+		t.Errorf("sequential read latency = %+v", l)
+	}
+	if got := byName["sequential-read"].Bytes; got != 2*config.FileSize {
+		t.Errorf("sequential read bytes = %d, want %d", got, 2*config.FileSize)
+	}
+	if l := byName["create-delete-storm"].Latency["remove"]; l.Count != 40 {
+		t.Errorf("Expected 40 removes, got %+v", l)
+	}
+	if l := byName["directory-walk"].Latency["stat"]; l.Count != 24 {
+		t.Errorf("Expected 24 stats, got %+v", l)
+	}
+
+	if _, err := base.Stat(config.Dir); err == nil {
+		t.Error("Expected the workload files to be removed")
+	}
+
+	var b strings.Builder
+	if err := WriteResults(&b, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"workload", "random-read", "(all)", "remove"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestRunSetupError(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := smallConfig(t)
+	w := Workload{
+		Name:  "broken",
+		Setup: func(fs absfs.FileSystem, dir string, config Config) error { return errors.New("no space") },
+		Run:   func(fs absfs.FileSystem, dir string, config Config) error { return nil },
+	}
+
+	if _, err := Run(base, w, config); err == nil || err.Error() != "bench: broken: setup: no space" {
+		t.Errorf("Expected a setup error naming the workload, got %v", err)
+	}
+	if _, err := base.Stat(config.Dir); err == nil {
+		t.Error("Expected the workload files to be removed")
+	}
+}
@@ -0,0 +1,183 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"path"
+
+	"github.com/absfs/absfs"
+)
+
+// Workload is a filesystem workload.
+type Workload struct {
+	// Name identifies the workload in results
+	Name string
+
+	// Setup prepares the files of the workload in dir, on the filesystem
+	// under test but without measuring it (optional)
+	Setup func(fs absfs.FileSystem, dir string, config Config) error
+
+	// Run runs the workload in dir once
+	Run func(fs absfs.FileSystem, dir string, config Config) error
+}
+
+// The standard workloads.
+var (
+	// SequentialRead reads a FileSize file from start to end in BlockSize
+	// reads
+	SequentialRead = Workload{
+		Name:  "sequential-read",
+		Setup: writeDataFile,
+		Run:   sequentialRead,
+	}
+
+	// RandomRead makes RandomReads reads of BlockSize at random offsets of
+	// a FileSize file
+	RandomRead = Workload{
+		Name:  "random-read",
+		Setup: writeDataFile,
+		Run:   randomRead,
+	}
+
+	// CreateDeleteStorm creates, writes and closes SmallFiles files of
+	// SmallFileSize, then removes them
+	CreateDeleteStorm = Workload{
+		Name: "create-delete-storm",
+		Run:  createDeleteStorm,
+	}
+
+	// DirectoryWalk walks a tree of WalkDirs directories of WalkFiles files
+	// each, reading every directory and stating every file
+	DirectoryWalk = Workload{
+		Name:  "directory-walk",
+		Setup: makeTree,
+		Run:   directoryWalk,
+	}
+)
+
+// Workloads returns the standard workloads, in the order RunAll runs them.
+func Workloads() []Workload {
+	return []Workload{SequentialRead, RandomRead, CreateDeleteStorm, DirectoryWalk}
+}
+
+// dataFile is the file read by SequentialRead and RandomRead.
+const dataFile = "data"
+
+// writeDataFile writes a FileSize file of dataFile in dir.
+func writeDataFile(fs absfs.FileSystem, dir string, config Config) error {
+	f, err := fs.Create(path.Join(dir, dataFile))
+	if err != nil {
+		return err
+	}
+	block := bytes.Repeat([]byte{0xa5}, config.BlockSize)
+	for written := int64(0); written < config.FileSize; written += int64(len(block)) {
+		if remaining := config.FileSize - written; remaining < int64(len(block)) {
+			block = block[:remaining]
+		}
+		if _, err := f.Write(block); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func sequentialRead(fs absfs.FileSystem, dir string, config Config) error {
+	f, err := fs.Open(path.Join(dir, dataFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, config.BlockSize)
+	for {
+		if _, err := f.Read(buf); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func randomRead(fs absfs.FileSystem, dir string, config Config) error {
+	f, err := fs.Open(path.Join(dir, dataFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rng := rand.New(rand.NewPCG(config.Seed, config.Seed))
+	buf := make([]byte, config.BlockSize)
+	span := max(config.FileSize-int64(config.BlockSize), 1)
+	for range config.RandomReads {
+		if _, err := f.ReadAt(buf, rng.Int64N(span)); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+func createDeleteStorm(fs absfs.FileSystem, dir string, config Config) error {
+	content := bytes.Repeat([]byte{0x5a}, config.SmallFileSize)
+	for i := range config.SmallFiles {
+		f, err := fs.Create(path.Join(dir, fmt.Sprintf("small-%d", i)))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(content); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	for i := range config.SmallFiles {
+		if err := fs.Remove(path.Join(dir, fmt.Sprintf("small-%d", i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makeTree creates the tree walked by DirectoryWalk in dir.
+func makeTree(fs absfs.FileSystem, dir string, config Config) error {
+	for d := range config.WalkDirs {
+		sub := path.Join(dir, fmt.Sprintf("dir-%d", d))
+		if err := fs.Mkdir(sub, 0o755); err != nil {
+			return err
+		}
+		for i := range config.WalkFiles {
+			f, err := fs.Create(path.Join(sub, fmt.Sprintf("file-%d", i)))
+			if err != nil {
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func directoryWalk(fs absfs.FileSystem, dir string, config Config) error {
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if err := directoryWalk(fs, name, config); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fs.Stat(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
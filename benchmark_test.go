@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/absfs/absfs"
+	"go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 )
 
 // BenchmarkMetricsOverhead measures the overhead of metrics collection.
@@ -242,3 +244,21 @@ func BenchmarkMemoryAllocation(b *testing.B) {
 		f.Close()
 	}
 }
+
+// BenchmarkOTelNoop measures the cost of OpenTelemetry instrumentation with
+// no-op providers, which should build no attributes per operation.
+func BenchmarkOTelNoop(b *testing.B) {
+	fs, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:  noop.NewMeterProvider(),
+		TracerProvider: tracenoop.NewTracerProvider(),
+		EnableTracing:  true,
+	})
+	if err != nil {
+		b.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fs.Stat("/test")
+	}
+}
@@ -0,0 +1,61 @@
+package metricsfs
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Latency bucket presets, in seconds, for Config.LatencyBuckets. The
+// default buckets, 1ms to 10s by decades, suit no storage in particular;
+// these resolve the latencies typical of each kind of storage.
+var (
+	// BucketsSSD covers 10µs to 100ms, for local SSDs and NVMe drives
+	// where most operations complete in under 100µs
+	BucketsSSD = []float64{
+		0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+		0.001, 0.0025, 0.005, 0.01, 0.025, 0.1,
+	}
+
+	// BucketsHDD covers 500µs to 2.5s, for spinning disks whose reads are
+	// dominated by seeks of several milliseconds
+	BucketsHDD = []float64{
+		0.0005, 0.001, 0.0025, 0.005, 0.01, 0.02,
+		0.05, 0.1, 0.25, 0.5, 1, 2.5,
+	}
+
+	// BucketsNetworkFS covers 100µs to 10s, for NFS, SMB and other network
+	// filesystems mixing cached and round-trip operations
+	BucketsNetworkFS = []float64{
+		0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01,
+		0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 10,
+	}
+
+	// BucketsObjectStore covers 5ms to 60s, for S3-style object stores
+	// with tens of milliseconds per request and long transfers
+	BucketsObjectStore = []float64{
+		0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5,
+		1, 2.5, 5, 10, 30, 60,
+	}
+)
+
+// bucketPresets are the latency bucket presets by name.
+var bucketPresets = map[string][]float64{
+	"ssd":         BucketsSSD,
+	"hdd":         BucketsHDD,
+	"networkfs":   BucketsNetworkFS,
+	"objectstore": BucketsObjectStore,
+}
+
+// WithBucketPreset returns a copy of c whose LatencyBuckets are the named
+// preset: "ssd", "hdd", "networkfs" or "objectstore", for BucketsSSD,
+// BucketsHDD, BucketsNetworkFS and BucketsObjectStore. Selecting presets
+// by name suits configuration files; in code, assigning the preset to
+// LatencyBuckets is equivalent.
+func (c Config) WithBucketPreset(name string) (Config, error) {
+	buckets, ok := bucketPresets[name]
+	if !ok {
+		return c, fmt.Errorf("metricsfs: unknown bucket preset %q", name)
+	}
+	c.LatencyBuckets = slices.Clone(buckets)
+	return c, nil
+}
@@ -0,0 +1,41 @@
+package metricsfs
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBucketPresets(t *testing.T) {
+	for name, buckets := range bucketPresets {
+		for i := 1; i < len(buckets); i++ {
+			if buckets[i] <= buckets[i-1] {
+				t.Errorf("Expected preset %s to be strictly increasing, got %v", name, buckets)
+			}
+		}
+	}
+
+	config, err := DefaultConfig().WithBucketPreset("ssd")
+	if err != nil {
+		t.Fatalf("WithBucketPreset failed: %v", err)
+	}
+	if !slices.Equal(config.LatencyBuckets, BucketsSSD) {
+		t.Errorf("Expected the SSD buckets, got %v", config.LatencyBuckets)
+	}
+
+	// The preset is copied
+	config.LatencyBuckets[0] = 42
+	if BucketsSSD[0] == 42 {
+		t.Error("Expected WithBucketPreset to copy the preset")
+	}
+
+	if _, err := DefaultConfig().WithBucketPreset("floppy"); err == nil {
+		t.Error("Expected an unknown preset to fail")
+	}
+
+	// Latency histograms use the preset
+	config, _ = DefaultConfig().WithBucketPreset("objectstore")
+	c := NewCollector(config)
+	if c.maxLatencyBucket != 60 {
+		t.Errorf("Expected the largest bucket to be 60s, got %v", c.maxLatencyBucket)
+	}
+}
@@ -0,0 +1,98 @@
+package metricsfs
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// callbackDispatcher runs callbacks on a pool of workers fed by a bounded
+// queue, so that slow callbacks do not slow down filesystem operations.
+type callbackDispatcher struct {
+	queue   chan func()
+	pending atomic.Int64
+
+	// Flush waits on idle until no callbacks are pending
+	mu   sync.Mutex
+	idle *sync.Cond
+}
+
+// newCallbackDispatcher creates a dispatcher with a queue of size callbacks.
+func newCallbackDispatcher(size int) *callbackDispatcher {
+	d := &callbackDispatcher{queue: make(chan func(), size)}
+	d.idle = sync.NewCond(&d.mu)
+	return d
+}
+
+// dispatch runs fn, asynchronously when CallbackQueueSize is set. A callback
+// that does not fit in the queue is dropped and counted under name.
+func (c *Collector) dispatch(name string, fn func()) {
+	d := c.callbacks
+	if d == nil {
+		fn()
+		return
+	}
+
+	d.pending.Add(1)
+	select {
+	case d.queue <- fn:
+	default:
+		d.done()
+		c.callbacksDroppedTotal.WithLabelValues(name).Inc()
+	}
+}
+
+// runCallbacks runs queued callbacks until the collector is closed, then
+// runs those still queued.
+func (c *Collector) runCallbacks() {
+	defer c.background.Done()
+
+	d := c.callbacks
+	for {
+		select {
+		case fn := <-d.queue:
+			d.run(fn)
+		case <-c.done:
+			for {
+				select {
+				case fn := <-d.queue:
+					d.run(fn)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// run runs a queued callback.
+func (d *callbackDispatcher) run(fn func()) {
+	defer d.done()
+	fn()
+}
+
+// done marks a callback as no longer pending.
+func (d *callbackDispatcher) done() {
+	if d.pending.Add(-1) == 0 {
+		d.mu.Lock()
+		d.idle.Broadcast()
+		d.mu.Unlock()
+	}
+}
+
+// Flush waits until all queued OnOperation, OnError and OnChecksumMismatch
+// callbacks have run.
+// Callbacks queued while Flush waits are waited for as well. It returns
+// immediately when callbacks are synchronous or the collector is closed,
+// as Close runs the queued callbacks itself.
+func (c *Collector) Flush() {
+	d := c.callbacks
+	if d == nil || c.closed.Load() {
+		return
+	}
+
+	d.mu.Lock()
+	for d.pending.Load() > 0 {
+		d.idle.Wait()
+	}
+	d.mu.Unlock()
+}
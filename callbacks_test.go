@@ -0,0 +1,76 @@
+package metricsfs
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAsyncCallbacks(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	var calls atomic.Int64
+
+	config := DefaultConfig()
+	config.CallbackQueueSize = 1
+	config.OnOperation = func(op Operation) {
+		started <- struct{}{}
+		<-release
+		calls.Add(1)
+	}
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	// The worker blocks in the first callback without blocking the operation
+	fs.Stat("/a")
+	<-started
+
+	// One more fits in the queue; the rest are dropped
+	fs.Stat("/b")
+	fs.Stat("/c")
+	fs.Stat("/d")
+
+	if got := testutil.ToFloat64(c.callbacksDroppedTotal.WithLabelValues("operation")); got != 2 {
+		t.Errorf("Expected 2 dropped callbacks, got %v", got)
+	}
+
+	close(release)
+	c.Flush()
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected 2 callbacks to have run after Flush, got %d", got)
+	}
+}
+
+func TestAsyncCallbacksClose(t *testing.T) {
+	var calls atomic.Int64
+	config := DefaultConfig()
+	config.CallbackQueueSize = 100
+	config.CallbackWorkers = 4
+	config.OnError = func(operation string, err error) { calls.Add(1) }
+	fs := NewWithConfig(&errorMockFS{}, config)
+
+	for i := 0; i < 50; i++ {
+		fs.Stat("/missing")
+	}
+
+	// Close runs the callbacks still queued
+	fs.Collector().Close()
+	if got := calls.Load(); got != 50 {
+		t.Errorf("Expected 50 callbacks to have run after Close, got %d", got)
+	}
+	fs.Collector().Flush()
+}
+
+func TestSyncCallbacksByDefault(t *testing.T) {
+	var called bool
+	config := DefaultConfig()
+	config.OnOperation = func(op Operation) { called = true }
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.Stat("/a")
+	if !called {
+		t.Error("Expected OnOperation to run before the operation returns")
+	}
+	fs.Collector().Flush()
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package metricsfs
+
+// diskCapacity reports that filesystem capacity is unavailable on this
+// platform, so capacity metrics are not exported.
+func diskCapacity(path string) (total, free uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package metricsfs
+
+import "golang.org/x/sys/unix"
+
+// diskCapacity returns the size of the host filesystem containing path and
+// the bytes available on it to unprivileged users.
+func diskCapacity(path string) (total, free uint64, ok bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, 0, false
+	}
+	bsize := uint64(st.Bsize)
+	return uint64(st.Blocks) * bsize, uint64(st.Bavail) * bsize, true
+}
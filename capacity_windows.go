@@ -0,0 +1,18 @@
+//go:build windows
+
+package metricsfs
+
+import "golang.org/x/sys/windows"
+
+// diskCapacity returns the size of the volume containing path and the bytes
+// available on it to the calling user.
+func diskCapacity(path string) (total, free uint64, ok bool) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, nil); err != nil {
+		return 0, 0, false
+	}
+	return total, free, true
+}
@@ -0,0 +1,169 @@
+package metricsfs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	rtrace "runtime/trace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// startRegion starts a runtime/trace region for op while the execution
+// tracer is running, with TraceRegions.
+func (c *Collector) startRegion(op Op) *rtrace.Region {
+	if !c.config.TraceRegions || !rtrace.IsEnabled() {
+		return nil
+	}
+	return rtrace.StartRegion(context.Background(), "fs."+string(op))
+}
+
+// ChromeTraceWriter writes operations to a stream in the Chrome trace event
+// format, which chrome://tracing, Perfetto and speedscope load, to see file
+// I/O on a timeline. Install its Record method as Config.OnOperation:
+//
+//	f, _ := os.Create("fs-trace.json")
+//	tracer := metricsfs.NewChromeTraceWriter(f)
+//	defer tracer.Close()
+//	config.OnOperation = tracer.Record
+//
+// Each operation is a complete event named after the operation, with its
+// path, bytes, error, handle ID and instance as arguments. Concurrent
+// operations are laid out on separate rows so that they do not nest.
+// Operations are placed when Record is called, at the end of the operation
+// unless CallbackQueueSize defers it, and last their measured duration,
+// zero for reads and writes not sampled under LatencySampleRate.
+//
+// The stream is a JSON array, buffered and terminated by Close. To see
+// operations on the same timeline as goroutine scheduling in go tool trace,
+// set Config.TraceRegions instead.
+type ChromeTraceWriter struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	pid    int
+	epoch  time.Time
+	lanes  []time.Time // the end of the last event on each row
+	events int
+	err    error
+	closed bool
+}
+
+// chromeTraceEvent is an event of the Chrome trace event format.
+type chromeTraceEvent struct {
+	Name      string            `json:"name"`
+	Category  string            `json:"cat,omitempty"`
+	Phase     string            `json:"ph"`
+	Timestamp json.Number       `json:"ts"`
+	Duration  json.Number       `json:"dur,omitempty"`
+	PID       int               `json:"pid"`
+	TID       int               `json:"tid"`
+	Args      map[string]string `json:"args,omitempty"`
+}
+
+// NewChromeTraceWriter returns a ChromeTraceWriter writing to w. Close does
+// not close w.
+func NewChromeTraceWriter(w io.Writer) *ChromeTraceWriter {
+	t := &ChromeTraceWriter{w: bufio.NewWriter(w), pid: os.Getpid(), epoch: time.Now()}
+	t.w.WriteString("[")
+	t.write(chromeTraceEvent{
+		Name:      "process_name",
+		Phase:     "M",
+		Timestamp: "0",
+		PID:       t.pid,
+		Args:      map[string]string{"name": "metricsfs"},
+	})
+	return t
+}
+
+// Record writes op as a complete event.
+func (t *ChromeTraceWriter) Record(op Operation) {
+	end := time.Now()
+	start := end.Add(-op.Duration)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+
+	lane := len(t.lanes)
+	for i, busyUntil := range t.lanes {
+		if !busyUntil.After(start) {
+			lane = i
+			break
+		}
+	}
+	if lane == len(t.lanes) {
+		t.lanes = append(t.lanes, end)
+	} else {
+		t.lanes[lane] = end
+	}
+
+	args := map[string]string{"instance": op.Instance}
+	if op.Path != "" {
+		args["path"] = op.Path
+	}
+	if op.BytesTransferred > 0 {
+		args["bytes"] = strconv.FormatInt(op.BytesTransferred, 10)
+	}
+	if op.Error != nil {
+		args["error"] = op.Error.Error()
+	}
+	if op.HandleID != "" {
+		args["handle_id"] = op.HandleID
+	}
+	t.write(chromeTraceEvent{
+		Name:      op.Name,
+		Category:  "metricsfs",
+		Phase:     "X",
+		Timestamp: microseconds(start.Sub(t.epoch)),
+		Duration:  microseconds(op.Duration),
+		PID:       t.pid,
+		TID:       lane + 1,
+		Args:      args,
+	})
+}
+
+// write writes event on a line of its own. Write errors are kept for Close.
+func (t *ChromeTraceWriter) write(event chromeTraceEvent) {
+	if t.err != nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		t.err = err
+		return
+	}
+	if t.events > 0 {
+		t.w.WriteString(",")
+	}
+	t.events++
+	t.w.WriteString("\n")
+	_, t.err = t.w.Write(line)
+}
+
+// Close terminates the JSON array and flushes it. It returns the first error
+// writing the trace. Operations recorded after Close are ignored.
+func (t *ChromeTraceWriter) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return t.err
+	}
+	t.closed = true
+
+	if t.err == nil {
+		t.w.WriteString("\n]\n")
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
+
+// microseconds formats d in microseconds, the unit of the trace event
+// format, keeping nanosecond precision.
+func microseconds(d time.Duration) json.Number {
+	return json.Number(strconv.FormatFloat(float64(d)/float64(time.Microsecond), 'f', -1, 64))
+}
@@ -0,0 +1,96 @@
+package metricsfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	rtrace "runtime/trace"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChromeTraceWriter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewChromeTraceWriter(&buf)
+
+	config := DefaultConfig()
+	config.Instance = "uploads"
+	config.OnOperation = tracer.Record
+	fs := NewWithConfig(newMockFS(), config)
+	fs.Stat("/a.txt")
+	fs.Mkdir("/dir", 0755)
+
+	// Overlapping operations go on separate rows
+	tracer.Record(Operation{Name: "read", Duration: time.Hour, Path: "/long", BytesTransferred: 42, Error: errors.New("boom")})
+	tracer.Record(Operation{Name: "write", Duration: time.Minute})
+	tracer.Record(Operation{Name: "sync"})
+
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	tracer.Record(Operation{Name: "stat"})
+
+	var events []struct {
+		Name  string            `json:"name"`
+		Phase string            `json:"ph"`
+		TS    float64           `json:"ts"`
+		Dur   float64           `json:"dur"`
+		TID   int               `json:"tid"`
+		Args  map[string]string `json:"args"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+		t.Fatalf("Invalid trace: %v\n%s", err, buf.String())
+	}
+	if len(events) != 6 {
+		t.Fatalf("Expected a metadata event and 5 operations, got %d:\n%s", len(events), buf.String())
+	}
+	if events[0].Phase != "M" || events[0].Args["name"] != "metricsfs" {
+		t.Errorf("unexpected metadata event %+v", events[0])
+	}
+	if e := events[1]; e.Name != "stat" || e.Phase != "X" || e.Args["path"] != "/a.txt" || e.Args["instance"] != "uploads" {
+		t.Errorf("unexpected stat event %+v", e)
+	}
+	read, write := events[3], events[4]
+	if read.Dur != float64(time.Hour/time.Microsecond) || read.Args["bytes"] != "42" || read.Args["error"] != "boom" {
+		t.Errorf("unexpected read event %+v", read)
+	}
+	if read.TID == write.TID {
+		t.Errorf("Expected overlapping operations on separate rows, both on %d", read.TID)
+	}
+	if sync := events[5]; sync.TID != 1 {
+		t.Errorf("Expected the first free row to be reused, got %d", sync.TID)
+	}
+}
+
+func TestTraceRegions(t *testing.T) {
+	config := DefaultConfig()
+	config.TraceRegions = true
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	if r := c.startRegion(OpStat); r != nil {
+		t.Error("Expected no region while the execution tracer is stopped")
+	}
+
+	var buf bytes.Buffer
+	if err := rtrace.Start(&buf); err != nil {
+		t.Skipf("execution tracer unavailable: %v", err)
+	}
+	fs.Stat("/a.txt")
+	region := c.startRegion(OpRead)
+	untraced := New(newMockFS()).Collector().startRegion(OpRead)
+	rtrace.Stop()
+
+	if region == nil {
+		t.Error("Expected a region while tracing")
+	} else {
+		region.End()
+	}
+	if untraced != nil {
+		t.Error("Expected no region without TraceRegions")
+	}
+	if !strings.Contains(buf.String(), "fs.stat") {
+		t.Error("Expected the stat region in the trace")
+	}
+}
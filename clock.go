@@ -0,0 +1,72 @@
+package metricsfs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time operations are timed with. See Config.Clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock of time.Now.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// coarseClock is a Clock reading the time cached by a ticker, trading
+// precision for a Now without a clock read. The cached time is kept as an
+// offset from a base time so that it retains a monotonic reading.
+type coarseClock struct {
+	base    time.Time
+	elapsed atomic.Int64
+}
+
+func newCoarseClock() *coarseClock {
+	return &coarseClock{base: time.Now()}
+}
+
+func (c *coarseClock) Now() time.Time {
+	return c.base.Add(time.Duration(c.elapsed.Load()))
+}
+
+// tick updates the cached time.
+func (c *coarseClock) tick() {
+	c.elapsed.Store(int64(time.Since(c.base)))
+}
+
+// newClock returns the clock configured by config, starting the ticker of
+// a coarse clock.
+func (c *Collector) newClock(config Config) Clock {
+	switch {
+	case config.Clock != nil:
+		return config.Clock
+	case config.ClockResolution > 0:
+		clock := newCoarseClock()
+		c.background.Add(1)
+		go c.runCoarseClock(clock, config.ClockResolution)
+		return clock
+	default:
+		return systemClock{}
+	}
+}
+
+// runCoarseClock updates clock every resolution until Close is called.
+func (c *Collector) runCoarseClock(clock *coarseClock, resolution time.Duration) {
+	defer c.background.Done()
+
+	ticker := time.NewTicker(resolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			clock.tick()
+		}
+	}
+}
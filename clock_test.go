@@ -0,0 +1,156 @@
+package metricsfs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// stepClock is a Clock advancing by step on each Now.
+type stepClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+// advance moves the clock forward by d.
+func (c *stepClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestClock(t *testing.T) {
+	var ops []Operation
+	config := DefaultConfig()
+	config.Clock = &stepClock{now: time.Unix(1000, 0), step: 25 * time.Millisecond}
+	config.OnOperation = func(op Operation) { ops = append(ops, op) }
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.Stat("/a")
+	fs.Stat("/b")
+
+	if len(ops) != 2 {
+		t.Fatalf("Expected 2 operations, got %d", len(ops))
+	}
+	for _, op := range ops {
+		if op.Duration != 25*time.Millisecond {
+			t.Errorf("Expected a 25ms duration from the clock, got %v", op.Duration)
+		}
+	}
+	if got := histogramCount(t, fs.Collector().statDuration); got != 2 {
+		t.Errorf("Expected 2 stat durations, got %d", got)
+	}
+}
+
+func TestCoarseClock(t *testing.T) {
+	config := DefaultConfig()
+	config.ClockResolution = time.Millisecond
+	c := NewCollector(config)
+	defer c.Close()
+
+	clock, ok := c.clock.(*coarseClock)
+	if !ok {
+		t.Fatalf("Expected a coarse clock, got %T", c.clock)
+	}
+	first := clock.Now()
+	deadline := time.Now().Add(5 * time.Second)
+	for !clock.Now().After(first) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the coarse clock to advance")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Without a tick, start and finish read the same time
+	clock = newCoarseClock()
+	c.clock = clock
+	start := c.startOperation(OpStat)
+	time.Sleep(2 * time.Millisecond)
+	if d := c.finishOperation(OpStat, start); d != 0 {
+		t.Errorf("Expected a zero duration between ticks, got %v", d)
+	}
+}
+
+func TestClockAccessTimes(t *testing.T) {
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	config := DefaultConfig()
+	config.Clock = clock
+	config.EnablePathMetrics = true
+	config.TrackedStateTTL = time.Hour
+	config.EnableWorkingSetMetrics = true
+	config.WorkingSetWindow = 6 * time.Minute
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	fs.Stat("/a")
+	if got := c.WorkingSetSize(); got != 1 {
+		t.Errorf("Expected 1 path in the working set, got %d", got)
+	}
+	if n := c.cleanupIdleState(clock.Now()); n != 0 {
+		t.Errorf("Expected no idle paths, got %d", n)
+	}
+
+	// Access times come from the clock, not the system time
+	clock.advance(2 * time.Hour)
+	if got := c.WorkingSetSize(); got != 0 {
+		t.Errorf("Expected an empty working set, got %d", got)
+	}
+	if n := c.cleanupIdleState(clock.Now()); n != 1 {
+		t.Errorf("Expected the path to be idle, got %d evicted", n)
+	}
+}
+
+func TestOTelClock(t *testing.T) {
+	c, err := NewOTelCollector(OTelConfig{
+		MeterProvider: noop.NewMeterProvider(),
+		Clock:         &stepClock{now: time.Unix(1000, 0), step: 25 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewOTelCollector failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if d := c.finishOperation(ctx, OpStat, c.startOperation(ctx, OpStat)); d != 25*time.Millisecond {
+		t.Errorf("Expected a 25ms duration from the clock, got %v", d)
+	}
+}
+
+func TestClockTimestamps(t *testing.T) {
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	config := DefaultConfig()
+	config.Clock = clock
+	config.SlowOperationThreshold = time.Nanosecond
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	scope := fs.BeginScope("job")
+	clock.advance(3 * time.Second)
+	scope.FS().Stat("/a")
+	summary := scope.End()
+	if !summary.Start.Equal(time.Unix(1000, 0)) || summary.Duration != 3*time.Second {
+		t.Errorf("Expected a 3s scope from the clock, got %v from %v", summary.Duration, summary.Start)
+	}
+
+	if got := c.Stats().Timestamp; !got.Equal(time.Unix(1003, 0)) {
+		t.Errorf("Expected the stats timestamp from the clock, got %v", got)
+	}
+
+	// Stepping the clock gives the next stat a duration, making it slow
+	clock.step = time.Millisecond
+	fs.Stat("/b")
+	slow := c.SlowOperations()
+	if len(slow) != 1 || slow[0].Time.Before(time.Unix(1003, 0)) || slow[0].Time.After(time.Unix(1004, 0)) {
+		t.Errorf("Expected a slow operation timed by the clock, got %+v", slow)
+	}
+}
@@ -0,0 +1,138 @@
+package metricsfs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrFilesLeaked is returned by MetricsFS.Close when files opened through
+// the filesystem are still open. See Config.EnableLeakDetection.
+var ErrFilesLeaked = errors.New("metricsfs: files left open")
+
+// openFiles tracks the files a MetricsFS and its copies have open, with
+// EnableLeakDetection, and whether the MetricsFS was closed.
+type openFiles struct {
+	mu     sync.Mutex
+	files  map[*MetricsFile]struct{}
+	closed bool
+}
+
+// add tracks f until it is closed.
+func (o *openFiles) add(f *MetricsFile) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.files == nil {
+		o.files = make(map[*MetricsFile]struct{})
+	}
+	o.files[f] = struct{}{}
+	f.openFiles = o
+	f.openedAt = f.collector.clock.Now()
+}
+
+// remove stops tracking f.
+func (o *openFiles) remove(f *MetricsFile) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.files, f)
+}
+
+// list returns the files still open, sorted by path.
+func (o *openFiles) list() []*MetricsFile {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return sortedFiles(o.files)
+}
+
+// close marks the set as closed and returns the files still open, or false
+// if it was closed already.
+func (o *openFiles) close() ([]*MetricsFile, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil, false
+	}
+	o.closed = true
+	return sortedFiles(o.files), true
+}
+
+// sortedFiles returns the files of set sorted by path.
+func sortedFiles(set map[*MetricsFile]struct{}) []*MetricsFile {
+	files := make([]*MetricsFile, 0, len(set))
+	for f := range set {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files
+}
+
+// track tracks f among m's open files, with EnableLeakDetection.
+func (m *MetricsFS) track(f *MetricsFile) {
+	if m.collector.config.EnableLeakDetection {
+		m.open.add(f)
+	}
+}
+
+// Close shuts the filesystem down for batch jobs and other programs that
+// exit once done. With EnableLeakDetection, the files opened through m or
+// its copies that are still open are counted in files_leaked_total, closed
+// with CloseLeakedFiles, and reported by an error wrapping ErrFilesLeaked.
+// Close then waits for the queued callbacks, calls Config.OnClose with a
+// final snapshot of the stats and, unless the collector was given to
+// NewWithCollector and may be shared, closes the collector, letting
+// reporters emit their final report. The underlying filesystem is not
+// closed. Close is idempotent; only the first call reports leaks.
+func (m *MetricsFS) Close() error {
+	leaked, ok := m.open.close()
+	if !ok {
+		return nil
+	}
+
+	var err error
+	if len(leaked) > 0 {
+		m.collector.recordFilesLeaked(len(leaked))
+		paths := make([]string, len(leaked))
+		for i, f := range leaked {
+			paths[i] = f.path
+			if m.collector.config.CloseLeakedFiles {
+				f.Close()
+			}
+		}
+		err = fmt.Errorf("%w: %s", ErrFilesLeaked, strings.Join(paths, ", "))
+	}
+
+	m.collector.Flush()
+	if m.collector.config.OnClose != nil && !m.collector.closed.Load() {
+		m.collector.config.OnClose(m.collector.Stats())
+	}
+	if m.ownsCollector {
+		m.collector.Close()
+	}
+	return err
+}
+
+// initLeakMetrics creates the leaked files counter.
+func (c *Collector) initLeakMetrics(config Config) {
+	c.filesLeakedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "files_leaked_total",
+			Help:        "Files still open when the filesystem they were opened through was closed",
+			ConstLabels: config.constLabelsFor("files_leaked_total"),
+		},
+		nil,
+	)
+}
+
+// recordFilesLeaked counts n files still open on Close.
+func (c *Collector) recordFilesLeaked(n int) {
+	if c.closed.Load() {
+		return
+	}
+	c.filesLeakedTotal.WithLabelValues().Add(float64(n))
+}
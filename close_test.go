@@ -0,0 +1,131 @@
+package metricsfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCloseWithoutLeaks(t *testing.T) {
+	var final *Stats
+	config := DefaultConfig()
+	config.EnableLeakDetection = true
+	config.OnClose = func(s Stats) { final = &s }
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, err := fs.Open("/a.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Close()
+
+	if err := fs.Close(); err != nil {
+		t.Errorf("Close() = %v, want nil", err)
+	}
+	if final == nil || final.Operations["open"].Count != 1 {
+		t.Errorf("Expected a final snapshot with 1 open, got %+v", final)
+	}
+	if !fs.Collector().Closed() {
+		t.Error("Expected the collector to be closed")
+	}
+
+	// Close is idempotent
+	final = nil
+	if err := fs.Close(); err != nil || final != nil {
+		t.Errorf("Second Close() = %v, called OnClose: %v", err, final != nil)
+	}
+}
+
+func TestCloseReportsLeaks(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableLeakDetection = true
+	collector := NewCollector(config)
+	fs := NewWithCollector(newMockFS(), collector, "")
+
+	if _, err := fs.Open("/b.txt"); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := fs.WithContext(context.Background()).Create("/a.txt"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	closed, err := fs.Open("/c.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	closed.Close()
+
+	err = fs.Close()
+	if !errors.Is(err, ErrFilesLeaked) {
+		t.Fatalf("Close() = %v, want ErrFilesLeaked", err)
+	}
+	if !strings.HasSuffix(err.Error(), ": /a.txt, /b.txt") {
+		t.Errorf("Close() = %q, want the leaked paths", err)
+	}
+	if v := testutil.ToFloat64(collector.filesLeakedTotal); v != 2 {
+		t.Errorf("Expected 2 leaked files, got %v", v)
+	}
+	if v := testutil.ToFloat64(collector.operationsTotal.WithLabelValues("close", "success")); v != 1 {
+		t.Errorf("Expected the leaked files to be left open, got %v closes", v)
+	}
+}
+
+func TestCloseLeakedFiles(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableLeakDetection = true
+	config.CloseLeakedFiles = true
+	collector := NewCollector(config)
+	fs := NewWithCollector(newMockFS(), collector, "")
+
+	if _, err := fs.Open("/a.txt"); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := fs.Close(); !errors.Is(err, ErrFilesLeaked) {
+		t.Fatalf("Close() = %v, want ErrFilesLeaked", err)
+	}
+	if v := testutil.ToFloat64(collector.operationsTotal.WithLabelValues("close", "success")); v != 1 {
+		t.Errorf("Expected the leaked file to be closed, got %v closes", v)
+	}
+}
+
+func TestCloseSharedCollector(t *testing.T) {
+	collector := NewCollector(DefaultConfig())
+	fs := NewWithCollector(newMockFS(), collector, "a")
+
+	if _, err := fs.Open("/a.txt"); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	// Leaks are not tracked without EnableLeakDetection
+	if err := fs.Close(); err != nil {
+		t.Errorf("Close() = %v, want nil", err)
+	}
+	if collector.Closed() {
+		t.Error("Expected a collector given to NewWithCollector to stay open")
+	}
+}
+
+func TestSharedCollectorBackgroundOnce(t *testing.T) {
+	config := DefaultConfig()
+	config.Health = HealthConfig{ProbePath: "/", CheckInterval: time.Hour}
+	config.Trash = TrashConfig{Dir: "/.trash", Retention: time.Hour}
+	collector := NewCollector(config)
+	NewWithCollector(newMockFS(), collector, "a")
+	NewWithCollector(newMockFS(), collector, "b")
+
+	// The first wrapper started the health checks and the purger
+	for _, name := range []string{"health", "trash"} {
+		if collector.addBackgroundOnce(name) {
+			t.Errorf("Expected %s to be started once per collector", name)
+			collector.background.Done()
+		}
+	}
+
+	collector.Close()
+	if collector.addBackground() {
+		t.Error("Expected no background goroutine to start after Close")
+		collector.background.Done()
+	}
+}
@@ -1,37 +1,104 @@
 package metricsfs
 
 import (
+	"context"
 	"errors"
-	"os"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"path/filepath"
+	"runtime"
+	rtrace "runtime/trace"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Collector collects and exposes filesystem metrics.
 type Collector struct {
 	config Config
 
+	// instance identifies the wrapped filesystem, see Config.Instance
+	instance string
+
+	// live holds the settings UpdateConfig changes at runtime; liveMu
+	// serializes updates
+	live   atomic.Pointer[liveConfig]
+	liveMu sync.Mutex
+
+	// clock times operations, see Config.Clock
+	clock Clock
+
+	// dynamicLabels are the per-operation label names (instance, root and
+	// context labels) appended to operation-level metrics
+	dynamicLabels []string
+
 	// Operation counters
 	operationsTotal    *prometheus.CounterVec
 	fileOpensTotal     *prometheus.CounterVec
-	fileCreatesTotal   prometheus.Counter
+	fileCreatesTotal   *prometheus.CounterVec
 	dirOperationsTotal *prometheus.CounterVec
+	pathKindTotal      *prometheus.CounterVec
+
+	// Directory listing sizes
+	readdirEntries      *prometheus.HistogramVec
+	readdirEntriesTotal *prometheus.CounterVec
+	readdirNameBytes    *prometheus.HistogramVec
+
+	// Overwrite tracking (if enabled)
+	fileOverwritesTotal   *prometheus.CounterVec
+	overwrittenBytesTotal *prometheus.CounterVec
+
+	// In-flight operations
+	operationsInFlight *prometheus.GaugeVec
+
+	// Series of the built-in operations, resolved once instead of by label
+	// lookup on each operation. Operations with dynamic labels look their
+	// series up in the vectors
+	inFlightSeries  *opSeries[prometheus.Gauge]
+	successSeries   *opSeries[prometheus.Counter]
+	errorSeries     *opSeries[prometheus.Counter]
+	durationSeries  *opSeries[prometheus.Observer] // operation_duration_seconds
+	opLatencySeries *opSeries[prometheus.Observer] // read_duration_seconds etc.
+	bytesSeries     *opSeries[prometheus.Counter]  // bytes_read_total and bytes_written_total
+	sizeSeries      *opSeries[prometheus.Observer] // read_size_bytes and write_size_bytes
+
+	// Sharded counters of the built-in operations (if enabled)
+	sharded *shardedCounters
+
+	// Aggregate counters used for health checks
+	inFlight        atomic.Int64
+	lastFinish      atomic.Int64
+	totalOperations atomic.Int64
+	totalErrors     atomic.Int64
 
 	// Latency histograms
 	operationDuration *prometheus.HistogramVec
-	readDuration      prometheus.Histogram
-	writeDuration     prometheus.Histogram
-	statDuration      prometheus.Histogram
-	openDuration      prometheus.Histogram
+	readDuration      *prometheus.HistogramVec
+	writeDuration     *prometheus.HistogramVec
+	statDuration      *prometheus.HistogramVec
+	openDuration      *prometheus.HistogramVec
 
 	// Bandwidth counters
-	bytesReadTotal    prometheus.Counter
-	bytesWrittenTotal prometheus.Counter
+	bytesReadTotal    *prometheus.CounterVec
+	bytesWrittenTotal *prometheus.CounterVec
 	readSizeBytes     *prometheus.HistogramVec
 	writeSizeBytes    *prometheus.HistogramVec
+	shortReadsTotal   *prometheus.CounterVec
+	shortWritesTotal  *prometheus.CounterVec
+	eofTotal          *prometheus.CounterVec
+
+	// Observations beyond the largest latency or size bucket
+	histogramOverflowTotal *prometheus.CounterVec
+
+	// Durations clamped because of clock anomalies
+	durationAnomaliesTotal *prometheus.CounterVec
+	maxLatencyBucket       float64
+	maxSizeBucket          float64
 
 	// Note: Throughput (bytes/second) is calculated via Prometheus queries:
 	// - Read throughput: rate(fs_bytes_read_total[5m])
@@ -49,11 +116,154 @@ type Collector struct {
 	openFilesMax      atomic.Int64
 	openFilesGauge    prometheus.Gauge
 	openFilesMaxGauge prometheus.Gauge
+	openFilesLimit    *prometheus.Desc
+
+	// Host filesystem capacity (if CapacityPath is set)
+	capacityBytes *prometheus.Desc
+	freeBytes     *prometheus.Desc
+
+	// Walk metrics
+	walksTotal           *prometheus.CounterVec
+	walkEntriesTotal     *prometheus.CounterVec
+	walkErrorsTotal      *prometheus.CounterVec
+	walkParallelism      *prometheus.HistogramVec
+	walkEntriesPerSecond *prometheus.HistogramVec
+	walkFilesTotal       *prometheus.CounterVec
+	walkDirsTotal        *prometheus.CounterVec
+	walkBytesTotal       *prometheus.CounterVec
+	walkDuration         *prometheus.HistogramVec
+
+	// Lock metrics
+	lockWait             *prometheus.HistogramVec
+	lockHeld             *prometheus.HistogramVec
+	lockContentionsTotal *prometheus.CounterVec
+
+	// Glob metrics
+	globMatches *prometheus.HistogramVec
+
+	// Files left open on MetricsFS.Close
+	filesLeakedTotal *prometheus.CounterVec
+
+	// Scope metrics
+	scopesTotal     *prometheus.CounterVec
+	scopeDuration   *prometheus.HistogramVec
+	scopeFiles      *prometheus.HistogramVec
+	scopeBytesTotal *prometheus.CounterVec
+	trackedScopes   *boundedLabels
 
 	// Path metrics (if enabled)
 	pathAccessTotal *prometheus.CounterVec
+	pathDuration    *prometheus.HistogramVec
+	pathBytesTotal  *prometheus.CounterVec
 	pathMutex       sync.RWMutex
-	trackedPaths    map[string]bool
+	trackedPaths    map[string]*atomic.Int64 // path -> last access (unix nanoseconds)
+
+	// Extension metrics (if enabled)
+	extensionOperationsTotal *prometheus.CounterVec
+	extensionBytesTotal      *prometheus.CounterVec
+	extensionDuration        *prometheus.HistogramVec
+	trackedExtensions        *boundedLabels
+
+	// CPU time metrics (if enabled)
+	operationCPUSeconds  *prometheus.CounterVec
+	operationWallSeconds *prometheus.CounterVec
+
+	// Access pattern metrics (if enabled)
+	accessPatternTotal *prometheus.CounterVec
+	seekDistanceBytes  *prometheus.HistogramVec
+
+	// Hot path metrics (if enabled)
+	hotPathAccesses *prometheus.Desc
+	hotPaths        *hotPaths
+
+	// Layer metrics (if enabled)
+	layerReadsTotal     *prometheus.CounterVec
+	layerReadBytesTotal *prometheus.CounterVec
+	layerReadDuration   *prometheus.HistogramVec
+	trackedLayers       *boundedLabels
+
+	// Working set metrics (if enabled)
+	workingSetPaths *prometheus.Desc
+	workingSet      *workingSet
+
+	// Rename metrics (if enabled)
+	renamesTotal      *prometheus.CounterVec
+	trackedPathGroups *boundedLabels
+
+	// Mode transitions
+	modeTransitionsTotal *prometheus.CounterVec
+	transitions          transitionLog
+	slowOps              slowLog
+	pathLimitEngaged     atomic.Bool
+
+	// Degraded state, from the most recent health check
+	degraded           *prometheus.GaugeVec
+	degradedMu         sync.Mutex
+	degradedConditions string
+
+	// Asynchronous callbacks (if enabled)
+	callbacks             *callbackDispatcher
+	callbacksDroppedTotal *prometheus.CounterVec
+
+	// Operations to measure, nil for all
+	opFilter *operationFilter
+
+	// Allocations per operation (if enabled)
+	allocations          *allocationCalibration
+	operationAllocations *prometheus.GaugeVec
+
+	// Audit log (if enabled)
+	audit            *auditLog
+	auditErrorsTotal *prometheus.CounterVec
+
+	// Checksum verification (if enabled)
+	verify                     *verifier
+	checksumVerificationsTotal *prometheus.CounterVec
+
+	// SLO thresholds (if configured)
+	sloOperationsTotal   *prometheus.CounterVec
+	apdexOperationsTotal *prometheus.CounterVec
+
+	// Latency extremes (if enabled)
+	extremes    *sync.Map // Op to *latencyExtremes
+	latencyMin  *prometheus.GaugeVec
+	latencyMax  *prometheus.GaugeVec
+	latencyLast *prometheus.GaugeVec
+
+	// File size limits (if configured)
+	sizeLimitViolationsTotal *prometheus.CounterVec
+
+	// Configuration warnings
+	warningsMu        sync.Mutex
+	warnings          []ConfigWarning
+	configWarningInfo *prometheus.Desc
+
+	// Trash (if enabled)
+	trashedTotal          *prometheus.CounterVec
+	trashedBytesTotal     *prometheus.CounterVec
+	trashRestoresTotal    *prometheus.CounterVec
+	trashPurgedTotal      *prometheus.CounterVec
+	trashPurgedBytesTotal *prometheus.CounterVec
+
+	// Active ProfileFor window, if any
+	profile atomic.Pointer[profileWindow]
+
+	// Tracked state gauges
+	trackedPathsGauge      prometheus.Gauge
+	trackedStateBytesGauge prometheus.Gauge
+
+	// Lifecycle
+	done       chan struct{}
+	background sync.WaitGroup
+	closed     atomic.Bool
+
+	// backgroundMu orders the start of background goroutines with Close;
+	// backgroundOnce names those run once per collector
+	backgroundMu   sync.Mutex
+	backgroundOnce map[string]bool
+
+	registryMu  sync.Mutex
+	registerers []prometheus.Registerer
 }
 
 // NewCollector creates a new metrics collector with the given configuration.
@@ -61,10 +271,21 @@ func NewCollector(config Config) *Collector {
 	config.applyDefaults()
 
 	c := &Collector{
-		config:       config,
-		trackedPaths: make(map[string]bool),
+		config:            config,
+		instance:          config.Instance,
+		dynamicLabels:     dynamicLabelNames(config),
+		trackedPaths:      make(map[string]*atomic.Int64),
+		done:              make(chan struct{}),
+		trackedExtensions: newBoundedLabels(config.MaxTrackedExtensions),
+		trackedScopes:     newBoundedLabels(config.MaxTrackedScopes),
+		trackedLayers:     newBoundedLabels(maxTrackedLayers),
+		trackedPathGroups: newBoundedLabels(config.MaxPathGroups),
+		maxLatencyBucket:  largestBucket(config.LatencyBuckets),
+		maxSizeBucket:     largestBucket(config.SizeBuckets),
 	}
 
+	c.live.Store(newLiveConfig(config))
+
 	// Initialize operation counters
 	c.operationsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -72,9 +293,9 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "operations_total",
 			Help:        "Total filesystem operations by type and status",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("operations_total"),
 		},
-		[]string{"operation", "status"},
+		append([]string{"operation", "status"}, c.dynamicLabels...),
 	)
 
 	c.fileOpensTotal = prometheus.NewCounterVec(
@@ -83,19 +304,20 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "file_opens_total",
 			Help:        "File opens by mode",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("file_opens_total"),
 		},
 		[]string{"mode"},
 	)
 
-	c.fileCreatesTotal = prometheus.NewCounter(
+	c.fileCreatesTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace:   config.Namespace,
 			Subsystem:   config.Subsystem,
 			Name:        "file_creates_total",
 			Help:        "File creation count",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("file_creates_total"),
 		},
+		nil,
 	)
 
 	c.dirOperationsTotal = prometheus.NewCounterVec(
@@ -104,116 +326,267 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "dir_operations_total",
 			Help:        "Directory operations",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("dir_operations_total"),
 		},
 		[]string{"operation"},
 	)
 
-	// Initialize latency histograms
-	if config.EnableLatencyMetrics {
-		c.operationDuration = prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Namespace:   config.Namespace,
-				Subsystem:   config.Subsystem,
-				Name:        "operation_duration_seconds",
-				Help:        "Operation duration distribution",
-				Buckets:     config.LatencyBuckets,
-				ConstLabels: config.ConstLabels,
-			},
-			[]string{"operation"},
-		)
+	c.pathKindTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "path_kind_total",
+			Help:        "Operations by whether their path was absolute or relative",
+			ConstLabels: config.constLabelsFor("path_kind_total"),
+		},
+		[]string{"operation", "kind"},
+	)
 
-		c.readDuration = prometheus.NewHistogram(
-			prometheus.HistogramOpts{
-				Namespace:   config.Namespace,
-				Subsystem:   config.Subsystem,
-				Name:        "read_duration_seconds",
-				Help:        "Read operation latency",
-				Buckets:     config.LatencyBuckets,
-				ConstLabels: config.ConstLabels,
-			},
-		)
+	c.readdirEntries = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "readdir_entries",
+			Help:        "Distribution of the number of entries returned by directory reads",
+			Buckets:     prometheus.ExponentialBuckets(1, 4, 10),
+			ConstLabels: config.constLabelsFor("readdir_entries"),
+		},
+		nil,
+	)
 
-		c.writeDuration = prometheus.NewHistogram(
-			prometheus.HistogramOpts{
-				Namespace:   config.Namespace,
-				Subsystem:   config.Subsystem,
-				Name:        "write_duration_seconds",
-				Help:        "Write operation latency",
-				Buckets:     config.LatencyBuckets,
-				ConstLabels: config.ConstLabels,
-			},
-		)
+	c.readdirEntriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "readdir_entries_total",
+			Help:        "Total directory entries listed",
+			ConstLabels: config.constLabelsFor("readdir_entries_total"),
+		},
+		nil,
+	)
 
-		c.statDuration = prometheus.NewHistogram(
-			prometheus.HistogramOpts{
-				Namespace:   config.Namespace,
-				Subsystem:   config.Subsystem,
-				Name:        "stat_duration_seconds",
-				Help:        "Stat operation latency",
-				Buckets:     config.LatencyBuckets,
-				ConstLabels: config.ConstLabels,
-			},
-		)
+	c.readdirNameBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "readdir_name_bytes",
+			Help:        "Total length of the names returned by each directory read, approximating its memory cost",
+			Buckets:     prometheus.ExponentialBuckets(256, 4, 10),
+			ConstLabels: config.constLabelsFor("readdir_name_bytes"),
+		},
+		nil,
+	)
 
-		c.openDuration = prometheus.NewHistogram(
-			prometheus.HistogramOpts{
-				Namespace:   config.Namespace,
-				Subsystem:   config.Subsystem,
-				Name:        "open_duration_seconds",
-				Help:        "Open operation latency",
-				Buckets:     config.LatencyBuckets,
-				ConstLabels: config.ConstLabels,
-			},
-		)
-	}
+	c.histogramOverflowTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "histogram_overflow_total",
+			Help:        "Observations exceeding the largest configured bucket, by histogram",
+			ConstLabels: config.constLabelsFor("histogram_overflow_total"),
+		},
+		[]string{"metric"},
+	)
+
+	c.modeTransitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "mode_transitions_total",
+			Help:        "Changes in the operating mode of the instrumentation",
+			ConstLabels: config.constLabelsFor("mode_transitions_total"),
+		},
+		[]string{"mode", "state"},
+	)
+
+	c.durationAnomaliesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "duration_anomalies_total",
+			Help:        "Operation durations clamped because they were negative or implausibly large",
+			ConstLabels: config.constLabelsFor("duration_anomalies_total"),
+		},
+		[]string{"operation", "kind"},
+	)
+
+	c.operationsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "operations_in_flight",
+			Help:        "Filesystem operations currently in progress",
+			ConstLabels: config.constLabelsFor("operations_in_flight"),
+		},
+		[]string{"operation"},
+	)
 
-	// Initialize bandwidth counters
-	if config.EnableBandwidthMetrics {
-		c.bytesReadTotal = prometheus.NewCounter(
+	// Initialize overwrite counters (if enabled)
+	if config.EnableOverwriteDetection {
+		c.fileOverwritesTotal = prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace:   config.Namespace,
 				Subsystem:   config.Subsystem,
-				Name:        "bytes_read_total",
-				Help:        "Total bytes read",
-				ConstLabels: config.ConstLabels,
+				Name:        "file_overwrites_total",
+				Help:        "Create calls that truncated an existing file",
+				ConstLabels: config.constLabelsFor("file_overwrites_total"),
 			},
+			nil,
 		)
 
-		c.bytesWrittenTotal = prometheus.NewCounter(
+		c.overwrittenBytesTotal = prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace:   config.Namespace,
 				Subsystem:   config.Subsystem,
-				Name:        "bytes_written_total",
-				Help:        "Total bytes written",
-				ConstLabels: config.ConstLabels,
+				Name:        "overwritten_bytes_total",
+				Help:        "Bytes of existing file data destroyed by Create",
+				ConstLabels: config.constLabelsFor("overwritten_bytes_total"),
 			},
+			nil,
 		)
+	}
 
-		c.readSizeBytes = prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Namespace:   config.Namespace,
-				Subsystem:   config.Subsystem,
-				Name:        "read_size_bytes",
-				Help:        "Distribution of read sizes",
-				Buckets:     config.SizeBuckets,
-				ConstLabels: config.ConstLabels,
-			},
-			[]string{"operation"},
-		)
+	// Initialize latency histograms. They are created even when disabled, so
+	// that UpdateConfig can enable them; unused vectors export nothing
+	c.operationDuration = prometheus.NewHistogramVec(
+		config.nativeHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "operation_duration_seconds",
+			Help:        "Operation duration distribution",
+			Buckets:     config.LatencyBuckets,
+			ConstLabels: config.constLabelsFor("operation_duration_seconds"),
+		}),
+		append([]string{"operation"}, c.dynamicLabels...),
+	)
 
-		c.writeSizeBytes = prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Namespace:   config.Namespace,
-				Subsystem:   config.Subsystem,
-				Name:        "write_size_bytes",
-				Help:        "Distribution of write sizes",
-				Buckets:     config.SizeBuckets,
-				ConstLabels: config.ConstLabels,
-			},
-			[]string{"operation"},
-		)
-	}
+	c.readDuration = prometheus.NewHistogramVec(
+		config.nativeHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "read_duration_seconds",
+			Help:        "Read operation latency",
+			Buckets:     config.LatencyBuckets,
+			ConstLabels: config.constLabelsFor("read_duration_seconds"),
+		}),
+		nil,
+	)
+
+	c.writeDuration = prometheus.NewHistogramVec(
+		config.nativeHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "write_duration_seconds",
+			Help:        "Write operation latency",
+			Buckets:     config.LatencyBuckets,
+			ConstLabels: config.constLabelsFor("write_duration_seconds"),
+		}),
+		nil,
+	)
+
+	c.statDuration = prometheus.NewHistogramVec(
+		config.nativeHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "stat_duration_seconds",
+			Help:        "Stat operation latency",
+			Buckets:     config.LatencyBuckets,
+			ConstLabels: config.constLabelsFor("stat_duration_seconds"),
+		}),
+		nil,
+	)
+
+	c.openDuration = prometheus.NewHistogramVec(
+		config.nativeHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "open_duration_seconds",
+			Help:        "Open operation latency",
+			Buckets:     config.LatencyBuckets,
+			ConstLabels: config.constLabelsFor("open_duration_seconds"),
+		}),
+		nil,
+	)
+
+	// Initialize bandwidth counters, also unconditionally
+	c.bytesReadTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "bytes_read_total",
+			Help:        "Total bytes read",
+			ConstLabels: config.constLabelsFor("bytes_read_total"),
+		},
+		c.dynamicLabels,
+	)
+
+	c.bytesWrittenTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "bytes_written_total",
+			Help:        "Total bytes written",
+			ConstLabels: config.constLabelsFor("bytes_written_total"),
+		},
+		c.dynamicLabels,
+	)
+
+	c.readSizeBytes = prometheus.NewHistogramVec(
+		config.nativeHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "read_size_bytes",
+			Help:        "Distribution of read sizes",
+			Buckets:     config.SizeBuckets,
+			ConstLabels: config.constLabelsFor("read_size_bytes"),
+		}),
+		[]string{"operation"},
+	)
+
+	c.writeSizeBytes = prometheus.NewHistogramVec(
+		config.nativeHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "write_size_bytes",
+			Help:        "Distribution of write sizes",
+			Buckets:     config.SizeBuckets,
+			ConstLabels: config.constLabelsFor("write_size_bytes"),
+		}),
+		[]string{"operation"},
+	)
+
+	c.shortReadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "short_reads_total",
+			Help:        "Reads that returned fewer bytes than requested without an error",
+			ConstLabels: config.constLabelsFor("short_reads_total"),
+		},
+		[]string{"operation"},
+	)
+
+	c.shortWritesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "short_writes_total",
+			Help:        "Writes that accepted fewer bytes than given without an error",
+			ConstLabels: config.constLabelsFor("short_writes_total"),
+		},
+		[]string{"operation"},
+	)
+
+	c.eofTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "eof_total",
+			Help:        "Reads that reached the end of a file or directory",
+			ConstLabels: config.constLabelsFor("eof_total"),
+		},
+		[]string{"operation"},
+	)
 
 	// Initialize error counters
 	c.errorsTotal = prometheus.NewCounterVec(
@@ -222,9 +595,9 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "errors_total",
 			Help:        "Errors by operation and type",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("errors_total"),
 		},
-		[]string{"operation", "error_type"},
+		append([]string{"operation", "error_type"}, c.dynamicLabels...),
 	)
 
 	c.permissionErrorsTotal = prometheus.NewCounterVec(
@@ -233,7 +606,7 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "permission_errors_total",
 			Help:        "Permission denied errors",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("permission_errors_total"),
 		},
 		[]string{"operation"},
 	)
@@ -244,7 +617,7 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "not_found_errors_total",
 			Help:        "File/directory not found errors",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("not_found_errors_total"),
 		},
 		[]string{"operation"},
 	)
@@ -255,7 +628,7 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "timeout_errors_total",
 			Help:        "Timeout errors",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("timeout_errors_total"),
 		},
 		[]string{"operation"},
 	)
@@ -267,7 +640,7 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "open_files",
 			Help:        "Currently open files",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("open_files"),
 		},
 	)
 
@@ -277,204 +650,1953 @@ func NewCollector(config Config) *Collector {
 			Subsystem:   config.Subsystem,
 			Name:        "open_files_max",
 			Help:        "Maximum concurrent open files observed",
-			ConstLabels: config.ConstLabels,
+			ConstLabels: config.constLabelsFor("open_files_max"),
 		},
 	)
 
-	// Initialize path metrics (if enabled)
-	if config.EnablePathMetrics {
-		c.pathAccessTotal = prometheus.NewCounterVec(
+	// Read from the OS on each scrape; not exported where unsupported
+	c.openFilesLimit = prometheus.NewDesc(
+		prometheus.BuildFQName(config.Namespace, config.Subsystem, "open_files_limit"),
+		"Soft limit on open file descriptors of the process (RLIMIT_NOFILE)",
+		nil,
+		config.constLabelsFor("open_files_limit"),
+	)
+
+	if config.CapacityPath != "" {
+		c.capacityBytes = prometheus.NewDesc(
+			prometheus.BuildFQName(config.Namespace, config.Subsystem, "capacity_bytes"),
+			"Size of the host filesystem containing CapacityPath",
+			nil,
+			config.constLabelsFor("capacity_bytes"),
+		)
+		c.freeBytes = prometheus.NewDesc(
+			prometheus.BuildFQName(config.Namespace, config.Subsystem, "free_bytes"),
+			"Bytes available to unprivileged users on the host filesystem containing CapacityPath",
+			nil,
+			config.constLabelsFor("free_bytes"),
+		)
+	}
+
+	// Initialize rename metrics (if enabled)
+	if config.EnableRenameMetrics {
+		c.renamesTotal = prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace:   config.Namespace,
 				Subsystem:   config.Subsystem,
-				Name:        "path_access_total",
-				Help:        "Access counts for specific paths",
-				ConstLabels: config.ConstLabels,
+				Name:        "renames_total",
+				Help:        "Renames by source and destination path group",
+				ConstLabels: config.constLabelsFor("renames_total"),
 			},
-			[]string{"path", "operation"},
+			[]string{"from_group", "to_group", "status"},
 		)
 	}
 
-	return c
-}
+	// Initialize tracked state gauges
+	c.trackedPathsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "tracked_paths",
+			Help:        "Paths currently tracked for path-level metrics",
+			ConstLabels: config.constLabelsFor("tracked_paths"),
+		},
+	)
 
-// Describe implements prometheus.Collector.
-func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
-	c.operationsTotal.Describe(ch)
-	c.fileOpensTotal.Describe(ch)
-	c.fileCreatesTotal.Describe(ch)
-	c.dirOperationsTotal.Describe(ch)
+	c.trackedStateBytesGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "tracked_state_bytes",
+			Help:        "Estimated memory used by per-path and per-extension tracking state",
+			ConstLabels: config.constLabelsFor("tracked_state_bytes"),
+		},
+	)
 
-	if c.config.EnableLatencyMetrics {
-		c.operationDuration.Describe(ch)
-		c.readDuration.Describe(ch)
-		c.writeDuration.Describe(ch)
-		c.statDuration.Describe(ch)
-		c.openDuration.Describe(ch)
-	}
+	// Initialize walk metrics
+	c.walksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walks_total",
+			Help:        "Directory tree walks by walker type",
+			ConstLabels: config.constLabelsFor("walks_total"),
+		},
+		[]string{"walker"},
+	)
 
-	if c.config.EnableBandwidthMetrics {
-		c.bytesReadTotal.Describe(ch)
-		c.bytesWrittenTotal.Describe(ch)
-		c.readSizeBytes.Describe(ch)
-		c.writeSizeBytes.Describe(ch)
-	}
+	c.walkEntriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walk_entries_total",
+			Help:        "Entries visited by directory tree walks",
+			ConstLabels: config.constLabelsFor("walk_entries_total"),
+		},
+		[]string{"walker"},
+	)
 
-	c.errorsTotal.Describe(ch)
-	c.permissionErrorsTotal.Describe(ch)
-	c.notFoundErrorsTotal.Describe(ch)
-	c.timeoutErrorsTotal.Describe(ch)
+	c.walkErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walk_errors_total",
+			Help:        "Errors reported to directory tree walk callbacks",
+			ConstLabels: config.constLabelsFor("walk_errors_total"),
+		},
+		[]string{"walker"},
+	)
 
-	c.openFilesGauge.Describe(ch)
-	c.openFilesMaxGauge.Describe(ch)
+	c.walkParallelism = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walk_parallelism",
+			Help:        "Maximum concurrent walk callbacks observed per walk",
+			Buckets:     prometheus.ExponentialBuckets(1, 2, 8),
+			ConstLabels: config.constLabelsFor("walk_parallelism"),
+		},
+		[]string{"walker"},
+	)
 
-	if c.config.EnablePathMetrics {
-		c.pathAccessTotal.Describe(ch)
-	}
-}
+	c.walkEntriesPerSecond = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walk_entries_per_second",
+			Help:        "Entries visited per second per walk",
+			Buckets:     prometheus.ExponentialBuckets(10, 10, 6),
+			ConstLabels: config.constLabelsFor("walk_entries_per_second"),
+		},
+		[]string{"walker"},
+	)
 
-// Collect implements prometheus.Collector.
-func (c *Collector) Collect(ch chan<- prometheus.Metric) {
-	// Update gauges before collecting
-	c.openFilesGauge.Set(float64(c.openFiles.Load()))
-	c.openFilesMaxGauge.Set(float64(c.openFilesMax.Load()))
+	c.walkFilesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walk_files_total",
+			Help:        "Files and other non-directory entries visited by directory tree walks",
+			ConstLabels: config.constLabelsFor("walk_files_total"),
+		},
+		[]string{"walker"},
+	)
 
-	c.operationsTotal.Collect(ch)
-	c.fileOpensTotal.Collect(ch)
-	c.fileCreatesTotal.Collect(ch)
-	c.dirOperationsTotal.Collect(ch)
+	c.walkDirsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walk_dirs_total",
+			Help:        "Directories visited by directory tree walks",
+			ConstLabels: config.constLabelsFor("walk_dirs_total"),
+		},
+		[]string{"walker"},
+	)
 
-	if c.config.EnableLatencyMetrics {
-		c.operationDuration.Collect(ch)
-		c.readDuration.Collect(ch)
-		c.writeDuration.Collect(ch)
-		c.statDuration.Collect(ch)
-		c.openDuration.Collect(ch)
-	}
+	c.walkBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walk_bytes_total",
+			Help:        "Sizes of the regular files whose information walk callbacks read",
+			ConstLabels: config.constLabelsFor("walk_bytes_total"),
+		},
+		[]string{"walker"},
+	)
 
-	if c.config.EnableBandwidthMetrics {
-		c.bytesReadTotal.Collect(ch)
-		c.bytesWrittenTotal.Collect(ch)
-		c.readSizeBytes.Collect(ch)
-		c.writeSizeBytes.Collect(ch)
-	}
+	c.walkDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "walk_duration_seconds",
+			Help:        "Wall time of directory tree walks",
+			Buckets:     prometheus.ExponentialBuckets(0.01, 10, 7),
+			ConstLabels: config.constLabelsFor("walk_duration_seconds"),
+		},
+		[]string{"walker"},
+	)
 
-	c.errorsTotal.Collect(ch)
-	c.permissionErrorsTotal.Collect(ch)
-	c.notFoundErrorsTotal.Collect(ch)
-	c.timeoutErrorsTotal.Collect(ch)
+	// Initialize lock metrics
+	c.initLockMetrics(config)
 
-	c.openFilesGauge.Collect(ch)
-	c.openFilesMaxGauge.Collect(ch)
+	// Initialize glob metrics
+	c.initGlobMetrics(config)
 
-	if c.config.EnablePathMetrics {
-		c.pathAccessTotal.Collect(ch)
-	}
-}
+	// Initialize leaked files counter
+	c.initLeakMetrics(config)
 
-// recordOperation records metrics for a filesystem operation.
-func (c *Collector) recordOperation(op, path string, duration time.Duration, bytesTransferred int64, err error) {
-	// Determine status
-	status := "success"
-	if err != nil {
-		status = "error"
-		c.recordError(op, err)
+	// Initialize degraded state gauge
+	c.degraded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "degraded",
+			Help:        "Whether the health condition failed at the most recent health check (1) or not (0)",
+			ConstLabels: config.constLabelsFor("degraded"),
+		},
+		[]string{"reason"},
+	)
+
+	// Initialize scope metrics
+	c.scopesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "scopes_total",
+			Help:        "Ended scopes by name and status",
+			ConstLabels: config.constLabelsFor("scopes_total"),
+		},
+		[]string{"scope", "status"},
+	)
+
+	c.scopeDuration = prometheus.NewHistogramVec(
+		config.nativeHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "scope_duration_seconds",
+			Help:        "Duration of scopes from BeginScope to End",
+			Buckets:     config.LatencyBuckets,
+			ConstLabels: config.constLabelsFor("scope_duration_seconds"),
+		}),
+		[]string{"scope"},
+	)
+
+	c.scopeFiles = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "scope_files",
+			Help:        "Distinct paths touched per scope",
+			Buckets:     prometheus.ExponentialBuckets(1, 4, 8),
+			ConstLabels: config.constLabelsFor("scope_files"),
+		},
+		[]string{"scope"},
+	)
+
+	c.scopeBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "scope_bytes_total",
+			Help:        "Bytes transferred within ended scopes by direction",
+			ConstLabels: config.constLabelsFor("scope_bytes_total"),
+		},
+		[]string{"scope", "direction"},
+	)
+
+	// Initialize path metrics, also unconditionally; path latency metrics
+	// cannot be enabled at runtime
+	c.pathAccessTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "path_access_total",
+			Help:        "Access counts for specific paths",
+			ConstLabels: config.constLabelsFor("path_access_total"),
+		},
+		[]string{"path", "operation"},
+	)
+
+	if config.EnablePathLatencyMetrics {
+		c.pathDuration = prometheus.NewHistogramVec(
+			config.nativeHistogram(prometheus.HistogramOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "path_operation_duration_seconds",
+				Help:        "Operation duration distribution for specific paths",
+				Buckets:     config.LatencyBuckets,
+				ConstLabels: config.constLabelsFor("path_operation_duration_seconds"),
+			}),
+			[]string{"path", "operation"},
+		)
+
+		c.pathBytesTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "path_bytes_total",
+				Help:        "Bytes transferred for specific paths",
+				ConstLabels: config.constLabelsFor("path_bytes_total"),
+			},
+			[]string{"path", "operation"},
+		)
+	}
+
+	// Initialize CPU time metrics (if enabled)
+	if config.EnableCPUMetrics {
+		c.operationCPUSeconds = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "operation_cpu_seconds_total",
+				Help:        "CPU time consumed by the calling goroutine inside filesystem operations",
+				ConstLabels: config.constLabelsFor("operation_cpu_seconds_total"),
+			},
+			[]string{"operation"},
+		)
+
+		c.operationWallSeconds = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "operation_wall_seconds_total",
+				Help:        "Wall time spent inside filesystem operations for which CPU time was measured",
+				ConstLabels: config.constLabelsFor("operation_wall_seconds_total"),
+			},
+			[]string{"operation"},
+		)
+	}
+
+	// Initialize access pattern metrics (if enabled)
+	if config.EnableAccessPatternMetrics {
+		c.accessPatternTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "io_access_pattern_total",
+				Help:        "Reads and writes by access pattern (sequential or random)",
+				ConstLabels: config.constLabelsFor("io_access_pattern_total"),
+			},
+			[]string{"operation", "pattern"},
+		)
+
+		c.seekDistanceBytes = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "io_seek_distance_bytes",
+				Help:        "Distance of random reads and writes from the end of the previous access",
+				Buckets:     prometheus.ExponentialBuckets(512, 8, 8),
+				ConstLabels: config.constLabelsFor("io_seek_distance_bytes"),
+			},
+			[]string{"operation", "direction"},
+		)
+	}
+
+	// Initialize hot path metrics (if enabled)
+	if config.EnableHotPaths {
+		// Exported from a snapshot on each scrape, so paths that leave the
+		// top K disappear without tracking their series
+		c.hotPathAccesses = prometheus.NewDesc(
+			prometheus.BuildFQName(config.Namespace, config.Subsystem, "hot_path_accesses"),
+			"Approximate access counts of the most accessed paths",
+			[]string{"path"},
+			config.constLabelsFor("hot_path_accesses"),
+		)
+		c.hotPaths = newHotPaths(config.HotPathsTopK)
+	}
+
+	// Initialize layer metrics (if enabled)
+	if config.EnableLayerMetrics {
+		c.layerReadsTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "layer_reads_total",
+				Help:        "Reads by the filesystem layer that served them",
+				ConstLabels: config.constLabelsFor("layer_reads_total"),
+			},
+			[]string{"layer", "status"},
+		)
+
+		c.layerReadBytesTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "layer_read_bytes_total",
+				Help:        "Bytes read by the filesystem layer that served them",
+				ConstLabels: config.constLabelsFor("layer_read_bytes_total"),
+			},
+			[]string{"layer"},
+		)
+
+		c.layerReadDuration = prometheus.NewHistogramVec(
+			config.nativeHistogram(prometheus.HistogramOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "layer_read_duration_seconds",
+				Help:        "Read latency by the filesystem layer that served the read",
+				Buckets:     config.LatencyBuckets,
+				ConstLabels: config.constLabelsFor("layer_read_duration_seconds"),
+			}),
+			[]string{"layer"},
+		)
+	}
+
+	// Initialize working set metrics (if enabled)
+	if config.EnableWorkingSetMetrics {
+		c.workingSetPaths = prometheus.NewDesc(
+			prometheus.BuildFQName(config.Namespace, config.Subsystem, "working_set_paths"),
+			"Approximate number of distinct paths accessed within the working set window",
+			nil,
+			config.constLabelsFor("working_set_paths"),
+		)
+		c.workingSet = newWorkingSet(config.WorkingSetWindow)
+	}
+
+	// Initialize extension metrics (if enabled)
+	if config.EnableExtensionMetrics {
+		c.extensionOperationsTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "extension_operations_total",
+				Help:        "Filesystem operations by file extension",
+				ConstLabels: config.constLabelsFor("extension_operations_total"),
+			},
+			[]string{"extension", "operation"},
+		)
+
+		c.extensionBytesTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "extension_bytes_total",
+				Help:        "Bytes transferred by file extension",
+				ConstLabels: config.constLabelsFor("extension_bytes_total"),
+			},
+			[]string{"extension", "operation"},
+		)
+
+		c.extensionDuration = prometheus.NewHistogramVec(
+			config.nativeHistogram(prometheus.HistogramOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "extension_operation_duration_seconds",
+				Help:        "Operation duration distribution by file extension",
+				Buckets:     config.LatencyBuckets,
+				ConstLabels: config.constLabelsFor("extension_operation_duration_seconds"),
+			}),
+			[]string{"extension", "operation"},
+		)
+	}
+
+	c.opFilter = newOperationFilter(config.OperationFilter)
+
+	// Initialize allocation metrics (if enabled)
+	if config.EnableAllocationMetrics {
+		c.operationAllocations = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "operation_allocations",
+				Help:        "Heap allocations made by the instrumentation per operation, as last calibrated",
+				ConstLabels: config.constLabelsFor("operation_allocations"),
+			},
+			[]string{"operation"},
+		)
+		c.allocations = &allocationCalibration{}
+	}
+
+	// Initialize audit log (if enabled)
+	if config.Audit.Writer != nil {
+		c.auditErrorsTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "audit_errors_total",
+				Help:        "Audit log entries that could not be written",
+				ConstLabels: config.constLabelsFor("audit_errors_total"),
+			},
+			nil,
+		)
+		c.audit = newAuditLog(config.Audit)
+	}
+
+	// Initialize checksum verification (if enabled)
+	if config.Verify.Manifest != nil {
+		c.checksumVerificationsTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "checksum_verifications_total",
+				Help:        "Files read in full whose checksum was verified against the manifest, by path group and result (match or mismatch)",
+				ConstLabels: config.constLabelsFor("checksum_verifications_total"),
+			},
+			[]string{"group", "result"},
+		)
+		c.verify = newVerifier(config.Verify)
+	}
+
+	// Initialize SLO threshold metrics (if configured)
+	c.initSLO(config)
+
+	// Initialize latency extremes gauges (if enabled)
+	c.initLatencyExtremes(config)
+
+	// Initialize file size limit metrics (if configured)
+	if len(config.MaxFileSizes) > 0 {
+		c.sizeLimitViolationsTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "size_limit_violations_total",
+				Help:        "Writes refused for growing a file beyond the maximum size of its path group",
+				ConstLabels: config.constLabelsFor("size_limit_violations_total"),
+			},
+			[]string{"path_group"},
+		)
+	}
+
+	// Check the features enabled against each other
+	c.checkConfig(config)
+
+	// Initialize trash metrics (if enabled)
+	if config.Trash.Dir != "" {
+		c.trashedTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "trashed_total",
+				Help:        "Entries moved to the trash instead of being deleted, by operation",
+				ConstLabels: config.constLabelsFor("trashed_total"),
+			},
+			[]string{"operation"},
+		)
+		c.trashedBytesTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "trashed_bytes_total",
+				Help:        "Bytes moved to the trash instead of being deleted, by operation",
+				ConstLabels: config.constLabelsFor("trashed_bytes_total"),
+			},
+			[]string{"operation"},
+		)
+		c.trashRestoresTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "trash_restores_total",
+				Help:        "Trash entries restored to their original path, by result (success or error)",
+				ConstLabels: config.constLabelsFor("trash_restores_total"),
+			},
+			[]string{"result"},
+		)
+		c.trashPurgedTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "trash_purged_total",
+				Help:        "Trash entries deleted for good, by trigger (retention or manual)",
+				ConstLabels: config.constLabelsFor("trash_purged_total"),
+			},
+			[]string{"trigger"},
+		)
+		c.trashPurgedBytesTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "trash_purged_bytes_total",
+				Help:        "Bytes of trash entries deleted for good, by trigger (retention or manual)",
+				ConstLabels: config.constLabelsFor("trash_purged_bytes_total"),
+			},
+			[]string{"trigger"},
+		)
+	}
+
+	// Initialize asynchronous callbacks (if enabled)
+	if config.CallbackQueueSize > 0 {
+		c.callbacksDroppedTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "callbacks_dropped_total",
+				Help:        "Callbacks dropped because the callback queue was full",
+				ConstLabels: config.constLabelsFor("callbacks_dropped_total"),
+			},
+			[]string{"callback"},
+		)
+		c.callbacks = newCallbackDispatcher(config.CallbackQueueSize)
+		for i := 0; i < config.CallbackWorkers; i++ {
+			c.background.Add(1)
+			go c.runCallbacks()
+		}
+	}
+
+	c.initSeries()
+	c.initUnlabeled()
+	if config.EnableShardedCounters && len(c.dynamicLabels) == 0 {
+		c.sharded = newShardedCounters()
+	}
+
+	c.clock = c.newClock(config)
+	if c.instance == "" {
+		c.instance = newInstanceID()
+	}
+
+	// Start idle state cleanup (if enabled)
+	if config.TrackedStateTTL > 0 {
+		c.background.Add(1)
+		go c.runCleanup(config.CleanupInterval)
+	}
+
+	// Start allocation calibration (if enabled)
+	if config.EnableAllocationMetrics {
+		c.background.Add(1)
+		go c.runAllocationCalibration(config.AllocationCalibrationInterval)
+	}
+
+	return c
+}
+
+// initSeries creates the caches of the series of the built-in operations.
+// Series of disabled metrics are never looked up.
+func (c *Collector) initSeries() {
+	c.inFlightSeries = newOpSeries(func(op Op) prometheus.Gauge {
+		return c.operationsInFlight.WithLabelValues(string(op))
+	})
+	c.successSeries = newOpSeries(func(op Op) prometheus.Counter {
+		return c.operationsTotal.WithLabelValues(string(op), "success")
+	})
+	c.errorSeries = newOpSeries(func(op Op) prometheus.Counter {
+		return c.operationsTotal.WithLabelValues(string(op), "error")
+	})
+	c.durationSeries = newOpSeries(func(op Op) prometheus.Observer {
+		return c.operationDuration.WithLabelValues(string(op))
+	})
+	c.opLatencySeries = newOpSeries(func(op Op) prometheus.Observer {
+		switch op {
+		case OpRead:
+			return c.readDuration.WithLabelValues()
+		case OpWrite:
+			return c.writeDuration.WithLabelValues()
+		case OpStat:
+			return c.statDuration.WithLabelValues()
+		case OpOpen:
+			return c.openDuration.WithLabelValues()
+		}
+		return nil
+	})
+	c.bytesSeries = newOpSeries(func(op Op) prometheus.Counter {
+		if transfersOut(op) {
+			return c.bytesWrittenTotal.WithLabelValues()
+		}
+		return c.bytesReadTotal.WithLabelValues()
+	})
+	c.sizeSeries = newOpSeries(func(op Op) prometheus.Observer {
+		if transfersOut(op) {
+			return c.writeSizeBytes.WithLabelValues(string(op))
+		}
+		return c.readSizeBytes.WithLabelValues(string(op))
+	})
+}
+
+// resetSeries drops the cached series of the built-in operations, after the
+// vectors holding them were reset.
+func (c *Collector) resetSeries() {
+	c.successSeries.reset()
+	c.errorSeries.reset()
+	c.durationSeries.reset()
+	c.opLatencySeries.reset()
+	c.bytesSeries.reset()
+	c.sizeSeries.reset()
+}
+
+// initUnlabeled creates the series of unlabeled metrics so that they are
+// exported with a zero value before their first observation.
+func (c *Collector) initUnlabeled() {
+	c.fileCreatesTotal.WithLabelValues()
+
+	if c.config.EnableOverwriteDetection {
+		c.fileOverwritesTotal.WithLabelValues()
+		c.overwrittenBytesTotal.WithLabelValues()
+	}
+
+	live := c.live.Load()
+	if live.config.EnableLatencyMetrics {
+		c.readDuration.WithLabelValues()
+		c.writeDuration.WithLabelValues()
+		c.statDuration.WithLabelValues()
+		c.openDuration.WithLabelValues()
+	}
+
+	if live.config.EnableBandwidthMetrics && len(c.dynamicLabels) == 0 {
+		c.bytesReadTotal.WithLabelValues()
+		c.bytesWrittenTotal.WithLabelValues()
+	}
+}
+
+// resettable is implemented by all Prometheus metric vectors.
+type resettable interface {
+	Reset()
+}
+
+// metricVecs returns the metric vectors owned by the collector, which Reset
+// clears. The in-flight gauge is not among them: operations running across
+// a Reset still decrement it when they finish.
+func (c *Collector) metricVecs() []resettable {
+	vecs := []resettable{
+		c.operationsTotal,
+		c.fileOpensTotal,
+		c.fileCreatesTotal,
+		c.dirOperationsTotal,
+		c.pathKindTotal,
+		c.readdirEntries,
+		c.readdirEntriesTotal,
+		c.readdirNameBytes,
+		c.histogramOverflowTotal,
+		c.durationAnomaliesTotal,
+		c.modeTransitionsTotal,
+		c.errorsTotal,
+		c.permissionErrorsTotal,
+		c.notFoundErrorsTotal,
+		c.timeoutErrorsTotal,
+		c.walksTotal,
+		c.walkEntriesTotal,
+		c.walkErrorsTotal,
+		c.walkParallelism,
+		c.walkEntriesPerSecond,
+		c.walkFilesTotal,
+		c.walkDirsTotal,
+		c.walkBytesTotal,
+		c.walkDuration,
+		c.lockWait,
+		c.lockHeld,
+		c.lockContentionsTotal,
+		c.globMatches,
+		c.filesLeakedTotal,
+		c.degraded,
+		c.scopesTotal,
+		c.scopeDuration,
+		c.scopeFiles,
+		c.scopeBytesTotal,
+	}
+
+	if c.config.EnableOverwriteDetection {
+		vecs = append(vecs, c.fileOverwritesTotal, c.overwrittenBytesTotal)
+	}
+
+	vecs = append(vecs, c.operationDuration, c.readDuration, c.writeDuration, c.statDuration, c.openDuration)
+	vecs = append(vecs, c.bytesReadTotal, c.bytesWrittenTotal, c.readSizeBytes, c.writeSizeBytes,
+		c.shortReadsTotal, c.shortWritesTotal, c.eofTotal)
+
+	vecs = append(vecs, c.pathAccessTotal)
+	if c.config.EnablePathLatencyMetrics {
+		vecs = append(vecs, c.pathDuration, c.pathBytesTotal)
+	}
+
+	if c.config.EnableExtensionMetrics {
+		vecs = append(vecs, c.extensionOperationsTotal, c.extensionBytesTotal, c.extensionDuration)
+	}
+
+	if c.config.EnableCPUMetrics {
+		vecs = append(vecs, c.operationCPUSeconds, c.operationWallSeconds)
+	}
+
+	if c.config.EnableAccessPatternMetrics {
+		vecs = append(vecs, c.accessPatternTotal, c.seekDistanceBytes)
+	}
+
+	if c.config.EnableRenameMetrics {
+		vecs = append(vecs, c.renamesTotal)
+	}
+
+	if c.callbacks != nil {
+		vecs = append(vecs, c.callbacksDroppedTotal)
+	}
+
+	if c.audit != nil {
+		vecs = append(vecs, c.auditErrorsTotal)
+	}
+
+	if c.verify != nil {
+		vecs = append(vecs, c.checksumVerificationsTotal)
+	}
+
+	if c.sloOperationsTotal != nil {
+		vecs = append(vecs, c.sloOperationsTotal)
+	}
+	if c.apdexOperationsTotal != nil {
+		vecs = append(vecs, c.apdexOperationsTotal)
+	}
+	if c.latencyMin != nil {
+		vecs = append(vecs, c.latencyMin, c.latencyMax, c.latencyLast)
+	}
+	if c.sizeLimitViolationsTotal != nil {
+		vecs = append(vecs, c.sizeLimitViolationsTotal)
+	}
+
+	if c.trashedTotal != nil {
+		vecs = append(vecs, c.trashedTotal, c.trashedBytesTotal, c.trashRestoresTotal, c.trashPurgedTotal, c.trashPurgedBytesTotal)
+	}
+
+	if c.allocations != nil {
+		vecs = append(vecs, c.operationAllocations)
+	}
+
+	if c.config.EnableLayerMetrics {
+		vecs = append(vecs, c.layerReadsTotal, c.layerReadBytesTotal, c.layerReadDuration)
+	}
+
+	return vecs
+}
+
+// Reset zeroes all counters and histograms, clears tracked paths and
+// extensions, and resets the maximum open files gauge to the current
+// number of open files. Series for labeled metrics are dropped until they
+// are observed again. The mode transition history and the operations in
+// flight are kept.
+func (c *Collector) Reset() {
+	for _, vec := range c.metricVecs() {
+		vec.Reset()
+	}
+	c.resetSeries()
+	c.discardShards()
+	if c.extremes != nil {
+		c.extremes.Clear()
+	}
+	c.initUnlabeled()
+
+	c.pathMutex.Lock()
+	c.trackedPaths = make(map[string]*atomic.Int64)
+	c.pathMutex.Unlock()
+
+	c.trackedExtensions.reset()
+	c.trackedPathGroups.reset()
+	c.trackedScopes.reset()
+	c.trackedLayers.reset()
+	if c.hotPaths != nil {
+		c.hotPaths.reset()
+	}
+	if c.workingSet != nil {
+		c.workingSet.reset()
+	}
+	c.slowOps.reset()
+
+	c.openFilesMax.Store(c.openFiles.Load())
+
+	if c.pathLimitEngaged.CompareAndSwap(true, false) {
+		c.recordModeTransition(ModePathLimit, "released")
+	}
+	if !c.closed.Load() {
+		c.recordModeTransition(ModeCollector, "reset")
+	}
+}
+
+// Register registers the collector with reg. Registries registered through
+// this method are unregistered automatically by Close.
+func (c *Collector) Register(reg prometheus.Registerer) error {
+	if err := reg.Register(c); err != nil {
+		return err
+	}
+
+	c.registryMu.Lock()
+	c.registerers = append(c.registerers, reg)
+	c.registryMu.Unlock()
+
+	return nil
+}
+
+// Unregister unregisters the collector from reg. It reports whether the
+// collector was registered with reg.
+func (c *Collector) Unregister(reg prometheus.Registerer) bool {
+	c.registryMu.Lock()
+	for i, r := range c.registerers {
+		if r == reg {
+			c.registerers = append(c.registerers[:i], c.registerers[i+1:]...)
+			break
+		}
+	}
+	c.registryMu.Unlock()
+
+	return reg.Unregister(c)
+}
+
+// Close unregisters the collector from every registry it was registered
+// with through Register, drops all metric series and stops recording.
+// Operations performed after Close are passed through without metrics.
+// Close is idempotent and always returns nil.
+func (c *Collector) Close() error {
+	c.backgroundMu.Lock()
+	closed := c.closed.Swap(true)
+	c.backgroundMu.Unlock()
+	if closed {
+		return nil
+	}
+
+	// Stop background goroutines, letting reporters emit a final report
+	close(c.done)
+	c.background.Wait()
+
+	c.registryMu.Lock()
+	registerers := c.registerers
+	c.registerers = nil
+	c.registryMu.Unlock()
+
+	for _, reg := range registerers {
+		reg.Unregister(c)
+	}
+	c.unpublishExpvar()
+
+	c.Reset()
+	c.recordModeTransition(ModeCollector, "closed")
+	return nil
+}
+
+// addBackground adds a background goroutine for Close to wait for, and
+// reports whether it may be started, which it may not once the collector is
+// closed. The check and the Add are made under backgroundMu, which Close
+// holds while marking the collector closed, so that no goroutine is added
+// while Close waits.
+func (c *Collector) addBackground() bool {
+	c.backgroundMu.Lock()
+	defer c.backgroundMu.Unlock()
+
+	if c.closed.Load() {
+		return false
+	}
+	c.background.Add(1)
+	return true
+}
+
+// addBackgroundOnce is like addBackground for a goroutine run once per
+// collector, whichever of the wrappers sharing it starts it first: it
+// reports false if one was already added under name.
+func (c *Collector) addBackgroundOnce(name string) bool {
+	c.backgroundMu.Lock()
+	defer c.backgroundMu.Unlock()
+
+	if c.closed.Load() || c.backgroundOnce[name] {
+		return false
+	}
+	if c.backgroundOnce == nil {
+		c.backgroundOnce = make(map[string]bool)
+	}
+	c.backgroundOnce[name] = true
+	c.background.Add(1)
+	return true
+}
+
+// Closed reports whether Close has been called.
+func (c *Collector) Closed() bool {
+	return c.closed.Load()
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.operationsTotal.Describe(ch)
+	c.fileOpensTotal.Describe(ch)
+	c.fileCreatesTotal.Describe(ch)
+	c.dirOperationsTotal.Describe(ch)
+	c.pathKindTotal.Describe(ch)
+	c.readdirEntries.Describe(ch)
+	c.readdirEntriesTotal.Describe(ch)
+	c.readdirNameBytes.Describe(ch)
+	c.histogramOverflowTotal.Describe(ch)
+	c.durationAnomaliesTotal.Describe(ch)
+	c.modeTransitionsTotal.Describe(ch)
+	c.operationsInFlight.Describe(ch)
+	ch <- c.configWarningInfo
+
+	if c.config.EnableOverwriteDetection {
+		c.fileOverwritesTotal.Describe(ch)
+		c.overwrittenBytesTotal.Describe(ch)
+	}
+
+	c.operationDuration.Describe(ch)
+	c.readDuration.Describe(ch)
+	c.writeDuration.Describe(ch)
+	c.statDuration.Describe(ch)
+	c.openDuration.Describe(ch)
+
+	c.bytesReadTotal.Describe(ch)
+	c.bytesWrittenTotal.Describe(ch)
+	c.readSizeBytes.Describe(ch)
+	c.writeSizeBytes.Describe(ch)
+	c.shortReadsTotal.Describe(ch)
+	c.shortWritesTotal.Describe(ch)
+	c.eofTotal.Describe(ch)
+
+	c.errorsTotal.Describe(ch)
+	c.permissionErrorsTotal.Describe(ch)
+	c.notFoundErrorsTotal.Describe(ch)
+	c.timeoutErrorsTotal.Describe(ch)
+
+	c.openFilesGauge.Describe(ch)
+	c.openFilesMaxGauge.Describe(ch)
+	ch <- c.openFilesLimit
+	if c.config.CapacityPath != "" {
+		ch <- c.capacityBytes
+		ch <- c.freeBytes
+	}
+	c.trackedPathsGauge.Describe(ch)
+	c.trackedStateBytesGauge.Describe(ch)
+
+	c.walksTotal.Describe(ch)
+	c.walkEntriesTotal.Describe(ch)
+	c.walkErrorsTotal.Describe(ch)
+	c.walkParallelism.Describe(ch)
+	c.walkEntriesPerSecond.Describe(ch)
+	c.walkFilesTotal.Describe(ch)
+	c.walkDirsTotal.Describe(ch)
+	c.walkBytesTotal.Describe(ch)
+	c.walkDuration.Describe(ch)
+	c.lockWait.Describe(ch)
+	c.lockHeld.Describe(ch)
+	c.lockContentionsTotal.Describe(ch)
+	c.globMatches.Describe(ch)
+	c.filesLeakedTotal.Describe(ch)
+	c.degraded.Describe(ch)
+	c.scopesTotal.Describe(ch)
+	c.scopeDuration.Describe(ch)
+	c.scopeFiles.Describe(ch)
+	c.scopeBytesTotal.Describe(ch)
+
+	c.pathAccessTotal.Describe(ch)
+	if c.config.EnablePathLatencyMetrics {
+		c.pathDuration.Describe(ch)
+		c.pathBytesTotal.Describe(ch)
+	}
+
+	if c.config.EnableExtensionMetrics {
+		c.extensionOperationsTotal.Describe(ch)
+		c.extensionBytesTotal.Describe(ch)
+		c.extensionDuration.Describe(ch)
+	}
+
+	if c.config.EnableCPUMetrics {
+		c.operationCPUSeconds.Describe(ch)
+		c.operationWallSeconds.Describe(ch)
+	}
+
+	if c.config.EnableAccessPatternMetrics {
+		c.accessPatternTotal.Describe(ch)
+		c.seekDistanceBytes.Describe(ch)
+	}
+
+	if c.callbacks != nil {
+		c.callbacksDroppedTotal.Describe(ch)
+	}
+
+	if c.audit != nil {
+		c.auditErrorsTotal.Describe(ch)
+	}
+
+	if c.verify != nil {
+		c.checksumVerificationsTotal.Describe(ch)
+	}
+
+	if c.sloOperationsTotal != nil {
+		c.sloOperationsTotal.Describe(ch)
+	}
+	if c.apdexOperationsTotal != nil {
+		c.apdexOperationsTotal.Describe(ch)
+	}
+	if c.latencyMin != nil {
+		c.latencyMin.Describe(ch)
+		c.latencyMax.Describe(ch)
+		c.latencyLast.Describe(ch)
+	}
+	if c.sizeLimitViolationsTotal != nil {
+		c.sizeLimitViolationsTotal.Describe(ch)
+	}
+
+	if c.trashedTotal != nil {
+		c.trashedTotal.Describe(ch)
+		c.trashedBytesTotal.Describe(ch)
+		c.trashRestoresTotal.Describe(ch)
+		c.trashPurgedTotal.Describe(ch)
+		c.trashPurgedBytesTotal.Describe(ch)
+	}
+
+	if c.allocations != nil {
+		c.operationAllocations.Describe(ch)
+	}
+
+	if c.config.EnableLayerMetrics {
+		c.layerReadsTotal.Describe(ch)
+		c.layerReadBytesTotal.Describe(ch)
+		c.layerReadDuration.Describe(ch)
+	}
+
+	if c.config.EnableWorkingSetMetrics {
+		ch <- c.workingSetPaths
+	}
+
+	if c.config.EnableHotPaths {
+		ch <- c.hotPathAccesses
+	}
+
+	if c.config.EnableRenameMetrics {
+		c.renamesTotal.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	// Update gauges and sharded counters before collecting
+	c.flushShards()
+	c.flushLatencyExtremes()
+	c.openFilesGauge.Set(float64(c.openFiles.Load()))
+	c.openFilesMaxGauge.Set(float64(c.openFilesMax.Load()))
+	paths, stateBytes := c.trackedState()
+	c.trackedPathsGauge.Set(float64(paths))
+	c.trackedStateBytesGauge.Set(float64(stateBytes))
+
+	c.operationsTotal.Collect(ch)
+	c.fileOpensTotal.Collect(ch)
+	c.fileCreatesTotal.Collect(ch)
+	c.dirOperationsTotal.Collect(ch)
+	c.pathKindTotal.Collect(ch)
+	c.readdirEntries.Collect(ch)
+	c.readdirEntriesTotal.Collect(ch)
+	c.readdirNameBytes.Collect(ch)
+	c.histogramOverflowTotal.Collect(ch)
+	c.durationAnomaliesTotal.Collect(ch)
+	c.modeTransitionsTotal.Collect(ch)
+	c.operationsInFlight.Collect(ch)
+
+	if c.config.EnableOverwriteDetection {
+		c.fileOverwritesTotal.Collect(ch)
+		c.overwrittenBytesTotal.Collect(ch)
+	}
+
+	c.operationDuration.Collect(ch)
+	c.readDuration.Collect(ch)
+	c.writeDuration.Collect(ch)
+	c.statDuration.Collect(ch)
+	c.openDuration.Collect(ch)
+
+	c.bytesReadTotal.Collect(ch)
+	c.bytesWrittenTotal.Collect(ch)
+	c.readSizeBytes.Collect(ch)
+	c.writeSizeBytes.Collect(ch)
+	c.shortReadsTotal.Collect(ch)
+	c.shortWritesTotal.Collect(ch)
+	c.eofTotal.Collect(ch)
+
+	c.errorsTotal.Collect(ch)
+	c.permissionErrorsTotal.Collect(ch)
+	c.notFoundErrorsTotal.Collect(ch)
+	c.timeoutErrorsTotal.Collect(ch)
+
+	c.openFilesGauge.Collect(ch)
+	c.openFilesMaxGauge.Collect(ch)
+	if limit, ok := openFilesLimit(); ok {
+		ch <- prometheus.MustNewConstMetric(c.openFilesLimit, prometheus.GaugeValue, float64(limit))
+	}
+	if c.config.CapacityPath != "" {
+		if total, free, ok := diskCapacity(c.config.CapacityPath); ok {
+			ch <- prometheus.MustNewConstMetric(c.capacityBytes, prometheus.GaugeValue, float64(total))
+			ch <- prometheus.MustNewConstMetric(c.freeBytes, prometheus.GaugeValue, float64(free))
+		}
+	}
+	c.trackedPathsGauge.Collect(ch)
+	c.trackedStateBytesGauge.Collect(ch)
+	c.collectWarnings(ch)
+
+	c.walksTotal.Collect(ch)
+	c.walkEntriesTotal.Collect(ch)
+	c.walkErrorsTotal.Collect(ch)
+	c.walkParallelism.Collect(ch)
+	c.walkEntriesPerSecond.Collect(ch)
+	c.walkFilesTotal.Collect(ch)
+	c.walkDirsTotal.Collect(ch)
+	c.walkBytesTotal.Collect(ch)
+	c.walkDuration.Collect(ch)
+	c.lockWait.Collect(ch)
+	c.lockHeld.Collect(ch)
+	c.lockContentionsTotal.Collect(ch)
+	c.globMatches.Collect(ch)
+	c.filesLeakedTotal.Collect(ch)
+	c.degraded.Collect(ch)
+	c.scopesTotal.Collect(ch)
+	c.scopeDuration.Collect(ch)
+	c.scopeFiles.Collect(ch)
+	c.scopeBytesTotal.Collect(ch)
+
+	c.pathAccessTotal.Collect(ch)
+	if c.config.EnablePathLatencyMetrics {
+		c.pathDuration.Collect(ch)
+		c.pathBytesTotal.Collect(ch)
+	}
+
+	if c.config.EnableExtensionMetrics {
+		c.extensionOperationsTotal.Collect(ch)
+		c.extensionBytesTotal.Collect(ch)
+		c.extensionDuration.Collect(ch)
+	}
+
+	if c.config.EnableCPUMetrics {
+		c.operationCPUSeconds.Collect(ch)
+		c.operationWallSeconds.Collect(ch)
+	}
+
+	if c.config.EnableAccessPatternMetrics {
+		c.accessPatternTotal.Collect(ch)
+		c.seekDistanceBytes.Collect(ch)
+	}
+
+	if c.callbacks != nil {
+		c.callbacksDroppedTotal.Collect(ch)
+	}
+
+	if c.audit != nil {
+		c.auditErrorsTotal.Collect(ch)
+	}
+
+	if c.verify != nil {
+		c.checksumVerificationsTotal.Collect(ch)
+	}
+
+	if c.sloOperationsTotal != nil {
+		c.sloOperationsTotal.Collect(ch)
+	}
+	if c.apdexOperationsTotal != nil {
+		c.apdexOperationsTotal.Collect(ch)
+	}
+	if c.latencyMin != nil {
+		c.latencyMin.Collect(ch)
+		c.latencyMax.Collect(ch)
+		c.latencyLast.Collect(ch)
+	}
+	if c.sizeLimitViolationsTotal != nil {
+		c.sizeLimitViolationsTotal.Collect(ch)
+	}
+
+	if c.trashedTotal != nil {
+		c.trashedTotal.Collect(ch)
+		c.trashedBytesTotal.Collect(ch)
+		c.trashRestoresTotal.Collect(ch)
+		c.trashPurgedTotal.Collect(ch)
+		c.trashPurgedBytesTotal.Collect(ch)
+	}
+
+	if c.allocations != nil {
+		c.operationAllocations.Collect(ch)
+	}
+
+	if c.config.EnableLayerMetrics {
+		c.layerReadsTotal.Collect(ch)
+		c.layerReadBytesTotal.Collect(ch)
+		c.layerReadDuration.Collect(ch)
+	}
+
+	if c.config.EnableWorkingSetMetrics {
+		ch <- prometheus.MustNewConstMetric(c.workingSetPaths, prometheus.GaugeValue, float64(c.WorkingSetSize()))
+	}
+
+	if c.config.EnableHotPaths {
+		for _, hot := range c.hotPaths.top() {
+			ch <- prometheus.MustNewConstMetric(c.hotPathAccesses, prometheus.GaugeValue, float64(hot.Count), hot.Path)
+		}
+	}
+
+	if c.config.EnableRenameMetrics {
+		c.renamesTotal.Collect(ch)
+	}
+}
+
+// operationStart is returned by startOperation and passed to finishOperation.
+type operationStart struct {
+	time time.Time
+
+	// unsampled is set when the operation is not timed under
+	// LatencySampleRate
+	unsampled bool
+
+	// cpu is the thread CPU time at the start, valid when cpuOK is set
+	cpu   time.Duration
+	cpuOK bool
+
+	// region is the runtime/trace region of the operation, with
+	// TraceRegions while tracing
+	region *rtrace.Region
+}
+
+// startOperation marks op as in flight and returns its start time. With CPU
+// metrics enabled, the calling goroutine stays on its OS thread until
+// finishOperation so that the thread's CPU time can be attributed to op.
+func (c *Collector) startOperation(op Op) operationStart {
+	if !c.measured(op) {
+		return operationStart{}
+	}
+
+	if c.closed.Load() || !c.sharded.addInFlight(op, 1) {
+		c.inFlight.Add(1)
+		if !c.closed.Load() {
+			c.inFlightSeries.get(op).Inc()
+		}
+	}
+
+	var start operationStart
+	start.region = c.startRegion(op)
+	if c.config.EnableCPUMetrics {
+		runtime.LockOSThread()
+		start.cpu, start.cpuOK = threadCPUTime()
+	}
+	switch {
+	case c.profile.Load() != nil:
+		start.time = c.clock.Now()
+	case c.live.Load().timed:
+		if c.sampleLatency(op) {
+			start.time = c.clock.Now()
+		} else {
+			start.unsampled = true
+		}
+	}
+	return start
+}
+
+// finishOperation marks op as no longer in flight and returns the time
+// elapsed since start, or 0 if the operation was not timed.
+func (c *Collector) finishOperation(op Op, start operationStart) time.Duration {
+	if start.region != nil {
+		start.region.End()
+	}
+	if !c.measured(op) {
+		return 0
+	}
+
+	var duration time.Duration
+	if !start.time.IsZero() {
+		duration = c.sanitizeDuration(op, c.clock.Now().Sub(start.time))
+		c.lastFinish.Store(start.time.Add(duration).UnixNano())
+	}
+
+	if c.config.EnableCPUMetrics {
+		cpu, ok := threadCPUTime()
+		runtime.UnlockOSThread()
+		if ok && start.cpuOK && !c.closed.Load() {
+			c.operationCPUSeconds.WithLabelValues(string(op)).Add(max(cpu-start.cpu, 0).Seconds())
+			c.operationWallSeconds.WithLabelValues(string(op)).Add(duration.Seconds())
+		}
+	}
+
+	if c.closed.Load() || !c.sharded.addInFlight(op, -1) {
+		c.inFlight.Add(-1)
+		if !c.closed.Load() {
+			c.inFlightSeries.get(op).Dec()
+		}
+	}
+	if start.unsampled {
+		return unsampled
+	}
+	return duration
+}
+
+// unsampled is the duration finishOperation returns for operations not
+// timed under LatencySampleRate. Their duration is reported as 0 and not
+// observed in latency histograms.
+const unsampled time.Duration = -1
+
+// sampleLatency reports whether to time op under LatencySampleRate.
+func (c *Collector) sampleLatency(op Op) bool {
+	n := c.live.Load().config.LatencySampleRate
+	if n <= 1 || (op != OpRead && op != OpWrite) || c.config.EnableCPUMetrics {
+		return true
+	}
+	return rand.IntN(n) == 0
+}
+
+// timesOperations reports whether config records or passes on operation
+// durations. When nothing does, operations are not timed at all, sparing
+// two clock reads per operation.
+func timesOperations(config Config) bool {
+	return config.EnableLatencyMetrics ||
+		(config.EnablePathMetrics && config.EnablePathLatencyMetrics) ||
+		config.EnableExtensionMetrics ||
+		config.EnableLayerMetrics ||
+		config.EnableCPUMetrics ||
+		len(config.SLOThresholds) > 0 ||
+		config.SlowOperationThreshold > 0 ||
+		config.EnableLatencyExtremes ||
+		config.Health.StallTimeout > 0 ||
+		config.Audit.Writer != nil ||
+		config.OnOperation != nil ||
+		config.AmbientSpanEvents
+}
+
+// sanitizeDuration clamps durations that cannot be real, such as negative
+// ones or ones above MaxOperationDuration, caused by clock skew on some
+// virtual machines. Each clamped duration is counted as an anomaly.
+func (c *Collector) sanitizeDuration(op Op, duration time.Duration) time.Duration {
+	kind := ""
+	switch {
+	case duration < 0:
+		kind, duration = "negative", 0
+	case c.config.MaxOperationDuration > 0 && duration > c.config.MaxOperationDuration:
+		kind, duration = "too_large", c.config.MaxOperationDuration
+	}
+
+	if kind != "" && !c.closed.Load() {
+		c.durationAnomaliesTotal.WithLabelValues(string(op), kind).Inc()
+	}
+	return duration
+}
+
+// recordOperation records metrics for a filesystem operation.
+func (c *Collector) recordOperation(ctx context.Context, op Op, path string, duration time.Duration, bytesTransferred int64, err error) {
+	if c.closed.Load() || !c.measured(op) {
+		return
+	}
+
+	// Benign sentinels such as io.EOF are not failures
+	err = operationError(err)
+
+	live := c.live.Load()
+
+	// Reads and writes not sampled under LatencySampleRate have no duration
+	sampled := duration != unsampled
+	if !sampled {
+		duration = 0
+	}
+
+	if c.config.AmbientSpanEvents {
+		addAmbientSpanEvent(ctx, op, duration, bytesTransferred, err)
+	}
+
+	// Resolve instance and per-request labels from the context
+	ctxValues := c.dynamicLabelValues(ctx)
+
+	if p := c.profile.Load(); p != nil {
+		p.record(op, path, duration, bytesTransferred, err)
+	}
+	if s := scopeFromContext(ctx); s != nil {
+		s.record(op, path, bytesTransferred, err)
+	}
+	c.recordAudit(ctx, op, path, duration, bytesTransferred, err, ctxValues)
+
+	// Determine status
+	status := "success"
+	if err != nil {
+		status = "error"
+		c.recordError(op, err, ctxValues)
 	}
 
 	// Record operation count
-	c.operationsTotal.WithLabelValues(op, status).Inc()
+	if !c.sharded.count(op, err != nil) {
+		c.totalOperations.Add(1)
+		if err != nil {
+			c.totalErrors.Add(1)
+		}
+		if ctxValues == nil {
+			if err != nil {
+				c.errorSeries.get(op).Inc()
+			} else {
+				c.successSeries.get(op).Inc()
+			}
+		} else {
+			c.operationsTotal.WithLabelValues(append([]string{string(op), status}, ctxValues...)...).Inc()
+		}
+	}
 
 	// Record latency if enabled
-	if c.config.EnableLatencyMetrics {
-		c.operationDuration.WithLabelValues(op).Observe(duration.Seconds())
+	if live.config.EnableLatencyMetrics && sampled {
+		exemplar := traceExemplar(ctx)
+		if ctxValues == nil {
+			c.observeLatency(c.durationSeries.get(op), "operation_duration_seconds", duration, exemplar)
+		} else {
+			c.observeLatency(c.operationDuration.WithLabelValues(append([]string{string(op)}, ctxValues...)...), "operation_duration_seconds", duration, exemplar)
+		}
 
 		// Also record in specific operation histograms
 		switch op {
-		case "read":
-			c.readDuration.Observe(duration.Seconds())
-		case "write":
-			c.writeDuration.Observe(duration.Seconds())
-		case "stat":
-			c.statDuration.Observe(duration.Seconds())
-		case "open":
-			c.openDuration.Observe(duration.Seconds())
+		case OpRead:
+			c.observeLatency(c.opLatencySeries.get(op), "read_duration_seconds", duration, exemplar)
+		case OpWrite:
+			c.observeLatency(c.opLatencySeries.get(op), "write_duration_seconds", duration, exemplar)
+		case OpStat:
+			c.observeLatency(c.opLatencySeries.get(op), "stat_duration_seconds", duration, exemplar)
+		case OpOpen:
+			c.observeLatency(c.opLatencySeries.get(op), "open_duration_seconds", duration, exemplar)
 		}
 	}
 
+	// Record latency extremes if enabled
+	if c.extremes != nil && sampled {
+		c.recordLatencyExtremes(op, duration)
+	}
+
+	// Record SLO thresholds if configured
+	if live.sloThresholds != nil && sampled {
+		c.recordSLO(live.sloThresholds, op, duration, err)
+	}
+
+	// Log slow operations if configured
+	if live.config.SlowOperationThreshold > 0 && sampled {
+		c.recordSlowOperation(live.config.SlowOperationThreshold, op, path, duration, err)
+	}
+
 	// Record bandwidth if enabled
-	if c.config.EnableBandwidthMetrics && bytesTransferred > 0 {
+	if live.config.EnableBandwidthMetrics && bytesTransferred > 0 {
 		switch op {
-		case "read":
-			c.bytesReadTotal.Add(float64(bytesTransferred))
-			c.readSizeBytes.WithLabelValues(op).Observe(float64(bytesTransferred))
-		case "write":
-			c.bytesWrittenTotal.Add(float64(bytesTransferred))
-			c.writeSizeBytes.WithLabelValues(op).Observe(float64(bytesTransferred))
+		case OpRead, OpReadFile:
+			if !c.sharded.addBytes(op, bytesTransferred) {
+				if ctxValues == nil {
+					c.bytesSeries.get(op).Add(float64(bytesTransferred))
+				} else {
+					c.bytesReadTotal.WithLabelValues(ctxValues...).Add(float64(bytesTransferred))
+				}
+			}
+			c.observeSize(c.sizeSeries.get(op), "read_size_bytes", bytesTransferred)
+		case OpWrite, OpWriteFile:
+			if !c.sharded.addBytes(op, bytesTransferred) {
+				if ctxValues == nil {
+					c.bytesSeries.get(op).Add(float64(bytesTransferred))
+				} else {
+					c.bytesWrittenTotal.WithLabelValues(ctxValues...).Add(float64(bytesTransferred))
+				}
+			}
+			c.observeSize(c.sizeSeries.get(op), "write_size_bytes", bytesTransferred)
 		}
 	}
 
 	// Record path metrics if enabled
-	if c.config.EnablePathMetrics && path != "" {
-		c.recordPathAccess(path, op)
+	if live.config.EnablePathMetrics && path != "" {
+		c.recordPathAccess(path, op, duration, sampled, bytesTransferred)
+	}
+
+	// Record hot paths if enabled
+	if c.config.EnableHotPaths && path != "" {
+		c.hotPaths.add(path)
+	}
+
+	// Record working set if enabled
+	if c.config.EnableWorkingSetMetrics && path != "" {
+		c.workingSet.add(path, c.clock.Now())
+	}
+
+	// Record extension metrics if enabled
+	if c.config.EnableExtensionMetrics && path != "" {
+		c.recordExtension(path, op, duration, sampled, bytesTransferred)
 	}
 
 	// Call user callback if provided
 	if c.config.OnOperation != nil {
-		c.config.OnOperation(Operation{
-			Name:             op,
+		operation := Operation{
+			Name:             string(op),
 			Duration:         duration,
 			BytesTransferred: bytesTransferred,
 			Path:             path,
 			Error:            err,
-		})
+			HandleID:         HandleID(ctx),
+			Instance:         c.instanceOf(ctx),
+		}
+		c.dispatch("operation", func() { c.config.OnOperation(operation) })
+	}
+}
+
+// observeLatency observes duration in h, counting it as an overflow of metric
+// when it exceeds the largest latency bucket. A non-nil exemplar is attached
+// to the observation.
+func (c *Collector) observeLatency(h prometheus.Observer, metric string, duration time.Duration, exemplar prometheus.Labels) {
+	seconds := duration.Seconds()
+	if eo, ok := h.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(seconds, exemplar)
+	} else {
+		h.Observe(seconds)
+	}
+	if seconds > c.maxLatencyBucket {
+		c.recordHistogramOverflow(metric, seconds)
+	}
+}
+
+// traceExemplar returns exemplar labels identifying the span in ctx, or nil
+// if ctx carries no valid span context.
+func traceExemplar(ctx context.Context) prometheus.Labels {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return prometheus.Labels{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	}
+}
+
+// observeSize observes size in h, counting it as an overflow of metric when
+// it exceeds the largest size bucket.
+func (c *Collector) observeSize(h prometheus.Observer, metric string, size int64) {
+	h.Observe(float64(size))
+	if float64(size) > c.maxSizeBucket {
+		c.recordHistogramOverflow(metric, float64(size))
+	}
+}
+
+// recordHistogramOverflow records an observation of metric that landed in
+// the +Inf bucket.
+func (c *Collector) recordHistogramOverflow(metric string, value float64) {
+	c.histogramOverflowTotal.WithLabelValues(metric).Inc()
+
+	if c.config.OnHistogramOverflow != nil {
+		c.config.OnHistogramOverflow(metric, value)
+	}
+}
+
+// largestBucket returns the upper bound of the largest finite bucket in
+// buckets, which default to prometheus.DefBuckets when empty.
+func largestBucket(buckets []float64) float64 {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	return buckets[len(buckets)-1]
+}
+
+// instanceLabel is the label that identifies the wrapper sharing a collector.
+const instanceLabel = "fs_instance"
+
+// dynamicLabelNames returns the per-operation label names for config.
+func dynamicLabelNames(config Config) []string {
+	var names []string
+	if config.EnableInstanceLabel {
+		names = append(names, instanceLabel)
+	}
+	if config.EnableRootLabel {
+		names = append(names, rootLabel)
+	}
+	return append(names, config.ContextLabelNames...)
+}
+
+// dynamicLabelValues returns the values of the instance, root and context
+// labels for ctx, in dynamicLabels order. Missing labels are reported as "".
+func (c *Collector) dynamicLabelValues(ctx context.Context) []string {
+	if len(c.dynamicLabels) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(c.dynamicLabels))
+	if c.config.EnableInstanceLabel {
+		instance := instanceFromContext(ctx)
+		if instance == "" {
+			instance = c.config.Instance
+		}
+		values = append(values, instance)
+	}
+	if c.config.EnableRootLabel {
+		values = append(values, rootFromContext(ctx))
 	}
+
+	if len(c.config.ContextLabelNames) > 0 {
+		var labels prometheus.Labels
+		if c.config.ContextLabels != nil && ctx != nil {
+			labels = c.config.ContextLabels(ctx)
+		}
+		for _, name := range c.config.ContextLabelNames {
+			values = append(values, labels[name])
+		}
+	}
+
+	return values
+}
+
+// newInstanceID returns a random identifier for a collector configured
+// without Config.Instance.
+func newInstanceID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+// Instance returns the identifier of the wrapped filesystem in the
+// collector's telemetry: Config.Instance, or an ID generated when the
+// collector was created.
+func (c *Collector) Instance() string {
+	return c.instance
+}
+
+// instanceOf returns the instance recorded for an operation issued with
+// ctx: the wrapper's for collectors shared with NewWithCollector, and the
+// collector's otherwise.
+func (c *Collector) instanceOf(ctx context.Context) string {
+	if instance := instanceFromContext(ctx); instance != "" {
+		return instance
+	}
+	return c.instance
+}
+
+// instanceKey is the context key holding the wrapper instance name.
+type instanceKey struct{}
+
+// withInstance returns a copy of ctx carrying the wrapper instance name.
+func withInstance(ctx context.Context, instance string) context.Context {
+	return context.WithValue(ctx, instanceKey{}, instance)
+}
+
+// instanceFromContext returns the wrapper instance name carried by ctx.
+func instanceFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	instance, _ := ctx.Value(instanceKey{}).(string)
+	return instance
+}
+
+// isBenignError reports whether err is a sentinel that signals normal
+// termination of an operation (such as io.EOF at the end of a file or
+// directory listing) rather than a failure.
+func isBenignError(err error) bool {
+	return errors.Is(err, io.EOF)
+}
+
+// operationError returns err, or nil if err is benign.
+// It is shared by the Prometheus and OpenTelemetry collectors so that both
+// classify errors identically.
+func operationError(err error) error {
+	if isBenignError(err) {
+		return nil
+	}
+	return err
 }
 
 // recordError records error metrics.
-func (c *Collector) recordError(op string, err error) {
+func (c *Collector) recordError(op Op, err error, ctxValues []string) {
 	if err == nil {
 		return
 	}
 
 	// Categorize error
-	errorType := "unknown"
-	if errors.Is(err, os.ErrNotExist) {
-		errorType = "not_found"
-		c.notFoundErrorsTotal.WithLabelValues(op).Inc()
-	} else if errors.Is(err, os.ErrPermission) {
-		errorType = "permission"
-		c.permissionErrorsTotal.WithLabelValues(op).Inc()
-	} else if errors.Is(err, os.ErrDeadlineExceeded) {
-		errorType = "timeout"
-		c.timeoutErrorsTotal.WithLabelValues(op).Inc()
+	errorType := categorizeError(err)
+	switch errorType {
+	case "not_found":
+		c.notFoundErrorsTotal.WithLabelValues(string(op)).Inc()
+	case "permission":
+		c.permissionErrorsTotal.WithLabelValues(string(op)).Inc()
+	case "timeout":
+		c.timeoutErrorsTotal.WithLabelValues(string(op)).Inc()
 	}
 
-	c.errorsTotal.WithLabelValues(op, errorType).Inc()
+	if ctxValues == nil {
+		c.errorsTotal.WithLabelValues(string(op), errorType).Inc()
+	} else {
+		c.errorsTotal.WithLabelValues(append([]string{string(op), errorType}, ctxValues...)...).Inc()
+	}
 
 	// Call user callback if provided
 	if c.config.OnError != nil {
-		c.config.OnError(op, err)
+		c.dispatch("error", func() { c.config.OnError(string(op), err) })
 	}
 }
 
 // recordPathAccess records path-level metrics with cardinality protection.
-func (c *Collector) recordPathAccess(path, op string) {
+// With GroupPathMetrics, path is replaced by its group before tracking.
+func (c *Collector) recordPathAccess(path string, op Op, duration time.Duration, sampled bool, bytesTransferred int64) {
+	if c.config.GroupPathMetrics {
+		path = c.config.PathGroupFunc(path)
+	}
+
 	c.pathMutex.RLock()
-	tracked := c.trackedPaths[path]
+	lastAccess, tracked := c.trackedPaths[path]
 	count := len(c.trackedPaths)
 	c.pathMutex.RUnlock()
 
 	// If already tracked or under limit, record it
-	if tracked || count < c.config.MaxTrackedPaths {
-		if !tracked {
-			c.pathMutex.Lock()
-			c.trackedPaths[path] = true
-			c.pathMutex.Unlock()
+	if !tracked {
+		if count >= c.config.MaxTrackedPaths {
+			if c.pathLimitEngaged.CompareAndSwap(false, true) {
+				c.recordModeTransition(ModePathLimit, "engaged")
+			}
+			return
+		}
+		c.pathMutex.Lock()
+		if lastAccess, tracked = c.trackedPaths[path]; !tracked {
+			lastAccess = new(atomic.Int64)
+			c.trackedPaths[path] = lastAccess
+		}
+		c.pathMutex.Unlock()
+	}
+
+	if c.config.TrackedStateTTL > 0 {
+		lastAccess.Store(c.clock.Now().UnixNano())
+	}
+	c.pathAccessTotal.WithLabelValues(path, string(op)).Inc()
+
+	if c.config.EnablePathLatencyMetrics && sampled {
+		c.observeLatency(c.pathDuration.WithLabelValues(path, string(op)), "path_operation_duration_seconds", duration, nil)
+		if bytesTransferred > 0 && transfersData(op) {
+			c.pathBytesTotal.WithLabelValues(path, string(op)).Add(float64(bytesTransferred))
 		}
-		c.pathAccessTotal.WithLabelValues(path, op).Inc()
 	}
 }
 
+// trackedStateEntryOverhead approximates the per-entry cost of a tracked
+// path or extension beyond the bytes of the key itself.
+const trackedStateEntryOverhead = 64
+
+// trackedState returns the number of tracked paths and an estimate of the
+// memory used by per-path and per-extension tracking state.
+func (c *Collector) trackedState() (paths int, bytes int64) {
+	c.pathMutex.RLock()
+	paths = len(c.trackedPaths)
+	for path := range c.trackedPaths {
+		bytes += int64(len(path)) + trackedStateEntryOverhead
+	}
+	c.pathMutex.RUnlock()
+
+	bytes += c.trackedExtensions.sizeEstimate()
+	bytes += c.trackedPathGroups.sizeEstimate()
+	bytes += c.trackedScopes.sizeEstimate()
+	bytes += c.trackedLayers.sizeEstimate()
+	if c.hotPaths != nil {
+		bytes += c.hotPaths.sizeEstimate()
+	}
+	if c.workingSet != nil {
+		bytes += c.workingSet.sizeEstimate()
+	}
+
+	return paths, bytes
+}
+
+// runCleanup periodically evicts idle tracked state until Close is called.
+func (c *Collector) runCleanup(interval time.Duration) {
+	defer c.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.cleanupIdleState(c.clock.Now())
+		}
+	}
+}
+
+// cleanupIdleState evicts tracked paths that have not been accessed within
+// TrackedStateTTL of now, dropping their metric series and freeing room for
+// new paths under MaxTrackedPaths. It returns the number of evicted paths.
+func (c *Collector) cleanupIdleState(now time.Time) int {
+	cutoff := now.Add(-c.config.TrackedStateTTL).UnixNano()
+
+	var evicted []string
+	c.pathMutex.Lock()
+	for path, lastAccess := range c.trackedPaths {
+		if lastAccess.Load() < cutoff {
+			delete(c.trackedPaths, path)
+			evicted = append(evicted, path)
+		}
+	}
+	c.pathMutex.Unlock()
+
+	for _, path := range evicted {
+		c.pathAccessTotal.DeletePartialMatch(prometheus.Labels{"path": path})
+		if c.config.EnablePathLatencyMetrics {
+			c.pathDuration.DeletePartialMatch(prometheus.Labels{"path": path})
+			c.pathBytesTotal.DeletePartialMatch(prometheus.Labels{"path": path})
+		}
+	}
+
+	if len(evicted) > 0 && c.pathLimitEngaged.CompareAndSwap(true, false) {
+		c.recordModeTransition(ModePathLimit, "released")
+	}
+
+	return len(evicted)
+}
+
+// recordExtension records extension-level metrics with cardinality protection.
+func (c *Collector) recordExtension(path string, op Op, duration time.Duration, sampled bool, bytesTransferred int64) {
+	ext := c.extensionLabel(path)
+
+	c.extensionOperationsTotal.WithLabelValues(ext, string(op)).Inc()
+	if sampled {
+		c.observeLatency(c.extensionDuration.WithLabelValues(ext, string(op)), "extension_operation_duration_seconds", duration, nil)
+	}
+	if bytesTransferred > 0 && transfersData(op) {
+		c.extensionBytesTotal.WithLabelValues(ext, string(op)).Add(float64(bytesTransferred))
+	}
+}
+
+// extensionLabel returns the extension label for path. Paths without an
+// extension are labeled "none"; extensions beyond MaxTrackedExtensions are
+// labeled "other".
+func (c *Collector) extensionLabel(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return "none"
+	}
+
+	return c.trackedExtensions.label(ext)
+}
+
+// pathGroup returns the bounded path group label for path.
+func (c *Collector) pathGroup(path string) string {
+	return c.trackedPathGroups.label(c.config.PathGroupFunc(path))
+}
+
+// recordRename records a rename between the path groups of oldpath and newpath.
+func (c *Collector) recordRename(oldpath, newpath string, err error) {
+	if c.closed.Load() {
+		return
+	}
+
+	status := "success"
+	if operationError(err) != nil {
+		status = "error"
+	}
+	c.renamesTotal.WithLabelValues(c.pathGroup(oldpath), c.pathGroup(newpath), status).Inc()
+}
+
+// DefaultPathGroup groups a path by its first element, e.g. "/staging/a/b"
+// becomes "/staging". The root and relative single-element paths are
+// returned unchanged.
+func DefaultPathGroup(path string) string {
+	path = filepath.ToSlash(path)
+	rest := strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		rest = rest[:i]
+	}
+	if strings.HasPrefix(path, "/") {
+		return "/" + rest
+	}
+	return rest
+}
+
+// boundedLabels limits the number of distinct values used for a label.
+// Values beyond the limit are reported as "other".
+type boundedLabels struct {
+	mu     sync.RWMutex
+	values map[string]bool
+	max    int
+}
+
+// newBoundedLabels creates a limiter that admits at most max distinct values.
+func newBoundedLabels(max int) *boundedLabels {
+	return &boundedLabels{values: make(map[string]bool), max: max}
+}
+
+// label returns value if it is, or can become, one of the admitted values,
+// and "other" otherwise.
+func (b *boundedLabels) label(value string) string {
+	b.mu.RLock()
+	tracked := b.values[value]
+	count := len(b.values)
+	b.mu.RUnlock()
+
+	if tracked {
+		return value
+	}
+	if count >= b.max {
+		return "other"
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.values[value] && len(b.values) >= b.max {
+		return "other"
+	}
+	b.values[value] = true
+	return value
+}
+
+// reset forgets all admitted values.
+func (b *boundedLabels) reset() {
+	b.mu.Lock()
+	b.values = make(map[string]bool)
+	b.mu.Unlock()
+}
+
+// sizeEstimate returns the approximate memory used by the admitted values.
+func (b *boundedLabels) sizeEstimate() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var bytes int64
+	for value := range b.values {
+		bytes += int64(len(value)) + trackedStateEntryOverhead
+	}
+	return bytes
+}
+
 // trackFileOpen increments the open file counter.
 func (c *Collector) trackFileOpen() {
 	current := c.openFiles.Add(1)
@@ -498,15 +2620,160 @@ func (c *Collector) trackFileClose() {
 
 // recordFileOpen records a file open operation.
 func (c *Collector) recordFileOpen(mode string) {
+	if c.closed.Load() {
+		return
+	}
+
 	c.fileOpensTotal.WithLabelValues(mode).Inc()
 }
 
+// recordOpen records a successful open of path in mode, which took
+// duration, as a directory open if dir is set and as a file open otherwise.
+func (c *Collector) recordOpen(ctx context.Context, path, mode string, duration time.Duration, dir bool) {
+	if dir {
+		c.recordOperation(ctx, OpOpenDir, path, duration, 0, nil)
+		c.recordDirOperation(OpOpen)
+		return
+	}
+	c.recordOperation(ctx, OpOpen, path, duration, 0, nil)
+	c.recordFileOpen(mode)
+}
+
 // recordFileCreate records a file creation.
 func (c *Collector) recordFileCreate() {
-	c.fileCreatesTotal.Inc()
+	if c.closed.Load() {
+		return
+	}
+
+	c.fileCreatesTotal.WithLabelValues().Inc()
+}
+
+// recordFileOverwrite records a Create that truncated an existing file of the given size.
+func (c *Collector) recordFileOverwrite(size int64) {
+	if c.closed.Load() {
+		return
+	}
+
+	c.fileOverwritesTotal.WithLabelValues().Inc()
+	if size > 0 {
+		c.overwrittenBytesTotal.WithLabelValues().Add(float64(size))
+	}
+}
+
+// recordWalk records the summary of a completed directory tree walk.
+func (c *Collector) recordWalk(walker string, duration time.Duration, w *walkStats) {
+	if c.closed.Load() {
+		return
+	}
+
+	entries := w.entries.Load()
+	c.walksTotal.WithLabelValues(walker).Inc()
+	c.walkEntriesTotal.WithLabelValues(walker).Add(float64(entries))
+	c.walkErrorsTotal.WithLabelValues(walker).Add(float64(w.errors.Load()))
+	c.walkFilesTotal.WithLabelValues(walker).Add(float64(w.files.Load()))
+	c.walkDirsTotal.WithLabelValues(walker).Add(float64(w.dirs.Load()))
+	c.walkBytesTotal.WithLabelValues(walker).Add(float64(w.bytes.Load()))
+	c.walkParallelism.WithLabelValues(walker).Observe(float64(w.maxActive.Load()))
+	if duration > 0 {
+		c.walkDuration.WithLabelValues(walker).Observe(duration.Seconds())
+		c.walkEntriesPerSecond.WithLabelValues(walker).Observe(float64(entries) / duration.Seconds())
+	}
+}
+
+// recordScope records the summary of an ended scope.
+func (c *Collector) recordScope(s ScopeSummary) {
+	if c.closed.Load() {
+		return
+	}
+
+	scope := c.trackedScopes.label(s.Name)
+	status := "success"
+	if s.Errors > 0 {
+		status = "error"
+	}
+	c.scopesTotal.WithLabelValues(scope, status).Inc()
+	c.scopeDuration.WithLabelValues(scope).Observe(s.Duration.Seconds())
+	c.scopeFiles.WithLabelValues(scope).Observe(float64(s.Files))
+	c.scopeBytesTotal.WithLabelValues(scope, "read").Add(float64(s.BytesRead))
+	c.scopeBytesTotal.WithLabelValues(scope, "write").Add(float64(s.BytesWritten))
+
+	if c.config.OnScopeEnd != nil {
+		c.config.OnScopeEnd(s)
+	}
+}
+
+// recordTransfer records a read or write of n of the requested bytes that
+// returned err, counting short transfers and ends of file. Directory reads
+// pass a requested count of zero so that only io.EOF is counted.
+func (c *Collector) recordTransfer(op Op, requested, n int, err error) {
+	if c.closed.Load() || !c.live.Load().config.EnableBandwidthMetrics || !c.measured(op) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, io.EOF):
+		c.eofTotal.WithLabelValues(string(op)).Inc()
+	case err == nil && n < requested:
+		if op == OpWrite {
+			c.shortWritesTotal.WithLabelValues(string(op)).Inc()
+		} else {
+			c.shortReadsTotal.WithLabelValues(string(op)).Inc()
+		}
+	}
+}
+
+// recordAccessPattern records a read or write that started distance bytes
+// from the end of the previous access on the same handle.
+func (c *Collector) recordAccessPattern(op Op, distance int64) {
+	if c.closed.Load() {
+		return
+	}
+
+	if distance == 0 {
+		c.accessPatternTotal.WithLabelValues(string(op), "sequential").Inc()
+		return
+	}
+
+	c.accessPatternTotal.WithLabelValues(string(op), "random").Inc()
+	direction := "forward"
+	if distance < 0 {
+		direction = "backward"
+		distance = -distance
+	}
+	c.seekDistanceBytes.WithLabelValues(string(op), direction).Observe(float64(distance))
 }
 
 // recordDirOperation records a directory operation.
-func (c *Collector) recordDirOperation(op string) {
-	c.dirOperationsTotal.WithLabelValues(op).Inc()
+func (c *Collector) recordDirOperation(op Op) {
+	if c.closed.Load() || !c.measured(op) {
+		return
+	}
+
+	c.dirOperationsTotal.WithLabelValues(string(op)).Inc()
+}
+
+// recordPathKind records whether the path passed to op was absolute.
+func (c *Collector) recordPathKind(op Op, absolute bool) {
+	if c.closed.Load() {
+		return
+	}
+
+	kind := "relative"
+	if absolute {
+		kind = "absolute"
+	}
+	c.pathKindTotal.WithLabelValues(string(op), kind).Inc()
+}
+
+// recordReaddirEntries records a directory read that returned n entries
+// whose names total nameBytes bytes. Reads that fail without returning
+// entries, such as the io.EOF ending a paged listing, are not observed.
+func (c *Collector) recordReaddirEntries(n int, nameBytes int64, err error) {
+	if c.closed.Load() || (n == 0 && err != nil) || !c.measured(OpReaddir) {
+		return
+	}
+
+	c.readdirEntries.WithLabelValues().Observe(float64(n))
+	c.readdirEntriesTotal.WithLabelValues().Add(float64(n))
+	c.readdirNameBytes.WithLabelValues().Observe(float64(nameBytes))
 }
@@ -1,6 +1,7 @@
 package metricsfs
 
 import (
+	"context"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -17,6 +18,12 @@ type Config struct {
 	// ConstLabels are labels that will be applied to all metrics
 	ConstLabels prometheus.Labels
 
+	// ConstLabelsExclude lists, by metric name without namespace or
+	// subsystem (e.g. "bytes_read_total"), the ConstLabels that are not
+	// attached to that metric. Use it to keep high-frequency metrics small
+	// in backends that store labels per sample (default: nil)
+	ConstLabelsExclude map[string][]string
+
 	// EnableLatencyMetrics controls whether operation latency histograms are collected
 	EnableLatencyMetrics bool
 
@@ -28,31 +35,382 @@ type Config struct {
 	EnablePathMetrics bool
 
 	// LatencyBuckets defines histogram buckets for operation latency (in seconds)
-	// Default: [0.001, 0.01, 0.1, 1.0, 10.0]
+	// Default: [0.001, 0.01, 0.1, 1.0, 10.0]. See BucketsSSD and the other
+	// presets, and WithBucketPreset
 	LatencyBuckets []float64
 
 	// SizeBuckets defines histogram buckets for data transfer sizes (in bytes)
 	// Default: prometheus.ExponentialBuckets(1024, 2, 10)
 	SizeBuckets []float64
 
+	// EnableNativeHistograms additionally records the latency and size
+	// histograms as Prometheus native histograms, which are sparse and high
+	// resolution without tuning bucket lists. The classic buckets are kept
+	// for scrapers without native histogram support. Requires Prometheus
+	// 2.40 or later scraping with the protobuf format (default: false)
+	EnableNativeHistograms bool
+
+	// NativeHistogramBucketFactor is the maximum ratio between the upper
+	// bounds of consecutive native histogram buckets.
+	// Only used when EnableNativeHistograms is true (default: 1.1)
+	NativeHistogramBucketFactor float64
+
+	// NativeHistogramMaxBuckets limits the number of buckets of each native
+	// histogram; resolution is reduced when it is exceeded.
+	// Only used when EnableNativeHistograms is true (default: 160)
+	NativeHistogramMaxBuckets uint32
+
 	// MaxTrackedPaths is the maximum number of unique paths to track
 	// Only used when EnablePathMetrics is true (default: 100)
 	MaxTrackedPaths int
 
+	// EnablePathLatencyMetrics adds per-path latency histograms and byte
+	// counters to the path access counts, so hot paths can be compared by
+	// latency as well as frequency.
+	// Only used when EnablePathMetrics is true (default: false)
+	EnablePathLatencyMetrics bool
+
+	// ResolveRelativePaths records operations on relative paths under the
+	// path resolved against the wrapped filesystem's working directory, so
+	// that relative and absolute uses of the same file share path labels and
+	// callbacks see the same path. The working directory is read with Getwd
+	// and cached until Chdir (default: false)
+	ResolveRelativePaths bool
+
+	// GroupPathMetrics labels path-level metrics with PathGroupFunc(path)
+	// instead of the full path. Groups count towards MaxTrackedPaths.
+	// Only used when EnablePathMetrics is true (default: false)
+	GroupPathMetrics bool
+
 	// PathSampleRate controls sampling rate for path metrics (0.0 to 1.0)
 	// Only used when EnablePathMetrics is true (default: 0.01)
 	PathSampleRate float64
 
+	// MaxOperationDuration is the longest plausible operation duration.
+	// Longer durations, like negative ones, are treated as clock anomalies:
+	// they are clamped and counted in duration_anomalies_total instead of
+	// skewing the latency histograms. Zero disables the cap
+	// (default: 1h with DefaultConfig)
+	MaxOperationDuration time.Duration
+
+	// SLOThresholds are latency thresholds by operation name, e.g. "read".
+	// Each operation with a threshold is counted in slo_operations_total as
+	// under or over it, for alerting on the share of slow operations
+	// without relying on histogram buckets (default: nil)
+	SLOThresholds map[string]time.Duration
+
+	// SlowOperationThreshold keeps the most recent operations taking at
+	// least this long, for Collector.SlowOperations and the debug
+	// dashboard. Zero disables it (default: 0)
+	SlowOperationThreshold time.Duration
+
+	// EnableApdex also counts the operations with an SLOThresholds
+	// threshold T in apdex_operations_total, by Apdex zone: satisfied
+	// within T, tolerating within 4T and frustrated beyond or failed.
+	// The Apdex score is (satisfied + tolerating/2) / total
+	// (default: false)
+	EnableApdex bool
+
+	// EnableLatencyExtremes exposes the shortest and longest duration of
+	// each operation since the previous scrape, and the duration of the most
+	// recent one, as gauges and in Stats, for readers without histogram
+	// math (default: false)
+	EnableLatencyExtremes bool
+
+	// LatencySampleRate times only 1 in LatencySampleRate reads and writes,
+	// chosen at random, sparing the clock reads and histogram observations
+	// of the others. Operation counts and byte totals stay exact; latency
+	// histograms, audit entries and OnOperation see the duration of sampled
+	// operations only, the others reporting 0. Ignored with
+	// EnableCPUMetrics and during profiling windows (default: 0, time all)
+	LatencySampleRate int
+
+	// Clock times operations, health checks, scopes and profiling windows,
+	// and tells the access times of working set and idle state tracking and
+	// the timestamps of stats, snapshots, audit entries, slow operations,
+	// mode transitions and open files (default: the system clock). Inject a
+	// fake clock to make them deterministic in tests
+	Clock Clock
+
+	// ClockResolution, when set and Clock is not, times operations with a
+	// coarse clock cached by a background ticker every ClockResolution,
+	// sparing a clock read on each operation start and finish. Durations
+	// are then multiples of ClockResolution, often 0 for fast operations
+	ClockResolution time.Duration
+
+	// CapacityPath is a path on the host filesystem whose size and free
+	// space are exported as capacity_bytes and free_bytes on each scrape.
+	// It is an OS path, not a path of the wrapped filesystem. Capacity is
+	// only available on Linux, macOS, FreeBSD, DragonFly BSD and Windows
+	// (default: "", disabled)
+	CapacityPath string
+
+	// TrackedStateTTL is how long a tracked path may stay idle before it is
+	// evicted together with its metric series. Zero disables eviction
+	// (default: 0)
+	TrackedStateTTL time.Duration
+
+	// CleanupInterval is how often idle tracked state is evicted.
+	// Only used when TrackedStateTTL is set (default: 1m)
+	CleanupInterval time.Duration
+
+	// EnableExtensionMetrics controls whether operation counts, bytes and latency
+	// are collected per file extension (e.g. ".jpg", ".parquet")
+	EnableExtensionMetrics bool
+
+	// MaxTrackedExtensions is the maximum number of distinct extensions to track.
+	// Extensions beyond the limit are recorded as "other".
+	// Only used when EnableExtensionMetrics is true (default: 50)
+	MaxTrackedExtensions int
+
+	// EnableRenameMetrics controls whether renames are counted by the path
+	// groups of their source and destination
+	EnableRenameMetrics bool
+
+	// PathGroupFunc maps a path to a low-cardinality group such as a top-level
+	// directory (default: DefaultPathGroup)
+	PathGroupFunc func(path string) string
+
+	// MaxPathGroups is the maximum number of distinct path groups to track.
+	// Groups beyond the limit are recorded as "other" (default: 50)
+	MaxPathGroups int
+
+	// MaxFileSizes are maximum file sizes in bytes by path group, as
+	// returned by PathGroupFunc, with "*" applying to groups not listed.
+	// Writes and truncations that would grow a file beyond its limit fail
+	// with ErrFileTooLarge and are counted in
+	// size_limit_violations_total (default: nil)
+	MaxFileSizes map[string]int64
+
+	// MaxTrackedScopes is the maximum number of distinct scope names used as
+	// the scope label of scope metrics. Names beyond the limit are recorded
+	// as "other" (default: 50). See MetricsFS.BeginScope
+	MaxTrackedScopes int
+
+	// EnableCPUMetrics measures the CPU time the calling goroutine spends
+	// inside each operation, alongside its wall time, to tell CPU-bound
+	// backends (compression, encryption) from I/O-bound ones. The goroutine
+	// is locked to its OS thread for the duration of each operation. Only
+	// supported on Linux; elsewhere no CPU metrics are recorded
+	// (default: false)
+	EnableCPUMetrics bool
+
+	// TraceRegions wraps each operation in a runtime/trace region named
+	// "fs.<op>", e.g. "fs.read", while the execution tracer is running, so
+	// that file I/O shows on the same timeline as goroutine scheduling in
+	// go tool trace. Regions belong to the calling goroutine, not to trace
+	// tasks. See ChromeTraceWriter for traces without the execution tracer
+	// (default: false)
+	TraceRegions bool
+
+	// EnableAccessPatternMetrics classifies reads and writes on open files as
+	// sequential or random by following each handle's offsets, and records
+	// the distance of random accesses from the previous one (default: false)
+	EnableAccessPatternMetrics bool
+
+	// EnableHotPaths tracks approximate access counts for all paths in
+	// bounded memory and exports the HotPathsTopK most accessed ones.
+	// See Collector.HotPaths (default: false)
+	EnableHotPaths bool
+
+	// HotPathsTopK is the number of most accessed paths exported.
+	// Only used when EnableHotPaths is true (default: 10)
+	HotPathsTopK int
+
+	// EnableLayerMetrics records reads per layer for files that implement
+	// LayeredFile, such as those of overlay filesystems, to tell whether the
+	// upper or lower layer served them (default: false)
+	EnableLayerMetrics bool
+
+	// EnableWorkingSetMetrics estimates the number of distinct paths accessed
+	// within WorkingSetWindow in bounded memory, e.g. to size a cache layered
+	// above the filesystem. See Collector.WorkingSetSize (default: false)
+	EnableWorkingSetMetrics bool
+
+	// EnableAllocationMetrics periodically measures the heap allocations the
+	// instrumentation makes per operation, to catch regressions of the
+	// allocation-free hot path. See Collector.OperationAllocations
+	// (default: false)
+	EnableAllocationMetrics bool
+
+	// AllocationCalibrationInterval is how often allocations are measured.
+	// Each measurement briefly stops the world to read memory statistics.
+	// Only used when EnableAllocationMetrics is true (default: 10m)
+	AllocationCalibrationInterval time.Duration
+
+	// WorkingSetWindow is the sliding window of the working set estimate.
+	// Only used when EnableWorkingSetMetrics is true (default: 5m)
+	WorkingSetWindow time.Duration
+
+	// EnableOverwriteDetection controls whether Create checks for an existing
+	// file before truncating it, counting overwrites and the bytes destroyed.
+	// This costs an extra Lstat per Create (default: false)
+	EnableOverwriteDetection bool
+
+	// EnableHandleKindDetection controls whether opened handles are classified
+	// as files or directories, so that directory handles are recorded as the
+	// opendir and closedir operations and in dir_operations_total instead of
+	// as open, close and file_opens_total. Handles are classified by a Stat on
+	// open; when it fails, the open is recorded once Readdir or Stat on the
+	// handle tells its kind, or as a file open when it is closed
+	// (default: false)
+	EnableHandleKindDetection bool
+
+	// EnableHandleIDs assigns each opened file handle a short random ID,
+	// carried by the operations on the handle from its open to its Close,
+	// so that all activity of one handle can be correlated when the same
+	// path is open several times. The ID appears in audit entries,
+	// Operation.HandleID, ProfileReport handles and HandleID(ctx) in
+	// interceptors (default: false)
+	EnableHandleIDs bool
+
+	// EnableLeakDetection tracks the files opened through a MetricsFS until
+	// they are closed, so that MetricsFS.Close can report those left open:
+	// they are counted in files_leaked_total and Close returns an error
+	// wrapping ErrFilesLeaked that names them (default: false)
+	EnableLeakDetection bool
+
+	// CloseLeakedFiles makes MetricsFS.Close also close the files left
+	// open. Only used when EnableLeakDetection is true (default: false)
+	CloseLeakedFiles bool
+
+	// EnableShardedCounters spreads the operation, error, in-flight and byte
+	// counters over per-processor shards of atomic counters, drained into the
+	// exported metrics on Collect, Stats and health checks, so that very hot
+	// workloads on many cores do not contend on shared counters. Histograms
+	// are lock-free already and are unaffected. Operations with dynamic
+	// labels (EnableInstanceLabel, EnableRootLabel, ContextLabels) and custom
+	// operations use the regular counters (default: false)
+	EnableShardedCounters bool
+
+	// Instance identifies the wrapped filesystem in all of its telemetry,
+	// so that records from different subsystems can be cross-referenced:
+	// the fs_instance const label of every metric, and the instance of
+	// audit entries, Operation, Stats and ProfileReport. When empty, an ID
+	// is generated for the records other than metrics, whose series would
+	// otherwise change on every restart. See Collector.Instance
+	Instance string
+
+	// EnableInstanceLabel adds an fs_instance label to operation-level metrics
+	// (operations_total, operation_duration_seconds, errors_total,
+	// bytes_read_total and bytes_written_total) so that several wrappers can
+	// share one Collector. See NewWithCollector. Operations of wrappers
+	// without an instance are labeled with Instance.
+	EnableInstanceLabel bool
+
+	// EnableRootLabel adds a root label to operation-level metrics holding
+	// the directory of the sub-filesystem, returned by MetricsFS.Sub, that
+	// each operation was issued through, or "" for operations on the
+	// filesystem itself. Each Sub directory adds a set of series
+	// (default: false)
+	EnableRootLabel bool
+
+	// ContextLabelNames are the names of per-request labels added to
+	// operation-level metrics. Their values
+	// are resolved for each operation with ContextLabels.
+	ContextLabelNames []string
+
+	// ContextLabels extracts per-request label values (e.g. tenant_id or
+	// job_name) from the context an operation was issued with. Only labels
+	// listed in ContextLabelNames are used; missing ones are recorded as "".
+	// See MetricsFS.WithContext.
+	ContextLabels func(ctx context.Context) prometheus.Labels
+
+	// Health configures the conditions evaluated by MetricsFS.Healthy
+	Health HealthConfig
+
+	// OperationFilter selects the operations that are measured
+	// (default: all)
+	OperationFilter OperationFilter
+
+	// IncludePaths, when set, limits the measured operations to those on
+	// paths matching one of these path.Match patterns, or lying under a
+	// directory that does, e.g. "/data" or "/home/*/cache" (default: all)
+	IncludePaths []string
+
+	// ExcludePaths lists path.Match patterns of paths not measured, even if
+	// included, together with the paths under them, e.g. "/tmp" or "/proc".
+	// Operations on filtered out paths, and on files opened there, go
+	// straight to the wrapped filesystem without being recorded. Relative
+	// paths are matched as given, or resolved with ResolveRelativePaths
+	ExcludePaths []string
+
+	// Audit configures the audit log of operations (default: disabled)
+	Audit AuditConfig
+
+	// Verify configures the verification of files read in full against a
+	// manifest of checksums (default: disabled)
+	Verify VerifyConfig
+
+	// Trash moves entries removed with Remove and RemoveAll into a trash
+	// directory instead of deleting them (default: disabled)
+	Trash TrashConfig
+
 	// OnOperation is called after each filesystem operation
 	OnOperation func(op Operation)
 
-	// OnError is called when an operation encounters an error
+	// AmbientSpanEvents records each operation as an event on the
+	// OpenTelemetry span of its context, if any, such as one passed with
+	// MetricsFS.WithContext. Events are named after the operation and carry
+	// fs.bytes, fs.duration_us and, for failures, fs.error, giving request
+	// traces filesystem visibility without a span per operation
+	// (default: false)
+	AmbientSpanEvents bool
+
+	// Interceptors wrap each filesystem call of a MetricsFS and its files,
+	// the first outermost. Unlike OnOperation, they run around the call and
+	// can fail it. See Interceptor
+	Interceptors []Interceptor
+
+	// CallbackQueueSize, when set, runs OnOperation, OnError and
+	// OnChecksumMismatch asynchronously: they are queued, up to this many,
+	// and run by CallbackWorkers goroutines instead of on the operation's
+	// goroutine.
+	// Callbacks that do not fit in the queue are dropped and counted in
+	// callbacks_dropped_total. See Collector.Flush (default: 0, synchronous)
+	CallbackQueueSize int
+
+	// CallbackWorkers is the number of goroutines running queued callbacks.
+	// With more than one, callbacks may run concurrently and out of order.
+	// Only used when CallbackQueueSize is set (default: 1)
+	CallbackWorkers int
+
+	// OnError is called when an operation encounters an error, with the
+	// name of the operation, e.g. string(OpOpen)
 	OnError func(operation string, err error)
+
+	// OnHistogramOverflow is called when an observation exceeds the largest
+	// bucket of a latency or size histogram, with the histogram name (e.g.
+	// "operation_duration_seconds") and the observed value
+	OnHistogramOverflow func(metric string, value float64)
+
+	// OnModeTransition is called when the instrumentation changes operating
+	// mode, such as when path tracking reaches MaxTrackedPaths.
+	// See Collector.ModeTransitions
+	OnModeTransition func(t ModeTransition)
+
+	// OnDegraded is called with the health status when the set of failed
+	// health conditions changes, including when the filesystem recovers.
+	// See MetricsFS.CheckHealth
+	OnDegraded func(status HealthStatus)
+
+	// OnScopeEnd is called with the summary of each scope when it ends.
+	// See MetricsFS.BeginScope
+	OnScopeEnd func(s ScopeSummary)
+
+	// OnChecksumMismatch is called when a file read in full does not match
+	// its checksum in the Verify manifest. See VerifyConfig
+	OnChecksumMismatch func(m ChecksumMismatch)
+
+	// OnClose is called with a final snapshot of the stats when a MetricsFS
+	// is closed. See MetricsFS.Close
+	OnClose func(final Stats)
 }
 
 // Operation represents a completed filesystem operation with metrics.
 type Operation struct {
-	// Name of the operation (e.g., "read", "write", "stat")
+	// Name of the operation (e.g., "read", "write", "stat"); compare it
+	// with the Op constants as string(OpRead)
 	Name string
 
 	// Duration of the operation
@@ -66,21 +424,42 @@ type Operation struct {
 
 	// Error that occurred during the operation, if any
 	Error error
+
+	// HandleID is the ID of the file handle the operation was issued on,
+	// with EnableHandleIDs
+	HandleID string
+
+	// Instance identifies the filesystem the operation was issued on. See
+	// Collector.Instance
+	Instance string
 }
 
 // DefaultConfig returns a Config with default values.
 func DefaultConfig() Config {
 	return Config{
-		Namespace:              "fs",
-		Subsystem:              "",
-		ConstLabels:            nil,
-		EnableLatencyMetrics:   true,
-		EnableBandwidthMetrics: true,
-		EnablePathMetrics:      false,
-		LatencyBuckets:         []float64{0.001, 0.01, 0.1, 1.0, 10.0},
-		SizeBuckets:            prometheus.ExponentialBuckets(1024, 2, 10),
-		MaxTrackedPaths:        100,
-		PathSampleRate:         0.01,
+		Namespace:                     "fs",
+		Subsystem:                     "",
+		ConstLabels:                   nil,
+		EnableLatencyMetrics:          true,
+		EnableBandwidthMetrics:        true,
+		EnablePathMetrics:             false,
+		LatencyBuckets:                []float64{0.001, 0.01, 0.1, 1.0, 10.0},
+		SizeBuckets:                   prometheus.ExponentialBuckets(1024, 2, 10),
+		MaxTrackedPaths:               100,
+		PathSampleRate:                0.01,
+		EnableExtensionMetrics:        false,
+		MaxTrackedExtensions:          50,
+		CleanupInterval:               time.Minute,
+		PathGroupFunc:                 DefaultPathGroup,
+		MaxPathGroups:                 50,
+		MaxTrackedScopes:              50,
+		HotPathsTopK:                  10,
+		WorkingSetWindow:              5 * time.Minute,
+		AllocationCalibrationInterval: 10 * time.Minute,
+		CallbackWorkers:               1,
+		NativeHistogramBucketFactor:   1.1,
+		NativeHistogramMaxBuckets:     160,
+		MaxOperationDuration:          time.Hour,
 	}
 }
 
@@ -101,4 +480,418 @@ func (c *Config) applyDefaults() {
 	if c.PathSampleRate == 0 {
 		c.PathSampleRate = 0.01
 	}
+	if c.MaxTrackedExtensions == 0 {
+		c.MaxTrackedExtensions = 50
+	}
+	if c.CleanupInterval == 0 {
+		c.CleanupInterval = time.Minute
+	}
+	if c.PathGroupFunc == nil {
+		c.PathGroupFunc = DefaultPathGroup
+	}
+	if c.MaxPathGroups == 0 {
+		c.MaxPathGroups = 50
+	}
+	if c.MaxTrackedScopes == 0 {
+		c.MaxTrackedScopes = 50
+	}
+	if c.HotPathsTopK == 0 {
+		c.HotPathsTopK = 10
+	}
+	if c.CallbackWorkers == 0 {
+		c.CallbackWorkers = 1
+	}
+	if c.WorkingSetWindow == 0 {
+		c.WorkingSetWindow = 5 * time.Minute
+	}
+	if c.AllocationCalibrationInterval == 0 {
+		c.AllocationCalibrationInterval = 10 * time.Minute
+	}
+	if c.NativeHistogramBucketFactor == 0 {
+		c.NativeHistogramBucketFactor = 1.1
+	}
+	if c.NativeHistogramMaxBuckets == 0 {
+		c.NativeHistogramMaxBuckets = 160
+	}
+}
+
+// nativeHistogram returns opts configured for native histograms when
+// EnableNativeHistograms is set, and unchanged otherwise.
+func (c Config) nativeHistogram(opts prometheus.HistogramOpts) prometheus.HistogramOpts {
+	if !c.EnableNativeHistograms {
+		return opts
+	}
+	opts.NativeHistogramBucketFactor = c.NativeHistogramBucketFactor
+	opts.NativeHistogramMaxBucketNumber = c.NativeHistogramMaxBuckets
+	opts.NativeHistogramMinResetDuration = time.Hour
+	return opts
+}
+
+// instanceLabeledMetrics are the metrics given the fs_instance label of each
+// operation by EnableInstanceLabel.
+var instanceLabeledMetrics = map[string]bool{
+	"operations_total":           true,
+	"operation_duration_seconds": true,
+	"errors_total":               true,
+	"bytes_read_total":           true,
+	"bytes_written_total":        true,
+}
+
+// constLabelsFor returns the ConstLabels attached to the named metric,
+// including the fs_instance label of Instance.
+func (c Config) constLabelsFor(name string) prometheus.Labels {
+	exclude := c.ConstLabelsExclude[name]
+	instance := c.Instance != "" && !(c.EnableInstanceLabel && instanceLabeledMetrics[name])
+	if len(exclude) == 0 && !instance {
+		return c.ConstLabels
+	}
+
+	labels := make(prometheus.Labels, len(c.ConstLabels)+1)
+	for k, v := range c.ConstLabels {
+		labels[k] = v
+	}
+	if instance {
+		labels[instanceLabel] = c.Instance
+	}
+	for _, k := range exclude {
+		delete(labels, k)
+	}
+	return labels
+}
+
+// Merge returns a copy of c with the non-zero fields of override applied on top.
+//
+// Scalar and slice fields in override replace those in c when they are
+// non-zero. Boolean fields can only be enabled by override, never disabled.
+// ConstLabels and ConstLabelsExclude are merged key by key, with override
+// taking precedence.
+// Callbacks are chained: the callback from c runs first, then the one from
+// override. ContextLabels extractors are chained by merging their results.
+func (c Config) Merge(override Config) Config {
+	merged := c
+
+	if override.Namespace != "" {
+		merged.Namespace = override.Namespace
+	}
+	if override.Subsystem != "" {
+		merged.Subsystem = override.Subsystem
+	}
+	if len(override.ConstLabels) > 0 {
+		labels := make(prometheus.Labels, len(c.ConstLabels)+len(override.ConstLabels))
+		for k, v := range c.ConstLabels {
+			labels[k] = v
+		}
+		for k, v := range override.ConstLabels {
+			labels[k] = v
+		}
+		merged.ConstLabels = labels
+	}
+	if len(override.ConstLabelsExclude) > 0 {
+		exclude := make(map[string][]string, len(c.ConstLabelsExclude)+len(override.ConstLabelsExclude))
+		for k, v := range c.ConstLabelsExclude {
+			exclude[k] = v
+		}
+		for k, v := range override.ConstLabelsExclude {
+			exclude[k] = v
+		}
+		merged.ConstLabelsExclude = exclude
+	}
+
+	if len(override.SLOThresholds) > 0 {
+		thresholds := make(map[string]time.Duration, len(c.SLOThresholds)+len(override.SLOThresholds))
+		for op, threshold := range c.SLOThresholds {
+			thresholds[op] = threshold
+		}
+		for op, threshold := range override.SLOThresholds {
+			thresholds[op] = threshold
+		}
+		merged.SLOThresholds = thresholds
+	}
+
+	if len(override.MaxFileSizes) > 0 {
+		sizes := make(map[string]int64, len(c.MaxFileSizes)+len(override.MaxFileSizes))
+		for group, size := range c.MaxFileSizes {
+			sizes[group] = size
+		}
+		for group, size := range override.MaxFileSizes {
+			sizes[group] = size
+		}
+		merged.MaxFileSizes = sizes
+	}
+
+	merged.EnableLatencyMetrics = c.EnableLatencyMetrics || override.EnableLatencyMetrics
+	merged.EnableApdex = c.EnableApdex || override.EnableApdex
+	merged.EnableLatencyExtremes = c.EnableLatencyExtremes || override.EnableLatencyExtremes
+	merged.AmbientSpanEvents = c.AmbientSpanEvents || override.AmbientSpanEvents
+	merged.EnableBandwidthMetrics = c.EnableBandwidthMetrics || override.EnableBandwidthMetrics
+	merged.EnablePathMetrics = c.EnablePathMetrics || override.EnablePathMetrics
+	merged.EnablePathLatencyMetrics = c.EnablePathLatencyMetrics || override.EnablePathLatencyMetrics
+	merged.GroupPathMetrics = c.GroupPathMetrics || override.GroupPathMetrics
+	merged.ResolveRelativePaths = c.ResolveRelativePaths || override.ResolveRelativePaths
+	merged.EnableExtensionMetrics = c.EnableExtensionMetrics || override.EnableExtensionMetrics
+	merged.EnableOverwriteDetection = c.EnableOverwriteDetection || override.EnableOverwriteDetection
+	merged.EnableHandleKindDetection = c.EnableHandleKindDetection || override.EnableHandleKindDetection
+	merged.EnableHandleIDs = c.EnableHandleIDs || override.EnableHandleIDs
+	merged.EnableLeakDetection = c.EnableLeakDetection || override.EnableLeakDetection
+	merged.CloseLeakedFiles = c.CloseLeakedFiles || override.CloseLeakedFiles
+	merged.EnableShardedCounters = c.EnableShardedCounters || override.EnableShardedCounters
+	merged.EnableInstanceLabel = c.EnableInstanceLabel || override.EnableInstanceLabel
+	merged.EnableRootLabel = c.EnableRootLabel || override.EnableRootLabel
+	merged.EnableRenameMetrics = c.EnableRenameMetrics || override.EnableRenameMetrics
+	merged.EnableHotPaths = c.EnableHotPaths || override.EnableHotPaths
+	merged.EnableWorkingSetMetrics = c.EnableWorkingSetMetrics || override.EnableWorkingSetMetrics
+	merged.EnableLayerMetrics = c.EnableLayerMetrics || override.EnableLayerMetrics
+	merged.EnableAllocationMetrics = c.EnableAllocationMetrics || override.EnableAllocationMetrics
+	merged.EnableAccessPatternMetrics = c.EnableAccessPatternMetrics || override.EnableAccessPatternMetrics
+	merged.EnableCPUMetrics = c.EnableCPUMetrics || override.EnableCPUMetrics
+	merged.TraceRegions = c.TraceRegions || override.TraceRegions
+	merged.EnableNativeHistograms = c.EnableNativeHistograms || override.EnableNativeHistograms
+
+	if override.LatencyBuckets != nil {
+		merged.LatencyBuckets = override.LatencyBuckets
+	}
+	if override.SizeBuckets != nil {
+		merged.SizeBuckets = override.SizeBuckets
+	}
+	if override.MaxTrackedPaths != 0 {
+		merged.MaxTrackedPaths = override.MaxTrackedPaths
+	}
+	if override.PathSampleRate != 0 {
+		merged.PathSampleRate = override.PathSampleRate
+	}
+	if override.TrackedStateTTL != 0 {
+		merged.TrackedStateTTL = override.TrackedStateTTL
+	}
+	if override.CleanupInterval != 0 {
+		merged.CleanupInterval = override.CleanupInterval
+	}
+	if override.PathGroupFunc != nil {
+		merged.PathGroupFunc = override.PathGroupFunc
+	}
+	if override.MaxPathGroups != 0 {
+		merged.MaxPathGroups = override.MaxPathGroups
+	}
+	if override.MaxTrackedScopes != 0 {
+		merged.MaxTrackedScopes = override.MaxTrackedScopes
+	}
+	if override.MaxTrackedExtensions != 0 {
+		merged.MaxTrackedExtensions = override.MaxTrackedExtensions
+	}
+	if override.CapacityPath != "" {
+		merged.CapacityPath = override.CapacityPath
+	}
+	if override.SlowOperationThreshold != 0 {
+		merged.SlowOperationThreshold = override.SlowOperationThreshold
+	}
+	if override.MaxOperationDuration != 0 {
+		merged.MaxOperationDuration = override.MaxOperationDuration
+	}
+	if override.LatencySampleRate != 0 {
+		merged.LatencySampleRate = override.LatencySampleRate
+	}
+	if override.Instance != "" {
+		merged.Instance = override.Instance
+	}
+	if override.Clock != nil {
+		merged.Clock = override.Clock
+	}
+	if override.ClockResolution != 0 {
+		merged.ClockResolution = override.ClockResolution
+	}
+	if override.NativeHistogramBucketFactor != 0 {
+		merged.NativeHistogramBucketFactor = override.NativeHistogramBucketFactor
+	}
+	if override.NativeHistogramMaxBuckets != 0 {
+		merged.NativeHistogramMaxBuckets = override.NativeHistogramMaxBuckets
+	}
+	if override.HotPathsTopK != 0 {
+		merged.HotPathsTopK = override.HotPathsTopK
+	}
+	if override.WorkingSetWindow != 0 {
+		merged.WorkingSetWindow = override.WorkingSetWindow
+	}
+	if override.AllocationCalibrationInterval != 0 {
+		merged.AllocationCalibrationInterval = override.AllocationCalibrationInterval
+	}
+	if override.CallbackQueueSize != 0 {
+		merged.CallbackQueueSize = override.CallbackQueueSize
+	}
+	if override.CallbackWorkers != 0 {
+		merged.CallbackWorkers = override.CallbackWorkers
+	}
+	if override.ContextLabelNames != nil {
+		merged.ContextLabelNames = override.ContextLabelNames
+	}
+	if override.Health != (HealthConfig{}) {
+		merged.Health = override.Health
+	}
+	if len(override.OperationFilter.Include) > 0 || len(override.OperationFilter.Exclude) > 0 {
+		merged.OperationFilter = override.OperationFilter
+	}
+	if override.IncludePaths != nil {
+		merged.IncludePaths = override.IncludePaths
+	}
+	if override.ExcludePaths != nil {
+		merged.ExcludePaths = override.ExcludePaths
+	}
+	if override.Audit.Writer != nil {
+		merged.Audit = override.Audit
+	}
+	if override.Verify.Manifest != nil {
+		merged.Verify = override.Verify
+	}
+	if override.Trash.Dir != "" {
+		merged.Trash = override.Trash
+	}
+
+	if len(override.Interceptors) > 0 {
+		// Base interceptors stay outermost
+		merged.Interceptors = append(append([]Interceptor(nil), c.Interceptors...), override.Interceptors...)
+	}
+
+	merged.ContextLabels = chainContextLabels(c.ContextLabels, override.ContextLabels)
+	merged.OnOperation = chainOnOperation(c.OnOperation, override.OnOperation)
+	merged.OnError = chainOnError(c.OnError, override.OnError)
+	merged.OnHistogramOverflow = chainOnHistogramOverflow(c.OnHistogramOverflow, override.OnHistogramOverflow)
+	merged.OnModeTransition = chainOnModeTransition(c.OnModeTransition, override.OnModeTransition)
+	merged.OnDegraded = chainOnDegraded(c.OnDegraded, override.OnDegraded)
+	merged.OnScopeEnd = chainOnScopeEnd(c.OnScopeEnd, override.OnScopeEnd)
+	merged.OnChecksumMismatch = chainOnChecksumMismatch(c.OnChecksumMismatch, override.OnChecksumMismatch)
+	merged.OnClose = chainOnClose(c.OnClose, override.OnClose)
+
+	return merged
+}
+
+// chainContextLabels returns an extractor that merges the labels of first and
+// second, with second taking precedence.
+func chainContextLabels(first, second func(ctx context.Context) prometheus.Labels) func(ctx context.Context) prometheus.Labels {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(ctx context.Context) prometheus.Labels {
+		labels := prometheus.Labels{}
+		for k, v := range first(ctx) {
+			labels[k] = v
+		}
+		for k, v := range second(ctx) {
+			labels[k] = v
+		}
+		return labels
+	}
+}
+
+// chainOnOperation returns a callback that calls first and then second.
+func chainOnOperation(first, second func(op Operation)) func(op Operation) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(op Operation) {
+		first(op)
+		second(op)
+	}
+}
+
+// chainOnError returns a callback that calls first and then second.
+func chainOnError(first, second func(operation string, err error)) func(operation string, err error) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(operation string, err error) {
+		first(operation, err)
+		second(operation, err)
+	}
+}
+
+// chainOnHistogramOverflow returns a callback that calls first and then second.
+func chainOnHistogramOverflow(first, second func(metric string, value float64)) func(metric string, value float64) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(metric string, value float64) {
+		first(metric, value)
+		second(metric, value)
+	}
+}
+
+// chainOnModeTransition returns a callback that calls first and then second.
+func chainOnModeTransition(first, second func(t ModeTransition)) func(t ModeTransition) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(t ModeTransition) {
+		first(t)
+		second(t)
+	}
+}
+
+// chainOnDegraded returns a callback that calls first and then second.
+func chainOnDegraded(first, second func(status HealthStatus)) func(status HealthStatus) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(status HealthStatus) {
+		first(status)
+		second(status)
+	}
+}
+
+// chainOnScopeEnd returns a callback that calls first and then second.
+func chainOnScopeEnd(first, second func(s ScopeSummary)) func(s ScopeSummary) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(s ScopeSummary) {
+		first(s)
+		second(s)
+	}
+}
+
+// chainOnChecksumMismatch returns a callback that calls first and then
+// second.
+func chainOnChecksumMismatch(first, second func(m ChecksumMismatch)) func(m ChecksumMismatch) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(m ChecksumMismatch) {
+		first(m)
+		second(m)
+	}
+}
+
+// chainOnClose returns a callback that calls first and then second.
+func chainOnClose(first, second func(final Stats)) func(final Stats) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(final Stats) {
+		first(final)
+		second(final)
+	}
 }
@@ -0,0 +1,150 @@
+package metricsfs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/absfs/absfs"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// TestInterfaceCompliance checks that each exported wrapper implements the
+// absfs interfaces it is intended to, as the compile-time assertions do for
+// the builds that include them.
+func TestInterfaceCompliance(t *testing.T) {
+	otelFS, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:  newRecordingMeterProvider(),
+		TracerProvider: tracenoop.NewTracerProvider(),
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+	otelFile, err := otelFS.Create("/test.txt")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	fileSystem := reflect.TypeOf((*absfs.SymlinkFileSystem)(nil)).Elem()
+	file := reflect.TypeOf((*absfs.File)(nil)).Elem()
+	tests := []struct {
+		wrapper any
+		iface   reflect.Type
+	}{
+		{New(newMockFS()), fileSystem},
+		{otelFS, fileSystem},
+		{&MetricsFile{}, file},
+		{otelFile, file},
+	}
+
+	for _, tt := range tests {
+		typ := reflect.TypeOf(tt.wrapper)
+		if !typ.Implements(tt.iface) {
+			t.Errorf("%v does not implement %v", typ, tt.iface)
+		}
+	}
+}
+
+// uninstrumentedMethods lists the absfs methods that do not touch the
+// underlying filesystem and so record no operation.
+var uninstrumentedMethods = map[string]bool{
+	"Separator":     true,
+	"ListSeparator": true,
+	"TempDir":       true,
+	"Name":          true,
+}
+
+// callWithZeroArgs calls the method named name on v with zero-value
+// arguments. Panics are left to fail the test.
+func callWithZeroArgs(v any, name string) {
+	method := reflect.ValueOf(v).MethodByName(name)
+	args := make([]reflect.Value, method.Type().NumIn())
+	for i := range args {
+		args[i] = reflect.Zero(method.Type().In(i))
+	}
+	method.Call(args)
+}
+
+// interfaceMethods returns the method names of the interface type I.
+func interfaceMethods[I any]() []string {
+	typ := reflect.TypeOf((*I)(nil)).Elem()
+	names := make([]string, typ.NumMethod())
+	for i := range names {
+		names[i] = typ.Method(i).Name
+	}
+	return names
+}
+
+// totalOperations returns the number of operations recorded by c.
+func totalOperations(c *Collector) int64 {
+	var total int64
+	for _, op := range c.Stats().Operations {
+		total += op.Count
+	}
+	return total
+}
+
+// TestConformanceMetricsFS checks that every absfs.SymlinkFileSystem and
+// absfs.File method of the MetricsFS wrappers records an operation, so that
+// methods added to the absfs interfaces are not forwarded uninstrumented.
+func TestConformanceMetricsFS(t *testing.T) {
+	for _, name := range interfaceMethods[absfs.SymlinkFileSystem]() {
+		if uninstrumentedMethods[name] {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			fs := New(newMockFS())
+			callWithZeroArgs(fs, name)
+			if totalOperations(fs.Collector()) == 0 {
+				t.Errorf("MetricsFS.%s recorded no operation", name)
+			}
+		})
+	}
+
+	for _, name := range interfaceMethods[absfs.File]() {
+		if uninstrumentedMethods[name] {
+			continue
+		}
+		t.Run("File."+name, func(t *testing.T) {
+			fs := New(newMockFS())
+			f, err := fs.Create("/test.txt")
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			before := totalOperations(fs.Collector())
+			callWithZeroArgs(f, name)
+			if totalOperations(fs.Collector()) == before {
+				t.Errorf("MetricsFile.%s recorded no operation", name)
+			}
+		})
+	}
+}
+
+// TestConformanceOTelMetricsFS is TestConformanceMetricsFS for
+// OTelMetricsFS. Its files only record reads, writes and closes, so they are
+// checked for compliance by TestInterfaceCompliance alone.
+func TestConformanceOTelMetricsFS(t *testing.T) {
+	newFS := func(t *testing.T) (*OTelMetricsFS, *recordingMeter) {
+		provider := newRecordingMeterProvider()
+		fs, err := NewWithOTel(newMockFS(), OTelConfig{
+			MeterProvider:  provider,
+			TracerProvider: tracenoop.NewTracerProvider(),
+		})
+		if err != nil {
+			t.Fatalf("NewWithOTel failed: %v", err)
+		}
+		return fs, provider.meter
+	}
+
+	for _, name := range interfaceMethods[absfs.SymlinkFileSystem]() {
+		if uninstrumentedMethods[name] {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			fs, meter := newFS(t)
+			callWithZeroArgs(fs, name)
+			if meter.count("fs.operation.duration") == 0 {
+				t.Errorf("OTelMetricsFS.%s recorded no operation", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package metricsfs
+
+import "time"
+
+// threadCPUTime reports that per-thread CPU time is unavailable on this
+// platform, so CPU metrics are not recorded.
+func threadCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package metricsfs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// busyStatMockFS burns CPU for a while in Stat.
+type busyStatMockFS struct {
+	*mockFS
+}
+
+func (m *busyStatMockFS) Stat(name string) (os.FileInfo, error) {
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+	return m.mockFS.Stat(name)
+}
+
+func TestCPUMetrics(t *testing.T) {
+	if _, ok := threadCPUTime(); !ok {
+		t.Skip("thread CPU time is not supported on this platform")
+	}
+
+	config := DefaultConfig()
+	config.EnableCPUMetrics = true
+	fs := NewWithConfig(&busyStatMockFS{newMockFS()}, config)
+
+	fs.Stat("/test.txt")
+
+	c := fs.collector
+	cpu := testutil.ToFloat64(c.operationCPUSeconds.WithLabelValues("stat"))
+	wall := testutil.ToFloat64(c.operationWallSeconds.WithLabelValues("stat"))
+	if wall < 0.02 {
+		t.Errorf("Expected at least 20ms of wall time, got %v", wall)
+	}
+	if cpu < 0.01 || cpu > wall*1.1 {
+		t.Errorf("Expected CPU time close to wall time for a busy loop, got cpu=%v wall=%v", cpu, wall)
+	}
+}
+
+func TestCPUMetricsDisabled(t *testing.T) {
+	fs := New(newMockFS())
+	fs.Stat("/test.txt")
+
+	if fs.collector.operationCPUSeconds != nil {
+		t.Error("Expected no CPU metrics when disabled")
+	}
+}
@@ -0,0 +1,187 @@
+package metricsfs
+
+import (
+	"cmp"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugPage is the data the debug dashboard renders.
+type debugPage struct {
+	Instance  string
+	Version   string
+	Time      time.Time
+	Interval  time.Duration
+	Stats     Stats
+	Ops       []debugOp
+	Errors    []debugError
+	Warnings  []ConfigWarning
+	Files     []debugFile
+	HotPaths  []PathCount
+	Slow      []SlowOperation
+	Tracking  bool
+	Hot       bool
+	Threshold time.Duration
+}
+
+type debugOp struct {
+	Name   string
+	Count  int64
+	Errors int64
+	Rate   float64
+	Max    time.Duration
+}
+
+type debugError struct {
+	Operation string
+	Type      string
+	Count     int64
+}
+
+type debugFile struct {
+	OpenFileInfo
+	Age time.Duration
+}
+
+var debugTemplate = template.Must(template.New("debug").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string { return d.Round(time.Microsecond).String() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>metricsfs {{.Instance}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 1em 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border-bottom: 1px solid #ddd; padding: 2px 12px 2px 0; text-align: left; }
+td.n { text-align: right; font-family: monospace; }
+.err { color: #b00; }
+.note { color: #777; }
+</style>
+</head>
+<body>
+<h1>metricsfs {{.Instance}}</h1>
+<p class="note">{{.Version}} &middot; {{.Time.Format "2006-01-02 15:04:05 MST"}} &middot; rates over the last {{ms .Interval}} &middot;
+{{.Stats.OpenFiles}} open files (max {{.Stats.OpenFilesMax}}) &middot; {{.Stats.InFlight}} in flight &middot;
+{{.Stats.BytesRead}} bytes read &middot; {{.Stats.BytesWritten}} bytes written</p>
+{{with .Warnings}}<h2>Warnings</h2>
+<ul>{{range .}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<h2>Operations</h2>
+{{if .Ops}}<table>
+<tr><th>operation</th><th>count</th><th>errors</th><th>ops/s</th><th>max latency</th></tr>
+{{range .Ops}}<tr><td>{{.Name}}</td><td class="n">{{.Count}}</td><td class="n{{if .Errors}} err{{end}}">{{.Errors}}</td><td class="n">{{printf "%.1f" .Rate}}</td><td class="n">{{if .Max}}{{ms .Max}}{{end}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No operations yet.</p>{{end}}
+<h2>Errors</h2>
+{{if .Errors}}<table>
+<tr><th>operation</th><th>type</th><th>count</th></tr>
+{{range .Errors}}<tr><td>{{.Operation}}</td><td>{{.Type}}</td><td class="n err">{{.Count}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No errors.</p>{{end}}
+<h2>Open files</h2>
+{{if not .Tracking}}<p class="note">Set EnableLeakDetection to list open files.</p>
+{{else if .Files}}<table>
+<tr><th>age</th><th>handle</th><th>path</th></tr>
+{{range .Files}}<tr><td class="n">{{ms .Age}}</td><td>{{.HandleID}}</td><td>{{.Path}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No open files.</p>{{end}}
+<h2>Hot paths</h2>
+{{if not .Hot}}<p class="note">Set EnableHotPaths to list hot paths.</p>
+{{else if .HotPaths}}<table>
+<tr><th>count</th><th>path</th></tr>
+{{range .HotPaths}}<tr><td class="n">{{.Count}}</td><td>{{.Path}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No paths yet.</p>{{end}}
+<h2>Slow operations</h2>
+{{if not .Threshold}}<p class="note">Set SlowOperationThreshold to list slow operations.</p>
+{{else if .Slow}}<p class="note">Most recent first, at least {{ms .Threshold}}.</p>
+<table>
+<tr><th>time</th><th>operation</th><th>duration</th><th>path</th><th>error</th></tr>
+{{range .Slow}}<tr><td>{{.Time.Format "15:04:05.000"}}</td><td>{{.Operation}}</td><td class="n">{{ms .Duration}}</td><td>{{.Path}}</td><td class="err">{{.Error}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No operations of at least {{ms .Threshold}}.</p>{{end}}
+</body>
+</html>
+`))
+
+// DebugHandler returns an http.Handler serving a self-contained HTML
+// dashboard of the filesystem, for tools too small for Prometheus and
+// Grafana: operation counts and rates, errors by type, open files with
+// their age (with EnableLeakDetection), hot paths (with EnableHotPaths) and
+// recent slow operations (with SlowOperationThreshold). Mount it at a path
+// such as /debug/fsmetrics. The page refreshes itself every 5 seconds;
+// rates cover the interval since the handler last served it.
+func (m *MetricsFS) DebugHandler() http.Handler {
+	c := m.collector
+	var (
+		mu       sync.Mutex
+		previous = c.Stats()
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		stats := c.Stats()
+		mu.Lock()
+		last := previous
+		previous = stats
+		mu.Unlock()
+
+		config := c.Config()
+		page := debugPage{
+			Instance:  c.instance,
+			Version:   Version(),
+			Time:      stats.Timestamp,
+			Interval:  stats.Timestamp.Sub(last.Timestamp),
+			Stats:     stats,
+			Warnings:  m.Warnings(),
+			Tracking:  config.EnableLeakDetection,
+			Hot:       config.EnableHotPaths,
+			Threshold: config.SlowOperationThreshold,
+		}
+
+		seconds := page.Interval.Seconds()
+		for name, op := range stats.Operations {
+			row := debugOp{Name: name, Count: op.Count, Errors: op.Errors, Max: op.MaxLatency}
+			if delta := op.Count - last.Operations[name].Count; delta > 0 && seconds > 0 {
+				row.Rate = float64(delta) / seconds
+			}
+			page.Ops = append(page.Ops, row)
+		}
+		slices.SortFunc(page.Ops, func(a, b debugOp) int { return strings.Compare(a.Name, b.Name) })
+
+		errors := make(map[[2]string]int64)
+		collectValues(c.errorsTotal, func(labels map[string]string, value float64) {
+			errors[[2]string{labels["operation"], labels["error_type"]}] += int64(value)
+		})
+		for key, count := range errors {
+			page.Errors = append(page.Errors, debugError{Operation: key[0], Type: key[1], Count: count})
+		}
+		slices.SortFunc(page.Errors, func(a, b debugError) int {
+			if a.Count != b.Count {
+				return cmp.Compare(b.Count, a.Count)
+			}
+			return strings.Compare(a.Operation+a.Type, b.Operation+b.Type)
+		})
+
+		for _, f := range m.OpenFiles() {
+			page.Files = append(page.Files, debugFile{OpenFileInfo: f, Age: stats.Timestamp.Sub(f.OpenedAt)})
+		}
+		slices.SortFunc(page.Files, func(a, b debugFile) int { return cmp.Compare(b.Age, a.Age) })
+
+		if page.Hot {
+			page.HotPaths = c.HotPaths()
+		}
+
+		page.Slow = c.SlowOperations()
+		slices.Reverse(page.Slow)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		debugTemplate.Execute(w, page)
+	})
+}
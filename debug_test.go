@@ -0,0 +1,72 @@
+package metricsfs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	config := DefaultConfig()
+	config.Instance = "uploads"
+	config.EnableLeakDetection = true
+	config.EnableHotPaths = true
+	config.SlowOperationThreshold = time.Millisecond
+	fs := NewWithConfig(newMockFS(), config)
+	h := fs.DebugHandler()
+
+	if _, err := fs.Open("/leaked.txt"); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	fs.Stat("/<script>.txt")
+	c := fs.Collector()
+	c.recordOperation(context.Background(), OpStat, "/missing", 5*time.Millisecond, 0, os.ErrNotExist)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/fsmetrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	page := rec.Body.String()
+	for _, want := range []string{
+		"metricsfs uploads",
+		"<td>stat</td>",
+		"<td>not_found</td>",
+		"/leaked.txt",
+		"/&lt;script&gt;.txt",
+		"/missing",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected %q in page:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Error("Expected paths to be escaped")
+	}
+}
+
+func TestDebugHandlerDisabledSections(t *testing.T) {
+	h := New(newMockFS()).DebugHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	page := rec.Body.String()
+	for _, want := range []string{"Set EnableLeakDetection", "Set EnableHotPaths", "Set SlowOperationThreshold", "No operations yet."} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected %q in page:\n%s", want, page)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", rec.Code)
+	}
+}
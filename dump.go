@@ -0,0 +1,115 @@
+package metricsfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenFileInfo describes a file open through a MetricsFS. See
+// MetricsFS.OpenFiles.
+type OpenFileInfo struct {
+	// Path is the path the file was opened with
+	Path string `json:"path"`
+
+	// HandleID is the ID of the file handle, with EnableHandleIDs
+	HandleID string `json:"handle_id,omitempty"`
+
+	// OpenedAt is when the file was opened
+	OpenedAt time.Time `json:"opened_at"`
+}
+
+// OpenFiles returns the files opened through m or its copies that are still
+// open, sorted by path. Files are only tracked with EnableLeakDetection;
+// OpenFiles returns nil without it.
+func (m *MetricsFS) OpenFiles() []OpenFileInfo {
+	if !m.collector.config.EnableLeakDetection {
+		return nil
+	}
+	files := m.open.list()
+	infos := make([]OpenFileInfo, len(files))
+	for i, f := range files {
+		infos[i] = OpenFileInfo{Path: f.path, HandleID: HandleID(f.ctx), OpenedAt: f.openedAt}
+	}
+	return infos
+}
+
+// Dump writes a human-readable report of the filesystem's state to w: its
+// stats, configuration warnings, open files with their age (with
+// EnableLeakDetection) and hot paths (with EnableHotPaths). It serves
+// processes that misbehave without a metrics endpoint; see DumpOnSignal.
+func (m *MetricsFS) Dump(w io.Writer) error {
+	var b strings.Builder
+	now := m.collector.clock.Now()
+	fmt.Fprintf(&b, "=== metricsfs dump (instance %s) ===\n", m.collector.instance)
+	m.collector.Stats().WriteText(&b)
+
+	if warnings := m.Warnings(); len(warnings) > 0 {
+		b.WriteString("\nwarnings:\n")
+		for _, warning := range warnings {
+			fmt.Fprintf(&b, "  %s\n", warning)
+		}
+	}
+
+	if m.collector.config.EnableLeakDetection {
+		files := m.OpenFiles()
+		fmt.Fprintf(&b, "\nopen files (%d):\n", len(files))
+		for _, f := range files {
+			fmt.Fprintf(&b, "  %-12s %-16s %s\n", now.Sub(f.OpenedAt).Round(time.Millisecond), f.HandleID, f.Path)
+		}
+	}
+
+	if m.collector.config.EnableHotPaths {
+		b.WriteString("\nhot paths:\n")
+		for _, p := range m.collector.HotPaths() {
+			fmt.Fprintf(&b, "  %12d %s\n", p.Count, p.Path)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DumpOnSignal writes a Dump to w whenever the process receives sig, such
+// as syscall.SIGUSR1, until the returned stop function is called or the
+// collector is closed. Meanwhile sig no longer has its default effect:
+// SIGQUIT, for example, no longer dumps the goroutines and exits.
+func (m *MetricsFS) DumpOnSignal(sig os.Signal, w io.Writer) (stop func()) {
+	c := m.collector
+	if !c.addBackground() {
+		return func() {}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sig)
+
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		defer c.background.Done()
+		defer close(stopped)
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-signals:
+				m.Dump(w)
+			case <-stopCh:
+				return
+			case <-c.done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stopCh) })
+		<-stopped
+	}
+}
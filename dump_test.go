@@ -0,0 +1,79 @@
+package metricsfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOpenFiles(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableLeakDetection = true
+	config.EnableHandleIDs = true
+	fs := NewWithConfig(newMockFS(), config)
+
+	b, _ := fs.Open("/b.txt")
+	fs.WithContext(context.Background()).Create("/a.txt")
+	closed, _ := fs.Open("/c.txt")
+	closed.Close()
+
+	files := fs.OpenFiles()
+	if len(files) != 2 || files[0].Path != "/a.txt" || files[1].Path != "/b.txt" {
+		t.Fatalf("OpenFiles() = %+v, want /a.txt and /b.txt", files)
+	}
+	for _, f := range files {
+		if f.HandleID == "" || f.OpenedAt.IsZero() {
+			t.Errorf("Expected a handle ID and open time, got %+v", f)
+		}
+	}
+
+	b.Close()
+	if files := fs.OpenFiles(); len(files) != 1 {
+		t.Errorf("Expected 1 open file after Close, got %+v", files)
+	}
+
+	if files := New(newMockFS()).OpenFiles(); files != nil {
+		t.Errorf("Expected no open files without EnableLeakDetection, got %+v", files)
+	}
+}
+
+func TestDump(t *testing.T) {
+	config := DefaultConfig()
+	config.Instance = "uploads"
+	config.EnableLeakDetection = true
+	config.EnableHotPaths = true
+	config.EnableApdex = true
+	fs := NewWithConfig(newMockFS(), config)
+
+	if _, err := fs.Open("/leaked.txt"); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	fs.Stat("/hot.txt")
+	fs.Stat("/hot.txt")
+
+	var b strings.Builder
+	if err := fs.Dump(&b); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	dump := b.String()
+	for _, want := range []string{
+		"instance uploads",
+		"stat",
+		"EnableApdex:",
+		"open files (1):",
+		"/leaked.txt",
+		"hot paths:",
+		"/hot.txt",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("Expected %q in dump:\n%s", want, dump)
+		}
+	}
+
+	// Sections of disabled features are left out
+	b.Reset()
+	New(newMockFS()).Dump(&b)
+	if strings.Contains(b.String(), "open files (") || strings.Contains(b.String(), "hot paths:") {
+		t.Errorf("Expected no open files or hot paths:\n%s", b.String())
+	}
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package metricsfs
+
+import (
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// syncBuilder is a strings.Builder safe for concurrent use.
+type syncBuilder struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (s *syncBuilder) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuilder) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestDumpOnSignal(t *testing.T) {
+	fs := New(newMockFS())
+	fs.Stat("/a.txt")
+
+	var out syncBuilder
+	stop := fs.DumpOnSignal(syscall.SIGUSR1, &out)
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "metricsfs dump") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a dump on SIGUSR1")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stop()
+	stop() // idempotent
+	fs.Collector().Close()
+	fs.DumpOnSignal(syscall.SIGUSR1, &out)() // after Close
+}
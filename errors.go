@@ -0,0 +1,38 @@
+package metricsfs
+
+import (
+	"errors"
+	"os"
+)
+
+// categorizeError returns the error_type label for err: "not_found",
+// "permission" or "timeout" for the portable os errors, "size_limit" for
+// ErrFileTooLarge, a category derived from the platform error number when
+// there is one (see errnoCategory), and "unknown" otherwise.
+func categorizeError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "not_found"
+	case errors.Is(err, os.ErrPermission):
+		return "permission"
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrFileTooLarge):
+		return "size_limit"
+	}
+
+	// Checked before os.ErrExist, which also matches ENOTEMPTY on Unix
+	if category := errnoCategory(err); category != "" {
+		return category
+	}
+
+	if errors.Is(err, os.ErrExist) {
+		return "exists"
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package metricsfs
+
+// errnoCategory reports that error numbers are not classified on this
+// platform; Plan 9, js and wasip1 errors are categorized by the portable os
+// errors only.
+func errnoCategory(err error) string {
+	return ""
+}
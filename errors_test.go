@@ -0,0 +1,29 @@
+package metricsfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestCategorizeError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{os.ErrNotExist, "not_found"},
+		{&fs.PathError{Op: "open", Path: "/x", Err: os.ErrPermission}, "permission"},
+		{fmt.Errorf("read: %w", os.ErrDeadlineExceeded), "timeout"},
+		{os.ErrExist, "exists"},
+		{errors.New("boom"), "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := categorizeError(tt.err); got != tt.want {
+			t.Errorf("categorizeError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package metricsfs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errnoCategories maps Unix error numbers to error_type labels.
+var errnoCategories = map[syscall.Errno]string{
+	syscall.ENOSPC:       "disk_full",
+	syscall.EDQUOT:       "quota",
+	syscall.EMFILE:       "fd_exhausted",
+	syscall.ENFILE:       "fd_exhausted",
+	syscall.EROFS:        "read_only",
+	syscall.EIO:          "io_error",
+	syscall.ENAMETOOLONG: "name_too_long",
+	syscall.ELOOP:        "symlink_loop",
+	syscall.ENOTEMPTY:    "not_empty",
+	syscall.ENOTDIR:      "not_dir",
+	syscall.EISDIR:       "is_dir",
+	syscall.EXDEV:        "cross_device",
+	syscall.EBUSY:        "busy",
+}
+
+// errnoCategory returns the error_type label for the syscall.Errno wrapped
+// by err, or "" if there is none or it is not classified.
+func errnoCategory(err error) string {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return ""
+	}
+	return errnoCategories[errno]
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package metricsfs
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCategorizeErrno(t *testing.T) {
+	tests := []struct {
+		errno syscall.Errno
+		want  string
+	}{
+		{syscall.ENOSPC, "disk_full"},
+		{syscall.EDQUOT, "quota"},
+		{syscall.EMFILE, "fd_exhausted"},
+		{syscall.ENFILE, "fd_exhausted"},
+		{syscall.EROFS, "read_only"},
+		{syscall.EIO, "io_error"},
+		{syscall.ENAMETOOLONG, "name_too_long"},
+		{syscall.EEXIST, "exists"},
+		{syscall.ENOTEMPTY, "not_empty"},
+		{syscall.ENOENT, "not_found"},
+		{syscall.EACCES, "permission"},
+		{syscall.EINVAL, "unknown"},
+	}
+
+	for _, tt := range tests {
+		err := &os.PathError{Op: "write", Path: "/data", Err: tt.errno}
+		if got := categorizeError(err); got != tt.want {
+			t.Errorf("categorizeError(%v) = %q, want %q", tt.errno, got, tt.want)
+		}
+	}
+}
+
+func TestErrnoErrorType(t *testing.T) {
+	fs := New(newMockFS())
+	fs.collector.recordError(OpWrite, &os.PathError{Op: "write", Path: "/data", Err: syscall.ENOSPC}, nil)
+
+	if v := testutil.ToFloat64(fs.collector.errorsTotal.WithLabelValues("write", "disk_full")); v != 1 {
+		t.Errorf("Expected 1 disk_full error, got %v", v)
+	}
+}
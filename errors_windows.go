@@ -0,0 +1,59 @@
+//go:build windows
+
+package metricsfs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows system error codes from winerror.h. The syscall package's E*
+// constants are invented values on Windows and are never returned by the OS.
+const (
+	errorTooManyOpenFiles    syscall.Errno = 4
+	errorNotSameDevice       syscall.Errno = 17
+	errorWriteProtect        syscall.Errno = 19
+	errorCRC                 syscall.Errno = 23
+	errorSharingViolation    syscall.Errno = 32
+	errorHandleDiskFull      syscall.Errno = 39
+	errorFileExists          syscall.Errno = 80
+	errorDiskFull            syscall.Errno = 112
+	errorDirNotEmpty         syscall.Errno = 145
+	errorAlreadyExists       syscall.Errno = 183
+	errorFilenameExcedRange  syscall.Errno = 206
+	errorDirectory           syscall.Errno = 267
+	errorIODevice            syscall.Errno = 1117
+	errorDiskQuotaExceeded   syscall.Errno = 1295
+	errorNotEnoughQuota      syscall.Errno = 1816
+	errorCantResolveFilename syscall.Errno = 1921
+)
+
+// errnoCategories maps Windows error codes to error_type labels.
+var errnoCategories = map[syscall.Errno]string{
+	errorDiskFull:            "disk_full",
+	errorHandleDiskFull:      "disk_full",
+	errorDiskQuotaExceeded:   "quota",
+	errorNotEnoughQuota:      "quota",
+	errorTooManyOpenFiles:    "fd_exhausted",
+	errorWriteProtect:        "read_only",
+	errorCRC:                 "io_error",
+	errorIODevice:            "io_error",
+	errorFilenameExcedRange:  "name_too_long",
+	errorCantResolveFilename: "symlink_loop",
+	errorAlreadyExists:       "exists",
+	errorFileExists:          "exists",
+	errorDirNotEmpty:         "not_empty",
+	errorDirectory:           "not_dir",
+	errorNotSameDevice:       "cross_device",
+	errorSharingViolation:    "busy",
+}
+
+// errnoCategory returns the error_type label for the syscall.Errno wrapped
+// by err, or "" if there is none or it is not classified.
+func errnoCategory(err error) string {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return ""
+	}
+	return errnoCategories[errno]
+}
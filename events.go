@@ -0,0 +1,382 @@
+package metricsfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// EventFormat is the encoding of the requests sent by an EventExporter.
+type EventFormat int
+
+const (
+	// EventFormatOTLP sends events as OTLP/HTTP log records, one per
+	// operation, to an OpenTelemetry Collector or any OTLP logs endpoint,
+	// e.g. "http://otel-collector:4318/v1/logs"
+	EventFormatOTLP EventFormat = iota
+
+	// EventFormatProto sends events as OperationEventBatch messages of the
+	// schema in proto/events.proto, for services receiving them directly
+	EventFormatProto
+)
+
+// EventExportConfig configures an EventExporter.
+type EventExportConfig struct {
+	// URL receives the batches of events, POSTed as protobuf
+	URL string
+
+	// Format is the encoding of the batches (default: EventFormatOTLP)
+	Format EventFormat
+
+	// Headers are set on every request, e.g. an Authorization header
+	Headers map[string]string
+
+	// BatchSize sends a batch as soon as this many events are queued
+	// (default: 512)
+	BatchSize int
+
+	// FlushInterval is the longest an event is queued (default: 5s)
+	FlushInterval time.Duration
+
+	// QueueSize is the number of events queued while a batch is being sent
+	// before further events are dropped (default: 8192)
+	QueueSize int
+
+	// Timeout bounds each request (default: 10s)
+	Timeout time.Duration
+
+	// Client sends the requests (default: http.DefaultClient)
+	Client *http.Client
+
+	// OnError is called with the error of each failed request. The events
+	// of a failed request are dropped
+	OnError func(err error)
+}
+
+// applyDefaults fills in the zero fields of config.
+func (config *EventExportConfig) applyDefaults() {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 8192
+	}
+	config.QueueSize = max(config.QueueSize, config.BatchSize)
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+}
+
+// EventExporter sends every operation as a raw event to a central service,
+// which can then analyze access patterns across hosts, such as files hot on
+// many hosts at once, that aggregated metrics cannot answer. Install its
+// Record method as Config.OnOperation:
+//
+//	events := metricsfs.NewEventExporter(metricsfs.EventExportConfig{URL: "http://otel-collector:4318/v1/logs"})
+//	defer events.Close()
+//	config.OnOperation = events.Record
+//
+// Events are sent over HTTP in batches, as OTLP log records or in the
+// metricsfs schema; an OpenTelemetry Collector can forward OTLP logs to
+// gRPC backends.
+type EventExporter struct {
+	config EventExportConfig
+	host   string
+
+	mu      sync.Mutex
+	queue   []eventRecord
+	dropped uint64
+	total   uint64
+	closed  bool
+
+	closeErr error
+
+	flush   chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// eventRecord is a queued operation.
+type eventRecord struct {
+	op    Operation
+	start time.Time
+}
+
+// NewEventExporter creates an EventExporter and starts sending its batches.
+func NewEventExporter(config EventExportConfig) *EventExporter {
+	config.applyDefaults()
+	host, _ := os.Hostname()
+	e := &EventExporter{
+		config:  config,
+		host:    host,
+		flush:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(e.stopped)
+		ticker := time.NewTicker(config.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.send()
+			case <-e.flush:
+				e.send()
+			case <-e.stop:
+				e.closeErr = e.send()
+				return
+			}
+		}
+	}()
+	return e
+}
+
+// Record queues op to be sent. It is safe for concurrent use and does not
+// block on the network: events are dropped when the queue is full.
+func (e *EventExporter) Record(op Operation) {
+	record := eventRecord{op: op, start: time.Now().Add(-op.Duration)}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	if len(e.queue) >= e.config.QueueSize {
+		e.dropped++
+		e.total++
+		return
+	}
+	e.queue = append(e.queue, record)
+	if len(e.queue) >= e.config.BatchSize {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full.
+func (e *EventExporter) Dropped() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.total
+}
+
+// Close stops the exporter after sending the queued events, and returns the
+// error of the last request that failed doing so. Operations recorded after
+// Close are ignored.
+func (e *EventExporter) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.mu.Unlock()
+
+	close(e.stop)
+	<-e.stopped
+	return e.closeErr
+}
+
+// send sends the queued events in batches of at most BatchSize, and returns
+// the error of the last failed batch.
+func (e *EventExporter) send() error {
+	e.mu.Lock()
+	queue, dropped := e.queue, e.dropped
+	e.queue, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	var last error
+	for len(queue) > 0 || dropped > 0 {
+		n := min(len(queue), e.config.BatchSize)
+		if err := e.post(queue[:n], dropped); err != nil {
+			last = err
+			if e.config.OnError != nil {
+				e.config.OnError(err)
+			}
+		}
+		queue, dropped = queue[n:], 0
+	}
+	return last
+}
+
+// post sends a single batch.
+func (e *EventExporter) post(batch []eventRecord, dropped uint64) error {
+	var body []byte
+	if e.config.Format == EventFormatProto {
+		body = encodeEventBatch(e.host, batch, dropped)
+	} else {
+		body = encodeLogsRequest(e.host, batch)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("metricsfs: event export: %w", err)
+	}
+	for name, value := range e.config.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("metricsfs: event export: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("metricsfs: event export: unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// encodeEventBatch encodes batch as an OperationEventBatch message.
+func encodeEventBatch(host string, batch []eventRecord, dropped uint64) []byte {
+	var buf []byte
+	buf = appendStringField(buf, 1, host)
+	for _, record := range batch {
+		op := record.op
+		var event []byte
+		event = appendStringField(event, 1, op.Name)
+		event = appendStringField(event, 2, op.Path)
+		event = protowire.AppendTag(event, 3, protowire.Fixed64Type)
+		event = protowire.AppendFixed64(event, uint64(record.start.UnixNano()))
+		event = appendIntField(event, 4, int64(op.Duration))
+		event = appendIntField(event, 5, op.BytesTransferred)
+		if op.Error != nil {
+			event = appendStringField(event, 6, op.Error.Error())
+		}
+		event = appendStringField(event, 7, op.HandleID)
+		event = appendStringField(event, 8, op.Instance)
+		buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, event)
+	}
+	if dropped > 0 {
+		buf = protowire.AppendTag(buf, 3, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, dropped)
+	}
+	return buf
+}
+
+// OTLP severity numbers of successful and failed operations.
+const (
+	otlpSeverityInfo  = 9
+	otlpSeverityError = 17
+)
+
+// encodeLogsRequest encodes batch as an OTLP ExportLogsServiceRequest
+// message, with a log record per operation whose body is the operation name
+// and whose attributes follow those of the OpenTelemetry spans.
+func encodeLogsRequest(host string, batch []eventRecord) []byte {
+	var resource []byte
+	resource = appendStringAttr(resource, 1, "host.name", host)
+
+	var scope []byte
+	scope = appendStringField(scope, 1, modulePath)
+	scope = appendStringField(scope, 2, Version())
+
+	var scopeLogs []byte
+	scopeLogs = protowire.AppendTag(scopeLogs, 1, protowire.BytesType)
+	scopeLogs = protowire.AppendBytes(scopeLogs, scope)
+	now := uint64(time.Now().UnixNano())
+	for _, record := range batch {
+		op := record.op
+		var log []byte
+		log = protowire.AppendTag(log, 1, protowire.Fixed64Type)
+		log = protowire.AppendFixed64(log, uint64(record.start.UnixNano()))
+		severity, text := otlpSeverityInfo, "INFO"
+		if op.Error != nil {
+			severity, text = otlpSeverityError, "ERROR"
+		}
+		log = appendIntField(log, 2, int64(severity))
+		log = appendStringField(log, 3, text)
+		log = protowire.AppendTag(log, 5, protowire.BytesType)
+		log = protowire.AppendBytes(log, appendStringField(nil, 1, op.Name))
+		log = appendStringAttr(log, 6, "fs.operation", op.Name)
+		log = appendStringAttr(log, 6, "fs.path", op.Path)
+		log = appendIntAttr(log, 6, "fs.duration_ns", int64(op.Duration))
+		log = appendIntAttr(log, 6, "fs.bytes", op.BytesTransferred)
+		if op.Error != nil {
+			log = appendStringAttr(log, 6, "fs.error", op.Error.Error())
+		}
+		log = appendStringAttr(log, 6, "fs.handle.id", op.HandleID)
+		log = appendStringAttr(log, 6, "fs.instance", op.Instance)
+		log = protowire.AppendTag(log, 11, protowire.Fixed64Type)
+		log = protowire.AppendFixed64(log, now)
+		scopeLogs = protowire.AppendTag(scopeLogs, 2, protowire.BytesType)
+		scopeLogs = protowire.AppendBytes(scopeLogs, log)
+	}
+
+	var resourceLogs []byte
+	resourceLogs = protowire.AppendTag(resourceLogs, 1, protowire.BytesType)
+	resourceLogs = protowire.AppendBytes(resourceLogs, resource)
+	resourceLogs = protowire.AppendTag(resourceLogs, 2, protowire.BytesType)
+	resourceLogs = protowire.AppendBytes(resourceLogs, scopeLogs)
+
+	var buf []byte
+	buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+	return protowire.AppendBytes(buf, resourceLogs)
+}
+
+// appendStringAttr appends an OTLP KeyValue attribute with a string value
+// as field num, unless value is empty.
+func appendStringAttr(buf []byte, num protowire.Number, key, value string) []byte {
+	if value == "" {
+		return buf
+	}
+	return appendAttr(buf, num, key, appendStringField(nil, 1, value))
+}
+
+// appendIntAttr appends an OTLP KeyValue attribute with an int value as
+// field num.
+func appendIntAttr(buf []byte, num protowire.Number, key string, value int64) []byte {
+	v := protowire.AppendTag(nil, 3, protowire.VarintType)
+	return appendAttr(buf, num, key, protowire.AppendVarint(v, uint64(value)))
+}
+
+// appendAttr appends an OTLP KeyValue attribute with the encoded AnyValue
+// value as field num.
+func appendAttr(buf []byte, num protowire.Number, key string, value []byte) []byte {
+	var kv []byte
+	kv = appendStringField(kv, 1, key)
+	kv = protowire.AppendTag(kv, 2, protowire.BytesType)
+	kv = protowire.AppendBytes(kv, value)
+	buf = protowire.AppendTag(buf, num, protowire.BytesType)
+	return protowire.AppendBytes(buf, kv)
+}
+
+// appendStringField appends s as field num, unless it is empty.
+func appendStringField(buf []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, num, protowire.BytesType)
+	return protowire.AppendString(buf, s)
+}
+
+// appendIntField appends v as varint field num, unless it is zero.
+func appendIntField(buf []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, num, protowire.VarintType)
+	return protowire.AppendVarint(buf, uint64(v))
+}
@@ -0,0 +1,249 @@
+package metricsfs
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoFields decodes the fields of a protobuf message by number. Varint
+// and fixed64 values are returned as uint64, length-delimited ones as
+// []byte.
+func protoFields(t *testing.T, b []byte) map[protowire.Number][]any {
+	t.Helper()
+	fields := make(map[protowire.Number][]any)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		var v any
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		if n < 0 {
+			t.Fatalf("invalid field %d: %v", num, protowire.ParseError(n))
+		}
+		fields[num] = append(fields[num], v)
+		b = b[n:]
+	}
+	return fields
+}
+
+// eventCollector is an HTTP endpoint recording the bodies it receives.
+type eventCollector struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	block  chan struct{} // when set, requests wait for it to be closed
+}
+
+func (c *eventCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	c.bodies = append(c.bodies, body)
+	block := c.block
+	c.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+}
+
+func (c *eventCollector) received() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]byte(nil), c.bodies...)
+}
+
+// waitForRequests waits until c has received n requests.
+func (c *eventCollector) waitForRequests(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(c.received()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d requests", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEventExporterProto(t *testing.T) {
+	endpoint := &eventCollector{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	events := NewEventExporter(EventExportConfig{
+		URL:           server.URL,
+		Format:        EventFormatProto,
+		FlushInterval: time.Hour,
+	})
+	config := DefaultConfig()
+	config.Instance = "uploads"
+	config.OnOperation = events.Record
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, err := fs.OpenFile("/a.txt", 0, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+	if err := events.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	bodies := endpoint.received()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(bodies))
+	}
+	batch := protoFields(t, bodies[0])
+	var ops []string
+	for _, raw := range batch[2] {
+		event := protoFields(t, raw.([]byte))
+		op := string(event[1][0].([]byte))
+		ops = append(ops, op)
+		if got := string(event[8][0].([]byte)); got != "uploads" {
+			t.Errorf("%s: instance = %q", op, got)
+		}
+		if event[3][0].(uint64) == 0 {
+			t.Errorf("%s: expected a start time", op)
+		}
+		if op == "write" {
+			if got := event[5][0].(uint64); got != 5 {
+				t.Errorf("write: bytes = %d, want 5", got)
+			}
+			if got := string(event[2][0].([]byte)); got != "/a.txt" {
+				t.Errorf("write: path = %q", got)
+			}
+		}
+	}
+	if len(ops) != 3 || ops[0] != "open" || ops[1] != "write" || ops[2] != "close" {
+		t.Errorf("events = %v, want [open write close]", ops)
+	}
+
+	// Operations after Close are ignored
+	events.Record(Operation{Name: "stat"})
+	if err := events.Close(); err != nil {
+		t.Errorf("Second Close() = %v", err)
+	}
+}
+
+func TestEventExporterOTLP(t *testing.T) {
+	endpoint := &eventCollector{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	events := NewEventExporter(EventExportConfig{URL: server.URL + "/v1/logs", FlushInterval: time.Hour})
+	events.Record(Operation{Name: "stat", Path: "/a.txt", Duration: time.Millisecond})
+	events.Record(Operation{Name: "remove", Path: "/b.txt", Error: errors.New("permission denied")})
+	events.Close()
+
+	bodies := endpoint.received()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(bodies))
+	}
+	resourceLogs := protoFields(t, protoFields(t, bodies[0])[1][0].([]byte))
+	scopeLogs := protoFields(t, resourceLogs[2][0].([]byte))
+	scope := protoFields(t, scopeLogs[1][0].([]byte))
+	if got := string(scope[1][0].([]byte)); got != modulePath {
+		t.Errorf("scope = %q, want %q", got, modulePath)
+	}
+
+	records := scopeLogs[2]
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 log records, got %d", len(records))
+	}
+	for i, want := range []struct {
+		severity uint64
+		attrs    map[string]string
+	}{
+		{otlpSeverityInfo, map[string]string{"fs.operation": "stat", "fs.path": "/a.txt"}},
+		{otlpSeverityError, map[string]string{"fs.operation": "remove", "fs.error": "permission denied"}},
+	} {
+		record := protoFields(t, records[i].([]byte))
+		if got := record[2][0].(uint64); got != want.severity {
+			t.Errorf("record %d: severity = %d, want %d", i, got, want.severity)
+		}
+		attrs := make(map[string]string)
+		for _, raw := range record[6] {
+			kv := protoFields(t, raw.([]byte))
+			value := protoFields(t, kv[2][0].([]byte))
+			if s, ok := value[1]; ok {
+				attrs[string(kv[1][0].([]byte))] = string(s[0].([]byte))
+			}
+		}
+		for k, v := range want.attrs {
+			if attrs[k] != v {
+				t.Errorf("record %d: %s = %q, want %q", i, k, attrs[k], v)
+			}
+		}
+	}
+}
+
+func TestEventExporterBatchSizeAndDrops(t *testing.T) {
+	endpoint := &eventCollector{block: make(chan struct{})}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	events := NewEventExporter(EventExportConfig{
+		URL:           server.URL,
+		Format:        EventFormatProto,
+		BatchSize:     1,
+		QueueSize:     1,
+		FlushInterval: time.Hour,
+	})
+
+	// A full batch is sent without waiting for the flush interval
+	events.Record(Operation{Name: "stat"})
+	endpoint.waitForRequests(t, 1)
+
+	// While it is being sent, one event is queued and the next dropped
+	events.Record(Operation{Name: "open"})
+	events.Record(Operation{Name: "close"})
+	if got := events.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+
+	close(endpoint.block)
+	endpoint.waitForRequests(t, 2)
+	events.Close()
+
+	batch := protoFields(t, endpoint.received()[1])
+	if len(batch[2]) != 1 || batch[3][0].(uint64) != 1 {
+		t.Errorf("Expected the queued event and the drop count, got %v", batch)
+	}
+}
+
+func TestEventExporterReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var errs []error
+	events := NewEventExporter(EventExportConfig{
+		URL:           server.URL,
+		FlushInterval: time.Hour,
+		OnError:       func(err error) { errs = append(errs, err) },
+	})
+	events.Record(Operation{Name: "stat"})
+	if err := events.Close(); err == nil {
+		t.Error("Expected Close to return the failed request's error")
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 export error, got %v", errs)
+	}
+}
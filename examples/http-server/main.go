@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/absfs/metricsfs"
+	"github.com/absfs/metricsfs/metricsfshttp"
 	"github.com/absfs/osfs"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -17,7 +18,8 @@ func main() {
 		log.Fatalf("Failed to create base filesystem: %v", err)
 	}
 
-	// Wrap with metrics, using custom configuration
+	// Wrap with metrics, using custom configuration. Operations are labeled
+	// with the route of the request they serve
 	fs := metricsfs.NewWithConfig(base, metricsfs.Config{
 		Namespace:              "fileserver",
 		Subsystem:              "storage",
@@ -27,6 +29,8 @@ func main() {
 			"service": "http-fileserver",
 			"env":     "development",
 		},
+		ContextLabelNames: []string{metricsfshttp.RouteLabel},
+		ContextLabels:     metricsfshttp.RouteLabels,
 	})
 
 	// Register metrics
@@ -35,10 +39,11 @@ func main() {
 	// Create HTTP handlers
 	mux := http.NewServeMux()
 
-	// File server handler (serves files from current directory)
-	fileHandler := http.StripPrefix("/files/",
-		http.FileServer(http.Dir(".")))
-	mux.Handle("/files/", fileHandler)
+	// File server handler (serves files from the current directory through
+	// the instrumented filesystem)
+	fileHandler := http.StripPrefix("/files",
+		metricsfshttp.FileServer(fs))
+	mux.Handle("/files/", metricsfshttp.Route("/files/")(fileHandler))
 
 	// Metrics endpoint
 	mux.Handle("/metrics", promhttp.Handler())
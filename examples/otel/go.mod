@@ -6,7 +6,7 @@ replace github.com/absfs/metricsfs => ../..
 
 require (
 	github.com/absfs/metricsfs v0.0.0-00010101000000-000000000000
-	github.com/absfs/osfs v0.0.0-20220705103527-80b6215cf130
+	github.com/absfs/osfs v1.0.0
 	go.opentelemetry.io/otel v1.38.0
 	go.opentelemetry.io/otel/exporters/stdout/stdoutmetric v1.38.0
 	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.38.0
@@ -15,7 +15,7 @@ require (
 )
 
 require (
-	github.com/absfs/absfs v0.0.0-20251109181304-77e2f9ac4448 // indirect
+	github.com/absfs/absfs v1.0.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/go-logr/logr v1.4.3 // indirect
@@ -0,0 +1,66 @@
+package metricsfs
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+var (
+	expvarMu sync.Mutex
+
+	// expvarCollectors are the collectors published by name, nil after
+	// Close. Names stay in the map once published, since expvar cannot
+	// unpublish them
+	expvarCollectors = make(map[string]*Collector)
+)
+
+// PublishExpvar publishes the collector's Stats under name using the standard
+// expvar package, so they are served at /debug/vars. The stats are computed
+// each time the variable is read.
+//
+// expvar cannot unpublish variables, so the variable stays for the life of
+// the process: Close withdraws the collector from it, after which it reads
+// as null, and publishing another collector under the same name replaces
+// it, as when a filesystem is recreated. PublishExpvar returns an error if
+// name is already in use by a variable it did not publish.
+func (c *Collector) PublishExpvar(name string) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if _, ok := expvarCollectors[name]; !ok {
+		if expvar.Get(name) != nil {
+			return fmt.Errorf("metricsfs: expvar %q is already published", name)
+		}
+		expvar.Publish(name, expvar.Func(func() interface{} {
+			return expvarStats(name)
+		}))
+	}
+	expvarCollectors[name] = c
+	return nil
+}
+
+// expvarStats returns the Stats of the collector published under name, or
+// nil if it was closed.
+func expvarStats(name string) interface{} {
+	expvarMu.Lock()
+	c := expvarCollectors[name]
+	expvarMu.Unlock()
+
+	if c == nil {
+		return nil
+	}
+	return c.Stats()
+}
+
+// unpublishExpvar withdraws c from the variables it was published under.
+func (c *Collector) unpublishExpvar() {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	for name, published := range expvarCollectors {
+		if published == c {
+			expvarCollectors[name] = nil
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package metricsfs
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// latencyExtremes tracks the shortest, longest and most recent duration of
+// an operation over a window that restarts on each scrape.
+type latencyExtremes struct {
+	min  atomic.Int64 // nanoseconds, math.MaxInt64 while the window is empty
+	max  atomic.Int64 // nanoseconds, -1 while the window is empty
+	last atomic.Int64 // nanoseconds, -1 until the first observation
+}
+
+// newLatencyExtremes returns extremes with an empty window.
+func newLatencyExtremes() *latencyExtremes {
+	e := &latencyExtremes{}
+	e.min.Store(math.MaxInt64)
+	e.max.Store(-1)
+	e.last.Store(-1)
+	return e
+}
+
+// observe adds d to the window.
+func (e *latencyExtremes) observe(d time.Duration) {
+	ns := int64(d)
+	e.last.Store(ns)
+	for cur := e.min.Load(); ns < cur && !e.min.CompareAndSwap(cur, ns); cur = e.min.Load() {
+	}
+	for cur := e.max.Load(); ns > cur && !e.max.CompareAndSwap(cur, ns); cur = e.max.Load() {
+	}
+}
+
+// window returns the extremes of the window and whether it has any
+// observations, restarting it if restart is set. The most recent duration
+// outlives the window.
+func (e *latencyExtremes) window(restart bool) (minimum, maximum, last time.Duration, ok bool) {
+	if restart {
+		minimum = time.Duration(e.min.Swap(math.MaxInt64))
+		maximum = time.Duration(e.max.Swap(-1))
+	} else {
+		minimum = time.Duration(e.min.Load())
+		maximum = time.Duration(e.max.Load())
+	}
+	// An observation racing with the restart may land in either window
+	ok = maximum >= 0 && minimum <= maximum
+	return minimum, maximum, time.Duration(e.last.Load()), ok
+}
+
+// initLatencyExtremes creates the latency extremes gauges, with
+// EnableLatencyExtremes.
+func (c *Collector) initLatencyExtremes(config Config) {
+	if !config.EnableLatencyExtremes {
+		return
+	}
+
+	c.extremes = &sync.Map{}
+	newGauge := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        name,
+				Help:        help,
+				ConstLabels: config.constLabelsFor(name),
+			},
+			[]string{"operation"},
+		)
+	}
+	c.latencyMin = newGauge("operation_duration_min_seconds", "Shortest operation duration since the previous scrape")
+	c.latencyMax = newGauge("operation_duration_max_seconds", "Longest operation duration since the previous scrape")
+	c.latencyLast = newGauge("operation_duration_last_seconds", "Duration of the most recent operation")
+}
+
+// recordLatencyExtremes adds an operation's duration to its extremes.
+func (c *Collector) recordLatencyExtremes(op Op, duration time.Duration) {
+	e, ok := c.extremes.Load(op)
+	if !ok {
+		e, _ = c.extremes.LoadOrStore(op, newLatencyExtremes())
+	}
+	e.(*latencyExtremes).observe(duration)
+}
+
+// flushLatencyExtremes sets the latency extremes gauges from the windows of
+// each operation and restarts them. Operations not performed since the
+// previous flush keep their minimum and maximum.
+func (c *Collector) flushLatencyExtremes() {
+	if c.extremes == nil {
+		return
+	}
+
+	c.extremes.Range(func(key, value any) bool {
+		op := string(key.(Op))
+		minimum, maximum, last, ok := value.(*latencyExtremes).window(true)
+		if ok {
+			c.latencyMin.WithLabelValues(op).Set(minimum.Seconds())
+			c.latencyMax.WithLabelValues(op).Set(maximum.Seconds())
+		}
+		if last >= 0 {
+			c.latencyLast.WithLabelValues(op).Set(last.Seconds())
+		}
+		return true
+	})
+}
+
+// latencyExtremesStats adds the current latency extremes windows to the
+// operations of stats, without restarting them.
+func (c *Collector) latencyExtremesStats(stats map[string]OperationStats) {
+	if c.extremes == nil {
+		return
+	}
+
+	c.extremes.Range(func(key, value any) bool {
+		name := string(key.(Op))
+		minimum, maximum, last, ok := value.(*latencyExtremes).window(false)
+		op := stats[name]
+		if ok {
+			op.MinLatency = minimum
+			op.MaxLatency = maximum
+		}
+		if last >= 0 {
+			op.LastLatency = last
+		}
+		stats[name] = op
+		return true
+	})
+}
@@ -0,0 +1,63 @@
+package metricsfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLatencyExtremes(t *testing.T) {
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	config := DefaultConfig()
+	config.Clock = clock
+	config.EnableLatencyExtremes = true
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	for _, step := range []time.Duration{20, 5, 40, 10} {
+		clock.step = step * time.Millisecond
+		fs.Stat("/a")
+	}
+
+	// Stats report the window without restarting it
+	stat := c.Stats().Operations["stat"]
+	if stat.MinLatency != 5*time.Millisecond || stat.MaxLatency != 40*time.Millisecond || stat.LastLatency != 10*time.Millisecond {
+		t.Errorf("Expected 5ms, 40ms and 10ms, got %v, %v and %v", stat.MinLatency, stat.MaxLatency, stat.LastLatency)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	if _, err := reg.Gather(); err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	for _, tt := range []struct {
+		gauge *prometheus.GaugeVec
+		want  float64
+	}{
+		{c.latencyMin, 0.005},
+		{c.latencyMax, 0.04},
+		{c.latencyLast, 0.01},
+	} {
+		if got := testutil.ToFloat64(tt.gauge.WithLabelValues("stat")); got != tt.want {
+			t.Errorf("Expected %v, got %v", tt.want, got)
+		}
+	}
+
+	// The scrape restarted the window
+	clock.step = 15 * time.Millisecond
+	fs.Stat("/a")
+	reg.Gather()
+	if got := testutil.ToFloat64(c.latencyMin.WithLabelValues("stat")); got != 0.015 {
+		t.Errorf("Expected a minimum of 15ms after the scrape, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.latencyMax.WithLabelValues("stat")); got != 0.015 {
+		t.Errorf("Expected a maximum of 15ms after the scrape, got %v", got)
+	}
+
+	c.Reset()
+	if stat := c.Stats().Operations["stat"]; stat.LastLatency != 0 {
+		t.Errorf("Expected no latency after Reset, got %v", stat.LastLatency)
+	}
+}
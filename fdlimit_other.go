@@ -0,0 +1,9 @@
+//go:build !unix
+
+package metricsfs
+
+// openFilesLimit reports that there is no per-process open file limit to
+// expose on this platform. Windows handles are bounded only by memory.
+func openFilesLimit() (uint64, bool) {
+	return 0, false
+}
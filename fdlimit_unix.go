@@ -0,0 +1,17 @@
+//go:build unix
+
+package metricsfs
+
+import "golang.org/x/sys/unix"
+
+// openFilesLimit returns the soft RLIMIT_NOFILE of the process.
+func openFilesLimit() (uint64, bool) {
+	var rl unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, false
+	}
+	if uint64(rl.Cur) == unix.RLIM_INFINITY {
+		return 0, false
+	}
+	return uint64(rl.Cur), true
+}
@@ -1,166 +1,447 @@
 package metricsfs
 
 import (
+	"context"
 	"io"
 	"io/fs"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/absfs/absfs"
 )
 
+var (
+	_ absfs.File    = (*MetricsFile)(nil)
+	_ io.ReaderFrom = (*MetricsFile)(nil)
+	_ io.WriterTo   = (*MetricsFile)(nil)
+)
+
 // MetricsFile wraps an absfs.File and collects metrics on file operations.
 type MetricsFile struct {
 	file      absfs.File
 	collector *Collector
 	path      string
+	ctx       context.Context
+
+	// isDir is set when the handle is known to refer to a directory
+	isDir atomic.Bool
+
+	// pendingOpen is the open of a handle whose kind is not known yet, with
+	// EnableHandleKindDetection, until it is recorded by settleOpen
+	pendingOpen atomic.Pointer[pendingOpen]
+
+	// handle tracks the file when opened during a ProfileFor window
+	handle *profileHandle
+
+	// verifier checksums the file as it is read, with Config.Verify
+	verifier *fileVerifier
+
+	// maxSize is the size writes may not grow the file beyond, or 0
+	maxSize int64
+
+	// openFiles tracks the file until it is closed, with EnableLeakDetection,
+	// since openedAt
+	openFiles *openFiles
+	openedAt  time.Time
+
+	// Advisory lock state, with lockMode empty while unlocked
+	lockMu   sync.Mutex
+	lockMode string
+	lockedAt time.Time
+
+	// Access pattern state (if enabled)
+	patternMu sync.Mutex
+	position  int64 // offset of the next Read or Write
+	lastEnd   int64 // offset just past the previous read or write
+	appending bool  // writes always go to the end of the file
 }
 
-// newMetricsFile creates a new MetricsFile wrapper.
-func newMetricsFile(f absfs.File, collector *Collector, path string) *MetricsFile {
+// pendingOpen is an open whose recording waits for the handle's kind.
+type pendingOpen struct {
+	ctx      context.Context
+	mode     string
+	duration time.Duration
+}
+
+// newMetricsFile creates a new MetricsFile wrapper. Its operations are
+// recorded with ctx, which carries the handle's ID if it has one.
+func newMetricsFile(ctx context.Context, f absfs.File, collector *Collector, path string) *MetricsFile {
 	mf := &MetricsFile{
 		file:      f,
 		collector: collector,
 		path:      path,
+		ctx:       ctx,
+		maxSize:   collector.maxFileSize(path),
 	}
 
 	// Track file open
 	collector.trackFileOpen()
+	if p := collector.profile.Load(); p != nil {
+		mf.handle = p.openHandle(HandleID(ctx), path)
+	}
 
 	return mf
 }
 
+// WrapFile instruments a file obtained outside a MetricsFS, such as from a
+// backend-specific API on the unwrapped filesystem, recording its operations
+// in collector. The file counts as open until the returned file is closed;
+// the open itself is not recorded. Returns nil if f is nil.
+func WrapFile(f absfs.File, collector *Collector, path string) absfs.File {
+	if f == nil {
+		return nil
+	}
+	return newMetricsFile(collector.withHandleID(context.Background()), f, collector, path)
+}
+
 // Read reads data from the file.
 func (f *MetricsFile) Read(p []byte) (n int, err error) {
-	start := time.Now()
-	n, err = f.file.Read(p)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("read", f.path, duration, int64(n), err)
+	start := f.collector.startOperation(OpRead)
+	n, err = interceptResult(f.collector, f.ctx, OpRead, f.path, func() (int, error) {
+		return f.file.Read(p)
+	})
+	duration := f.collector.finishOperation(OpRead, start)
+
+	f.collector.recordOperation(f.ctx, OpRead, f.path, duration, int64(n), err)
+	f.recordLayerRead(duration, n, err)
+	f.recordAccess(OpRead, -1, n)
+	f.verifyRead(p, -1, n, err)
+	f.collector.recordTransfer(OpRead, len(p), n, err)
 
 	return n, err
 }
 
 // ReadAt reads data from the file at a specific offset.
 func (f *MetricsFile) ReadAt(p []byte, off int64) (n int, err error) {
-	start := time.Now()
-	n, err = f.file.ReadAt(p, off)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("read", f.path, duration, int64(n), err)
+	start := f.collector.startOperation(OpRead)
+	n, err = interceptResult(f.collector, f.ctx, OpRead, f.path, func() (int, error) {
+		return f.file.ReadAt(p, off)
+	})
+	duration := f.collector.finishOperation(OpRead, start)
+
+	f.collector.recordOperation(f.ctx, OpRead, f.path, duration, int64(n), err)
+	f.recordLayerRead(duration, n, err)
+	f.recordAccess(OpRead, off, n)
+	f.verifyRead(p, off, n, err)
+	f.collector.recordTransfer(OpRead, len(p), n, err)
 
 	return n, err
 }
 
 // Write writes data to the file.
 func (f *MetricsFile) Write(p []byte) (n int, err error) {
-	start := time.Now()
-	n, err = f.file.Write(p)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("write", f.path, duration, int64(n), err)
+	start := f.collector.startOperation(OpWrite)
+	n, err = interceptResult(f.collector, f.ctx, OpWrite, f.path, func() (int, error) {
+		if err := f.checkSize(-1, len(p)); err != nil {
+			return 0, err
+		}
+		return f.file.Write(p)
+	})
+	duration := f.collector.finishOperation(OpWrite, start)
+
+	f.collector.recordOperation(f.ctx, OpWrite, f.path, duration, int64(n), err)
+	f.recordAccess(OpWrite, -1, n)
+	f.collector.recordTransfer(OpWrite, len(p), n, err)
+	if f.verifier != nil {
+		f.verifier.abandon()
+	}
 
 	return n, err
 }
 
 // WriteAt writes data to the file at a specific offset.
 func (f *MetricsFile) WriteAt(p []byte, off int64) (n int, err error) {
-	start := time.Now()
-	n, err = f.file.WriteAt(p, off)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("write", f.path, duration, int64(n), err)
+	start := f.collector.startOperation(OpWrite)
+	n, err = interceptResult(f.collector, f.ctx, OpWrite, f.path, func() (int, error) {
+		if err := f.checkSize(off, len(p)); err != nil {
+			return 0, err
+		}
+		return f.file.WriteAt(p, off)
+	})
+	duration := f.collector.finishOperation(OpWrite, start)
+
+	f.collector.recordOperation(f.ctx, OpWrite, f.path, duration, int64(n), err)
+	f.recordAccess(OpWrite, off, n)
+	f.collector.recordTransfer(OpWrite, len(p), n, err)
+	if f.verifier != nil {
+		f.verifier.abandon()
+	}
 
 	return n, err
 }
 
 // WriteString writes a string to the file.
 func (f *MetricsFile) WriteString(s string) (n int, err error) {
-	start := time.Now()
-	n, err = io.WriteString(f.file, s)
-	duration := time.Since(start)
+	start := f.collector.startOperation(OpWrite)
+	n, err = interceptResult(f.collector, f.ctx, OpWrite, f.path, func() (int, error) {
+		if err := f.checkSize(-1, len(s)); err != nil {
+			return 0, err
+		}
+		return io.WriteString(f.file, s)
+	})
+	duration := f.collector.finishOperation(OpWrite, start)
+
+	f.collector.recordOperation(f.ctx, OpWrite, f.path, duration, int64(n), err)
+	f.recordAccess(OpWrite, -1, n)
+	f.collector.recordTransfer(OpWrite, len(s), n, err)
+	if f.verifier != nil {
+		f.verifier.abandon()
+	}
+
+	return n, err
+}
+
+// ReadFrom writes the data read from r until io.EOF to the file, recorded
+// as a single write. It delegates to the wrapped file's ReadFrom when it has
+// one, so that copies keep using sendfile or splice. Files with a size limit
+// are copied through Write to enforce it.
+func (f *MetricsFile) ReadFrom(r io.Reader) (n int64, err error) {
+	if f.maxSize > 0 {
+		return io.Copy(writerOnly{f}, r)
+	}
+
+	start := f.collector.startOperation(OpWrite)
+	n, err = interceptResult(f.collector, f.ctx, OpWrite, f.path, func() (int64, error) {
+		if rf, ok := f.file.(io.ReaderFrom); ok {
+			return rf.ReadFrom(r)
+		}
+		return io.Copy(f.file, r)
+	})
+	duration := f.collector.finishOperation(OpWrite, start)
+
+	f.collector.recordOperation(f.ctx, OpWrite, f.path, duration, n, err)
+	f.recordAccess(OpWrite, -1, int(n))
+	if f.verifier != nil {
+		f.verifier.abandon()
+	}
+
+	return n, err
+}
+
+// WriteTo writes the rest of the file to w, recorded as a single read. It
+// delegates to the wrapped file's WriteTo when it has one, so that copies
+// keep using sendfile or splice. Files being verified are copied through
+// Read so that their checksum is computed.
+func (f *MetricsFile) WriteTo(w io.Writer) (n int64, err error) {
+	if f.verifier != nil {
+		return io.Copy(w, readerOnly{f})
+	}
+
+	start := f.collector.startOperation(OpRead)
+	n, err = interceptResult(f.collector, f.ctx, OpRead, f.path, func() (int64, error) {
+		if wt, ok := f.file.(io.WriterTo); ok {
+			return wt.WriteTo(w)
+		}
+		return io.Copy(w, f.file)
+	})
+	duration := f.collector.finishOperation(OpRead, start)
 
-	f.collector.recordOperation("write", f.path, duration, int64(n), err)
+	f.collector.recordOperation(f.ctx, OpRead, f.path, duration, n, err)
+	f.recordLayerRead(duration, int(n), err)
+	f.recordAccess(OpRead, -1, int(n))
 
 	return n, err
 }
 
+// writerOnly hides the ReadFrom method of a writer from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
+
+// readerOnly hides the WriteTo method of a reader from io.Copy.
+type readerOnly struct {
+	io.Reader
+}
+
 // Seek sets the file offset for the next read or write.
 func (f *MetricsFile) Seek(offset int64, whence int) (int64, error) {
-	start := time.Now()
-	pos, err := f.file.Seek(offset, whence)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("seek", f.path, duration, 0, err)
+	start := f.collector.startOperation(OpSeek)
+	pos, err := interceptResult(f.collector, f.ctx, OpSeek, f.path, func() (int64, error) {
+		return f.file.Seek(offset, whence)
+	})
+	duration := f.collector.finishOperation(OpSeek, start)
+
+	f.collector.recordOperation(f.ctx, OpSeek, f.path, duration, 0, err)
+
+	if err == nil && f.collector.config.EnableAccessPatternMetrics {
+		f.patternMu.Lock()
+		f.position = pos
+		f.patternMu.Unlock()
+	}
+	if err == nil && f.verifier != nil {
+		f.verifier.seek(pos)
+	}
 
 	return pos, err
 }
 
+// recordAccess classifies a read or write of n bytes at off as sequential or
+// random. An off of -1 means the current position, which the access advances.
+// It also counts the transfer on the handle's profile, if any.
+func (f *MetricsFile) recordAccess(op Op, off int64, n int) {
+	if n > 0 && f.handle != nil {
+		f.handle.record(op, n)
+	}
+	if n <= 0 || !f.collector.config.EnableAccessPatternMetrics || !f.collector.measured(op) {
+		return
+	}
+
+	f.patternMu.Lock()
+	advance := off < 0
+	if advance {
+		off = f.position
+		if f.appending && op == OpWrite {
+			// Appends land at the end of the file wherever the position is
+			off = f.lastEnd
+		}
+	}
+	distance := off - f.lastEnd
+	f.lastEnd = off + int64(n)
+	if advance {
+		f.position = f.lastEnd
+	}
+	f.patternMu.Unlock()
+
+	f.collector.recordAccessPattern(op, distance)
+}
+
+// settleOpen records the pending open of the handle, if any, as a directory
+// open if dir is set and as a file open otherwise.
+func (f *MetricsFile) settleOpen(dir bool) {
+	p := f.pendingOpen.Swap(nil)
+	if p == nil {
+		return
+	}
+	f.collector.recordOpen(p.ctx, f.path, p.mode, p.duration, dir)
+	if dir && f.openFiles != nil {
+		f.openFiles.remove(f)
+	}
+}
+
+// markDir records that the handle refers to a directory.
+func (f *MetricsFile) markDir() {
+	f.isDir.Store(true)
+	f.settleOpen(true)
+}
+
 // Close closes the file.
 func (f *MetricsFile) Close() error {
-	start := time.Now()
-	err := f.file.Close()
-	duration := time.Since(start)
+	f.settleOpen(f.isDir.Load())
+	op := OpClose
+	if f.collector.config.EnableHandleKindDetection && f.isDir.Load() {
+		op = OpCloseDir
+	}
+
+	start := f.collector.startOperation(op)
+	err := f.collector.intercept(f.ctx, op, f.path, func() error {
+		return f.file.Close()
+	})
+	duration := f.collector.finishOperation(op, start)
 
-	f.collector.recordOperation("close", f.path, duration, 0, err)
+	f.collector.recordOperation(f.ctx, op, f.path, duration, 0, err)
 	f.collector.trackFileClose()
+	if f.openFiles != nil {
+		f.openFiles.remove(f)
+	}
+	f.released()
+	if f.handle != nil {
+		f.handle.closed.Store(f.collector.clock.Now().UnixNano())
+	}
+
+	if op == OpCloseDir {
+		f.collector.recordDirOperation(OpClose)
+	}
 
 	return err
 }
 
 // Stat returns file information.
 func (f *MetricsFile) Stat() (os.FileInfo, error) {
-	start := time.Now()
-	info, err := f.file.Stat()
-	duration := time.Since(start)
-
-	f.collector.recordOperation("stat", f.path, duration, 0, err)
+	start := f.collector.startOperation(OpStat)
+	info, err := interceptResult(f.collector, f.ctx, OpStat, f.path, func() (os.FileInfo, error) {
+		return f.file.Stat()
+	})
+	duration := f.collector.finishOperation(OpStat, start)
+
+	f.collector.recordOperation(f.ctx, OpStat, f.path, duration, 0, err)
+
+	if err == nil && info.IsDir() {
+		f.markDir()
+	} else if err == nil {
+		f.settleOpen(false)
+	}
 
 	return info, err
 }
 
 // Sync commits the current contents of the file to stable storage.
 func (f *MetricsFile) Sync() error {
-	start := time.Now()
-	err := f.file.Sync()
-	duration := time.Since(start)
+	start := f.collector.startOperation(OpSync)
+	err := f.collector.intercept(f.ctx, OpSync, f.path, func() error {
+		return f.file.Sync()
+	})
+	duration := f.collector.finishOperation(OpSync, start)
 
-	f.collector.recordOperation("sync", f.path, duration, 0, err)
+	f.collector.recordOperation(f.ctx, OpSync, f.path, duration, 0, err)
 
 	return err
 }
 
 // Truncate changes the size of the file.
 func (f *MetricsFile) Truncate(size int64) error {
-	start := time.Now()
-	err := f.file.Truncate(size)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("truncate", f.path, duration, 0, err)
+	start := f.collector.startOperation(OpTruncate)
+	err := f.collector.intercept(f.ctx, OpTruncate, f.path, func() error {
+		if err := f.collector.checkTruncate(f.path, f.maxSize, size, f.file.Stat); err != nil {
+			return err
+		}
+		return f.file.Truncate(size)
+	})
+	duration := f.collector.finishOperation(OpTruncate, start)
+
+	f.collector.recordOperation(f.ctx, OpTruncate, f.path, duration, 0, err)
+	if f.verifier != nil {
+		f.verifier.abandon()
+	}
 
 	return err
 }
 
 // Readdir reads directory entries.
 func (f *MetricsFile) Readdir(n int) ([]os.FileInfo, error) {
-	start := time.Now()
-	infos, err := f.file.Readdir(n)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("readdir", f.path, duration, 0, err)
-	f.collector.recordDirOperation("readdir")
+	start := f.collector.startOperation(OpReaddir)
+	infos, err := interceptResult(f.collector, f.ctx, OpReaddir, f.path, func() ([]os.FileInfo, error) {
+		return f.file.Readdir(n)
+	})
+	duration := f.collector.finishOperation(OpReaddir, start)
+
+	f.collector.recordOperation(f.ctx, OpReaddir, f.path, duration, 0, err)
+	f.collector.recordDirOperation(OpReaddir)
+	f.collector.recordTransfer(OpReaddir, 0, 0, err)
+	f.collector.recordReaddirEntries(len(infos), fileInfoNameBytes(infos), err)
+	if operationError(err) == nil {
+		f.markDir()
+	}
 
 	return infos, err
 }
 
 // Readdirnames reads directory entry names.
 func (f *MetricsFile) Readdirnames(n int) ([]string, error) {
-	start := time.Now()
-	names, err := f.file.Readdirnames(n)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("readdir", f.path, duration, 0, err)
-	f.collector.recordDirOperation("readdir")
+	start := f.collector.startOperation(OpReaddir)
+	names, err := interceptResult(f.collector, f.ctx, OpReaddir, f.path, func() ([]string, error) {
+		return f.file.Readdirnames(n)
+	})
+	duration := f.collector.finishOperation(OpReaddir, start)
+
+	f.collector.recordOperation(f.ctx, OpReaddir, f.path, duration, 0, err)
+	f.collector.recordDirOperation(OpReaddir)
+	f.collector.recordTransfer(OpReaddir, 0, 0, err)
+	f.collector.recordReaddirEntries(len(names), nameBytes(names), err)
+	if operationError(err) == nil {
+		f.markDir()
+	}
 
 	return names, err
 }
@@ -172,12 +453,46 @@ func (f *MetricsFile) Name() string {
 
 // ReadDir reads the contents of the directory and returns a slice of up to n DirEntry values.
 func (f *MetricsFile) ReadDir(n int) ([]fs.DirEntry, error) {
-	start := time.Now()
-	entries, err := f.file.ReadDir(n)
-	duration := time.Since(start)
-
-	f.collector.recordOperation("readdir", f.path, duration, 0, err)
-	f.collector.recordDirOperation("readdir")
+	start := f.collector.startOperation(OpReaddir)
+	entries, err := interceptResult(f.collector, f.ctx, OpReaddir, f.path, func() ([]fs.DirEntry, error) {
+		return f.file.ReadDir(n)
+	})
+	duration := f.collector.finishOperation(OpReaddir, start)
+
+	f.collector.recordOperation(f.ctx, OpReaddir, f.path, duration, 0, err)
+	f.collector.recordDirOperation(OpReaddir)
+	f.collector.recordTransfer(OpReaddir, 0, 0, err)
+	f.collector.recordReaddirEntries(len(entries), dirEntryNameBytes(entries), err)
+	if operationError(err) == nil {
+		f.markDir()
+	}
 
 	return entries, err
 }
+
+// nameBytes returns the total length of names.
+func nameBytes(names []string) int64 {
+	var n int64
+	for _, name := range names {
+		n += int64(len(name))
+	}
+	return n
+}
+
+// fileInfoNameBytes returns the total length of the names in infos.
+func fileInfoNameBytes(infos []os.FileInfo) int64 {
+	var n int64
+	for _, info := range infos {
+		n += int64(len(info.Name()))
+	}
+	return n
+}
+
+// dirEntryNameBytes returns the total length of the names in entries.
+func dirEntryNameBytes(entries []fs.DirEntry) int64 {
+	var n int64
+	for _, entry := range entries {
+		n += int64(len(entry.Name()))
+	}
+	return n
+}
@@ -0,0 +1,159 @@
+package metricsfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFileReadFromWriteTo(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := os.WriteFile(filepath.Join(dir, "src"), data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	fs := NewWithConfig(base, DefaultConfig())
+	c := fs.Collector()
+
+	src, err := fs.Open(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer src.Close()
+	dst, err := fs.Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer dst.Close()
+
+	// The copy is a single read and a single write
+	n, err := io.Copy(dst, src)
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("Expected to copy %d bytes, got %d, %v", len(data), n, err)
+	}
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("read", "success")); got != 1 {
+		t.Errorf("Expected 1 read, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("write", "success")); got != 1 {
+		t.Errorf("Expected 1 write, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.bytesReadTotal); got != float64(len(data)) {
+		t.Errorf("Expected %d bytes read, got %v", len(data), got)
+	}
+	if got := testutil.ToFloat64(c.bytesWrittenTotal); got != float64(len(data)) {
+		t.Errorf("Expected %d bytes written, got %v", len(data), got)
+	}
+
+	copied, err := os.ReadFile(filepath.Join(dir, "dst"))
+	if err != nil || !bytes.Equal(copied, data) {
+		t.Errorf("Expected the copy to match the source, got %d bytes, %v", len(copied), err)
+	}
+}
+
+func TestFileReadFromSizeLimit(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	dir := t.TempDir()
+
+	config := DefaultConfig()
+	config.MaxFileSizes = map[string]int64{"*": 8}
+	fs := NewWithConfig(base, config)
+
+	f, err := fs.Create(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer f.Close()
+
+	// io.LimitReader hides any WriteTo, so the copy goes through ReadFrom
+	_, err = io.Copy(f, io.LimitReader(bytes.NewReader(make([]byte, 64)), 64))
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "file")); info.Size() > 8 {
+		t.Errorf("Expected at most 8 bytes, got %d", info.Size())
+	}
+}
+
+// fileWriterFS is a mockFS with an optimized WriteFile.
+type fileWriterFS struct {
+	*mockFS
+	written map[string][]byte
+}
+
+func (m *fileWriterFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.written[name] = data
+	return nil
+}
+
+func TestReadFileWriteFile(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "file")
+	data := bytes.Repeat([]byte("x"), 4096)
+
+	fs := NewWithConfig(base, DefaultConfig())
+	c := fs.Collector()
+
+	if err := fs.WriteFile(name, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	read, err := fs.ReadFile(name)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Fatalf("Expected to read back %d bytes, got %d, %v", len(data), len(read), err)
+	}
+
+	// Each is a single operation, with no open, write or close
+	stats := c.Stats()
+	for op, want := range map[string]int64{"writefile": 1, "readfile": 1, "open": 0, "write": 0, "close": 0} {
+		if got := stats.Operations[op].Count; got != want {
+			t.Errorf("Expected %d %s operations, got %d", want, op, got)
+		}
+	}
+	if got := testutil.ToFloat64(c.bytesWrittenTotal); got != 4096 {
+		t.Errorf("Expected 4096 bytes written, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.bytesReadTotal); got != 4096 {
+		t.Errorf("Expected 4096 bytes read, got %v", got)
+	}
+}
+
+func TestWriteFileDelegates(t *testing.T) {
+	base := &fileWriterFS{mockFS: newMockFS(), written: make(map[string][]byte)}
+	config := DefaultConfig()
+	config.MaxFileSizes = map[string]int64{"/logs": 4}
+	fs := NewWithConfig(base, config)
+	c := fs.Collector()
+
+	if err := fs.WriteFile("/data/a", []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if string(base.written["/data/a"]) != "hello" {
+		t.Errorf("Expected the base WriteFile to be used, got %q", base.written)
+	}
+
+	if err := fs.WriteFile("/logs/a", []byte("hello"), 0644); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if _, ok := base.written["/logs/a"]; ok {
+		t.Error("Expected the oversized file not to be written")
+	}
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("writefile", "error")); got != 1 {
+		t.Errorf("Expected 1 failed writefile, got %v", got)
+	}
+}
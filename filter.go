@@ -0,0 +1,107 @@
+package metricsfs
+
+import "path"
+
+// OperationFilter selects the operations that are measured. Operations it
+// excludes are neither timed nor recorded, although files opened and closed
+// still count towards the open files; use it to skip frequent operations
+// that are cheap and of no interest, such as seeks and stats.
+type OperationFilter struct {
+	// Include lists the only operations measured (default: all)
+	Include []Op
+
+	// Exclude lists operations not measured, even if included
+	Exclude []Op
+}
+
+// operationFilter is the compiled form of an OperationFilter.
+type operationFilter struct {
+	include map[Op]bool // nil means all operations
+	exclude map[Op]bool
+}
+
+// newOperationFilter compiles f, or returns nil if it measures all
+// operations.
+func newOperationFilter(f OperationFilter) *operationFilter {
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return nil
+	}
+
+	filter := &operationFilter{exclude: make(map[Op]bool, len(f.Exclude))}
+	if len(f.Include) > 0 {
+		filter.include = make(map[Op]bool, len(f.Include))
+		for _, op := range f.Include {
+			filter.include[op] = true
+		}
+	}
+	for _, op := range f.Exclude {
+		filter.exclude[op] = true
+	}
+	return filter
+}
+
+// measured reports whether op passes the OperationFilter.
+func (c *Collector) measured(op Op) bool {
+	f := c.opFilter
+	if f == nil {
+		return true
+	}
+	return (f.include == nil || f.include[op]) && !f.exclude[op]
+}
+
+// pathFilter is the compiled form of Config.IncludePaths and
+// Config.ExcludePaths.
+type pathFilter struct {
+	include []string // nil means all paths
+	exclude []string
+}
+
+// newPathFilter compiles include and exclude, or returns nil if they measure
+// all paths.
+func newPathFilter(include, exclude []string) *pathFilter {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	clean := func(patterns []string) []string {
+		if len(patterns) == 0 {
+			return nil
+		}
+		cleaned := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			cleaned[i] = path.Clean(pattern)
+		}
+		return cleaned
+	}
+	return &pathFilter{include: clean(include), exclude: clean(exclude)}
+}
+
+// measured reports whether operations on name pass the filter.
+func (f *pathFilter) measured(name string) bool {
+	return (f.include == nil || matchPathGlob(name, f.include)) && !matchPathGlob(name, f.exclude)
+}
+
+// matchPathGlob reports whether name, or one of the directories it lies
+// under, matches one of patterns.
+func matchPathGlob(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	for p := path.Clean(name); ; p = path.Dir(p) {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+		if dir := path.Dir(p); dir == p {
+			return false
+		}
+	}
+}
+
+// passThrough reports whether operations on name bypass the instrumentation
+// because IncludePaths or ExcludePaths filter it out.
+func (m *MetricsFS) passThrough(name string) bool {
+	f := m.collector.live.Load().pathFilter
+	return f != nil && !f.measured(m.resolvePath(name))
+}
@@ -0,0 +1,141 @@
+package metricsfs
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOperationFilterExclude(t *testing.T) {
+	config := DefaultConfig()
+	config.OperationFilter = OperationFilter{Exclude: []Op{OpStat, OpSeek}}
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	if _, err := fs.Stat("/test.txt"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	f, err := fs.Open("/test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Seek(0, 0)
+	f.Stat()
+
+	stats := c.Stats()
+	if _, ok := stats.Operations["stat"]; ok {
+		t.Errorf("Expected stats to be excluded, got %v", stats.Operations["stat"])
+	}
+	if _, ok := stats.Operations["seek"]; ok {
+		t.Errorf("Expected seeks to be excluded, got %v", stats.Operations["seek"])
+	}
+	if stats.Operations["open"].Count != 1 {
+		t.Errorf("Expected 1 open, got %v", stats.Operations["open"])
+	}
+	if got := testutil.CollectAndCount(c.operationsInFlight); got != 1 {
+		t.Errorf("Expected in-flight series for open only, got %d", got)
+	}
+}
+
+func TestOperationFilterInclude(t *testing.T) {
+	config := DefaultConfig()
+	config.OperationFilter = OperationFilter{
+		Include: []Op{OpRead, OpWrite, OpOpen, OpClose},
+		Exclude: []Op{OpClose},
+	}
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	fs.Mkdir("/dir", 0755)
+	f, err := fs.Open("/test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Read(make([]byte, 8))
+	f.Close()
+
+	stats := c.Stats()
+	for _, op := range []string{"mkdir", "close"} {
+		if _, ok := stats.Operations[op]; ok {
+			t.Errorf("Expected %s to be excluded, got %v", op, stats.Operations[op])
+		}
+	}
+	for _, op := range []string{"open", "read"} {
+		if stats.Operations[op].Count != 1 {
+			t.Errorf("Expected 1 %s, got %v", op, stats.Operations[op])
+		}
+	}
+
+	// Excluded closes still close the file
+	if stats.OpenFiles != 0 {
+		t.Errorf("Expected no open files, got %d", stats.OpenFiles)
+	}
+	if got := testutil.ToFloat64(c.dirOperationsTotal.WithLabelValues("mkdir")); got != 0 {
+		t.Errorf("Expected excluded mkdir to skip directory metrics, got %v", got)
+	}
+}
+
+func TestConfigMergeOperationFilter(t *testing.T) {
+	base := Config{OperationFilter: OperationFilter{Exclude: []Op{OpStat}}}
+
+	if merged := base.Merge(Config{}); len(merged.OperationFilter.Exclude) != 1 {
+		t.Errorf("Expected the base filter to be kept, got %+v", merged.OperationFilter)
+	}
+	merged := base.Merge(Config{OperationFilter: OperationFilter{Include: []Op{OpRead}}})
+	if len(merged.OperationFilter.Exclude) != 0 || len(merged.OperationFilter.Include) != 1 {
+		t.Errorf("Expected the override filter to replace the base one, got %+v", merged.OperationFilter)
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	config := DefaultConfig()
+	config.IncludePaths = []string{"/data", "/home/*/cache/"}
+	config.ExcludePaths = []string{"/data/tmp"}
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	for _, name := range []string{"/data", "/data/a/b", "/home/ann/cache/x"} {
+		fs.Stat(name)
+	}
+	for _, name := range []string{"/database", "/data/tmp/x", "/home/ann/docs", "/proc/self"} {
+		fs.Stat(name)
+	}
+	if got := c.Stats().Operations["stat"].Count; got != 3 {
+		t.Errorf("Expected 3 measured stats, got %d", got)
+	}
+
+	// Files opened on filtered out paths are not wrapped
+	f, err := fs.Open("/data/tmp/x")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, ok := f.(*MetricsFile); ok {
+		t.Errorf("Expected a pass-through file for an excluded path")
+	}
+	f.Close()
+	if stats := c.Stats(); stats.Operations["open"].Count != 0 || stats.OpenFiles != 0 {
+		t.Errorf("Expected the excluded open to go unrecorded, got %+v", stats)
+	}
+}
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    bool
+	}{
+		{"/tmp", "/tmp", true},
+		{"/tmp/a/b", "/tmp", true},
+		{"/tmpfile", "/tmp", false},
+		{"/var/log/app.log", "/var/*/*.log", true},
+		{"/var/log/app.log/x", "/var/*/*.log", true},
+		{"/var/app.log", "/var/*/*.log", false},
+		{"tmp/a", "tmp", true},
+		{"/a", "[", false},
+	}
+	for _, tt := range tests {
+		if got := matchPathGlob(tt.name, []string{tt.pattern}); got != tt.want {
+			t.Errorf("matchPathGlob(%q, %q) = %v, want %v", tt.name, tt.pattern, got, tt.want)
+		}
+	}
+}
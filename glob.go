@@ -0,0 +1,151 @@
+package metricsfs
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/absfs/absfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Globber is implemented by filesystems with their own Glob, such as ones
+// that can match patterns server-side.
+type Globber interface {
+	Glob(pattern string) ([]string, error)
+}
+
+// Glob returns the names of all files matching pattern, with the syntax of
+// path.Match, or nil if there is none. It delegates to the wrapped
+// filesystem's Glob when it has one, and otherwise matches the pattern
+// against the directories it names, like filepath.Glob, without recording
+// their Lstat and ReadDir calls individually. The glob is recorded as a
+// single operation and its number of matches in glob_matches. The only
+// possible error is path.ErrBadPattern, or one returned by the wrapped
+// filesystem's Glob.
+func (m *MetricsFS) Glob(pattern string) ([]string, error) {
+	if m.passThrough(pattern) {
+		return m.glob(pattern)
+	}
+
+	start := m.collector.startOperation(OpGlob)
+	matches, err := interceptResult(m.collector, m.ctx, OpGlob, pattern, func() ([]string, error) {
+		return m.glob(pattern)
+	})
+	duration := m.collector.finishOperation(OpGlob, start)
+
+	path := m.metricPath(OpGlob, pattern)
+	m.collector.recordOperation(m.ctx, OpGlob, path, duration, 0, err)
+	if err == nil {
+		m.collector.recordGlobMatches(len(matches))
+	}
+
+	return matches, err
+}
+
+// glob returns the matches of pattern on the wrapped filesystem.
+func (m *MetricsFS) glob(pattern string) ([]string, error) {
+	if g, ok := m.fs.(Globber); ok {
+		return g.Glob(pattern)
+	}
+	return glob(m.fs, pattern)
+}
+
+// glob is filepath.Glob over fsys, with slash-separated paths.
+func glob(fsys absfs.FileSystem, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasGlobMeta(pattern) {
+		if _, err := lstat(fsys, pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := path.Split(pattern)
+	switch dir {
+	case "":
+		dir = "."
+	case "/":
+	default:
+		dir = dir[:len(dir)-1]
+	}
+	if !hasGlobMeta(dir) {
+		return globDir(fsys, dir, file, nil), nil
+	}
+
+	// Prevent infinite recursion on patterns such as "[/]"
+	if dir == pattern {
+		return nil, path.ErrBadPattern
+	}
+
+	dirs, err := glob(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches = globDir(fsys, d, file, matches)
+	}
+	return matches, nil
+}
+
+// globDir appends the names in dir matching pattern to matches. Directories
+// that cannot be read have no matches.
+func globDir(fsys absfs.FileSystem, dir, pattern string, matches []string) []string {
+	info, err := fsys.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return matches
+	}
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return matches
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ok, _ := path.Match(pattern, name); ok {
+			if dir == "." {
+				matches = append(matches, name)
+			} else {
+				matches = append(matches, path.Join(dir, name))
+			}
+		}
+	}
+	return matches
+}
+
+// hasGlobMeta reports whether pattern contains any of the magic characters
+// recognized by path.Match.
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// initGlobMetrics creates the glob metrics.
+func (c *Collector) initGlobMetrics(config Config) {
+	c.globMatches = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "glob_matches",
+			Help:        "Number of names matched per glob",
+			Buckets:     []float64{0, 1, 10, 100, 1000, 10000, 100000},
+			ConstLabels: config.constLabelsFor("glob_matches"),
+		},
+		nil,
+	)
+}
+
+// recordGlobMatches records the number of matches of a glob.
+func (c *Collector) recordGlobMatches(n int) {
+	if c.closed.Load() {
+		return
+	}
+	c.globMatches.WithLabelValues().Observe(float64(n))
+}
@@ -0,0 +1,100 @@
+package metricsfs
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// globberMockFS is a mockFS with its own Glob.
+type globberMockFS struct {
+	mockFS
+	patterns []string
+}
+
+func (m *globberMockFS) Glob(pattern string) ([]string, error) {
+	m.patterns = append(m.patterns, pattern)
+	return []string{"/logs/a.log", "/logs/b.log"}, nil
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app/a.log", "app/b.txt", "web/c.log", "web/old/d.log"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	fs := New(base)
+	c := fs.Collector()
+	root := filepath.ToSlash(dir)
+
+	for _, tt := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"*/*.log", []string{"app/a.log", "web/c.log"}},
+		{"web/*", []string{"web/c.log", "web/old"}},
+		{"app/a.log", []string{"app/a.log"}},
+		{"app/missing.log", nil},
+		{"none/*", nil},
+	} {
+		matches, err := fs.Glob(path.Join(root, tt.pattern))
+		if err != nil {
+			t.Errorf("Glob(%q) failed: %v", tt.pattern, err)
+			continue
+		}
+		var want []string
+		for _, name := range tt.want {
+			want = append(want, path.Join(root, name))
+		}
+		if !reflect.DeepEqual(matches, want) {
+			t.Errorf("Glob(%q) = %v, expected %v", tt.pattern, matches, want)
+		}
+	}
+
+	if _, err := fs.Glob(root + "/[a-"); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("Expected ErrBadPattern, got %v", err)
+	}
+
+	// Each glob is a single operation
+	stats := c.Stats()
+	if got := stats.Operations["glob"].Count; got != 6 {
+		t.Errorf("Expected 6 globs, got %d", got)
+	}
+	if got := stats.Operations["readdir"].Count + stats.Operations["lstat"].Count; got != 0 {
+		t.Errorf("Expected no readdir or lstat operations, got %d", got)
+	}
+	if got := histogramCount(t, c.globMatches); got != 5 {
+		t.Errorf("Expected 5 match counts, got %d", got)
+	}
+}
+
+func TestGlobDelegates(t *testing.T) {
+	base := &globberMockFS{mockFS: *newMockFS()}
+	fs := New(base)
+
+	matches, err := fs.Glob("/logs/*.log")
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %v, %v", matches, err)
+	}
+	if !reflect.DeepEqual(base.patterns, []string{"/logs/*.log"}) {
+		t.Errorf("Expected the base Glob to be used, got %v", base.patterns)
+	}
+	if got := testutil.ToFloat64(fs.Collector().operationsTotal.WithLabelValues("glob", "success")); got != 1 {
+		t.Errorf("Expected 1 glob, got %v", got)
+	}
+}
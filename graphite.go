@@ -0,0 +1,259 @@
+package metricsfs
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GraphiteConfig configures a GraphiteRecorder.
+type GraphiteConfig struct {
+	// Address is the host:port of the Graphite plaintext listener, e.g.
+	// "graphite:2003"
+	Address string
+
+	// Prefix is prepended to every metric path (default: "metricsfs")
+	Prefix string
+
+	// FlushInterval is the interval between flushes (default: 10s)
+	FlushInterval time.Duration
+
+	// ByInstance writes the operations of each filesystem under
+	// <prefix>.<instance>, for recorders shared by several filesystems. Set
+	// Config.Instance, since generated instance IDs change on every restart
+	ByInstance bool
+
+	// Timeout bounds connecting and writing each flush (default: 5s)
+	Timeout time.Duration
+
+	// OnError is called with the error of each failed flush
+	OnError func(err error)
+}
+
+// applyDefaults fills in the zero fields of config.
+func (config *GraphiteConfig) applyDefaults() {
+	if config.Prefix == "" {
+		config.Prefix = "metricsfs"
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 10 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+}
+
+// GraphiteRecorder aggregates operations and sends them to Graphite in its
+// plaintext format every flush interval. Install its Record method as
+// Config.OnOperation:
+//
+//	graphite := metricsfs.NewGraphiteRecorder(metricsfs.GraphiteConfig{Address: "graphite:2003"})
+//	defer graphite.Close()
+//	config.OnOperation = graphite.Record
+//
+// Each flush writes, for every operation performed during the interval, the
+// counters <prefix>.<op>.count, .errors and .bytes and the timers
+// <prefix>.<op>.timer.mean, .min and .max in milliseconds, under
+// <prefix>.<instance>.<op> with GraphiteConfig.ByInstance.
+// Counts are those of the interval, as StatsD flushes them, so that Graphite
+// stores rates without derivative functions.
+type GraphiteRecorder struct {
+	config GraphiteConfig
+
+	mu     sync.Mutex
+	stats  map[graphiteKey]*graphiteStats
+	closed bool
+
+	// connMu serializes flushes, so that Record never waits for the network
+	connMu sync.Mutex
+	conn   net.Conn
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// graphiteKey identifies the operations aggregated together.
+type graphiteKey struct {
+	instance string
+	op       string
+}
+
+// graphiteStats aggregates the operations of an interval.
+type graphiteStats struct {
+	count, errors, bytes int64
+	sum, min, max        time.Duration
+}
+
+// NewGraphiteRecorder creates a GraphiteRecorder and starts flushing it. The
+// connection is established on the first flush and re-established after
+// write errors.
+func NewGraphiteRecorder(config GraphiteConfig) *GraphiteRecorder {
+	config.applyDefaults()
+	g := &GraphiteRecorder{
+		config:  config,
+		stats:   make(map[graphiteKey]*graphiteStats),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(g.stopped)
+		ticker := time.NewTicker(config.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.Flush()
+			case <-g.stop:
+				return
+			}
+		}
+	}()
+	return g
+}
+
+// Record adds op to the current interval. It is safe for concurrent use and
+// does not block on the network.
+func (g *GraphiteRecorder) Record(op Operation) {
+	key := graphiteKey{op: op.Name}
+	if g.config.ByInstance {
+		key.instance = op.Instance
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return
+	}
+	s, ok := g.stats[key]
+	if !ok {
+		s = &graphiteStats{min: op.Duration, max: op.Duration}
+		g.stats[key] = s
+	}
+	s.count++
+	if op.Error != nil {
+		s.errors++
+	}
+	s.bytes += op.BytesTransferred
+	s.sum += op.Duration
+	s.min = min(s.min, op.Duration)
+	s.max = max(s.max, op.Duration)
+}
+
+// Flush sends the operations recorded since the last flush. They are
+// dropped if sending fails.
+func (g *GraphiteRecorder) Flush() error {
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+	return g.flush()
+}
+
+// Close stops flushing, sends the operations recorded since the last flush
+// and closes the connection. Operations recorded after Close are ignored.
+func (g *GraphiteRecorder) Close() error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil
+	}
+	g.closed = true
+	g.mu.Unlock()
+
+	close(g.stop)
+	<-g.stopped
+
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+	err := g.flush()
+	if g.conn != nil {
+		g.conn.Close()
+		g.conn = nil
+	}
+	return err
+}
+
+// flush writes and resets the recorded operations. g.connMu must be held.
+func (g *GraphiteRecorder) flush() error {
+	g.mu.Lock()
+	stats := g.stats
+	g.stats = make(map[graphiteKey]*graphiteStats)
+	g.mu.Unlock()
+	if len(stats) == 0 {
+		return nil
+	}
+
+	err := g.write(stats)
+	if err != nil {
+		if g.conn != nil {
+			g.conn.Close()
+			g.conn = nil
+		}
+		err = fmt.Errorf("metricsfs: graphite: %w", err)
+		if g.config.OnError != nil {
+			g.config.OnError(err)
+		}
+	}
+	return err
+}
+
+// write sends stats to Graphite in its plaintext format.
+func (g *GraphiteRecorder) write(stats map[graphiteKey]*graphiteStats) error {
+	if g.conn == nil {
+		conn, err := net.DialTimeout("tcp", g.config.Address, g.config.Timeout)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+	if err := g.conn.SetWriteDeadline(time.Now().Add(g.config.Timeout)); err != nil {
+		return err
+	}
+
+	keys := make([]graphiteKey, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].instance != keys[j].instance {
+			return keys[i].instance < keys[j].instance
+		}
+		return keys[i].op < keys[j].op
+	})
+
+	now := time.Now().Unix()
+	w := bufio.NewWriter(g.conn)
+	for _, key := range keys {
+		s := stats[key]
+		path := g.config.Prefix
+		if key.instance != "" {
+			path += "." + graphiteSanitize(key.instance)
+		}
+		path += "." + graphiteSanitize(key.op)
+
+		fmt.Fprintf(w, "%s.count %d %d\n", path, s.count, now)
+		fmt.Fprintf(w, "%s.errors %d %d\n", path, s.errors, now)
+		fmt.Fprintf(w, "%s.bytes %d %d\n", path, s.bytes, now)
+		fmt.Fprintf(w, "%s.timer.mean %g %d\n", path, milliseconds(s.sum)/float64(s.count), now)
+		fmt.Fprintf(w, "%s.timer.min %g %d\n", path, milliseconds(s.min), now)
+		fmt.Fprintf(w, "%s.timer.max %g %d\n", path, milliseconds(s.max), now)
+	}
+	return w.Flush()
+}
+
+// graphiteSanitizer replaces the characters that would split or break a
+// Graphite metric path node.
+var graphiteSanitizer = strings.NewReplacer(".", "_", " ", "_", "/", "_", "\n", "_")
+
+// graphiteSanitize returns s as a single Graphite path node.
+func graphiteSanitize(s string) string {
+	return graphiteSanitizer.Replace(s)
+}
+
+// milliseconds returns d in fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
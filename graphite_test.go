@@ -0,0 +1,197 @@
+package metricsfs
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// graphiteServer is a Graphite plaintext listener recording the lines it
+// receives.
+type graphiteServer struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	lines  []string
+	closed int // connections closed by the client
+}
+
+func newGraphiteServer(t *testing.T) *graphiteServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	s := &graphiteServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					s.mu.Lock()
+					s.lines = append(s.lines, scanner.Text())
+					s.mu.Unlock()
+				}
+				s.mu.Lock()
+				s.closed++
+				s.mu.Unlock()
+			}()
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// received waits for a connection to be closed by the client and returns
+// the metric paths and values received, without timestamps.
+func (s *graphiteServer) received(t *testing.T) map[string]string {
+	deadline := time.Now().Add(5 * time.Second)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.closed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the connection to be closed")
+		}
+		s.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		s.mu.Lock()
+	}
+	metrics := make(map[string]string)
+	for _, line := range s.lines {
+		fields := strings.Fields(line)
+		if len(fields) == 3 {
+			metrics[fields[0]] = fields[1]
+		}
+	}
+	return metrics
+}
+
+func TestGraphiteRecorder(t *testing.T) {
+	server := newGraphiteServer(t)
+	graphite := NewGraphiteRecorder(GraphiteConfig{
+		Address:       server.ln.Addr().String(),
+		Prefix:        "storage",
+		FlushInterval: time.Hour,
+	})
+
+	config := DefaultConfig()
+	config.OnOperation = graphite.Record
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, err := fs.OpenFile("/a.txt", 0, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Write([]byte("world!"))
+	f.Close()
+
+	if err := graphite.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	metrics := server.received(t)
+
+	for path, want := range map[string]string{
+		"storage.write.count":  "2",
+		"storage.write.errors": "0",
+		"storage.write.bytes":  "11",
+		"storage.open.count":   "1",
+		"storage.close.count":  "1",
+	} {
+		if got := metrics[path]; got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	for _, path := range []string{"storage.write.timer.mean", "storage.write.timer.min", "storage.write.timer.max"} {
+		if _, ok := metrics[path]; !ok {
+			t.Errorf("Expected the timer %s", path)
+		}
+	}
+	if _, ok := metrics["storage.stat.count"]; ok {
+		t.Error("Expected no metrics for operations not performed")
+	}
+
+	// Operations after Close are ignored
+	graphite.Record(Operation{Name: "stat"})
+	if err := graphite.Flush(); err != nil {
+		t.Errorf("Flush after Close = %v", err)
+	}
+}
+
+func TestGraphiteRecorderInstanceAndErrors(t *testing.T) {
+	server := newGraphiteServer(t)
+	graphite := NewGraphiteRecorder(GraphiteConfig{
+		Address:    server.ln.Addr().String(),
+		ByInstance: true,
+	})
+
+	graphite.Record(Operation{Name: "stat", Instance: "s3.us-east", Duration: 2 * time.Millisecond})
+	graphite.Record(Operation{Name: "stat", Instance: "s3.us-east", Duration: 4 * time.Millisecond, Error: errors.New("boom")})
+	graphite.Close()
+	metrics := server.received(t)
+
+	for path, want := range map[string]string{
+		"metricsfs.s3_us-east.stat.count":      "2",
+		"metricsfs.s3_us-east.stat.errors":     "1",
+		"metricsfs.s3_us-east.stat.timer.mean": "3",
+		"metricsfs.s3_us-east.stat.timer.min":  "2",
+		"metricsfs.s3_us-east.stat.timer.max":  "4",
+	} {
+		if got := metrics[path]; got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestGraphiteRecorderFlushesPeriodically(t *testing.T) {
+	server := newGraphiteServer(t)
+	graphite := NewGraphiteRecorder(GraphiteConfig{
+		Address:       server.ln.Addr().String(),
+		FlushInterval: 10 * time.Millisecond,
+	})
+	defer graphite.Close()
+
+	graphite.Record(Operation{Name: "read", BytesTransferred: 7})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		server.mu.Lock()
+		n := len(server.lines)
+		server.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a periodic flush")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGraphiteRecorderReportsErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var errs []error
+	graphite := NewGraphiteRecorder(GraphiteConfig{
+		Address:       addr,
+		FlushInterval: time.Hour,
+		OnError:       func(err error) { errs = append(errs, err) },
+	})
+	graphite.Record(Operation{Name: "stat"})
+	if err := graphite.Close(); err == nil {
+		t.Error("Expected an error for an unreachable address")
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 flush error, got %v", errs)
+	}
+}
@@ -0,0 +1,42 @@
+package metricsfs
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+)
+
+// handleIDKey is the context key holding the ID of the handle an operation
+// is issued on.
+type handleIDKey struct{}
+
+// newHandleID returns a random 16 hex digit handle ID.
+func newHandleID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+// withHandleID returns ctx carrying a new handle ID.
+func withHandleID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, handleIDKey{}, newHandleID())
+}
+
+// HandleID returns the ID of the file handle an operation was issued on, or
+// "" if ctx carries none. Handles are only assigned IDs with
+// Config.EnableHandleIDs or OTelConfig.HandleIDs; use it in interceptors or
+// ContextLabels to correlate their output with spans and audit records.
+func HandleID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(handleIDKey{}).(string)
+	return id
+}
+
+// withHandleID returns ctx carrying a new handle ID when handle IDs are
+// enabled, and ctx unchanged otherwise.
+func (c *Collector) withHandleID(ctx context.Context) context.Context {
+	if !c.config.EnableHandleIDs {
+		return ctx
+	}
+	return withHandleID(ctx)
+}
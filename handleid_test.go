@@ -0,0 +1,106 @@
+package metricsfs
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestHandleIDs(t *testing.T) {
+	var buf bytes.Buffer
+	var ops []Operation
+	config := DefaultConfig()
+	config.EnableHandleIDs = true
+	config.Audit = AuditConfig{Writer: &buf}
+	config.OnOperation = func(op Operation) { ops = append(ops, op) }
+	fs := NewWithConfig(newMockFS(), config)
+
+	// The same path open twice gets two handles
+	f1, err := fs.Create("/data")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f2, err := fs.OpenFile("/data", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f1.Write([]byte("hello"))
+	f2.Close()
+	f1.Close()
+	fs.Stat("/data")
+
+	entries := auditEntries(t, &buf)
+	if len(entries) != 6 {
+		t.Fatalf("Expected 6 audit entries, got %d: %+v", len(entries), entries)
+	}
+	id1, id2 := entries[0].Handle, entries[1].Handle
+	if len(id1) != 16 || len(id2) != 16 || id1 == id2 {
+		t.Fatalf("Expected two distinct 16 digit handle IDs, got %q and %q", id1, id2)
+	}
+	for i, want := range []string{id1, id2, id1, id2, id1, ""} {
+		if entries[i].Handle != want {
+			t.Errorf("Entry %d (%s): expected handle %q, got %q", i, entries[i].Operation, want, entries[i].Handle)
+		}
+		if ops[i].HandleID != want {
+			t.Errorf("Operation %d (%s): expected handle %q, got %q", i, ops[i].Name, want, ops[i].HandleID)
+		}
+	}
+}
+
+func TestHandleIDsDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	config := DefaultConfig()
+	config.Audit = AuditConfig{Writer: &buf}
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, err := fs.Create("/data")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Close()
+
+	for _, entry := range auditEntries(t, &buf) {
+		if entry.Handle != "" {
+			t.Errorf("Expected no handle ID without EnableHandleIDs, got %q", entry.Handle)
+		}
+	}
+}
+
+func TestOTelHandleIDs(t *testing.T) {
+	tp := &recordingTracerProvider{}
+	fs, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:  noop.NewMeterProvider(),
+		TracerProvider: tp,
+		EnableTracing:  true,
+		HandleIDs:      true,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	f, err := fs.Create("/data")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+	fs.Stat("/data")
+
+	if len(tp.spans) != 4 {
+		t.Fatalf("Expected 4 spans, got %d", len(tp.spans))
+	}
+	id := tp.spans[0].attributes["fs.handle.id"].AsString()
+	if len(id) != 16 {
+		t.Fatalf("Expected a 16 digit handle ID on the Create span, got %q", id)
+	}
+	for _, span := range tp.spans[1:3] {
+		if got := span.attributes["fs.handle.id"].AsString(); got != id {
+			t.Errorf("%s span: expected handle ID %q, got %q", span.name, id, got)
+		}
+	}
+	if _, ok := tp.spans[3].attributes["fs.handle.id"]; ok {
+		t.Errorf("Expected no handle ID on the Stat span")
+	}
+}
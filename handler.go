@@ -0,0 +1,145 @@
+package metricsfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// StatsHandler returns an http.Handler that serves the collector's Stats as
+// JSON, or as human-readable text when requested with ?format=text. It does
+// not require a Prometheus registry.
+func (c *Collector) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := c.Stats()
+
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			stats.WriteText(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(stats)
+	})
+}
+
+// StatsHandler returns an http.Handler that serves the filesystem's stats.
+// See Collector.StatsHandler.
+func (m *MetricsFS) StatsHandler() http.Handler {
+	return m.collector.StatsHandler()
+}
+
+// MetricsHandlerConfig configures a handler created with
+// Collector.MetricsHandlerWithConfig.
+type MetricsHandlerConfig struct {
+	// OmitCreated leaves out the _created series, holding the creation time
+	// of each counter, histogram and summary, which scrapers use to detect
+	// resets but which add a series per metric
+	OmitCreated bool
+
+	// Exemplars serves the trace exemplars attached to latency histograms of
+	// operations issued with a traced context
+	Exemplars bool
+}
+
+// MetricsHandler returns an http.Handler serving the collector's metrics
+// alone, in the OpenMetrics format with _created series to scrapers that
+// accept it and in the Prometheus text format otherwise. It uses a registry
+// of its own, so services exposing only this endpoint need not manage one.
+// Exemplars are not served; see MetricsHandlerWithConfig.
+func (c *Collector) MetricsHandler() http.Handler {
+	return c.MetricsHandlerWithConfig(MetricsHandlerConfig{})
+}
+
+// MetricsHandlerWithConfig returns an http.Handler serving the collector's
+// metrics as configured by config. See MetricsHandler.
+func (c *Collector) MetricsHandlerWithConfig(config MetricsHandlerConfig) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	var gatherer prometheus.Gatherer = registry
+	if !config.Exemplars {
+		gatherer = prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			families, err := registry.Gather()
+			for _, family := range families {
+				for _, m := range family.GetMetric() {
+					stripExemplars(m)
+				}
+			}
+			return families, err
+		})
+	}
+
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics:                   true,
+		EnableOpenMetricsTextCreatedSamples: !config.OmitCreated,
+	})
+}
+
+// stripExemplars removes the exemplars of m.
+func stripExemplars(m *dto.Metric) {
+	if counter := m.GetCounter(); counter != nil {
+		counter.Exemplar = nil
+	}
+	if h := m.GetHistogram(); h != nil {
+		for _, b := range h.GetBucket() {
+			b.Exemplar = nil
+		}
+		h.Exemplars = nil
+	}
+}
+
+// MetricsHandler returns an http.Handler serving the filesystem's metrics.
+// See Collector.MetricsHandler.
+func (m *MetricsFS) MetricsHandler() http.Handler {
+	return m.collector.MetricsHandler()
+}
+
+// MetricsHandlerWithConfig returns an http.Handler serving the filesystem's
+// metrics as configured by config. See Collector.MetricsHandlerWithConfig.
+func (m *MetricsFS) MetricsHandlerWithConfig(config MetricsHandlerConfig) http.Handler {
+	return m.collector.MetricsHandlerWithConfig(config)
+}
+
+// WriteText writes a human-readable summary of s to w.
+func (s Stats) WriteText(w io.Writer) error {
+	ops := make([]string, 0, len(s.Operations))
+	for op := range s.Operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "version:        %s (schema %d)\n", s.Version, s.SchemaVersion)
+	fmt.Fprintf(&b, "timestamp:      %s\n", s.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(&b, "bytes read:     %d\n", s.BytesRead)
+	fmt.Fprintf(&b, "bytes written:  %d\n", s.BytesWritten)
+	fmt.Fprintf(&b, "open files:     %d (max %d)\n", s.OpenFiles, s.OpenFilesMax)
+	fmt.Fprintf(&b, "in flight:      %d\n", s.InFlight)
+	fmt.Fprintf(&b, "\n%-12s %12s %12s\n", "operation", "count", "errors")
+	for _, op := range ops {
+		stats := s.Operations[op]
+		fmt.Fprintf(&b, "%-12s %12d %12d\n", op, stats.Count, stats.Errors)
+	}
+
+	if len(s.Transitions) > 0 {
+		fmt.Fprintf(&b, "\n%-25s %-12s %s\n", "time", "mode", "state")
+		for _, t := range s.Transitions {
+			fmt.Fprintf(&b, "%-25s %-12s %s\n", t.Time.Format(time.RFC3339), t.Mode, t.State)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
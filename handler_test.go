@@ -0,0 +1,158 @@
+package metricsfs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStatsHandlerJSON(t *testing.T) {
+	fs := New(newMockFS())
+	fs.Stat("/test.txt")
+
+	rec := httptest.NewRecorder()
+	fs.StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", ct)
+	}
+
+	var stats Stats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.Operations["stat"].Count != 1 {
+		t.Errorf("Expected 1 stat, got %d", stats.Operations["stat"].Count)
+	}
+	if stats.SchemaVersion != StatsSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", StatsSchemaVersion, stats.SchemaVersion)
+	}
+	if stats.Version == "" {
+		t.Error("Expected version to be set")
+	}
+}
+
+func TestStatsHandlerJSONFieldNames(t *testing.T) {
+	fs := New(newMockFS())
+
+	rec := httptest.NewRecorder()
+	fs.StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	// Downstream tooling depends on these names for schema version 1
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(rec.Body).Decode(&raw); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	for _, field := range []string{"schema_version", "version", "timestamp", "operations",
+		"bytes_read", "bytes_written", "open_files", "open_files_max", "in_flight"} {
+		if _, ok := raw[field]; !ok {
+			t.Errorf("Expected field %q in JSON stats", field)
+		}
+	}
+}
+
+func TestStatsHandlerText(t *testing.T) {
+	fs := New(newMockFS())
+	fs.Stat("/test.txt")
+
+	rec := httptest.NewRecorder()
+	fs.StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats?format=text", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text content type, got %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "stat") {
+		t.Errorf("Expected stat operation in text output, got:\n%s", body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "schema 1") {
+		t.Errorf("Expected schema version in text output, got:\n%s", body)
+	}
+}
+
+// openMetricsRequest returns a scrape request accepting OpenMetrics.
+func openMetricsRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	return req
+}
+
+func TestMetricsHandlerOpenMetrics(t *testing.T) {
+	fs := New(newMockFS())
+	fs.Stat("/test.txt")
+
+	rec := httptest.NewRecorder()
+	fs.MetricsHandler().ServeHTTP(rec, openMetricsRequest())
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("Expected OpenMetrics content type, got %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`fs_operations_total{operation="stat",status="success"} 1`,
+		`fs_operations_created{operation="stat",status="success"}`,
+		"# EOF",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsHandlerTextFormat(t *testing.T) {
+	fs := New(newMockFS())
+	fs.Stat("/test.txt")
+
+	rec := httptest.NewRecorder()
+	fs.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text content type, got %q", ct)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `fs_operations_total{operation="stat",status="success"} 1`) {
+		t.Errorf("Expected the stat counter in:\n%s", body)
+	}
+	if strings.Contains(body, "_created") {
+		t.Error("Expected no _created series in the text format")
+	}
+}
+
+func TestMetricsHandlerWithConfig(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	fs := New(newMockFS())
+	fs.WithContext(ctx).Stat("/traced")
+
+	exemplar := `trace_id="` + traceID.String() + `"`
+	tests := []struct {
+		config    MetricsHandlerConfig
+		created   bool
+		exemplars bool
+	}{
+		{MetricsHandlerConfig{}, true, false},
+		{MetricsHandlerConfig{OmitCreated: true, Exemplars: true}, false, true},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		fs.MetricsHandlerWithConfig(tt.config).ServeHTTP(rec, openMetricsRequest())
+		body := rec.Body.String()
+
+		if got := strings.Contains(body, "_created"); got != tt.created {
+			t.Errorf("%+v: _created series served = %v, want %v", tt.config, got, tt.created)
+		}
+		if got := strings.Contains(body, exemplar); got != tt.exemplars {
+			t.Errorf("%+v: exemplars served = %v, want %v", tt.config, got, tt.exemplars)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package metricsfs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthConfig configures the conditions evaluated by MetricsFS.Healthy.
+// Each condition is disabled when its threshold is left at zero.
+type HealthConfig struct {
+	// ProbePath is stat'ed on the underlying filesystem to verify it is
+	// reachable. The probe runs at most once per ProbeInterval.
+	ProbePath string
+
+	// ProbeInterval is the minimum time between probes (default: 10s)
+	ProbeInterval time.Duration
+
+	// MaxErrorRate is the highest tolerated fraction of failed operations
+	// (0.0 to 1.0) within ErrorRateWindow
+	MaxErrorRate float64
+
+	// ErrorRateWindow is the window over which the error rate is measured (default: 1m)
+	ErrorRateWindow time.Duration
+
+	// MinOperations is the minimum number of operations in the window before
+	// the error rate is evaluated (default: 10)
+	MinOperations int64
+
+	// StallTimeout marks the filesystem unhealthy when operations are in
+	// flight but none has completed for this long
+	StallTimeout time.Duration
+
+	// CheckInterval, when set, evaluates the conditions in the background
+	// at this interval until the collector is closed, keeping the
+	// fs_degraded gauge and Config.OnDegraded current without callers
+	// invoking CheckHealth
+	CheckInterval time.Duration
+}
+
+// Health conditions, as reported in HealthStatus.Conditions and the reason
+// label of the fs_degraded gauge.
+const (
+	// ConditionProbe fails when the ProbePath stat fails
+	ConditionProbe = "probe"
+
+	// ConditionErrorRate fails when the error rate exceeds MaxErrorRate
+	ConditionErrorRate = "error_rate"
+
+	// ConditionStall fails when operations stall for StallTimeout
+	ConditionStall = "stall"
+)
+
+// healthConditions are all health conditions.
+var healthConditions = []string{ConditionProbe, ConditionErrorRate, ConditionStall}
+
+// applyDefaults fills in default values for unset health options.
+func (h *HealthConfig) applyDefaults() {
+	if h.ProbeInterval == 0 {
+		h.ProbeInterval = 10 * time.Second
+	}
+	if h.ErrorRateWindow == 0 {
+		h.ErrorRateWindow = time.Minute
+	}
+	if h.MinOperations == 0 {
+		h.MinOperations = 10
+	}
+}
+
+// HealthStatus is the result of a health check.
+type HealthStatus struct {
+	// Healthy is true when all configured conditions hold
+	Healthy bool
+
+	// Reasons describes the conditions that failed
+	Reasons []string
+
+	// Conditions lists the conditions that failed, such as ConditionProbe,
+	// in the same order as Reasons
+	Conditions []string
+
+	// ErrorRate is the fraction of failed operations in the most recent
+	// window with at least MinOperations operations. It is reset to 0 when
+	// a window ends with fewer operations
+	ErrorRate float64
+
+	// InFlight is the number of operations currently in progress
+	InFlight int64
+}
+
+// healthChecker holds the state needed to evaluate health conditions.
+type healthChecker struct {
+	config HealthConfig
+
+	mu          sync.Mutex
+	lastProbe   time.Time
+	probeErr    error
+	windowStart time.Time
+	windowOps   int64
+	windowErrs  int64
+	errorRate   float64
+}
+
+// newHealthChecker creates a health checker for the given configuration.
+func newHealthChecker(config HealthConfig) *healthChecker {
+	config.applyDefaults()
+	return &healthChecker{config: config}
+}
+
+// Healthy reports whether the filesystem satisfies all configured health
+// conditions. See HealthConfig.
+func (m *MetricsFS) Healthy() bool {
+	return m.CheckHealth().Healthy
+}
+
+// CheckHealth evaluates the configured health conditions and reports which
+// of them failed.
+func (m *MetricsFS) CheckHealth() HealthStatus {
+	h := m.health
+	c := m.collector
+	now := c.clock.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := HealthStatus{Healthy: true, InFlight: c.inFlightCount()}
+	fail := func(condition, format string, args ...interface{}) {
+		status.Healthy = false
+		status.Reasons = append(status.Reasons, fmt.Sprintf(format, args...))
+		status.Conditions = append(status.Conditions, condition)
+	}
+
+	// Probe the underlying filesystem
+	if h.config.ProbePath != "" {
+		if h.lastProbe.IsZero() || now.Sub(h.lastProbe) >= h.config.ProbeInterval {
+			_, h.probeErr = m.fs.Stat(h.config.ProbePath)
+			h.lastProbe = now
+		}
+		if h.probeErr != nil {
+			fail(ConditionProbe, "probe of %s failed: %v", h.config.ProbePath, h.probeErr)
+		}
+	}
+
+	// Error rate over a tumbling window
+	c.flushShards()
+	ops, errs := c.totalOperations.Load(), c.totalErrors.Load()
+	if h.windowStart.IsZero() {
+		h.windowStart, h.windowOps, h.windowErrs = now, ops, errs
+	}
+	if n := ops - h.windowOps; n >= h.config.MinOperations && n > 0 {
+		h.errorRate = float64(errs-h.windowErrs) / float64(n)
+	}
+	if now.Sub(h.windowStart) >= h.config.ErrorRateWindow {
+		// A window too quiet to measure clears the rate, so that a burst of
+		// errors followed by low traffic does not fail the check forever
+		if n := ops - h.windowOps; n < h.config.MinOperations || n == 0 {
+			h.errorRate = 0
+		}
+		h.windowStart, h.windowOps, h.windowErrs = now, ops, errs
+	}
+	status.ErrorRate = h.errorRate
+	if h.config.MaxErrorRate > 0 && h.errorRate > h.config.MaxErrorRate {
+		fail(ConditionErrorRate, "error rate %.3f exceeds %.3f", h.errorRate, h.config.MaxErrorRate)
+	}
+
+	// Stalled operations
+	if h.config.StallTimeout > 0 && status.InFlight > 0 {
+		last := c.lastFinish.Load()
+		if last == 0 || now.Sub(time.Unix(0, last)) >= h.config.StallTimeout {
+			fail(ConditionStall, "%d operations in flight with none completed in %s", status.InFlight, h.config.StallTimeout)
+		}
+	}
+
+	c.recordHealth(status)
+	return status
+}
+
+// runHealthChecks evaluates the health conditions every interval until the
+// collector is closed.
+func (m *MetricsFS) runHealthChecks(interval time.Duration) {
+	defer m.collector.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.collector.done:
+			return
+		case <-ticker.C:
+			m.CheckHealth()
+		}
+	}
+}
+
+// startHealthChecks starts background health checks if CheckInterval is set
+// and the collector runs none yet, and returns m.
+func (m *MetricsFS) startHealthChecks() *MetricsFS {
+	interval := m.health.config.CheckInterval
+	if interval > 0 && m.collector.addBackgroundOnce("health") {
+		go m.runHealthChecks(interval)
+	}
+	return m
+}
+
+// recordHealth exports the degraded state of status and calls OnDegraded
+// when the set of failed conditions changes.
+func (c *Collector) recordHealth(status HealthStatus) {
+	if c.closed.Load() {
+		return
+	}
+
+	for _, condition := range healthConditions {
+		value := 0.0
+		for _, failed := range status.Conditions {
+			if failed == condition {
+				value = 1
+			}
+		}
+		c.degraded.WithLabelValues(condition).Set(value)
+	}
+
+	key := strings.Join(status.Conditions, ",")
+	c.degradedMu.Lock()
+	changed := key != c.degradedConditions
+	c.degradedConditions = key
+	c.degradedMu.Unlock()
+
+	if changed && c.config.OnDegraded != nil {
+		c.config.OnDegraded(status)
+	}
+}
+
+// HealthHandler returns an http.HandlerFunc suitable for Kubernetes liveness
+// and readiness probes. It responds with 200 when the filesystem is healthy
+// and 503 with the failed conditions otherwise.
+func (m *MetricsFS) HealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := m.CheckHealth()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !status.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, strings.Join(status.Reasons, "\n"))
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
@@ -0,0 +1,188 @@
+package metricsfs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHealthyByDefault(t *testing.T) {
+	fs := New(newMockFS())
+
+	if !fs.Healthy() {
+		t.Errorf("Expected healthy filesystem, got %v", fs.CheckHealth().Reasons)
+	}
+}
+
+func TestHealthProbeFailure(t *testing.T) {
+	config := DefaultConfig()
+	config.Health = HealthConfig{ProbePath: "/"}
+	fs := NewWithConfig(&errorMockFS{}, config)
+
+	status := fs.CheckHealth()
+	if status.Healthy {
+		t.Error("Expected unhealthy filesystem when probe fails")
+	}
+	if len(status.Reasons) != 1 {
+		t.Errorf("Expected 1 reason, got %v", status.Reasons)
+	}
+}
+
+func TestHealthErrorRate(t *testing.T) {
+	config := DefaultConfig()
+	config.Health = HealthConfig{MaxErrorRate: 0.5, MinOperations: 4}
+	fs := NewWithConfig(&errorMockFS{}, config)
+
+	// Establish the window before any operations
+	if !fs.Healthy() {
+		t.Fatal("Expected healthy filesystem before any operations")
+	}
+
+	for i := 0; i < 4; i++ {
+		fs.Stat("/test.txt")
+	}
+
+	status := fs.CheckHealth()
+	if status.Healthy {
+		t.Error("Expected unhealthy filesystem with 100% error rate")
+	}
+	if status.ErrorRate != 1 {
+		t.Errorf("Expected error rate 1, got %v", status.ErrorRate)
+	}
+}
+
+func TestHealthErrorRateRecovers(t *testing.T) {
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	config := DefaultConfig()
+	config.Clock = clock
+	config.Health = HealthConfig{MaxErrorRate: 0.5, MinOperations: 4, ErrorRateWindow: time.Minute}
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+	ctx := context.Background()
+
+	fs.CheckHealth()
+	for range 20 {
+		c.recordOperation(ctx, OpStat, "/a", 0, 0, errors.New("boom"))
+	}
+	if fs.Healthy() {
+		t.Fatal("Expected unhealthy filesystem after a burst of errors")
+	}
+
+	// The window with the burst ends; the next has too few operations to
+	// measure, and clears the rate when it ends
+	for range 2 {
+		clock.advance(61 * time.Second)
+		c.recordOperation(ctx, OpStat, "/a", 0, 0, nil)
+		fs.CheckHealth()
+	}
+	if status := fs.CheckHealth(); !status.Healthy || status.ErrorRate != 0 {
+		t.Errorf("Expected a healthy filesystem after the burst, got %+v", status)
+	}
+}
+
+func TestHealthStalledOperations(t *testing.T) {
+	base := &blockingStatMockFS{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	config := DefaultConfig()
+	config.Health = HealthConfig{StallTimeout: time.Millisecond}
+	fs := NewWithConfig(base, config)
+
+	done := make(chan struct{})
+	go func() {
+		fs.Stat("/test.txt")
+		close(done)
+	}()
+	<-base.started
+	time.Sleep(5 * time.Millisecond)
+
+	status := fs.CheckHealth()
+	if status.Healthy {
+		t.Error("Expected unhealthy filesystem with stalled operation")
+	}
+	if status.InFlight != 1 {
+		t.Errorf("Expected 1 operation in flight, got %d", status.InFlight)
+	}
+
+	close(base.release)
+	<-done
+
+	if !fs.Healthy() {
+		t.Errorf("Expected healthy filesystem after operation completed, got %v", fs.CheckHealth().Reasons)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	fs := New(newMockFS())
+	rec := httptest.NewRecorder()
+	fs.HealthHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+
+	config := DefaultConfig()
+	config.Health = HealthConfig{ProbePath: "/"}
+	fs = NewWithConfig(&errorMockFS{}, config)
+	rec = httptest.NewRecorder()
+	fs.HealthHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+}
+
+func TestHealthDegradedGauge(t *testing.T) {
+	var changes []HealthStatus
+	config := DefaultConfig()
+	config.Health = HealthConfig{MaxErrorRate: 0.5, MinOperations: 4, ErrorRateWindow: time.Hour}
+	config.OnDegraded = func(status HealthStatus) { changes = append(changes, status) }
+	fs := NewWithConfig(&errorMockFS{}, config)
+	c := fs.Collector()
+
+	fs.CheckHealth()
+	if got := testutil.ToFloat64(c.degraded.WithLabelValues(ConditionErrorRate)); got != 0 {
+		t.Errorf("Expected error_rate not degraded, got %v", got)
+	}
+
+	for i := 0; i < 4; i++ {
+		fs.Stat("/test.txt")
+	}
+	status := fs.CheckHealth()
+	fs.CheckHealth()
+
+	if len(status.Conditions) != 1 || status.Conditions[0] != ConditionErrorRate {
+		t.Errorf("Expected the error_rate condition to fail, got %v", status.Conditions)
+	}
+	if got := testutil.ToFloat64(c.degraded.WithLabelValues(ConditionErrorRate)); got != 1 {
+		t.Errorf("Expected error_rate degraded, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.degraded.WithLabelValues(ConditionProbe)); got != 0 {
+		t.Errorf("Expected probe not degraded, got %v", got)
+	}
+	if len(changes) != 1 || changes[0].Healthy {
+		t.Errorf("Expected OnDegraded to be called once when degrading, got %v", changes)
+	}
+}
+
+func TestHealthCheckInterval(t *testing.T) {
+	config := DefaultConfig()
+	config.Health = HealthConfig{ProbePath: "/", ProbeInterval: time.Millisecond, CheckInterval: time.Millisecond}
+	fs := NewWithConfig(&errorMockFS{}, config)
+	c := fs.Collector()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(c.degraded.WithLabelValues(ConditionProbe)) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected background health checks to mark the probe degraded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Close stops the background checks
+	c.Close()
+}
@@ -0,0 +1,160 @@
+package metricsfs
+
+import (
+	"container/heap"
+	"hash/maphash"
+	"sort"
+	"sync"
+)
+
+// Count-min sketch dimensions. With these values the sketch uses 64KiB and
+// overestimates a path's count by at most e/2048 (about 0.13%) of all
+// accesses with 98% probability.
+const (
+	hotPathsSketchWidth = 2048
+	hotPathsSketchDepth = 4
+)
+
+// PathCount is the approximate number of accesses to a path.
+type PathCount struct {
+	// Path is the accessed path
+	Path string `json:"path"`
+
+	// Count is the estimated number of accesses. It may overestimate, but
+	// never underestimates, the true count.
+	Count uint64 `json:"count"`
+}
+
+// HotPaths returns the most accessed paths, most accessed first. It returns
+// nil unless EnableHotPaths is set.
+func (c *Collector) HotPaths() []PathCount {
+	if c.hotPaths == nil {
+		return nil
+	}
+	return c.hotPaths.top()
+}
+
+// hotPaths finds heavy hitters among all accessed paths with bounded memory:
+// a count-min sketch estimates the access count of every path, and a min-heap
+// keeps the k paths with the highest estimates.
+type hotPaths struct {
+	mu      sync.Mutex
+	seed    maphash.Seed
+	sketch  [hotPathsSketchDepth][hotPathsSketchWidth]uint64
+	heap    pathCountHeap
+	entries map[string]*pathCountEntry
+	k       int
+}
+
+// newHotPaths creates a tracker for the k most accessed paths.
+func newHotPaths(k int) *hotPaths {
+	return &hotPaths{
+		seed:    maphash.MakeSeed(),
+		entries: make(map[string]*pathCountEntry, k),
+		k:       k,
+	}
+}
+
+// add records an access to path.
+func (h *hotPaths) add(path string) {
+	// Derive one index per row from a single 64-bit hash (double hashing)
+	sum := maphash.String(h.seed, path)
+	h1, h2 := sum&0xffffffff, sum>>32|1
+
+	h.mu.Lock()
+	estimate := ^uint64(0)
+	for row := uint64(0); row < hotPathsSketchDepth; row++ {
+		i := (h1 + row*h2) % hotPathsSketchWidth
+		h.sketch[row][i]++
+		estimate = min(estimate, h.sketch[row][i])
+	}
+
+	if e, ok := h.entries[path]; ok {
+		e.count = estimate
+		heap.Fix(&h.heap, e.index)
+	} else if len(h.heap) < h.k {
+		e := &pathCountEntry{path: path, count: estimate}
+		heap.Push(&h.heap, e)
+		h.entries[path] = e
+	} else if len(h.heap) > 0 && estimate > h.heap[0].count {
+		// Replace the least accessed of the top k
+		e := h.heap[0]
+		delete(h.entries, e.path)
+		e.path, e.count = path, estimate
+		heap.Fix(&h.heap, 0)
+		h.entries[path] = e
+	}
+	h.mu.Unlock()
+}
+
+// top returns the tracked paths, most accessed first.
+func (h *hotPaths) top() []PathCount {
+	h.mu.Lock()
+	paths := make([]PathCount, 0, len(h.heap))
+	for _, e := range h.heap {
+		paths = append(paths, PathCount{Path: e.path, Count: e.count})
+	}
+	h.mu.Unlock()
+
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Count != paths[j].Count {
+			return paths[i].Count > paths[j].Count
+		}
+		return paths[i].Path < paths[j].Path
+	})
+	return paths
+}
+
+// reset clears the sketch and the tracked paths.
+func (h *hotPaths) reset() {
+	h.mu.Lock()
+	h.sketch = [hotPathsSketchDepth][hotPathsSketchWidth]uint64{}
+	h.heap = nil
+	h.entries = make(map[string]*pathCountEntry, h.k)
+	h.mu.Unlock()
+}
+
+// sizeEstimate approximates the memory used by the tracker.
+func (h *hotPaths) sizeEstimate() int64 {
+	bytes := int64(hotPathsSketchDepth * hotPathsSketchWidth * 8)
+
+	h.mu.Lock()
+	for path := range h.entries {
+		bytes += int64(len(path)) + trackedStateEntryOverhead
+	}
+	h.mu.Unlock()
+
+	return bytes
+}
+
+// pathCountEntry is an element of pathCountHeap.
+type pathCountEntry struct {
+	path  string
+	count uint64
+	index int
+}
+
+// pathCountHeap is a min-heap of paths ordered by count.
+type pathCountHeap []*pathCountEntry
+
+func (h pathCountHeap) Len() int           { return len(h) }
+func (h pathCountHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h pathCountHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *pathCountHeap) Push(x any) {
+	e := x.(*pathCountEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *pathCountHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,76 @@
+package metricsfs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHotPaths(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableHotPaths = true
+	config.HotPathsTopK = 2
+	fs := NewWithConfig(newMockFS(), config)
+
+	for i := 0; i < 50; i++ {
+		fs.Stat("/hot")
+	}
+	for i := 0; i < 20; i++ {
+		fs.Stat("/warm")
+	}
+	// Many cold paths, each accessed once
+	for i := 0; i < 500; i++ {
+		fs.Stat(fmt.Sprintf("/cold/%d", i))
+	}
+
+	hot := fs.Collector().HotPaths()
+	if len(hot) != 2 {
+		t.Fatalf("Expected 2 hot paths, got %v", hot)
+	}
+	if hot[0].Path != "/hot" || hot[1].Path != "/warm" {
+		t.Errorf("Expected /hot then /warm, got %v", hot)
+	}
+	if hot[0].Count < 50 || hot[1].Count < 20 {
+		t.Errorf("Count-min estimates must not undercount, got %v", hot)
+	}
+}
+
+func TestHotPathsMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableHotPaths = true
+	config.HotPathsTopK = 1
+	fs := NewWithConfig(newMockFS(), config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	fs.Stat("/a")
+	fs.Stat("/b")
+	fs.Stat("/b")
+
+	expected := `
+# HELP fs_hot_path_accesses Approximate access counts of the most accessed paths
+# TYPE fs_hot_path_accesses gauge
+fs_hot_path_accesses{path="/b"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "fs_hot_path_accesses"); err != nil {
+		t.Error(err)
+	}
+
+	fs.Collector().Reset()
+	if hot := fs.Collector().HotPaths(); len(hot) != 0 {
+		t.Errorf("Expected no hot paths after Reset, got %v", hot)
+	}
+}
+
+func TestHotPathsDisabled(t *testing.T) {
+	fs := New(newMockFS())
+	fs.Stat("/a")
+
+	if hot := fs.Collector().HotPaths(); hot != nil {
+		t.Errorf("Expected nil hot paths when disabled, got %v", hot)
+	}
+}
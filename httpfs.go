@@ -0,0 +1,35 @@
+package metricsfs
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// httpFileSystem is an http.FileSystem serving the files of a MetricsFS.
+type httpFileSystem struct {
+	fs *MetricsFS
+}
+
+// HTTPFileSystem returns an http.FileSystem serving the working directory of
+// fs, like http.Dir("."), whose opens and the reads, seeks and Readdir calls
+// of the files served are recorded by fs. The operations are recorded with
+// fs's context; use fs.WithContext for each request, or the FileServer of
+// the metricsfshttp package, to label them per request.
+func HTTPFileSystem(fs *MetricsFS) http.FileSystem {
+	return httpFileSystem{fs: fs}
+}
+
+// Open opens the file at name, a slash-separated path rooted at the working
+// directory of the filesystem.
+func (h httpFileSystem) Open(name string) (http.File, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	f, err := h.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
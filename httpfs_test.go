@@ -0,0 +1,70 @@
+package metricsfs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	if err := base.Chdir(dir); err != nil {
+		t.Fatalf("failed to change to the test directory: %v", err)
+	}
+	mfs := New(base)
+	server := http.FileServer(HTTPFileSystem(mfs))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Fatalf("GET /a.txt: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Range requests seek to the start of the range
+	req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	req.Header.Set("Range", "bytes=1-")
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "ello" {
+		t.Fatalf("GET /a.txt bytes=1-: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "a.txt") {
+		t.Fatalf("GET /: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing.txt", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /missing.txt: got %d, want 404", rec.Code)
+	}
+
+	c := mfs.collector
+	for _, op := range []string{"open", "read", "seek", "readdir"} {
+		if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues(op, "success")); v == 0 {
+			t.Errorf("Expected %s operations to be recorded", op)
+		}
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("open", "error")); v == 0 {
+		t.Error("Expected the failed open to be recorded")
+	}
+	if v := testutil.ToFloat64(c.bytesReadTotal); v != 9 {
+		t.Errorf("Expected 9 bytes read, got %v", v)
+	}
+}
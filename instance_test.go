@@ -0,0 +1,126 @@
+package metricsfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestInstance(t *testing.T) {
+	var buf bytes.Buffer
+	var ops []Operation
+	config := DefaultConfig()
+	config.Instance = "data-volume"
+	config.Audit = AuditConfig{Writer: &buf}
+	config.OnOperation = func(op Operation) { ops = append(ops, op) }
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	fs.Stat("/a")
+
+	if fs.Instance() != "data-volume" || c.Instance() != "data-volume" {
+		t.Errorf("Expected instance data-volume, got %q and %q", fs.Instance(), c.Instance())
+	}
+	if entries := auditEntries(t, &buf); len(entries) != 1 || entries[0].Instance != "data-volume" {
+		t.Errorf("Expected an audit entry of data-volume, got %+v", entries)
+	}
+	if len(ops) != 1 || ops[0].Instance != "data-volume" {
+		t.Errorf("Expected an operation of data-volume, got %+v", ops)
+	}
+	if got := c.Stats().Instance; got != "data-volume" {
+		t.Errorf("Expected stats of data-volume, got %q", got)
+	}
+
+	// Every metric carries the instance
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			found := false
+			for _, label := range m.GetLabel() {
+				found = found || (label.GetName() == "fs_instance" && label.GetValue() == "data-volume")
+			}
+			if !found {
+				t.Errorf("Expected %s to carry fs_instance=data-volume", family.GetName())
+			}
+		}
+	}
+}
+
+func TestInstanceGenerated(t *testing.T) {
+	var ops []Operation
+	config := DefaultConfig()
+	config.OnOperation = func(op Operation) { ops = append(ops, op) }
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	fs.Stat("/a")
+
+	id := c.Instance()
+	if len(id) != 16 || id == NewCollector(DefaultConfig()).Instance() {
+		t.Fatalf("Expected a distinct generated 16 digit instance, got %q", id)
+	}
+	if fs.Instance() != "" {
+		t.Errorf("Expected an unnamed wrapper, got %q", fs.Instance())
+	}
+	if len(ops) != 1 || ops[0].Instance != id {
+		t.Errorf("Expected an operation of %s, got %+v", id, ops)
+	}
+
+	// Generated instances do not label metrics
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success")); got != 1 {
+		t.Errorf("Expected 1 stat without an instance label, got %v", got)
+	}
+}
+
+func TestInstanceLabel(t *testing.T) {
+	config := DefaultConfig()
+	config.Instance = "primary"
+	config.EnableInstanceLabel = true
+	c := NewCollector(config)
+	shared := NewWithCollector(newMockFS(), c, "replica")
+	own := NewWithConfig(newMockFS(), config)
+
+	shared.Stat("/a")
+	own.Stat("/a")
+
+	// Shared wrappers keep their instance, the const label is not duplicated
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success", "replica")); got != 1 {
+		t.Errorf("Expected 1 stat of replica, got %v", got)
+	}
+	if got := testutil.ToFloat64(own.Collector().operationsTotal.WithLabelValues("stat", "success", "primary")); got != 1 {
+		t.Errorf("Expected 1 stat of primary, got %v", got)
+	}
+	if err := prometheus.NewRegistry().Register(c); err != nil {
+		t.Errorf("Register failed: %v", err)
+	}
+}
+
+func TestOTelInstance(t *testing.T) {
+	tp := &recordingTracerProvider{}
+	fs, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:  noop.NewMeterProvider(),
+		TracerProvider: tp,
+		EnableTracing:  true,
+		Instance:       "data-volume",
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	fs.Stat("/a")
+
+	if len(tp.spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(tp.spans))
+	}
+	if got := tp.spans[0].attributes["fs.instance"].AsString(); got != "data-volume" {
+		t.Errorf("Expected fs.instance data-volume on the span, got %q", got)
+	}
+}
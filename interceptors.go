@@ -0,0 +1,59 @@
+package metricsfs
+
+import "context"
+
+// OpFunc performs the filesystem call of operation op on path. path is the
+// name the call was issued with, or the path a file was opened with for file
+// operations.
+type OpFunc func(ctx context.Context, op Op, path string) error
+
+// Interceptor wraps the filesystem calls of a MetricsFS and its files, to add
+// concerns such as logging, auditing or rate limiting around them. It returns
+// an OpFunc that calls next to perform the call, and should return next's
+// error unchanged; returning an error without calling next fails the
+// operation without reaching the underlying filesystem.
+//
+//	func logging(next metricsfs.OpFunc) metricsfs.OpFunc {
+//		return func(ctx context.Context, op metricsfs.Op, path string) error {
+//			err := next(ctx, op, path)
+//			log.Printf("%s %s: %v", op, path, err)
+//			return err
+//		}
+//	}
+//
+// Calls are timed with their interceptors, so time spent in an interceptor is
+// part of the recorded operation duration.
+type Interceptor func(next OpFunc) OpFunc
+
+// intercept runs call through the configured interceptors, the first of
+// which is outermost.
+func (c *Collector) intercept(ctx context.Context, op Op, path string, call func() error) error {
+	interceptors := c.config.Interceptors
+	if len(interceptors) == 0 {
+		return call()
+	}
+
+	next := OpFunc(func(context.Context, Op, string) error {
+		return call()
+	})
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		next = interceptors[i](next)
+	}
+	return next(ctx, op, path)
+}
+
+// interceptResult is intercept for calls that return a result. The result
+// is the zero value when an interceptor fails the call without calling next.
+func interceptResult[T any](c *Collector, ctx context.Context, op Op, path string, call func() (T, error)) (T, error) {
+	if len(c.config.Interceptors) == 0 {
+		return call()
+	}
+
+	var result T
+	err := c.intercept(ctx, op, path, func() error {
+		var err error
+		result, err = call()
+		return err
+	})
+	return result, err
+}
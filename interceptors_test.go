@@ -0,0 +1,106 @@
+package metricsfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// tracing returns an interceptor that appends name and the operation to
+// calls before and after each call.
+func tracing(name string, calls *[]string) Interceptor {
+	return func(next OpFunc) OpFunc {
+		return func(ctx context.Context, op Op, path string) error {
+			*calls = append(*calls, fmt.Sprintf("%s>%s %s", name, op, path))
+			err := next(ctx, op, path)
+			*calls = append(*calls, fmt.Sprintf("%s<%s", name, op))
+			return err
+		}
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	config := DefaultConfig()
+	config.Interceptors = []Interceptor{tracing("a", &calls), tracing("b", &calls)}
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, err := fs.Create("/test.txt")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+
+	want := []string{
+		"a>create /test.txt", "b>create /test.txt", "b<create", "a<create",
+		"a>write /test.txt", "b>write /test.txt", "b<write", "a<write",
+		"a>close /test.txt", "b>close /test.txt", "b<close", "a<close",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestInterceptorFailsCall(t *testing.T) {
+	errDenied := errors.New("denied")
+	config := DefaultConfig()
+	config.Interceptors = []Interceptor{func(next OpFunc) OpFunc {
+		return func(ctx context.Context, op Op, path string) error {
+			if op == OpRemove {
+				return errDenied
+			}
+			return next(ctx, op, path)
+		}
+	}}
+	base := newMockFS()
+	fs := NewWithConfig(base, config)
+
+	if err := fs.Remove("/test.txt"); err != errDenied {
+		t.Errorf("Expected Remove to fail with %v, got %v", errDenied, err)
+	}
+	if _, err := fs.Stat("/test.txt"); err != nil {
+		t.Errorf("Expected Stat to pass through, got %v", err)
+	}
+
+	c := fs.Collector()
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("remove", "error")); got != 1 {
+		t.Errorf("Expected the denied remove to be recorded as an error, got %v", got)
+	}
+}
+
+func TestInterceptorContext(t *testing.T) {
+	type key struct{}
+	var got any
+	config := DefaultConfig()
+	config.Interceptors = []Interceptor{func(next OpFunc) OpFunc {
+		return func(ctx context.Context, op Op, path string) error {
+			got = ctx.Value(key{})
+			return next(ctx, op, path)
+		}
+	}}
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.WithContext(context.WithValue(context.Background(), key{}, "job-42")).Stat("/test.txt")
+	if got != "job-42" {
+		t.Errorf("Expected the interceptor to see the filesystem's context, got %v", got)
+	}
+}
+
+func TestConfigMergeInterceptors(t *testing.T) {
+	var calls []string
+	base := Config{Interceptors: []Interceptor{tracing("base", &calls)}}
+	merged := base.Merge(Config{Interceptors: []Interceptor{tracing("override", &calls)}})
+
+	fs := NewWithConfig(newMockFS(), merged)
+	fs.Mkdir("/dir", 0755)
+
+	want := []string{"base>mkdir /dir", "override>mkdir /dir", "override<mkdir", "base<mkdir"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+}
@@ -0,0 +1,42 @@
+package metricsfs
+
+import "time"
+
+// maxTrackedLayers bounds the layer label; further layers are recorded as
+// "other".
+const maxTrackedLayers = 16
+
+// LayeredFile is implemented by files of overlay, union or copy-on-write
+// filesystems that can report which of their layers serves reads, such as
+// "upper" or "lower". Layer is called on every read, so a file that is
+// copied up between reads is attributed to its new layer.
+type LayeredFile interface {
+	Layer() string
+}
+
+// recordLayerRead records a read of n bytes served by layer.
+func (c *Collector) recordLayerRead(layer string, duration time.Duration, n int, err error) {
+	if c.closed.Load() || !c.config.EnableLayerMetrics || !c.measured(OpRead) {
+		return
+	}
+
+	layer = c.trackedLayers.label(layer)
+	status := "success"
+	if operationError(err) != nil {
+		status = "error"
+	}
+	c.layerReadsTotal.WithLabelValues(layer, status).Inc()
+	if duration != unsampled {
+		c.layerReadDuration.WithLabelValues(layer).Observe(duration.Seconds())
+	}
+	if n > 0 {
+		c.layerReadBytesTotal.WithLabelValues(layer).Add(float64(n))
+	}
+}
+
+// recordLayerRead records a read on f if its file reports layers.
+func (f *MetricsFile) recordLayerRead(duration time.Duration, n int, err error) {
+	if lf, ok := f.file.(LayeredFile); ok {
+		f.collector.recordLayerRead(lf.Layer(), duration, n, err)
+	}
+}
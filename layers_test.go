@@ -0,0 +1,87 @@
+package metricsfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/absfs/absfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// layeredMockFS serves files under /upper from the upper layer and all
+// others from the lower layer.
+type layeredMockFS struct {
+	mockFS
+}
+
+func (l *layeredMockFS) Open(name string) (absfs.File, error) {
+	layer := "lower"
+	if strings.HasPrefix(name, "/upper/") {
+		layer = "upper"
+	}
+	return &layeredMockFile{mockFile{name: name}, layer}, nil
+}
+
+type layeredMockFile struct {
+	mockFile
+	layer string
+}
+
+func (f *layeredMockFile) Read(p []byte) (n int, err error) {
+	return len(p), nil
+}
+
+func (f *layeredMockFile) ReadAt(p []byte, off int64) (n int, err error) {
+	return len(p), nil
+}
+
+func (f *layeredMockFile) Layer() string {
+	return f.layer
+}
+
+func TestLayerMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableLayerMetrics = true
+	fs := NewWithConfig(&layeredMockFS{}, config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	lower, _ := fs.Open("/photos/a.jpg")
+	lower.Read(make([]byte, 100))
+	lower.ReadAt(make([]byte, 50), 100)
+	upper, _ := fs.Open("/upper/b.jpg")
+	upper.Read(make([]byte, 10))
+
+	expected := `
+# HELP fs_layer_read_bytes_total Bytes read by the filesystem layer that served them
+# TYPE fs_layer_read_bytes_total counter
+fs_layer_read_bytes_total{layer="lower"} 150
+fs_layer_read_bytes_total{layer="upper"} 10
+# HELP fs_layer_reads_total Reads by the filesystem layer that served them
+# TYPE fs_layer_reads_total counter
+fs_layer_reads_total{layer="lower",status="success"} 2
+fs_layer_reads_total{layer="upper",status="success"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"fs_layer_read_bytes_total", "fs_layer_reads_total"); err != nil {
+		t.Error(err)
+	}
+	if n, _ := testutil.GatherAndCount(registry, "fs_layer_read_duration_seconds"); n != 2 {
+		t.Errorf("Expected read latency for 2 layers, got %d", n)
+	}
+}
+
+func TestLayerMetricsDisabled(t *testing.T) {
+	fs := NewWithConfig(&layeredMockFS{}, DefaultConfig())
+
+	f, _ := fs.Open("/photos/a.jpg")
+	f.Read(make([]byte, 100))
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+	if n, _ := testutil.GatherAndCount(registry, "fs_layer_reads_total"); n != 0 {
+		t.Errorf("Expected no layer metrics when disabled, got %d", n)
+	}
+}
@@ -0,0 +1,116 @@
+package metricsfs
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// liveConfig is the configuration of a collector with the changes of
+// UpdateConfig, and the settings derived from it. It is replaced, never
+// modified, so that operations read a consistent snapshot without locking.
+type liveConfig struct {
+	config Config
+
+	// timed is set when the configuration consumes operation durations;
+	// otherwise operations are only timed during profiling windows
+	timed bool
+
+	// sloThresholds are the positive SLOThresholds by operation, or nil
+	sloThresholds map[Op]time.Duration
+
+	// pathFilter selects the paths to measure, nil for all
+	pathFilter *pathFilter
+}
+
+func newLiveConfig(config Config) *liveConfig {
+	live := &liveConfig{
+		config:     config,
+		timed:      timesOperations(config),
+		pathFilter: newPathFilter(config.IncludePaths, config.ExcludePaths),
+	}
+	for op, threshold := range config.SLOThresholds {
+		if threshold <= 0 {
+			continue
+		}
+		if live.sloThresholds == nil {
+			live.sloThresholds = make(map[Op]time.Duration, len(config.SLOThresholds))
+		}
+		live.sloThresholds[Op(op)] = threshold
+	}
+	return live
+}
+
+// Config returns the collector's configuration, with the changes made by
+// UpdateConfig.
+func (c *Collector) Config() Config {
+	config := c.live.Load().config
+	config.SLOThresholds = maps.Clone(config.SLOThresholds)
+	config.IncludePaths = slices.Clone(config.IncludePaths)
+	config.ExcludePaths = slices.Clone(config.ExcludePaths)
+	return config
+}
+
+// UpdateConfig changes the collection settings of a running collector, for
+// example to record path metrics during an incident only. update is called
+// with a copy of the current configuration; of its changes, those to the
+// following fields apply to subsequent operations, and those to others are
+// ignored:
+//
+//   - EnableLatencyMetrics
+//   - EnableBandwidthMetrics
+//   - EnablePathMetrics
+//   - LatencySampleRate
+//   - SLOThresholds
+//   - SlowOperationThreshold
+//   - IncludePaths and ExcludePaths
+//
+// Disabling metrics stops recording them; their series keep their values,
+// except those of path metrics, which are dropped with the tracked paths
+// so that their cardinality is released. Path latency metrics are only
+// recorded if EnablePathLatencyMetrics was set when the collector was
+// created.
+func (c *Collector) UpdateConfig(update func(config *Config)) {
+	c.liveMu.Lock()
+	defer c.liveMu.Unlock()
+
+	current := c.Config()
+	changed := current
+	update(&changed)
+
+	current.EnableLatencyMetrics = changed.EnableLatencyMetrics
+	current.EnableBandwidthMetrics = changed.EnableBandwidthMetrics
+	current.EnablePathMetrics = changed.EnablePathMetrics
+	current.LatencySampleRate = changed.LatencySampleRate
+	current.SLOThresholds = maps.Clone(changed.SLOThresholds)
+	current.SlowOperationThreshold = changed.SlowOperationThreshold
+	current.IncludePaths = slices.Clone(changed.IncludePaths)
+	current.ExcludePaths = slices.Clone(changed.ExcludePaths)
+
+	previous := c.live.Swap(newLiveConfig(current))
+	if previous.config.EnablePathMetrics && !current.EnablePathMetrics {
+		c.dropPathMetrics()
+	}
+}
+
+// dropPathMetrics forgets the tracked paths and deletes their series.
+func (c *Collector) dropPathMetrics() {
+	c.pathMutex.Lock()
+	clear(c.trackedPaths)
+	c.pathMutex.Unlock()
+
+	c.pathAccessTotal.Reset()
+	if c.config.EnablePathLatencyMetrics {
+		c.pathDuration.Reset()
+		c.pathBytesTotal.Reset()
+	}
+	if c.pathLimitEngaged.CompareAndSwap(true, false) {
+		c.recordModeTransition(ModePathLimit, "released")
+	}
+}
+
+// UpdateConfig changes the collection settings of the filesystem's
+// collector. See Collector.UpdateConfig.
+func (m *MetricsFS) UpdateConfig(update func(config *Config)) {
+	m.collector.UpdateConfig(update)
+}
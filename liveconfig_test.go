@@ -0,0 +1,114 @@
+package metricsfs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUpdateConfigPathMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnablePathLatencyMetrics = true
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	fs.Stat("/before.txt")
+	if n := testutil.CollectAndCount(c.pathAccessTotal); n != 0 {
+		t.Fatalf("Expected no path series while disabled, got %d", n)
+	}
+
+	fs.UpdateConfig(func(config *Config) { config.EnablePathMetrics = true })
+	if !c.Config().EnablePathMetrics {
+		t.Error("Expected Config to reflect the update")
+	}
+	fs.Stat("/incident.txt")
+	if v := testutil.ToFloat64(c.pathAccessTotal.WithLabelValues("/incident.txt", "stat")); v != 1 {
+		t.Errorf("Expected 1 stat of /incident.txt, got %v", v)
+	}
+	if n := testutil.CollectAndCount(c.pathDuration); n != 1 {
+		t.Errorf("Expected 1 path latency series, got %d", n)
+	}
+
+	// Disabling path metrics releases their series and tracked paths
+	fs.UpdateConfig(func(config *Config) { config.EnablePathMetrics = false })
+	fs.Stat("/after.txt")
+	if n := testutil.CollectAndCount(c.pathAccessTotal); n != 0 {
+		t.Errorf("Expected the path series to be dropped, got %d", n)
+	}
+	if paths, _ := c.trackedState(); paths != 0 {
+		t.Errorf("Expected no tracked paths, got %d", paths)
+	}
+}
+
+func TestUpdateConfigLatencyMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableLatencyMetrics = false
+	config.EnableBandwidthMetrics = false
+	c := NewCollector(config)
+
+	c.UpdateConfig(func(config *Config) {
+		config.EnableLatencyMetrics = true
+		config.Namespace = "ignored"
+	})
+	if !c.live.Load().timed {
+		t.Error("Expected operations to be timed once latency metrics are enabled")
+	}
+	if got := c.Config().Namespace; got != "fs" {
+		t.Errorf("Namespace = %q, want the update to be ignored", got)
+	}
+
+	start := c.startOperation(OpStat)
+	c.recordOperation(context.Background(), OpStat, "/a", c.finishOperation(OpStat, start), 0, nil)
+	if got := histogramCount(t, c.statDuration); got != 1 {
+		t.Errorf("Expected 1 stat latency observation, got %d", got)
+	}
+	if got := c.Stats().BytesRead; got != 0 {
+		t.Errorf("Expected no bytes while bandwidth metrics are disabled, got %d", got)
+	}
+}
+
+func TestUpdateConfigSLOThresholds(t *testing.T) {
+	fs := New(newMockFS())
+	c := fs.Collector()
+
+	thresholds := map[string]time.Duration{"stat": time.Hour}
+	fs.UpdateConfig(func(config *Config) { config.SLOThresholds = thresholds })
+	thresholds["stat"] = 0 // the collector keeps its own copy
+
+	fs.Stat("/a.txt")
+	if v := testutil.ToFloat64(c.sloOperationsTotal.WithLabelValues("stat", "under")); v != 1 {
+		t.Errorf("Expected 1 stat under its threshold, got %v", v)
+	}
+
+	fs.UpdateConfig(func(config *Config) { config.SLOThresholds = nil })
+	fs.Stat("/a.txt")
+	if v := testutil.ToFloat64(c.sloOperationsTotal.WithLabelValues("stat", "under")); v != 1 {
+		t.Errorf("Expected operations without a threshold not to be counted, got %v", v)
+	}
+}
+
+func TestUpdateConfigConcurrent(t *testing.T) {
+	fs := New(newMockFS())
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				if i == 0 {
+					fs.UpdateConfig(func(config *Config) {
+						config.EnablePathMetrics = j%2 == 0
+						config.LatencySampleRate = j % 3
+					})
+					continue
+				}
+				fs.Stat("/a.txt")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,259 @@
+package metricsfs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrLockUnsupported is returned by the locking methods of a MetricsFile
+// whose underlying file supports no advisory locking.
+var ErrLockUnsupported = errors.New("metricsfs: file locking not supported")
+
+// Locker is implemented by files supporting advisory whole-file locks, such
+// as flock on Unix or LockFileEx on Windows. Lock and RLock block until the
+// exclusive or shared lock is acquired.
+type Locker interface {
+	Lock() error
+	RLock() error
+	Unlock() error
+}
+
+// TryLocker is implemented by Lockers that can attempt a lock without
+// blocking. TryLock and TryRLock report whether the lock was acquired.
+type TryLocker interface {
+	TryLock() (bool, error)
+	TryRLock() (bool, error)
+}
+
+// fder is implemented by files backed by an OS file descriptor or handle,
+// such as *os.File, which are locked with the platform's advisory locks.
+type fder interface {
+	Fd() uintptr
+}
+
+// locker returns the Locker of the underlying file: the file itself if it
+// is one, or the platform's advisory locks on its descriptor. It returns
+// nil if the file cannot be locked.
+func (f *MetricsFile) locker() Locker {
+	if l, ok := f.file.(Locker); ok {
+		return l
+	}
+	if fd, ok := f.file.(fder); ok {
+		return newFdLocker(fd.Fd())
+	}
+	return nil
+}
+
+// lockModeName returns the mode label of an exclusive or shared lock.
+func lockModeName(exclusive bool) string {
+	if exclusive {
+		return "exclusive"
+	}
+	return "shared"
+}
+
+// Lock acquires an exclusive advisory lock on the file, blocking until it is
+// available. The time spent waiting is recorded in lock_wait_seconds, and
+// waits behind another holder in lock_contentions_total when the underlying
+// file can attempt locks without blocking.
+func (f *MetricsFile) Lock() error {
+	return f.lock(true)
+}
+
+// RLock acquires a shared advisory lock on the file, blocking until it is
+// available. See Lock.
+func (f *MetricsFile) RLock() error {
+	return f.lock(false)
+}
+
+// TryLock attempts to acquire an exclusive advisory lock on the file without
+// blocking, and reports whether it did. Failed attempts are counted in
+// lock_contentions_total. It returns ErrLockUnsupported if the underlying
+// file cannot attempt locks.
+func (f *MetricsFile) TryLock() (bool, error) {
+	return f.tryLock(true)
+}
+
+// TryRLock attempts to acquire a shared advisory lock on the file without
+// blocking. See TryLock.
+func (f *MetricsFile) TryRLock() (bool, error) {
+	return f.tryLock(false)
+}
+
+// Unlock releases the file's advisory lock, recording how long it was held
+// in lock_held_seconds.
+func (f *MetricsFile) Unlock() error {
+	l := f.locker()
+
+	start := f.collector.startOperation(OpUnlock)
+	err := f.collector.intercept(f.ctx, OpUnlock, f.path, func() error {
+		if l == nil {
+			return ErrLockUnsupported
+		}
+		return l.Unlock()
+	})
+	duration := f.collector.finishOperation(OpUnlock, start)
+
+	f.collector.recordOperation(f.ctx, OpUnlock, f.path, duration, 0, err)
+	if err == nil {
+		f.released()
+	}
+
+	return err
+}
+
+// lock acquires an exclusive or shared lock, blocking.
+func (f *MetricsFile) lock(exclusive bool) error {
+	l := f.locker()
+	mode := lockModeName(exclusive)
+	begin := f.collector.clock.Now()
+
+	start := f.collector.startOperation(OpLock)
+	err := f.collector.intercept(f.ctx, OpLock, f.path, func() error {
+		if l == nil {
+			return ErrLockUnsupported
+		}
+		if tl, ok := l.(TryLocker); ok {
+			acquired, err := tryLock(tl, exclusive)
+			if err != nil || acquired {
+				return err
+			}
+			f.collector.recordLockContention(mode)
+		}
+		if exclusive {
+			return l.Lock()
+		}
+		return l.RLock()
+	})
+	duration := f.collector.finishOperation(OpLock, start)
+
+	f.collector.recordOperation(f.ctx, OpLock, f.path, duration, 0, err)
+	if err == nil {
+		now := f.collector.clock.Now()
+		f.collector.recordLockWait(mode, now.Sub(begin))
+		f.acquired(mode, now)
+	}
+
+	return err
+}
+
+// tryLock attempts an exclusive or shared lock without blocking.
+func (f *MetricsFile) tryLock(exclusive bool) (bool, error) {
+	tl, _ := f.locker().(TryLocker)
+	mode := lockModeName(exclusive)
+
+	start := f.collector.startOperation(OpLock)
+	acquired, err := interceptResult(f.collector, f.ctx, OpLock, f.path, func() (bool, error) {
+		if tl == nil {
+			return false, ErrLockUnsupported
+		}
+		return tryLock(tl, exclusive)
+	})
+	duration := f.collector.finishOperation(OpLock, start)
+
+	f.collector.recordOperation(f.ctx, OpLock, f.path, duration, 0, err)
+	switch {
+	case err != nil:
+	case acquired:
+		f.collector.recordLockWait(mode, 0)
+		f.acquired(mode, f.collector.clock.Now())
+	default:
+		f.collector.recordLockContention(mode)
+	}
+
+	return acquired, err
+}
+
+// tryLock attempts an exclusive or shared lock on tl.
+func tryLock(tl TryLocker, exclusive bool) (bool, error) {
+	if exclusive {
+		return tl.TryLock()
+	}
+	return tl.TryRLock()
+}
+
+// acquired notes that the file was locked in mode at now.
+func (f *MetricsFile) acquired(mode string, now time.Time) {
+	f.lockMu.Lock()
+	f.lockMode = mode
+	f.lockedAt = now
+	f.lockMu.Unlock()
+}
+
+// released records how long the file's lock was held, if it was locked.
+// Closing a file releases its lock too.
+func (f *MetricsFile) released() {
+	f.lockMu.Lock()
+	mode, lockedAt := f.lockMode, f.lockedAt
+	f.lockMode = ""
+	f.lockMu.Unlock()
+
+	if mode != "" {
+		f.collector.recordLockHeld(mode, f.collector.clock.Now().Sub(lockedAt))
+	}
+}
+
+// initLockMetrics creates the file locking metrics.
+func (c *Collector) initLockMetrics(config Config) {
+	c.lockWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "lock_wait_seconds",
+			Help:        "Time spent acquiring advisory file locks, by mode (exclusive or shared)",
+			Buckets:     config.LatencyBuckets,
+			ConstLabels: config.constLabelsFor("lock_wait_seconds"),
+		},
+		[]string{"mode"},
+	)
+
+	c.lockHeld = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "lock_held_seconds",
+			Help:        "Time advisory file locks were held until unlocked or closed, by mode",
+			Buckets:     prometheus.ExponentialBuckets(0.001, 10, 7),
+			ConstLabels: config.constLabelsFor("lock_held_seconds"),
+		},
+		[]string{"mode"},
+	)
+
+	c.lockContentionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "lock_contentions_total",
+			Help:        "Advisory file lock attempts that found the lock held, by mode",
+			ConstLabels: config.constLabelsFor("lock_contentions_total"),
+		},
+		[]string{"mode"},
+	)
+}
+
+// recordLockWait records the time taken to acquire a lock in mode.
+func (c *Collector) recordLockWait(mode string, wait time.Duration) {
+	if c.closed.Load() {
+		return
+	}
+	c.lockWait.WithLabelValues(mode).Observe(wait.Seconds())
+}
+
+// recordLockHeld records how long a lock in mode was held.
+func (c *Collector) recordLockHeld(mode string, held time.Duration) {
+	if c.closed.Load() {
+		return
+	}
+	c.lockHeld.WithLabelValues(mode).Observe(held.Seconds())
+}
+
+// recordLockContention counts a lock attempt in mode that found the lock
+// held.
+func (c *Collector) recordLockContention(mode string) {
+	if c.closed.Load() {
+		return
+	}
+	c.lockContentionsTotal.WithLabelValues(mode).Inc()
+}
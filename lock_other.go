@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package metricsfs
+
+// newFdLocker returns nil: advisory locks on file descriptors are not
+// supported on this platform.
+func newFdLocker(fd uintptr) Locker {
+	return nil
+}
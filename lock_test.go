@@ -0,0 +1,116 @@
+package metricsfs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// lockingMockFile is a mockFile with an advisory lock that may be held
+// elsewhere.
+type lockingMockFile struct {
+	mockFile
+	held    bool
+	locked  string
+	waiting int
+}
+
+func (f *lockingMockFile) Lock() error {
+	f.waiting++
+	f.locked = "exclusive"
+	return nil
+}
+
+func (f *lockingMockFile) RLock() error {
+	f.waiting++
+	f.locked = "shared"
+	return nil
+}
+
+func (f *lockingMockFile) Unlock() error {
+	f.locked = ""
+	return nil
+}
+
+func (f *lockingMockFile) TryLock() (bool, error) {
+	if f.held {
+		return false, nil
+	}
+	f.locked = "exclusive"
+	return true, nil
+}
+
+func (f *lockingMockFile) TryRLock() (bool, error) {
+	if f.held {
+		return false, nil
+	}
+	f.locked = "shared"
+	return true, nil
+}
+
+func TestFileLock(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = &stepClock{now: time.Unix(1000, 0), step: time.Millisecond}
+	c := NewCollector(config)
+	base := &lockingMockFile{held: true}
+	f := WrapFile(base, c, "/data/db").(*MetricsFile)
+
+	// Contended locks wait behind the holder
+	if err := f.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if base.waiting != 1 || base.locked != "exclusive" {
+		t.Errorf("Expected a blocking exclusive lock, got %d waits and %q", base.waiting, base.locked)
+	}
+	if err := f.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	if ok, err := f.TryRLock(); ok || err != nil {
+		t.Errorf("Expected TryRLock to fail while held, got %v, %v", ok, err)
+	}
+
+	// Uncontended locks are acquired at the first attempt
+	base.held = false
+	if err := f.RLock(); err != nil {
+		t.Fatalf("RLock failed: %v", err)
+	}
+	if base.waiting != 1 || base.locked != "shared" {
+		t.Errorf("Expected a shared lock without waiting, got %d waits and %q", base.waiting, base.locked)
+	}
+	f.Close()
+
+	if got := testutil.ToFloat64(c.lockContentionsTotal.WithLabelValues("exclusive")); got != 1 {
+		t.Errorf("Expected 1 exclusive contention, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.lockContentionsTotal.WithLabelValues("shared")); got != 1 {
+		t.Errorf("Expected 1 shared contention, got %v", got)
+	}
+	if got := testutil.CollectAndCount(c.lockWait); got != 2 {
+		t.Errorf("Expected waits of both modes, got %d series", got)
+	}
+	// The shared lock was released by Close
+	if got := testutil.CollectAndCount(c.lockHeld); got != 2 {
+		t.Errorf("Expected held times of both modes, got %d series", got)
+	}
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("lock", "success")); got != 3 {
+		t.Errorf("Expected 3 lock operations, got %v", got)
+	}
+}
+
+func TestFileLockUnsupported(t *testing.T) {
+	c := NewCollector(DefaultConfig())
+	f := WrapFile(&mockFile{name: "/a"}, c, "/a").(*MetricsFile)
+
+	if err := f.Lock(); !errors.Is(err, ErrLockUnsupported) {
+		t.Errorf("Expected ErrLockUnsupported, got %v", err)
+	}
+	if _, err := f.TryLock(); !errors.Is(err, ErrLockUnsupported) {
+		t.Errorf("Expected ErrLockUnsupported, got %v", err)
+	}
+	if err := f.Unlock(); !errors.Is(err, ErrLockUnsupported) {
+		t.Errorf("Expected ErrLockUnsupported, got %v", err)
+	}
+}
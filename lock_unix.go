@@ -0,0 +1,58 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package metricsfs
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// fdLocker locks a file descriptor with flock.
+type fdLocker struct {
+	fd int
+}
+
+// newFdLocker returns a Locker for fd.
+func newFdLocker(fd uintptr) Locker {
+	return fdLocker{fd: int(fd)}
+}
+
+func (l fdLocker) Lock() error {
+	return l.flock(unix.LOCK_EX)
+}
+
+func (l fdLocker) RLock() error {
+	return l.flock(unix.LOCK_SH)
+}
+
+func (l fdLocker) Unlock() error {
+	return l.flock(unix.LOCK_UN)
+}
+
+func (l fdLocker) TryLock() (bool, error) {
+	return l.tryFlock(unix.LOCK_EX)
+}
+
+func (l fdLocker) TryRLock() (bool, error) {
+	return l.tryFlock(unix.LOCK_SH)
+}
+
+// flock applies how to the descriptor, retrying when interrupted.
+func (l fdLocker) flock(how int) error {
+	for {
+		err := unix.Flock(l.fd, how)
+		if !errors.Is(err, unix.EINTR) {
+			return err
+		}
+	}
+}
+
+// tryFlock applies how to the descriptor without blocking.
+func (l fdLocker) tryFlock(how int) (bool, error) {
+	err := l.flock(how | unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
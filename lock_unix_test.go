@@ -0,0 +1,44 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package metricsfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFileLockFlock(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lock")
+	c := NewCollector(DefaultConfig())
+
+	open := func() *MetricsFile {
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		return WrapFile(f, c, name).(*MetricsFile)
+	}
+	a, b := open(), open()
+	defer a.Close()
+	defer b.Close()
+
+	if err := a.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if ok, err := b.TryLock(); ok || err != nil {
+		t.Errorf("Expected the lock to be held, got %v, %v", ok, err)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if ok, err := b.TryRLock(); !ok || err != nil {
+		t.Errorf("Expected the lock to be free, got %v, %v", ok, err)
+	}
+
+	if got := testutil.ToFloat64(c.lockContentionsTotal.WithLabelValues("exclusive")); got != 1 {
+		t.Errorf("Expected 1 contention, got %v", got)
+	}
+}
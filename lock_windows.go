@@ -0,0 +1,56 @@
+//go:build windows
+
+package metricsfs
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// fdLocker locks a file handle with LockFileEx.
+type fdLocker struct {
+	handle windows.Handle
+}
+
+// newFdLocker returns a Locker for the file handle fd.
+func newFdLocker(fd uintptr) Locker {
+	return fdLocker{handle: windows.Handle(fd)}
+}
+
+func (l fdLocker) Lock() error {
+	return l.lock(windows.LOCKFILE_EXCLUSIVE_LOCK)
+}
+
+func (l fdLocker) RLock() error {
+	return l.lock(0)
+}
+
+func (l fdLocker) Unlock() error {
+	var ol windows.Overlapped
+	return windows.UnlockFileEx(l.handle, 0, 1, 0, &ol)
+}
+
+func (l fdLocker) TryLock() (bool, error) {
+	return l.tryLock(windows.LOCKFILE_EXCLUSIVE_LOCK)
+}
+
+func (l fdLocker) TryRLock() (bool, error) {
+	return l.tryLock(0)
+}
+
+// lock locks the first byte of the file, the convention for whole-file
+// locks, with flags.
+func (l fdLocker) lock(flags uint32) error {
+	var ol windows.Overlapped
+	return windows.LockFileEx(l.handle, flags, 0, 1, 0, &ol)
+}
+
+// tryLock locks the file with flags without blocking.
+func (l fdLocker) tryLock(flags uint32) (bool, error) {
+	err := l.lock(flags | windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -1,20 +1,34 @@
 package metricsfs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/absfs/absfs"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Compile-time interface compliance check
-var _ absfs.FileSystem = (*MetricsFS)(nil)
+var _ absfs.SymlinkFileSystem = (*MetricsFS)(nil)
 
 // MetricsFS wraps an absfs.FileSystem and collects metrics on all operations.
 type MetricsFS struct {
 	fs        absfs.FileSystem
 	collector *Collector
+	ctx       context.Context
+	health    *healthChecker
+	instance  string
+	wd        *workingDir
+	trash     *trash
+	open      *openFiles
+
+	// ownsCollector is set when Close closes the collector
+	ownsCollector bool
 }
 
 // New creates a new MetricsFS that wraps the given filesystem.
@@ -24,12 +38,91 @@ func New(fs absfs.FileSystem) *MetricsFS {
 	return NewWithConfig(fs, config)
 }
 
-// NewWithConfig creates a new MetricsFS with custom configuration.
+// NewWithConfig creates a new MetricsFS with custom configuration. Enabled
+// features that have no effect with fs or the rest of config are reported by
+// Warnings.
 func NewWithConfig(fs absfs.FileSystem, config Config) *MetricsFS {
-	return &MetricsFS{
+	m := &MetricsFS{
 		fs:        fs,
 		collector: NewCollector(config),
+		ctx:       context.Background(),
+		health:    newHealthChecker(config.Health),
+		instance:  config.Instance,
+		wd:        &workingDir{},
+		trash:     newTrash(config.Trash),
+		open:      &openFiles{},
+
+		ownsCollector: true,
 	}
+	if m.instance != "" {
+		m.ctx = withInstance(m.ctx, m.instance)
+	}
+	m.collector.checkBase(fs)
+	return m.startHealthChecks().startTrashPurger()
+}
+
+// NewFromFiler creates a new MetricsFS that wraps filer, a backend
+// implementing only the minimal absfs.Filer interface, with default
+// configuration. The FileSystem methods filer lacks are provided by
+// absfs.ExtendFiler: Open, Create, MkdirAll, RemoveAll and Truncate are built
+// on OpenFile, Mkdir and Remove, the working directory is kept by the
+// wrapper, and symbolic link operations fail unless filer supports them.
+// Operations are recorded as issued, whether filer implements them or not.
+// For custom configuration, use NewWithConfig(absfs.ExtendFiler(filer), config).
+func NewFromFiler(filer absfs.Filer) *MetricsFS {
+	if fs, ok := filer.(absfs.FileSystem); ok {
+		return New(fs)
+	}
+	return New(absfs.ExtendFiler(filer))
+}
+
+// NewWithCollector creates a new MetricsFS that records into an existing
+// collector, allowing several wrappers to share one set of metrics. When the
+// collector was created with Config.EnableInstanceLabel, operation-level
+// metrics from this wrapper carry fs_instance=instance. The background health
+// checks and trash purger of a shared collector run once, on the filesystem
+// of the first wrapper that starts them.
+func NewWithCollector(fs absfs.FileSystem, collector *Collector, instance string) *MetricsFS {
+	m := &MetricsFS{
+		fs:        fs,
+		collector: collector,
+		ctx:       withInstance(context.Background(), instance),
+		health:    newHealthChecker(collector.config.Health),
+		instance:  instance,
+		wd:        &workingDir{},
+		trash:     newTrash(collector.config.Trash),
+		open:      &openFiles{},
+	}
+	collector.checkBase(fs)
+	return m.startHealthChecks().startTrashPurger()
+}
+
+// Instance returns the instance name of this wrapper: the instance given to
+// NewWithCollector or Config.Instance, or "" if it has neither. See
+// Collector.Instance for the identifier of an unnamed wrapper's telemetry.
+func (m *MetricsFS) Instance() string {
+	return m.instance
+}
+
+// WithContext returns a shallow copy of m whose operations, and those of the
+// files it opens, are recorded with ctx. The copy shares the underlying
+// filesystem and collector with m. Use it together with Config.ContextLabels
+// to attach per-request dimensions to operation metrics.
+func (m *MetricsFS) WithContext(ctx context.Context) *MetricsFS {
+	if ctx == nil {
+		panic("metricsfs: nil context")
+	}
+	m2 := *m
+	m2.ctx = ctx
+	if m.instance != "" {
+		m2.ctx = withInstance(ctx, m.instance)
+	}
+	return &m2
+}
+
+// Context returns the context operations on m are recorded with.
+func (m *MetricsFS) Context() context.Context {
+	return m.ctx
 }
 
 // Collector returns the Prometheus collector for this filesystem.
@@ -38,27 +131,68 @@ func (m *MetricsFS) Collector() *Collector {
 	return m.collector
 }
 
+// RegisterAll registers the collector of each of fss with reg under an
+// instance const label: the wrapper's Instance, or its index in fss when it
+// has none. Collectors shared by several wrappers are registered once, under
+// the first one. Registering continues past failures, which are returned
+// joined. The collectors must be configured alike, since a registry only
+// accepts metrics of the same name with the same label names. Collectors are
+// unregistered by their Close, as with Collector.Register.
+func RegisterAll(reg prometheus.Registerer, fss ...*MetricsFS) error {
+	var errs []error
+	registered := make(map[*Collector]bool, len(fss))
+	for i, m := range fss {
+		if registered[m.collector] {
+			continue
+		}
+		registered[m.collector] = true
+
+		instance := m.instance
+		if instance == "" {
+			instance = strconv.Itoa(i)
+		}
+		wrapped := prometheus.WrapRegistererWith(prometheus.Labels{"instance": instance}, reg)
+		if err := m.collector.Register(wrapped); err != nil {
+			errs = append(errs, fmt.Errorf("metricsfs: registering instance %q: %w", instance, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Open opens a file for reading.
 func (m *MetricsFS) Open(name string) (absfs.File, error) {
-	start := time.Now()
-	f, err := m.fs.Open(name)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.Open(name)
+	}
 
-	m.collector.recordOperation("open", name, duration, 0, err)
-	m.collector.recordFileOpen("read")
+	start := m.collector.startOperation(OpOpen)
+	f, err := interceptResult(m.collector, m.ctx, OpOpen, name, func() (absfs.File, error) {
+		return m.fs.Open(name)
+	})
+	duration := m.collector.finishOperation(OpOpen, start)
 
+	path := m.metricPath(OpOpen, name)
+	ctx := m.handleContext(err)
 	if err != nil {
+		m.collector.recordOperation(ctx, OpOpen, path, duration, 0, err)
+		m.collector.recordFileOpen("read")
 		return nil, err
 	}
 
-	return newMetricsFile(f, m.collector, name), nil
+	return m.wrapOpened(ctx, ctx, f, path, "read", duration), nil
 }
 
 // OpenFile opens a file with the specified flags and mode.
 func (m *MetricsFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
-	start := time.Now()
-	f, err := m.fs.OpenFile(name, flag, perm)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.OpenFile(name, flag, perm)
+	}
+
+	start := m.collector.startOperation(OpOpen)
+	f, err := interceptResult(m.collector, m.ctx, OpOpen, name, func() (absfs.File, error) {
+		return m.fs.OpenFile(name, flag, perm)
+	})
+	duration := m.collector.finishOperation(OpOpen, start)
 
 	// Determine mode
 	mode := "read"
@@ -71,99 +205,220 @@ func (m *MetricsFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.Fil
 		mode = "append"
 	}
 
-	m.collector.recordOperation("open", name, duration, 0, err)
-	m.collector.recordFileOpen(mode)
-
+	path := m.metricPath(OpOpen, name)
+	ctx := m.handleContext(err)
+	openCtx := m.collector.withOpenFlags(ctx, flag)
 	if err != nil {
+		m.collector.recordOperation(openCtx, OpOpen, path, duration, 0, err)
+		m.collector.recordFileOpen(mode)
 		return nil, err
 	}
 
-	return newMetricsFile(f, m.collector, name), nil
+	return m.wrapOpened(ctx, openCtx, f, path, mode, duration), nil
+}
+
+// handleContext returns the context an open that ended with err, and the
+// operations on the handle it opened, are recorded with. With handle IDs
+// enabled, it carries a new ID when the open succeeded.
+func (m *MetricsFS) handleContext(err error) context.Context {
+	if err != nil {
+		return m.ctx
+	}
+	return m.collector.withHandleID(m.ctx)
+}
+
+// wrapOpened records the open of a successfully opened handle, which took
+// duration, with openCtx and wraps it. When handle kind detection is enabled,
+// directory handles are recorded as OpOpenDir and directory operations
+// rather than file opens. A handle whose kind the Stat on open cannot tell
+// has its open recorded once its kind is known, by Readdir or Stat on the
+// handle, or as a file open when it is closed.
+func (m *MetricsFS) wrapOpened(ctx, openCtx context.Context, f absfs.File, name, mode string, duration time.Duration) *MetricsFile {
+	mf := newMetricsFile(ctx, f, m.collector, name)
+	mf.appending = mode == "append"
+
+	if m.collector.config.EnableHandleKindDetection {
+		info, err := f.Stat()
+		switch {
+		case err != nil:
+			mf.pendingOpen.Store(&pendingOpen{ctx: openCtx, mode: mode, duration: duration})
+		case info.IsDir():
+			mf.isDir.Store(true)
+			m.collector.recordOpen(openCtx, name, mode, duration, true)
+			return mf
+		default:
+			m.collector.recordOpen(openCtx, name, mode, duration, false)
+		}
+	} else {
+		m.collector.recordOpen(openCtx, name, mode, duration, false)
+	}
+
+	mf.verifier = m.collector.newFileVerifier(name)
+	m.track(mf)
+	return mf
 }
 
 // Create creates a new file.
 func (m *MetricsFS) Create(name string) (absfs.File, error) {
-	start := time.Now()
-	f, err := m.fs.Create(name)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.Create(name)
+	}
+
+	var existing os.FileInfo
+	if m.collector.config.EnableOverwriteDetection {
+		existing = m.lstatExisting(name)
+	}
+
+	start := m.collector.startOperation(OpCreate)
+	f, err := interceptResult(m.collector, m.ctx, OpCreate, name, func() (absfs.File, error) {
+		return m.fs.Create(name)
+	})
+	duration := m.collector.finishOperation(OpCreate, start)
 
-	m.collector.recordOperation("create", name, duration, 0, err)
+	path := m.metricPath(OpCreate, name)
+	ctx := m.handleContext(err)
+	m.collector.recordOperation(m.collector.withOpenFlags(ctx, os.O_RDWR|os.O_CREATE|os.O_TRUNC), OpCreate, path, duration, 0, err)
 	m.collector.recordFileCreate()
 	m.collector.recordFileOpen("write")
 
+	if err == nil && existing != nil && existing.Mode().IsRegular() {
+		m.collector.recordFileOverwrite(existing.Size())
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	return newMetricsFile(f, m.collector, name), nil
+	mf := newMetricsFile(ctx, f, m.collector, path)
+	m.track(mf)
+	return mf, nil
+}
+
+// lstatExisting returns file information for name without recording metrics,
+// or nil if it does not exist or cannot be inspected.
+func (m *MetricsFS) lstatExisting(name string) os.FileInfo {
+	var info os.FileInfo
+	var err error
+	if sfs, ok := m.fs.(interface {
+		Lstat(name string) (os.FileInfo, error)
+	}); ok {
+		info, err = sfs.Lstat(name)
+	} else {
+		info, err = m.fs.Stat(name)
+	}
+	if err != nil {
+		return nil
+	}
+	return info
 }
 
 // Mkdir creates a directory.
 func (m *MetricsFS) Mkdir(name string, perm os.FileMode) error {
-	start := time.Now()
-	err := m.fs.Mkdir(name, perm)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.Mkdir(name, perm)
+	}
+
+	start := m.collector.startOperation(OpMkdir)
+	err := m.collector.intercept(m.ctx, OpMkdir, name, func() error {
+		return m.fs.Mkdir(name, perm)
+	})
+	duration := m.collector.finishOperation(OpMkdir, start)
 
-	m.collector.recordOperation("mkdir", name, duration, 0, err)
-	m.collector.recordDirOperation("mkdir")
+	m.collector.recordOperation(m.ctx, OpMkdir, m.metricPath(OpMkdir, name), duration, 0, err)
+	m.collector.recordDirOperation(OpMkdir)
 
 	return err
 }
 
 // MkdirAll creates a directory and all necessary parent directories.
 func (m *MetricsFS) MkdirAll(name string, perm os.FileMode) error {
-	start := time.Now()
-	err := m.fs.MkdirAll(name, perm)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.MkdirAll(name, perm)
+	}
+
+	start := m.collector.startOperation(OpMkdirAll)
+	err := m.collector.intercept(m.ctx, OpMkdirAll, name, func() error {
+		return m.fs.MkdirAll(name, perm)
+	})
+	duration := m.collector.finishOperation(OpMkdirAll, start)
 
-	m.collector.recordOperation("mkdirall", name, duration, 0, err)
-	m.collector.recordDirOperation("mkdirall")
+	m.collector.recordOperation(m.ctx, OpMkdirAll, m.metricPath(OpMkdirAll, name), duration, 0, err)
+	m.collector.recordDirOperation(OpMkdirAll)
 
 	return err
 }
 
-// Remove removes a file or directory.
+// Remove removes a file or directory. With Config.Trash, files are moved to
+// the trash instead.
 func (m *MetricsFS) Remove(name string) error {
-	start := time.Now()
-	err := m.fs.Remove(name)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.remove(OpRemove, name)
+	}
+
+	start := m.collector.startOperation(OpRemove)
+	err := m.collector.intercept(m.ctx, OpRemove, name, func() error {
+		return m.remove(OpRemove, name)
+	})
+	duration := m.collector.finishOperation(OpRemove, start)
 
-	m.collector.recordOperation("remove", name, duration, 0, err)
-	m.collector.recordDirOperation("remove")
+	m.collector.recordOperation(m.ctx, OpRemove, m.metricPath(OpRemove, name), duration, 0, err)
+	m.collector.recordDirOperation(OpRemove)
 
 	return err
 }
 
-// RemoveAll removes a path and all children.
+// RemoveAll removes a path and all children. With Config.Trash, the path is
+// moved to the trash instead.
 func (m *MetricsFS) RemoveAll(name string) error {
-	start := time.Now()
-	err := m.fs.RemoveAll(name)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.remove(OpRemoveAll, name)
+	}
+
+	start := m.collector.startOperation(OpRemoveAll)
+	err := m.collector.intercept(m.ctx, OpRemoveAll, name, func() error {
+		return m.remove(OpRemoveAll, name)
+	})
+	duration := m.collector.finishOperation(OpRemoveAll, start)
 
-	m.collector.recordOperation("removeall", name, duration, 0, err)
-	m.collector.recordDirOperation("removeall")
+	m.collector.recordOperation(m.ctx, OpRemoveAll, m.metricPath(OpRemoveAll, name), duration, 0, err)
+	m.collector.recordDirOperation(OpRemoveAll)
 
 	return err
 }
 
 // Rename renames a file or directory.
 func (m *MetricsFS) Rename(oldpath, newpath string) error {
-	start := time.Now()
-	err := m.fs.Rename(oldpath, newpath)
-	duration := time.Since(start)
+	if m.passThrough(oldpath) {
+		return m.fs.Rename(oldpath, newpath)
+	}
 
-	m.collector.recordOperation("rename", oldpath, duration, 0, err)
+	start := m.collector.startOperation(OpRename)
+	err := m.collector.intercept(m.ctx, OpRename, oldpath, func() error {
+		return m.fs.Rename(oldpath, newpath)
+	})
+	duration := m.collector.finishOperation(OpRename, start)
+
+	m.collector.recordOperation(m.ctx, OpRename, m.metricPath(OpRename, oldpath), duration, 0, err)
+	if m.collector.config.EnableRenameMetrics {
+		m.collector.recordRename(m.resolvePath(oldpath), m.resolvePath(newpath), err)
+	}
 
 	return err
 }
 
 // Stat returns file information.
 func (m *MetricsFS) Stat(name string) (os.FileInfo, error) {
-	start := time.Now()
-	info, err := m.fs.Stat(name)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.Stat(name)
+	}
 
-	m.collector.recordOperation("stat", name, duration, 0, err)
+	start := m.collector.startOperation(OpStat)
+	info, err := interceptResult(m.collector, m.ctx, OpStat, name, func() (os.FileInfo, error) {
+		return m.fs.Stat(name)
+	})
+	duration := m.collector.finishOperation(OpStat, start)
+
+	m.collector.recordOperation(m.ctx, OpStat, m.metricPath(OpStat, name), duration, 0, err)
 
 	return info, err
 }
@@ -171,51 +426,112 @@ func (m *MetricsFS) Stat(name string) (os.FileInfo, error) {
 // Lstat returns file information without following symlinks.
 // This method is only available if the underlying filesystem implements SymlinkFileSystem.
 func (m *MetricsFS) Lstat(name string) (os.FileInfo, error) {
-	start := time.Now()
-
 	// Check if underlying filesystem supports Lstat
 	if sfs, ok := m.fs.(interface {
 		Lstat(name string) (os.FileInfo, error)
 	}); ok {
-		info, err := sfs.Lstat(name)
-		duration := time.Since(start)
-		m.collector.recordOperation("lstat", name, duration, 0, err)
-		return info, err
+		return m.lstat(sfs.Lstat, name)
 	}
 
 	// Fallback to Stat if Lstat not available
 	return m.Stat(name)
 }
 
+// lstat records a call of lstat, the Lstat of the underlying filesystem.
+func (m *MetricsFS) lstat(lstat func(name string) (os.FileInfo, error), name string) (os.FileInfo, error) {
+	if m.passThrough(name) {
+		return lstat(name)
+	}
+	start := m.collector.startOperation(OpLstat)
+	info, err := interceptResult(m.collector, m.ctx, OpLstat, name, func() (os.FileInfo, error) {
+		return lstat(name)
+	})
+	duration := m.collector.finishOperation(OpLstat, start)
+	m.collector.recordOperation(m.ctx, OpLstat, m.metricPath(OpLstat, name), duration, 0, err)
+	return info, err
+}
+
 // Chmod changes file permissions.
 func (m *MetricsFS) Chmod(name string, mode os.FileMode) error {
-	start := time.Now()
-	err := m.fs.Chmod(name, mode)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.Chmod(name, mode)
+	}
 
-	m.collector.recordOperation("chmod", name, duration, 0, err)
+	start := m.collector.startOperation(OpChmod)
+	err := m.collector.intercept(m.ctx, OpChmod, name, func() error {
+		return m.fs.Chmod(name, mode)
+	})
+	duration := m.collector.finishOperation(OpChmod, start)
+
+	m.collector.recordOperation(m.ctx, OpChmod, m.metricPath(OpChmod, name), duration, 0, err)
 
 	return err
 }
 
 // Chown changes file ownership.
 func (m *MetricsFS) Chown(name string, uid, gid int) error {
-	start := time.Now()
-	err := m.fs.Chown(name, uid, gid)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.Chown(name, uid, gid)
+	}
+
+	start := m.collector.startOperation(OpChown)
+	err := m.collector.intercept(m.ctx, OpChown, name, func() error {
+		return m.fs.Chown(name, uid, gid)
+	})
+	duration := m.collector.finishOperation(OpChown, start)
+
+	m.collector.recordOperation(m.ctx, OpChown, m.metricPath(OpChown, name), duration, 0, err)
+
+	return err
+}
 
-	m.collector.recordOperation("chown", name, duration, 0, err)
+// Lchown changes the ownership of a file without following symlinks.
+// This method is only available if the underlying filesystem implements SymlinkFileSystem.
+func (m *MetricsFS) Lchown(name string, uid, gid int) error {
+	// Check if underlying filesystem supports Lchown
+	if sfs, ok := m.fs.(interface {
+		Lchown(name string, uid, gid int) error
+	}); ok {
+		return m.lchown(sfs.Lchown, name, uid, gid)
+	}
 
+	if m.passThrough(name) {
+		return os.ErrInvalid
+	}
+	start := m.collector.startOperation(OpLchown)
+	duration := m.collector.finishOperation(OpLchown, start)
+	err := os.ErrInvalid
+	m.collector.recordOperation(m.ctx, OpLchown, m.metricPath(OpLchown, name), duration, 0, err)
+	return err
+}
+
+// lchown records a call of lchown, the Lchown of the underlying filesystem.
+func (m *MetricsFS) lchown(lchown func(name string, uid, gid int) error, name string, uid, gid int) error {
+	if m.passThrough(name) {
+		return lchown(name, uid, gid)
+	}
+	start := m.collector.startOperation(OpLchown)
+	err := m.collector.intercept(m.ctx, OpLchown, name, func() error {
+		return lchown(name, uid, gid)
+	})
+	duration := m.collector.finishOperation(OpLchown, start)
+	m.collector.recordOperation(m.ctx, OpLchown, m.metricPath(OpLchown, name), duration, 0, err)
 	return err
 }
 
 // Chtimes changes file access and modification times.
 func (m *MetricsFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
-	start := time.Now()
-	err := m.fs.Chtimes(name, atime, mtime)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.Chtimes(name, atime, mtime)
+	}
 
-	m.collector.recordOperation("chtimes", name, duration, 0, err)
+	start := m.collector.startOperation(OpChtimes)
+	err := m.collector.intercept(m.ctx, OpChtimes, name, func() error {
+		return m.fs.Chtimes(name, atime, mtime)
+	})
+	duration := m.collector.finishOperation(OpChtimes, start)
+
+	m.collector.recordOperation(m.ctx, OpChtimes, m.metricPath(OpChtimes, name), duration, 0, err)
 
 	return err
 }
@@ -223,82 +539,132 @@ func (m *MetricsFS) Chtimes(name string, atime time.Time, mtime time.Time) error
 // Readlink reads the target of a symbolic link.
 // This method is only available if the underlying filesystem implements SymlinkFileSystem.
 func (m *MetricsFS) Readlink(name string) (string, error) {
-	start := time.Now()
-
 	// Check if underlying filesystem supports Readlink
 	if sfs, ok := m.fs.(interface {
 		Readlink(name string) (string, error)
 	}); ok {
-		target, err := sfs.Readlink(name)
-		duration := time.Since(start)
-		m.collector.recordOperation("readlink", name, duration, 0, err)
-		return target, err
+		return m.readlink(sfs.Readlink, name)
 	}
 
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return "", os.ErrInvalid
+	}
+	start := m.collector.startOperation(OpReadlink)
+	duration := m.collector.finishOperation(OpReadlink, start)
 	err := os.ErrInvalid
-	m.collector.recordOperation("readlink", name, duration, 0, err)
+	m.collector.recordOperation(m.ctx, OpReadlink, m.metricPath(OpReadlink, name), duration, 0, err)
 	return "", err
 }
 
+// readlink records a call of readlink, the Readlink of the underlying
+// filesystem.
+func (m *MetricsFS) readlink(readlink func(name string) (string, error), name string) (string, error) {
+	if m.passThrough(name) {
+		return readlink(name)
+	}
+	start := m.collector.startOperation(OpReadlink)
+	target, err := interceptResult(m.collector, m.ctx, OpReadlink, name, func() (string, error) {
+		return readlink(name)
+	})
+	duration := m.collector.finishOperation(OpReadlink, start)
+	m.collector.recordOperation(m.ctx, OpReadlink, m.metricPath(OpReadlink, name), duration, 0, err)
+	return target, err
+}
+
 // Symlink creates a symbolic link.
 // This method is only available if the underlying filesystem implements SymlinkFileSystem.
 func (m *MetricsFS) Symlink(oldname, newname string) error {
-	start := time.Now()
-
 	// Check if underlying filesystem supports Symlink
 	if sfs, ok := m.fs.(interface {
 		Symlink(oldname, newname string) error
 	}); ok {
-		err := sfs.Symlink(oldname, newname)
-		duration := time.Since(start)
-		m.collector.recordOperation("symlink", newname, duration, 0, err)
-		return err
+		return m.symlink(sfs.Symlink, oldname, newname)
 	}
 
-	duration := time.Since(start)
+	if m.passThrough(newname) {
+		return os.ErrInvalid
+	}
+	start := m.collector.startOperation(OpSymlink)
+	duration := m.collector.finishOperation(OpSymlink, start)
 	err := os.ErrInvalid
-	m.collector.recordOperation("symlink", newname, duration, 0, err)
+	m.collector.recordOperation(m.ctx, OpSymlink, m.metricPath(OpSymlink, newname), duration, 0, err)
+	return err
+}
+
+// symlink records a call of symlink, the Symlink of the underlying
+// filesystem.
+func (m *MetricsFS) symlink(symlink func(oldname, newname string) error, oldname, newname string) error {
+	if m.passThrough(newname) {
+		return symlink(oldname, newname)
+	}
+	start := m.collector.startOperation(OpSymlink)
+	err := m.collector.intercept(m.ctx, OpSymlink, newname, func() error {
+		return symlink(oldname, newname)
+	})
+	duration := m.collector.finishOperation(OpSymlink, start)
+	m.collector.recordOperation(m.ctx, OpSymlink, m.metricPath(OpSymlink, newname), duration, 0, err)
 	return err
 }
 
 // Chdir changes the current working directory.
 func (m *MetricsFS) Chdir(dir string) error {
-	start := time.Now()
+	if m.passThrough(dir) {
+		if fs, ok := m.fs.(interface {
+			Chdir(dir string) error
+		}); ok {
+			err := fs.Chdir(dir)
+			if err == nil {
+				m.wd.invalidate()
+			}
+			return err
+		}
+		return os.ErrInvalid
+	}
+
+	// Resolved against the working directory before it changes
+	path := m.metricPath(OpChdir, dir)
+	start := m.collector.startOperation(OpChdir)
 
 	// Check if underlying filesystem implements Chdir
 	if fs, ok := m.fs.(interface {
 		Chdir(dir string) error
 	}); ok {
-		err := fs.Chdir(dir)
-		duration := time.Since(start)
-		m.collector.recordOperation("chdir", dir, duration, 0, err)
+		err := m.collector.intercept(m.ctx, OpChdir, dir, func() error {
+			return fs.Chdir(dir)
+		})
+		duration := m.collector.finishOperation(OpChdir, start)
+		m.collector.recordOperation(m.ctx, OpChdir, path, duration, 0, err)
+		if err == nil {
+			m.wd.invalidate()
+		}
 		return err
 	}
 
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(OpChdir, start)
 	err := os.ErrInvalid
-	m.collector.recordOperation("chdir", dir, duration, 0, err)
+	m.collector.recordOperation(m.ctx, OpChdir, path, duration, 0, err)
 	return err
 }
 
 // Getwd returns the current working directory.
 func (m *MetricsFS) Getwd() (string, error) {
-	start := time.Now()
+	start := m.collector.startOperation(OpGetwd)
 
 	// Check if underlying filesystem implements Getwd
 	if fs, ok := m.fs.(interface {
 		Getwd() (string, error)
 	}); ok {
-		dir, err := fs.Getwd()
-		duration := time.Since(start)
-		m.collector.recordOperation("getwd", dir, duration, 0, err)
+		dir, err := interceptResult(m.collector, m.ctx, OpGetwd, "", func() (string, error) {
+			return fs.Getwd()
+		})
+		duration := m.collector.finishOperation(OpGetwd, start)
+		m.collector.recordOperation(m.ctx, OpGetwd, dir, duration, 0, err)
 		return dir, err
 	}
 
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(OpGetwd, start)
 	err := os.ErrInvalid
-	m.collector.recordOperation("getwd", "", duration, 0, err)
+	m.collector.recordOperation(m.ctx, OpGetwd, "", duration, 0, err)
 	return "", err
 }
 
@@ -315,54 +681,149 @@ func (m *MetricsFS) TempDir() string {
 
 // Truncate truncates the named file to the specified size.
 func (m *MetricsFS) Truncate(name string, size int64) error {
-	start := time.Now()
+	if m.passThrough(name) {
+		if sfs, ok := m.fs.(interface {
+			Truncate(name string, size int64) error
+		}); ok {
+			return sfs.Truncate(name, size)
+		}
+		return os.ErrInvalid
+	}
+
+	start := m.collector.startOperation(OpTruncate)
 
 	// Check if underlying filesystem implements Truncate
 	if fs, ok := m.fs.(interface {
 		Truncate(name string, size int64) error
 	}); ok {
-		err := fs.Truncate(name, size)
-		duration := time.Since(start)
-		m.collector.recordOperation("truncate", name, duration, size, err)
+		err := m.collector.intercept(m.ctx, OpTruncate, name, func() error {
+			stat := func() (os.FileInfo, error) { return m.fs.Stat(name) }
+			if err := m.collector.checkTruncate(name, m.collector.maxFileSize(name), size, stat); err != nil {
+				return err
+			}
+			return fs.Truncate(name, size)
+		})
+		duration := m.collector.finishOperation(OpTruncate, start)
+		m.collector.recordOperation(m.ctx, OpTruncate, m.metricPath(OpTruncate, name), duration, size, err)
 		return err
 	}
 
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(OpTruncate, start)
 	err := os.ErrInvalid
-	m.collector.recordOperation("truncate", name, duration, size, err)
+	m.collector.recordOperation(m.ctx, OpTruncate, m.metricPath(OpTruncate, name), duration, size, err)
 	return err
 }
 
 // ReadDir reads the named directory and returns a list of directory entries.
 func (m *MetricsFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	start := time.Now()
-	entries, err := m.fs.ReadDir(name)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.ReadDir(name)
+	}
+
+	start := m.collector.startOperation(OpReaddir)
+	entries, err := interceptResult(m.collector, m.ctx, OpReaddir, name, func() ([]fs.DirEntry, error) {
+		return m.fs.ReadDir(name)
+	})
+	duration := m.collector.finishOperation(OpReaddir, start)
 
-	m.collector.recordOperation("readdir", name, duration, 0, err)
-	m.collector.recordDirOperation("readdir")
+	m.collector.recordOperation(m.ctx, OpReaddir, m.metricPath(OpReaddir, name), duration, 0, err)
+	m.collector.recordDirOperation(OpReaddir)
+	m.collector.recordReaddirEntries(len(entries), dirEntryNameBytes(entries), err)
 
 	return entries, err
 }
 
 // ReadFile reads the named file and returns its contents.
 func (m *MetricsFS) ReadFile(name string) ([]byte, error) {
-	start := time.Now()
-	data, err := m.fs.ReadFile(name)
-	duration := time.Since(start)
+	if m.passThrough(name) {
+		return m.fs.ReadFile(name)
+	}
+
+	start := m.collector.startOperation(OpReadFile)
+	data, err := interceptResult(m.collector, m.ctx, OpReadFile, name, func() ([]byte, error) {
+		return m.fs.ReadFile(name)
+	})
+	duration := m.collector.finishOperation(OpReadFile, start)
 
-	m.collector.recordOperation("readfile", name, duration, int64(len(data)), err)
+	path := m.metricPath(OpReadFile, name)
+	m.collector.recordOperation(m.ctx, OpReadFile, path, duration, int64(len(data)), err)
+	if err == nil {
+		m.collector.verifyContents(path, data)
+	}
 
 	return data, err
 }
 
-// Sub returns a Filer corresponding to the subtree rooted at dir.
+// fileWriter is implemented by filesystems with an optimized WriteFile.
+type fileWriter interface {
+	WriteFile(name string, data []byte, perm os.FileMode) error
+}
+
+// WriteFile writes data to the named file, creating it with perm if needed
+// and truncating it otherwise, recorded as a single operation. It delegates
+// to the wrapped filesystem's WriteFile when it has one, and otherwise opens,
+// writes and closes the file on the wrapped filesystem without recording
+// those operations individually. Data larger than the maximum size of the
+// file's path group is refused with ErrFileTooLarge, see
+// Config.MaxFileSizes.
+func (m *MetricsFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if m.passThrough(name) {
+		return m.writeFile(name, data, perm)
+	}
+
+	start := m.collector.startOperation(OpWriteFile)
+	n, err := interceptResult(m.collector, m.ctx, OpWriteFile, name, func() (int, error) {
+		if limit := m.collector.maxFileSize(name); limit > 0 && int64(len(data)) > limit {
+			m.collector.recordSizeLimitViolation(name)
+			return 0, ErrFileTooLarge
+		}
+		if err := m.writeFile(name, data, perm); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	})
+	duration := m.collector.finishOperation(OpWriteFile, start)
+
+	path := m.metricPath(OpWriteFile, name)
+	m.collector.recordOperation(m.ctx, OpWriteFile, path, duration, int64(n), err)
+
+	return err
+}
+
+// writeFile writes data to name on the wrapped filesystem.
+func (m *MetricsFS) writeFile(name string, data []byte, perm os.FileMode) error {
+	if w, ok := m.fs.(fileWriter); ok {
+		return w.WriteFile(name, data, perm)
+	}
+
+	f, err := m.fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Sub returns a read-only fs.FS corresponding to the subtree rooted at dir.
+// Its operations, and those of the files it opens, are recorded by m's
+// collector under their full paths, and carry the absolute path of dir as
+// their root label with Config.EnableRootLabel. The returned fs.FS
+// implements fs.SubFS, fs.ReadDirFS, fs.ReadFileFS and fs.StatFS.
 func (m *MetricsFS) Sub(dir string) (fs.FS, error) {
-	start := time.Now()
-	sub, err := m.fs.Sub(dir)
-	duration := time.Since(start)
+	if m.passThrough(dir) {
+		return m.fs.Sub(dir)
+	}
+
+	start := m.collector.startOperation(OpSub)
+	sub, err := interceptResult(m.collector, m.ctx, OpSub, dir, func() (fs.FS, error) {
+		return m.sub(dir)
+	})
+	duration := m.collector.finishOperation(OpSub, start)
 
-	m.collector.recordOperation("sub", dir, duration, 0, err)
+	m.collector.recordOperation(m.ctx, OpSub, m.metricPath(OpSub, dir), duration, 0, err)
 
 	if err != nil {
 		return nil, err
@@ -1,14 +1,22 @@
 package metricsfs
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/absfs/absfs"
+	"github.com/absfs/osfs"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // mockFS is a minimal mock filesystem for testing.
@@ -211,6 +219,37 @@ func TestNewWithConfig(t *testing.T) {
 	}
 }
 
+// filerOnly exposes only the absfs.Filer methods of the filer it wraps.
+type filerOnly struct {
+	absfs.Filer
+}
+
+func TestNewFromFiler(t *testing.T) {
+	fs := NewFromFiler(filerOnly{newMockFS()})
+
+	f, err := fs.Open("/test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Close()
+	if wd, err := fs.Getwd(); err != nil || wd != "/" {
+		t.Errorf("Getwd() = %q, %v, want \"/\"", wd, err)
+	}
+
+	c := fs.collector
+	for _, op := range []string{"open", "close", "getwd"} {
+		if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues(op, "success")); v != 1 {
+			t.Errorf("Expected 1 %s operation, got %v", op, v)
+		}
+	}
+
+	// A Filer that is also a FileSystem is used as is
+	base := newMockFS()
+	if fs := NewFromFiler(base); fs.fs != base {
+		t.Error("Expected a FileSystem to be wrapped directly")
+	}
+}
+
 func TestOperationCounting(t *testing.T) {
 	base := newMockFS()
 	fs := New(base)
@@ -325,6 +364,245 @@ func (e *errorMockFS) Stat(name string) (os.FileInfo, error) {
 	return nil, os.ErrPermission
 }
 
+// eofMockFS returns files whose reads always report io.EOF, and whose
+// reads at an offset fail.
+type eofMockFS struct {
+	mockFS
+}
+
+func (e *eofMockFS) Open(name string) (absfs.File, error) {
+	return &eofMockFile{mockFile{name: name}}, nil
+}
+
+type eofMockFile struct {
+	mockFile
+}
+
+func (f *eofMockFile) Read(p []byte) (n int, err error) {
+	return 0, io.EOF
+}
+
+func (f *eofMockFile) ReadAt(p []byte, off int64) (n int, err error) {
+	return 0, errors.New("i/o error")
+}
+
+func TestReadEOFNotCountedAsError(t *testing.T) {
+	var onErrorCalled bool
+	config := DefaultConfig()
+	config.OnError = func(operation string, err error) {
+		onErrorCalled = true
+	}
+	fs := NewWithConfig(&eofMockFS{}, config)
+
+	f, err := fs.Open("/test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Read(make([]byte, 8)); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+
+	if onErrorCalled {
+		t.Error("OnError should not be called for io.EOF")
+	}
+	if v := testutil.ToFloat64(fs.collector.operationsTotal.WithLabelValues("read", "success")); v != 1 {
+		t.Errorf("Expected 1 successful read, got %v", v)
+	}
+	if v := testutil.ToFloat64(fs.collector.errorsTotal.WithLabelValues("read", "unknown")); v != 0 {
+		t.Errorf("Expected no read errors, got %v", v)
+	}
+}
+
+func TestNativeHistograms(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		config := DefaultConfig()
+		config.EnableNativeHistograms = enabled
+		fs := NewWithConfig(newMockFS(), config)
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(fs.Collector())
+
+		fs.Stat("/test.txt")
+
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("Gather failed: %v", err)
+		}
+
+		var h *dto.Histogram
+		for _, mf := range families {
+			if mf.GetName() == "fs_operation_duration_seconds" {
+				h = mf.GetMetric()[0].GetHistogram()
+			}
+		}
+		if h == nil {
+			t.Fatal("fs_operation_duration_seconds not exported")
+		}
+
+		// A bucket factor of 1.1 selects schema 3; classic buckets are kept
+		native := h.Schema != nil
+		if native != enabled || (enabled && h.GetSchema() != 3) {
+			t.Errorf("EnableNativeHistograms=%v: got schema %v", enabled, h.Schema)
+		}
+		if len(h.GetBucket()) != len(config.LatencyBuckets) {
+			t.Errorf("Expected %d classic buckets, got %d", len(config.LatencyBuckets), len(h.GetBucket()))
+		}
+	}
+}
+
+func TestTraceExemplars(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	fs := New(newMockFS())
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	fs.Stat("/untraced")
+	fs.WithContext(ctx).Stat("/traced")
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	var exemplars []*dto.Exemplar
+	for _, mf := range families {
+		if mf.GetName() != "fs_operation_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, b := range m.GetHistogram().GetBucket() {
+				if e := b.GetExemplar(); e != nil {
+					exemplars = append(exemplars, e)
+				}
+			}
+		}
+	}
+
+	if len(exemplars) != 1 {
+		t.Fatalf("Expected 1 exemplar, got %d", len(exemplars))
+	}
+	labels := make(map[string]string)
+	for _, lp := range exemplars[0].GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	if labels["trace_id"] != traceID.String() || labels["span_id"] != spanID.String() {
+		t.Errorf("Unexpected exemplar labels: %v", labels)
+	}
+}
+
+func TestWrapFile(t *testing.T) {
+	collector := NewCollector(DefaultConfig())
+
+	f := WrapFile(&mockFile{name: "/range.bin"}, collector, "/range.bin")
+	if _, ok := f.(*MetricsFile); !ok {
+		t.Fatalf("Expected *MetricsFile, got %T", f)
+	}
+
+	if v := collector.openFiles.Load(); v != 1 {
+		t.Errorf("Expected 1 open file, got %d", v)
+	}
+
+	f.Write([]byte("hello"))
+	f.Close()
+
+	if v := testutil.ToFloat64(collector.operationsTotal.WithLabelValues("write", "success")); v != 1 {
+		t.Errorf("Expected 1 write, got %v", v)
+	}
+	if v := collector.openFiles.Load(); v != 0 {
+		t.Errorf("Expected 0 open files after Close, got %d", v)
+	}
+
+	if WrapFile(nil, collector, "/missing") != nil {
+		t.Error("Expected nil when wrapping a nil file")
+	}
+}
+
+// shortMockFS returns files that transfer only half of each read and write.
+type shortMockFS struct {
+	mockFS
+}
+
+func (s *shortMockFS) Open(name string) (absfs.File, error) {
+	return &shortMockFile{mockFile{name: name}}, nil
+}
+
+type shortMockFile struct {
+	mockFile
+}
+
+func (f *shortMockFile) Read(p []byte) (n int, err error) {
+	return len(p) / 2, nil
+}
+
+func (f *shortMockFile) Write(p []byte) (n int, err error) {
+	return len(p) / 2, nil
+}
+
+func TestShortTransferMetrics(t *testing.T) {
+	fs := New(&shortMockFS{})
+
+	f, err := fs.Open("/test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	f.Read(make([]byte, 8))
+	f.Write(make([]byte, 8))
+	f.Write(make([]byte, 8))
+	f.WriteAt(make([]byte, 8), 0) // full write
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.shortReadsTotal.WithLabelValues("read")); v != 1 {
+		t.Errorf("Expected 1 short read, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.shortWritesTotal.WithLabelValues("write")); v != 2 {
+		t.Errorf("Expected 2 short writes, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.eofTotal.WithLabelValues("read")); v != 0 {
+		t.Errorf("Expected no EOFs, got %v", v)
+	}
+
+	eofFS := New(&eofMockFS{})
+	ef, err := eofFS.Open("/test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer ef.Close()
+
+	ef.Read(make([]byte, 8))
+	if v := testutil.ToFloat64(eofFS.collector.eofTotal.WithLabelValues("read")); v != 1 {
+		t.Errorf("Expected 1 EOF, got %v", v)
+	}
+	if v := testutil.ToFloat64(eofFS.collector.shortReadsTotal.WithLabelValues("read")); v != 0 {
+		t.Errorf("EOF should not count as a short read, got %v", v)
+	}
+}
+
+func TestIsBenignError(t *testing.T) {
+	if !isBenignError(io.EOF) {
+		t.Error("io.EOF should be benign")
+	}
+	if !isBenignError(fmt.Errorf("wrapped: %w", io.EOF)) {
+		t.Error("wrapped io.EOF should be benign")
+	}
+	if isBenignError(io.ErrUnexpectedEOF) {
+		t.Error("io.ErrUnexpectedEOF should not be benign")
+	}
+	if isBenignError(os.ErrNotExist) {
+		t.Error("os.ErrNotExist should not be benign")
+	}
+}
+
 func TestFileDescriptorTracking(t *testing.T) {
 	base := newMockFS()
 	fs := New(base)
@@ -363,6 +641,451 @@ func TestFileDescriptorTracking(t *testing.T) {
 	}
 }
 
+// blockingStatMockFS blocks Stat until release is closed.
+type blockingStatMockFS struct {
+	mockFS
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingStatMockFS) Stat(name string) (os.FileInfo, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return &mockFileInfo{name: name}, nil
+}
+
+func TestOperationsInFlight(t *testing.T) {
+	base := &blockingStatMockFS{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	fs := New(base)
+	gauge := fs.collector.operationsInFlight.WithLabelValues("stat")
+
+	done := make(chan struct{})
+	go func() {
+		fs.Stat("/test.txt")
+		close(done)
+	}()
+
+	<-base.started
+	if v := testutil.ToFloat64(gauge); v != 1 {
+		t.Errorf("Expected 1 stat in flight, got %v", v)
+	}
+
+	close(base.release)
+	<-done
+	if v := testutil.ToFloat64(gauge); v != 0 {
+		t.Errorf("Expected 0 stats in flight, got %v", v)
+	}
+}
+
+// existingFileMockFS reports every path as an existing file of the given size,
+// or as missing if size is negative.
+type existingFileMockFS struct {
+	mockFS
+	size int64
+}
+
+func (e *existingFileMockFS) Lstat(name string) (os.FileInfo, error) {
+	if e.size < 0 {
+		return nil, os.ErrNotExist
+	}
+	return &sizedFileInfo{mockFileInfo{name: name}, e.size}, nil
+}
+
+type sizedFileInfo struct {
+	mockFileInfo
+	size int64
+}
+
+func (i *sizedFileInfo) Size() int64 { return i.size }
+
+func TestOverwriteDetection(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableOverwriteDetection = true
+	fs := NewWithConfig(&existingFileMockFS{size: 42}, config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	f, err := fs.Create("/existing.txt")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Close()
+
+	if v := testutil.ToFloat64(fs.collector.fileOverwritesTotal.WithLabelValues()); v != 1 {
+		t.Errorf("Expected 1 overwrite, got %v", v)
+	}
+	if v := testutil.ToFloat64(fs.collector.overwrittenBytesTotal.WithLabelValues()); v != 42 {
+		t.Errorf("Expected 42 overwritten bytes, got %v", v)
+	}
+}
+
+func TestOverwriteDetectionNewFile(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableOverwriteDetection = true
+	fs := NewWithConfig(&existingFileMockFS{size: -1}, config)
+
+	f, err := fs.Create("/new.txt")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Close()
+
+	if v := testutil.ToFloat64(fs.collector.fileOverwritesTotal.WithLabelValues()); v != 0 {
+		t.Errorf("Expected no overwrites, got %v", v)
+	}
+}
+
+// dirMockFS opens every path as a directory handle.
+type dirMockFS struct {
+	mockFS
+}
+
+func (d *dirMockFS) Open(name string) (absfs.File, error) {
+	return &dirMockFile{mockFile{name: name}}, nil
+}
+
+type dirMockFile struct {
+	mockFile
+}
+
+func (f *dirMockFile) Stat() (os.FileInfo, error) {
+	return &dirFileInfo{mockFileInfo{name: f.name}}, nil
+}
+
+type dirFileInfo struct {
+	mockFileInfo
+}
+
+func (i *dirFileInfo) IsDir() bool       { return true }
+func (i *dirFileInfo) Mode() os.FileMode { return os.ModeDir | 0755 }
+
+func TestHandleKindDetection(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableHandleKindDetection = true
+	fs := NewWithConfig(&dirMockFS{}, config)
+
+	d, err := fs.Open("/dir")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	d.Close()
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.dirOperationsTotal.WithLabelValues("open")); v != 1 {
+		t.Errorf("Expected 1 directory open, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.dirOperationsTotal.WithLabelValues("close")); v != 1 {
+		t.Errorf("Expected 1 directory close, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.fileOpensTotal.WithLabelValues("read")); v != 0 {
+		t.Errorf("Expected no file opens, got %v", v)
+	}
+	for op, want := range map[string]float64{"open": 0, "close": 0, "opendir": 1, "closedir": 1} {
+		if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues(op, "success")); v != want {
+			t.Errorf("Expected %v %s operations, got %v", want, op, v)
+		}
+	}
+
+	// Regular files are still counted as file opens
+	fs = NewWithConfig(newMockFS(), config)
+	f, _ := fs.Open("/file.txt")
+	f.Close()
+	if v := testutil.ToFloat64(fs.collector.fileOpensTotal.WithLabelValues("read")); v != 1 {
+		t.Errorf("Expected 1 file open, got %v", v)
+	}
+}
+
+// statlessMockFS opens files whose Stat fails.
+type statlessMockFS struct {
+	mockFS
+}
+
+func (s *statlessMockFS) Open(name string) (absfs.File, error) {
+	return &statlessMockFile{mockFile{name: name}}, nil
+}
+
+type statlessMockFile struct {
+	mockFile
+}
+
+func (f *statlessMockFile) Stat() (os.FileInfo, error) {
+	return nil, os.ErrPermission
+}
+
+func TestHandleKindDetectionDeferred(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableHandleKindDetection = true
+	fs := NewWithConfig(&statlessMockFS{}, config)
+	c := fs.collector
+
+	// The open of a handle the Stat on open cannot classify is recorded
+	// once Readdir shows it is a directory
+	d, err := fs.Open("/dir")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("opendir", "success")); v != 0 {
+		t.Errorf("Expected the open to wait for the handle's kind, got %v", v)
+	}
+	d.Readdir(-1)
+	d.Close()
+
+	// and as a file open when it is closed unclassified
+	f, _ := fs.Open("/file")
+	f.Close()
+
+	for op, want := range map[string]float64{"open": 1, "close": 1, "opendir": 1, "closedir": 1} {
+		if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues(op, "success")); v != want {
+			t.Errorf("Expected %v %s operations, got %v", want, op, v)
+		}
+	}
+	if v := testutil.ToFloat64(c.dirOperationsTotal.WithLabelValues("open")); v != 1 {
+		t.Errorf("Expected 1 directory open, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.fileOpensTotal.WithLabelValues("read")); v != 1 {
+		t.Errorf("Expected 1 file open, got %v", v)
+	}
+}
+
+func TestCollectorReset(t *testing.T) {
+	config := DefaultConfig()
+	config.EnablePathMetrics = true
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, _ := fs.Create("/test.txt")
+	f.Write([]byte("hello"))
+	f.Close()
+	fs.Stat("/test.txt")
+
+	c := fs.collector
+	c.Reset()
+
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success")); v != 0 {
+		t.Errorf("Expected stat count to be reset, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.fileCreatesTotal.WithLabelValues()); v != 0 {
+		t.Errorf("Expected create count to be reset, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.bytesWrittenTotal.WithLabelValues()); v != 0 {
+		t.Errorf("Expected bytes written to be reset, got %v", v)
+	}
+	if len(c.trackedPaths) != 0 {
+		t.Errorf("Expected tracked paths to be cleared, got %d", len(c.trackedPaths))
+	}
+
+	// Metrics are recorded again after a reset
+	fs.Stat("/test.txt")
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success")); v != 1 {
+		t.Errorf("Expected 1 stat after reset, got %v", v)
+	}
+}
+
+func TestCollectorResetInFlight(t *testing.T) {
+	c := NewCollector(DefaultConfig())
+
+	start := c.startOperation(OpStat)
+	c.Reset()
+	if v := testutil.ToFloat64(c.operationsInFlight.WithLabelValues("stat")); v != 1 {
+		t.Errorf("Expected the running stat to stay in flight across Reset, got %v", v)
+	}
+	c.finishOperation(OpStat, start)
+
+	if v := testutil.ToFloat64(c.operationsInFlight.WithLabelValues("stat")); v != 0 {
+		t.Errorf("Expected no stat in flight, got %v", v)
+	}
+	if n := c.inFlightCount(); n != 0 {
+		t.Errorf("Expected no operations in flight, got %d", n)
+	}
+}
+
+func TestCollectorClose(t *testing.T) {
+	fs := New(newMockFS())
+	c := fs.Collector()
+
+	registry := prometheus.NewRegistry()
+	if err := c.Register(registry); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !c.Closed() {
+		t.Error("Expected collector to report closed")
+	}
+
+	// The collector was unregistered, so it can be registered again
+	if err := registry.Register(c); err != nil {
+		t.Errorf("Expected collector to be unregistered by Close: %v", err)
+	}
+
+	// Operations still work but are no longer recorded
+	if _, err := fs.Stat("/test.txt"); err != nil {
+		t.Errorf("Stat after Close failed: %v", err)
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success")); v != 0 {
+		t.Errorf("Expected no operations recorded after Close, got %v", v)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Second Close failed: %v", err)
+	}
+}
+
+func TestRegisterAll(t *testing.T) {
+	fss := []*MetricsFS{
+		New(newMockFS()),
+		NewWithCollector(newMockFS(), NewCollector(DefaultConfig()), "data"),
+	}
+	fss[0].Stat("/test.txt")
+	fss[1].Stat("/test.txt")
+	fss[1].Stat("/test.txt")
+
+	registry := prometheus.NewRegistry()
+	if err := RegisterAll(registry, fss...); err != nil {
+		t.Fatalf("RegisterAll failed: %v", err)
+	}
+	if got := registeredInstances(t, registry); len(got) != 2 || got["0"] != 1 || got["data"] != 2 {
+		t.Errorf("Expected operations under instances 0 and data, got %v", got)
+	}
+
+	// Failures are reported without stopping the other registrations
+	duplicate := NewWithCollector(newMockFS(), NewCollector(DefaultConfig()), "data")
+	second := New(newMockFS())
+	second.Stat("/test.txt")
+	if err := RegisterAll(registry, duplicate, second); err == nil {
+		t.Error("Expected an error for a duplicate instance")
+	} else if !strings.Contains(err.Error(), `"data"`) {
+		t.Errorf("Expected the error to name the instance, got %v", err)
+	}
+	if got := registeredInstances(t, registry); len(got) != 3 {
+		t.Errorf("Expected instance 1 to be registered, got %v", got)
+	}
+
+	// Close unregisters the wrapped registrations
+	fss[0].Collector().Close()
+	if got := registeredInstances(t, registry); got["0"] != 0 {
+		t.Errorf("Expected instance 0 to be unregistered by Close, got %v", got)
+	}
+}
+
+func TestRegisterAllSharedCollector(t *testing.T) {
+	shared := NewCollector(Config{EnableInstanceLabel: true})
+	data := NewWithCollector(newMockFS(), shared, "data")
+	cache := NewWithCollector(newMockFS(), shared, "cache")
+	data.Stat("/test.txt")
+	cache.Stat("/test.txt")
+
+	// The shared collector is registered once, under the first wrapper
+	registry := prometheus.NewRegistry()
+	if err := RegisterAll(registry, data, cache); err != nil {
+		t.Fatalf("RegisterAll failed: %v", err)
+	}
+	if got := registeredInstances(t, registry); len(got) != 1 || got["data"] != 2 {
+		t.Errorf("Expected both wrappers' operations under instance data, got %v", got)
+	}
+}
+
+// registeredInstances returns the operation counts gathered from registry,
+// summed by instance label.
+func registeredInstances(t *testing.T, registry *prometheus.Registry) map[string]int {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	instances := map[string]int{}
+	for _, family := range families {
+		if family.GetName() != "fs_operations_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "instance" {
+					instances[label.GetValue()] += int(m.GetCounter().GetValue())
+				}
+			}
+		}
+	}
+	return instances
+}
+
+func TestUntimedOperations(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableLatencyMetrics = false
+	config.EnableBandwidthMetrics = false
+	c := NewCollector(config)
+
+	if c.live.Load().timed {
+		t.Fatal("Expected a collector without latency consumers to be untimed")
+	}
+	start := c.startOperation(OpStat)
+	if !start.time.IsZero() {
+		t.Error("Expected an untimed operation to skip the clock")
+	}
+	if d := c.finishOperation(OpStat, start); d != 0 {
+		t.Errorf("Expected a zero duration, got %v", d)
+	}
+	if c.inFlight.Load() != 0 {
+		t.Errorf("Expected no operations in flight, got %d", c.inFlight.Load())
+	}
+
+	// Profiling windows time operations regardless
+	c.profile.Store(&profileWindow{})
+	if start := c.startOperation(OpStat); start.time.IsZero() {
+		t.Error("Expected operations to be timed during a profiling window")
+	}
+
+	config.OnOperation = func(Operation) {}
+	if !NewCollector(config).live.Load().timed {
+		t.Error("Expected OnOperation to require timing")
+	}
+}
+
+func TestLatencySampleRate(t *testing.T) {
+	config := DefaultConfig()
+	config.LatencySampleRate = 10
+	c := NewCollector(config)
+
+	for i := 0; i < 1000; i++ {
+		start := c.startOperation(OpRead)
+		duration := c.finishOperation(OpRead, start)
+		c.recordOperation(context.Background(), OpRead, "/data", duration, 512, nil)
+	}
+	start := c.startOperation(OpStat)
+	c.recordOperation(context.Background(), OpStat, "/data", c.finishOperation(OpStat, start), 0, nil)
+
+	// Counters and byte totals are exact, latency is observed for a sample
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("read", "success")); got != 1000 {
+		t.Errorf("Expected 1000 reads, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.bytesReadTotal); got != 512000 {
+		t.Errorf("Expected 512000 bytes read, got %v", got)
+	}
+	if got := histogramCount(t, c.readDuration); got == 0 || got > 300 {
+		t.Errorf("Expected about 100 sampled reads, got %d", got)
+	}
+	if got := histogramCount(t, c.statDuration); got != 1 {
+		t.Errorf("Expected stats to be timed regardless of sampling, got %d", got)
+	}
+}
+
+// histogramCount returns the number of observations of the unlabeled
+// histogram in vec.
+func histogramCount(t *testing.T, vec *prometheus.HistogramVec) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := vec.WithLabelValues().(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
 func TestConfigDefaults(t *testing.T) {
 	config := DefaultConfig()
 
@@ -391,6 +1114,167 @@ func TestConfigDefaults(t *testing.T) {
 	}
 }
 
+func TestConstLabelsExclude(t *testing.T) {
+	config := DefaultConfig()
+	config.ConstLabels = prometheus.Labels{"service": "api", "region": "eu"}
+	config.ConstLabelsExclude = map[string][]string{
+		"bytes_read_total": {"region"},
+	}
+	fs := NewWithConfig(newMockFS(), config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	f, _ := fs.Open("/test.txt")
+	f.Close()
+
+	expected := `
+# HELP fs_bytes_read_total Total bytes read
+# TYPE fs_bytes_read_total counter
+fs_bytes_read_total{service="api"} 0
+# HELP fs_file_opens_total File opens by mode
+# TYPE fs_file_opens_total counter
+fs_file_opens_total{mode="read",region="eu",service="api"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "fs_bytes_read_total", "fs_file_opens_total"); err != nil {
+		t.Error(err)
+	}
+	if config.ConstLabels["region"] != "eu" {
+		t.Error("Excluding labels modified ConstLabels")
+	}
+}
+
+func TestConfigMerge(t *testing.T) {
+	var calls []string
+
+	base := DefaultConfig()
+	base.ConstLabels = prometheus.Labels{"service": "lib", "env": "dev"}
+	base.OnOperation = func(op Operation) { calls = append(calls, "base") }
+
+	override := Config{
+		Namespace:         "app",
+		ConstLabels:       prometheus.Labels{"env": "prod"},
+		EnablePathMetrics: true,
+		MaxTrackedPaths:   500,
+		OnOperation:       func(op Operation) { calls = append(calls, "override") },
+	}
+
+	merged := base.Merge(override)
+
+	if merged.Namespace != "app" {
+		t.Errorf("Expected namespace 'app', got '%s'", merged.Namespace)
+	}
+	if merged.MaxTrackedPaths != 500 {
+		t.Errorf("Expected MaxTrackedPaths 500, got %d", merged.MaxTrackedPaths)
+	}
+	if merged.PathSampleRate != base.PathSampleRate {
+		t.Errorf("Expected PathSampleRate to be kept, got %v", merged.PathSampleRate)
+	}
+	if !merged.EnablePathMetrics || !merged.EnableLatencyMetrics {
+		t.Error("Expected boolean options from both configs to be enabled")
+	}
+	if merged.ConstLabels["service"] != "lib" || merged.ConstLabels["env"] != "prod" {
+		t.Errorf("Unexpected merged labels: %v", merged.ConstLabels)
+	}
+	if base.ConstLabels["env"] != "dev" {
+		t.Error("Merge modified the receiver's labels")
+	}
+
+	excluded := base.Merge(Config{ConstLabelsExclude: map[string][]string{"bytes_read_total": {"env"}}}).
+		Merge(Config{ConstLabelsExclude: map[string][]string{"bytes_written_total": {"env"}}})
+	if len(excluded.ConstLabelsExclude) != 2 {
+		t.Errorf("Expected exclusions from both configs, got %v", excluded.ConstLabelsExclude)
+	}
+
+	merged.OnOperation(Operation{})
+	if len(calls) != 2 || calls[0] != "base" || calls[1] != "override" {
+		t.Errorf("Expected callbacks to chain base then override, got %v", calls)
+	}
+}
+
+type tenantKey struct{}
+
+func TestContextLabels(t *testing.T) {
+	config := DefaultConfig()
+	config.ContextLabelNames = []string{"tenant_id"}
+	config.ContextLabels = func(ctx context.Context) prometheus.Labels {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return prometheus.Labels{"tenant_id": tenant}
+	}
+	fs := NewWithConfig(newMockFS(), config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	tenantFS := fs.WithContext(ctx)
+	tenantFS.Stat("/test.txt")
+
+	f, err := tenantFS.Open("/test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Read(make([]byte, 8))
+	f.Close()
+
+	fs.Stat("/test.txt")
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success", "acme")); v != 1 {
+		t.Errorf("Expected 1 stat for tenant acme, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("read", "success", "acme")); v != 1 {
+		t.Errorf("Expected file read to inherit tenant acme, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success", "")); v != 1 {
+		t.Errorf("Expected 1 stat without tenant, got %v", v)
+	}
+
+	if _, err := testutil.GatherAndCount(registry); err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+}
+
+func TestSharedCollectorInstances(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableInstanceLabel = true
+	collector := NewCollector(config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	cache := NewWithCollector(newMockFS(), collector, "cache")
+	origin := NewWithCollector(newMockFS(), collector, "origin")
+
+	cache.Stat("/a")
+	origin.Stat("/a")
+	origin.Stat("/b")
+
+	f, _ := cache.Create("/c")
+	f.Write([]byte("hello"))
+	f.Close()
+
+	if v := testutil.ToFloat64(collector.operationsTotal.WithLabelValues("stat", "success", "cache")); v != 1 {
+		t.Errorf("Expected 1 stat for cache, got %v", v)
+	}
+	if v := testutil.ToFloat64(collector.operationsTotal.WithLabelValues("stat", "success", "origin")); v != 2 {
+		t.Errorf("Expected 2 stats for origin, got %v", v)
+	}
+	if v := testutil.ToFloat64(collector.bytesWrittenTotal.WithLabelValues("cache")); v != 5 {
+		t.Errorf("Expected 5 bytes written for cache, got %v", v)
+	}
+
+	// The instance survives WithContext
+	cache.WithContext(context.Background()).Stat("/a")
+	if v := testutil.ToFloat64(collector.operationsTotal.WithLabelValues("stat", "success", "cache")); v != 2 {
+		t.Errorf("Expected 2 stats for cache, got %v", v)
+	}
+
+	if _, err := testutil.GatherAndCount(registry); err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+}
+
 func TestOnOperationCallback(t *testing.T) {
 	base := newMockFS()
 
@@ -824,6 +1708,408 @@ func TestPathMetrics(t *testing.T) {
 	}
 }
 
+func TestPathLatencyMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnablePathMetrics = true
+	config.EnablePathLatencyMetrics = true
+	config.MaxTrackedPaths = 1
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, _ := fs.Create("/hot.txt")
+	f.Write([]byte("hello"))
+	f.Close()
+	fs.Stat("/cold.txt")
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.pathBytesTotal.WithLabelValues("/hot.txt", "write")); v != 5 {
+		t.Errorf("Expected 5 bytes written to /hot.txt, got %v", v)
+	}
+	if n := testutil.CollectAndCount(c.pathDuration); n != 3 {
+		t.Errorf("Expected create, write and close histograms for /hot.txt, got %d series", n)
+	}
+
+	// /cold.txt is beyond MaxTrackedPaths
+	if v := testutil.ToFloat64(c.pathAccessTotal.WithLabelValues("/cold.txt", "stat")); v != 0 {
+		t.Errorf("Expected untracked path not to be recorded, got %v", v)
+	}
+}
+
+func TestGroupedPathMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnablePathMetrics = true
+	config.EnablePathLatencyMetrics = true
+	config.GroupPathMetrics = true
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.Stat("/staging/a.txt")
+	fs.Stat("/staging/b/c.txt")
+	fs.Stat("/prod/d.txt")
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.pathAccessTotal.WithLabelValues("/staging", "stat")); v != 2 {
+		t.Errorf("Expected 2 stats in staging, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.pathAccessTotal.WithLabelValues("/prod", "stat")); v != 1 {
+		t.Errorf("Expected 1 stat in prod, got %v", v)
+	}
+	if n := testutil.CollectAndCount(c.pathDuration); n != 2 {
+		t.Errorf("Expected 2 grouped latency series, got %d", n)
+	}
+	if paths, _ := c.trackedState(); paths != 2 {
+		t.Errorf("Expected 2 tracked groups, got %d", paths)
+	}
+}
+
+func TestHistogramOverflow(t *testing.T) {
+	type overflow struct {
+		metric string
+		value  float64
+	}
+	var overflows []overflow
+
+	config := DefaultConfig()
+	config.SizeBuckets = []float64{1, 4}
+	config.OnHistogramOverflow = func(metric string, value float64) {
+		overflows = append(overflows, overflow{metric, value})
+	}
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, _ := fs.Create("/test.txt")
+	f.Write([]byte("abc"))
+	f.Write([]byte("hello"))
+	f.Close()
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.histogramOverflowTotal.WithLabelValues("write_size_bytes")); v != 1 {
+		t.Errorf("Expected 1 write size overflow, got %v", v)
+	}
+	if len(overflows) != 1 || overflows[0] != (overflow{"write_size_bytes", 5}) {
+		t.Errorf("Expected one overflow hook call for 5 bytes, got %+v", overflows)
+	}
+
+	// Latency stays within the default buckets
+	if v := testutil.ToFloat64(c.histogramOverflowTotal.WithLabelValues("operation_duration_seconds")); v != 0 {
+		t.Errorf("Expected no latency overflow, got %v", v)
+	}
+}
+
+func TestDurationAnomalies(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxOperationDuration = time.Minute
+	c := NewCollector(config)
+
+	if d := c.sanitizeDuration(OpRead, -time.Second); d != 0 {
+		t.Errorf("Expected negative duration to be clamped to 0, got %v", d)
+	}
+	if d := c.sanitizeDuration(OpRead, 2*time.Hour); d != time.Minute {
+		t.Errorf("Expected huge duration to be clamped to the cap, got %v", d)
+	}
+	if d := c.sanitizeDuration(OpRead, time.Second); d != time.Second {
+		t.Errorf("Expected plausible duration to be unchanged, got %v", d)
+	}
+
+	if v := testutil.ToFloat64(c.durationAnomaliesTotal.WithLabelValues("read", "negative")); v != 1 {
+		t.Errorf("Expected 1 negative anomaly, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.durationAnomaliesTotal.WithLabelValues("read", "too_large")); v != 1 {
+		t.Errorf("Expected 1 too_large anomaly, got %v", v)
+	}
+
+	// Without a cap only negative durations are anomalies
+	uncapped := NewCollector(Config{})
+	if d := uncapped.sanitizeDuration(OpRead, 2*time.Hour); d != 2*time.Hour {
+		t.Errorf("Expected no cap with a zero MaxOperationDuration, got %v", d)
+	}
+}
+
+func TestLargestBucket(t *testing.T) {
+	if got := largestBucket([]float64{0.1, 1, 10}); got != 10 {
+		t.Errorf("Expected 10, got %v", got)
+	}
+	if got := largestBucket(nil); got != prometheus.DefBuckets[len(prometheus.DefBuckets)-1] {
+		t.Errorf("Expected largest default bucket, got %v", got)
+	}
+}
+
+func TestAccessPatternMetrics(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	config := DefaultConfig()
+	config.EnableAccessPatternMetrics = true
+	fs := NewWithConfig(base, config)
+
+	f, err := fs.Create(t.TempDir() + "/data.bin")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer f.Close()
+
+	block := make([]byte, 4096)
+	for i := 0; i < 3; i++ {
+		f.Write(block)
+	}
+
+	// Rewind and read: the first read jumps back 12KiB, the second follows on
+	f.Seek(0, io.SeekStart)
+	f.Read(block)
+	f.Read(block)
+
+	// Skip ahead of the previous read
+	f.ReadAt(block[:100], 10000)
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.accessPatternTotal.WithLabelValues("write", "sequential")); v != 3 {
+		t.Errorf("Expected 3 sequential writes, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.accessPatternTotal.WithLabelValues("read", "sequential")); v != 1 {
+		t.Errorf("Expected 1 sequential read, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.accessPatternTotal.WithLabelValues("read", "random")); v != 2 {
+		t.Errorf("Expected 2 random reads, got %v", v)
+	}
+
+	expected := `
+# HELP fs_io_seek_distance_bytes Distance of random reads and writes from the end of the previous access
+# TYPE fs_io_seek_distance_bytes histogram
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="512"} 0
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="4096"} 0
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="32768"} 1
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="262144"} 1
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="2.097152e+06"} 1
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="1.6777216e+07"} 1
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="1.34217728e+08"} 1
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="1.073741824e+09"} 1
+fs_io_seek_distance_bytes_bucket{direction="backward",operation="read",le="+Inf"} 1
+fs_io_seek_distance_bytes_sum{direction="backward",operation="read"} 12288
+fs_io_seek_distance_bytes_count{direction="backward",operation="read"} 1
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="512"} 0
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="4096"} 1
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="32768"} 1
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="262144"} 1
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="2.097152e+06"} 1
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="1.6777216e+07"} 1
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="1.34217728e+08"} 1
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="1.073741824e+09"} 1
+fs_io_seek_distance_bytes_bucket{direction="forward",operation="read",le="+Inf"} 1
+fs_io_seek_distance_bytes_sum{direction="forward",operation="read"} 1808
+fs_io_seek_distance_bytes_count{direction="forward",operation="read"} 1
+`
+	if err := testutil.CollectAndCompare(c.seekDistanceBytes, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReaddirEntryMetrics(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	fs := New(base)
+
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		f, err := fs.Create(fmt.Sprintf("%s/file%d", dir, i))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		f.Close()
+	}
+
+	d, err := fs.Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	// Page through the listing: 2, 2, 1, then io.EOF
+	for {
+		if _, err := d.Readdirnames(2); err != nil {
+			break
+		}
+	}
+
+	if _, err := fs.ReadDir(dir); err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.readdirEntriesTotal.WithLabelValues()); v != 10 {
+		t.Errorf("Expected 10 entries listed, got %v", v)
+	}
+
+	expected := `
+# HELP fs_readdir_entries Distribution of the number of entries returned by directory reads
+# TYPE fs_readdir_entries histogram
+fs_readdir_entries_bucket{le="1"} 1
+fs_readdir_entries_bucket{le="4"} 3
+fs_readdir_entries_bucket{le="16"} 4
+fs_readdir_entries_bucket{le="64"} 4
+fs_readdir_entries_bucket{le="256"} 4
+fs_readdir_entries_bucket{le="1024"} 4
+fs_readdir_entries_bucket{le="4096"} 4
+fs_readdir_entries_bucket{le="16384"} 4
+fs_readdir_entries_bucket{le="65536"} 4
+fs_readdir_entries_bucket{le="262144"} 4
+fs_readdir_entries_bucket{le="+Inf"} 4
+fs_readdir_entries_sum 10
+fs_readdir_entries_count 4
+`
+	if err := testutil.CollectAndCompare(c.readdirEntries, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// Names are 5 bytes each ("file0" to "file4")
+	expected = `
+# HELP fs_readdir_name_bytes Total length of the names returned by each directory read, approximating its memory cost
+# TYPE fs_readdir_name_bytes histogram
+fs_readdir_name_bytes_bucket{le="256"} 4
+fs_readdir_name_bytes_bucket{le="1024"} 4
+fs_readdir_name_bytes_bucket{le="4096"} 4
+fs_readdir_name_bytes_bucket{le="16384"} 4
+fs_readdir_name_bytes_bucket{le="65536"} 4
+fs_readdir_name_bytes_bucket{le="262144"} 4
+fs_readdir_name_bytes_bucket{le="1048576"} 4
+fs_readdir_name_bytes_bucket{le="4194304"} 4
+fs_readdir_name_bytes_bucket{le="16777216"} 4
+fs_readdir_name_bytes_bucket{le="67108864"} 4
+fs_readdir_name_bytes_bucket{le="+Inf"} 4
+fs_readdir_name_bytes_sum 50
+fs_readdir_name_bytes_count 4
+`
+	if err := testutil.CollectAndCompare(c.readdirNameBytes, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAccessPatternAppend(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	config := DefaultConfig()
+	config.EnableAccessPatternMetrics = true
+	fs := NewWithConfig(base, config)
+
+	f, err := fs.OpenFile(t.TempDir()+"/log.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer f.Close()
+
+	f.Seek(0, io.SeekStart)
+	f.Write([]byte("one\n"))
+	f.Write([]byte("two\n"))
+
+	if v := testutil.ToFloat64(fs.collector.accessPatternTotal.WithLabelValues("write", "sequential")); v != 2 {
+		t.Errorf("Expected appends to be sequential, got %v", v)
+	}
+}
+
+func TestExtensionMetrics(t *testing.T) {
+	base := newMockFS()
+	config := DefaultConfig()
+	config.EnableExtensionMetrics = true
+	config.MaxTrackedExtensions = 2
+	fs := NewWithConfig(base, config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	fs.Stat("/photo.JPG")
+	fs.Stat("/data.parquet")
+	fs.Stat("/notes.txt")
+	fs.Stat("/Makefile")
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.extensionOperationsTotal.WithLabelValues(".jpg", "stat")); v != 1 {
+		t.Errorf("Expected 1 stat for .jpg, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.extensionOperationsTotal.WithLabelValues(".parquet", "stat")); v != 1 {
+		t.Errorf("Expected 1 stat for .parquet, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.extensionOperationsTotal.WithLabelValues("other", "stat")); v != 1 {
+		t.Errorf("Expected 1 stat for other, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.extensionOperationsTotal.WithLabelValues("none", "stat")); v != 1 {
+		t.Errorf("Expected 1 stat for none, got %v", v)
+	}
+
+	if _, err := testutil.GatherAndCount(registry); err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+}
+
+func TestIdleTrackedStateCleanup(t *testing.T) {
+	config := DefaultConfig()
+	config.EnablePathMetrics = true
+	config.MaxTrackedPaths = 2
+	config.TrackedStateTTL = time.Minute
+	config.CleanupInterval = time.Hour
+	fs := NewWithConfig(newMockFS(), config)
+	defer fs.collector.Close()
+
+	fs.Stat("/a")
+	fs.Stat("/b")
+
+	c := fs.collector
+	if paths, bytes := c.trackedState(); paths != 2 || bytes == 0 {
+		t.Errorf("Expected 2 tracked paths with non-zero size, got %d paths, %d bytes", paths, bytes)
+	}
+
+	// Nothing is idle yet
+	if n := c.cleanupIdleState(time.Now()); n != 0 {
+		t.Errorf("Expected no evictions, got %d", n)
+	}
+
+	// Both paths are idle an hour from now
+	if n := c.cleanupIdleState(time.Now().Add(time.Hour)); n != 2 {
+		t.Errorf("Expected 2 evictions, got %d", n)
+	}
+	if v := testutil.CollectAndCount(c.pathAccessTotal); v != 0 {
+		t.Errorf("Expected path series to be dropped, got %d", v)
+	}
+
+	// Evicted slots can be reused by new paths
+	fs.Stat("/c")
+	if v := testutil.ToFloat64(c.pathAccessTotal.WithLabelValues("/c", "stat")); v != 1 {
+		t.Errorf("Expected /c to be tracked after eviction, got %v", v)
+	}
+}
+
+func TestRenamePathGroups(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableRenameMetrics = true
+	config.MaxPathGroups = 2
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.Rename("/staging/a.txt", "/published/a.txt")
+	fs.Rename("/staging/b.txt", "/archive/b.txt")
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.renamesTotal.WithLabelValues("/staging", "/published", "success")); v != 1 {
+		t.Errorf("Expected 1 staging -> published rename, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.renamesTotal.WithLabelValues("/staging", "other", "success")); v != 1 {
+		t.Errorf("Expected 1 staging -> other rename, got %v", v)
+	}
+}
+
+func TestDefaultPathGroup(t *testing.T) {
+	tests := map[string]string{
+		"/staging/a/b.txt": "/staging",
+		"/file.txt":        "/file.txt",
+		"/":                "/",
+		"rel/dir/file":     "rel",
+	}
+	for path, want := range tests {
+		if got := DefaultPathGroup(path); got != want {
+			t.Errorf("DefaultPathGroup(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
 func TestChdir(t *testing.T) {
 	base := newMockFS()
 	fs := New(base)
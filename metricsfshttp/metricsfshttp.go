@@ -0,0 +1,99 @@
+// Package metricsfshttp integrates metricsfs with HTTP servers and routers.
+//
+// Middleware gives each request a view of a MetricsFS bound to the request's
+// context, so that Config.ContextLabels and trace exemplars apply to the
+// filesystem operations a handler performs. FileServer serves files through
+// the MetricsFS, and Route labels their operations with the route of the
+// request. Mount serves the stats and health endpoints. They use only
+// net/http types: Middleware and Route have the func(http.Handler)
+// http.Handler shape used by chi and gorilla/mux, and Gin and Echo accept it
+// through their standard library adapters, so the package adds no router
+// dependencies.
+package metricsfshttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/absfs/metricsfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fsKey is the context key holding the request's MetricsFS.
+type fsKey struct{}
+
+// Middleware returns middleware that binds fs to each request's context.
+// Handlers retrieve the bound filesystem with FS.
+func Middleware(fs *metricsfs.MetricsFS) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, fsKey{}, fs.WithContext(ctx))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// FS returns the MetricsFS bound to ctx by Middleware, or nil if there is
+// none.
+func FS(ctx context.Context) *metricsfs.MetricsFS {
+	fs, _ := ctx.Value(fsKey{}).(*metricsfs.MetricsFS)
+	return fs
+}
+
+// Router is the subset of http.ServeMux, chi.Router and similar routers used
+// by Mount.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// Mount registers the stats endpoint at prefix+"/stats" and the health
+// endpoint at prefix+"/health" on r.
+func Mount(r Router, prefix string, fs *metricsfs.MetricsFS) {
+	r.Handle(prefix+"/stats", fs.StatsHandler())
+	r.Handle(prefix+"/health", fs.HealthHandler())
+}
+
+// FileServer returns an http.FileServer serving the working directory of fs
+// through metricsfs.HTTPFileSystem, whose operations are recorded with the
+// context of each request, so that Config.ContextLabels, such as
+// RouteLabels, apply to them.
+func FileServer(fs *metricsfs.MetricsFS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(metricsfs.HTTPFileSystem(fs.WithContext(r.Context()))).ServeHTTP(w, r)
+	})
+}
+
+// RouteLabel is the label given the route of each request by RouteLabels.
+const RouteLabel = "route"
+
+// routeKey is the context key holding the request's route.
+type routeKey struct{}
+
+// Route returns middleware that records route, the pattern the handler is
+// registered with (e.g. "/files/"), as the route of each request. Use it
+// with RouteLabels to label the filesystem operations of the request.
+func Route(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)))
+		})
+	}
+}
+
+// RouteFromContext returns the route recorded in ctx by Route, or "" if
+// there is none.
+func RouteFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// RouteLabels is a Config.ContextLabels function labeling operations with
+// the route of their request, given Config.ContextLabelNames including
+// RouteLabel:
+//
+//	config.ContextLabelNames = []string{metricsfshttp.RouteLabel}
+//	config.ContextLabels = metricsfshttp.RouteLabels
+func RouteLabels(ctx context.Context) prometheus.Labels {
+	return prometheus.Labels{RouteLabel: RouteFromContext(ctx)}
+}
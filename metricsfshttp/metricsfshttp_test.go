@@ -0,0 +1,136 @@
+package metricsfshttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/absfs/metricsfs"
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type tenantKey struct{}
+
+func TestMiddleware(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+
+	var tenants []string
+	config := metricsfs.DefaultConfig()
+	config.ContextLabelNames = []string{"tenant"}
+	config.ContextLabels = func(ctx context.Context) prometheus.Labels {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		tenants = append(tenants, tenant)
+		return prometheus.Labels{"tenant": tenant}
+	}
+	fs := metricsfs.NewWithConfig(base, config)
+
+	dir := t.TempDir()
+	handler := Middleware(fs)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rfs := FS(r.Context())
+		if rfs == nil {
+			t.Fatal("Expected a MetricsFS in the request context")
+		}
+		rfs.Stat(dir)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), tenantKey{}, "acme"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(tenants) != 1 || tenants[0] != "acme" {
+		t.Errorf("Expected the stat to be recorded for tenant acme, got %v", tenants)
+	}
+	if FS(context.Background()) != nil {
+		t.Error("Expected nil outside the middleware")
+	}
+}
+
+func TestMount(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	fs := metricsfs.New(base)
+
+	mux := http.NewServeMux()
+	Mount(mux, "/debug/fs", fs)
+
+	for path, want := range map[string]string{
+		"/debug/fs/stats":  `"schema_version"`,
+		"/debug/fs/health": "ok",
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("GET %s: got %d %q, want 200 containing %q", path, rec.Code, rec.Body.String(), want)
+		}
+	}
+}
+
+func TestFileServerRoute(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	if err := base.Chdir(dir); err != nil {
+		t.Fatalf("failed to change to the test directory: %v", err)
+	}
+
+	config := metricsfs.DefaultConfig()
+	config.ContextLabelNames = []string{RouteLabel}
+	config.ContextLabels = RouteLabels
+	fs := metricsfs.NewWithConfig(base, config)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	mux := http.NewServeMux()
+	mux.Handle("/files/", Route("/files/")(http.StripPrefix("/files", FileServer(fs))))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/a.txt", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Fatalf("GET /files/a.txt: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	routed := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "fs_operations_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels[RouteLabel] == "/files/" {
+				routed[labels["operation"]] += m.GetCounter().GetValue()
+			}
+		}
+	}
+	for _, op := range []string{"open", "read", "close"} {
+		if routed[op] == 0 {
+			t.Errorf("Expected %s operations labeled with the route, got %v", op, routed)
+		}
+	}
+
+	if got := RouteFromContext(context.Background()); got != "" {
+		t.Errorf("RouteFromContext() = %q outside of Route, want \"\"", got)
+	}
+}
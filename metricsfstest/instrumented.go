@@ -0,0 +1,148 @@
+package metricsfstest
+
+import (
+	"testing"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/metricsfs"
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// InstrumentedFS is a MetricsFS registered with its own Prometheus registry,
+// for integration tests that assert on the metrics their operations produce.
+type InstrumentedFS struct {
+	*metricsfs.MetricsFS
+
+	t        testing.TB
+	config   metricsfs.Config
+	registry *prometheus.Registry
+}
+
+// NewInstrumentedFS wraps base with a MetricsFS configured by config
+// (default: metricsfs.DefaultConfig) and registers its collector with a new
+// registry. The collector is closed when the test ends.
+func NewInstrumentedFS(t testing.TB, base absfs.FileSystem, config ...metricsfs.Config) *InstrumentedFS {
+	t.Helper()
+
+	cfg := metricsfs.DefaultConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	m := metricsfs.NewWithConfig(base, cfg)
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(m.Collector()); err != nil {
+		t.Fatalf("failed to register the collector: %v", err)
+	}
+	t.Cleanup(func() { m.Collector().Close() })
+
+	return &InstrumentedFS{MetricsFS: m, t: t, config: cfg, registry: registry}
+}
+
+// NewInstrumentedTempFS is NewInstrumentedFS over the OS filesystem, with
+// its working directory set to a new t.TempDir so that relative paths stay
+// within the test.
+func NewInstrumentedTempFS(t testing.TB, config ...metricsfs.Config) *InstrumentedFS {
+	t.Helper()
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	if err := base.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("failed to change to the test directory: %v", err)
+	}
+	return NewInstrumentedFS(t, base, config...)
+}
+
+// Registry returns the registry the collector is registered with.
+func (i *InstrumentedFS) Registry() *prometheus.Registry {
+	return i.registry
+}
+
+// Gather returns the metric families currently in the registry, failing the
+// test if they cannot be gathered.
+func (i *InstrumentedFS) Gather() []*dto.MetricFamily {
+	i.t.Helper()
+
+	families, err := i.registry.Gather()
+	if err != nil {
+		i.t.Fatalf("failed to gather metrics: %v", err)
+	}
+	return families
+}
+
+// Value returns the sum of the metrics named name whose labels include
+// labels, given as name and value pairs. Counters, gauges and untyped
+// metrics contribute their value, histograms and summaries their sample
+// count. The name includes the namespace, as in "fs_operations_total".
+func (i *InstrumentedFS) Value(name string, labels ...string) float64 {
+	i.t.Helper()
+
+	if len(labels)%2 != 0 {
+		i.t.Fatalf("labels of %s must be name and value pairs, got %q", name, labels)
+	}
+
+	var sum float64
+	for _, family := range i.Gather() {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if !hasLabels(m, labels) {
+				continue
+			}
+			switch {
+			case m.Counter != nil:
+				sum += m.Counter.GetValue()
+			case m.Gauge != nil:
+				sum += m.Gauge.GetValue()
+			case m.Untyped != nil:
+				sum += m.Untyped.GetValue()
+			case m.Histogram != nil:
+				sum += float64(m.Histogram.GetSampleCount())
+			case m.Summary != nil:
+				sum += float64(m.Summary.GetSampleCount())
+			}
+		}
+	}
+	return sum
+}
+
+// Operations returns the number of op operations recorded, successful or
+// not.
+func (i *InstrumentedFS) Operations(op metricsfs.Op) float64 {
+	i.t.Helper()
+	return i.Value(i.name("operations_total"), "operation", string(op))
+}
+
+// Errors returns the number of failed op operations recorded.
+func (i *InstrumentedFS) Errors(op metricsfs.Op) float64 {
+	i.t.Helper()
+	return i.Value(i.name("operations_total"), "operation", string(op), "status", "error")
+}
+
+// name returns the full name of the metric name under the collector's
+// namespace and subsystem.
+func (i *InstrumentedFS) name(name string) string {
+	return prometheus.BuildFQName(i.config.Namespace, i.config.Subsystem, name)
+}
+
+// hasLabels reports whether m has every label in labels.
+func hasLabels(m *dto.Metric, labels []string) bool {
+	for j := 0; j < len(labels); j += 2 {
+		found := false
+		for _, label := range m.GetLabel() {
+			if label.GetName() == labels[j] && label.GetValue() == labels[j+1] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,405 @@
+// Package metricsfstest verifies that metrics wrappers instrument every
+// operation they forward to the underlying filesystem.
+//
+// It runs the absfs/fstesting wrapper suite against a wrapper built on top of
+// a Recorder, which counts the calls that reach the base filesystem, and then
+// reports each operation that was executed without a corresponding metric
+// increment.
+package metricsfstest
+
+import (
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/fstesting"
+	"github.com/absfs/metricsfs"
+)
+
+// Suite runs the fstesting wrapper suite against a metrics wrapper and checks
+// that every operation reaching the base filesystem was recorded.
+type Suite struct {
+	// BaseFS is the underlying filesystem. Required.
+	BaseFS absfs.FileSystem
+
+	// Wrap creates the metrics wrapper under test around fs
+	// (default: metricsfs.New)
+	Wrap func(fs absfs.FileSystem) absfs.FileSystem
+
+	// Recorded returns the number of operations the wrapper recorded, by
+	// operation name. It is required unless Wrap returns a
+	// *metricsfs.MetricsFS, whose Stats are used.
+	Recorded func(wrapper absfs.FileSystem) map[string]int64
+}
+
+// Run executes the fstesting wrapper suite and reports every operation that
+// reached BaseFS without being recorded by the wrapper.
+func (s *Suite) Run(t *testing.T) {
+	t.Helper()
+
+	if s.BaseFS == nil {
+		t.Fatal("Suite requires BaseFS to be set")
+	}
+
+	wrap := s.Wrap
+	if wrap == nil {
+		wrap = func(fs absfs.FileSystem) absfs.FileSystem { return metricsfs.New(fs) }
+	}
+
+	var recorder *Recorder
+	var wrapper absfs.FileSystem
+
+	// Registered before the suite runs so that it runs after the suite's own
+	// cleanup, which removes the test directory through the wrapper
+	t.Cleanup(func() {
+		if recorder == nil {
+			return
+		}
+
+		recorded := s.Recorded
+		if recorded == nil {
+			m, ok := wrapper.(*metricsfs.MetricsFS)
+			if !ok {
+				t.Errorf("Suite requires Recorded for wrapper type %T", wrapper)
+				return
+			}
+			recorded = statsRecorded(m)
+		}
+
+		AssertInstrumented(t, recorder, recorded(wrapper))
+	})
+
+	suite := &fstesting.WrapperSuite{
+		Factory: func(base absfs.FileSystem) (absfs.FileSystem, error) {
+			recorder = NewRecorder(base)
+			wrapper = wrap(recorder.FS())
+			return wrapper, nil
+		},
+		BaseFS:         s.BaseFS,
+		Name:           "metricsfstest",
+		TransformsData: false,
+		TransformsMeta: false,
+		ReadOnly:       false,
+	}
+
+	suite.Run(t)
+}
+
+// statsRecorded returns a Recorded function that reads m's Stats.
+func statsRecorded(m *metricsfs.MetricsFS) func(absfs.FileSystem) map[string]int64 {
+	return func(absfs.FileSystem) map[string]int64 {
+		counts := make(map[string]int64)
+		for name, op := range m.Collector().Stats().Operations {
+			counts[name] = op.Count
+		}
+		return counts
+	}
+}
+
+// AssertInstrumented reports an error for each operation that reached the
+// recorder's base filesystem without a corresponding count in recorded.
+func AssertInstrumented(t testing.TB, recorder *Recorder, recorded map[string]int64) {
+	t.Helper()
+
+	calls := recorder.Calls()
+	for _, op := range Uninstrumented(calls, recorded) {
+		t.Errorf("uninstrumented operation %q: %d call(s) reached the base filesystem, none were recorded", op, calls[op])
+	}
+}
+
+// aliases lists the operation names a wrapper may record for a base call in
+// addition to the call's own operation.
+var aliases = map[metricsfs.Op][]metricsfs.Op{
+	// MetricsFS records OpenFile as an open, and the calls WriteFile makes
+	// on filesystems without a WriteFile as a single writefile
+	metricsfs.OpOpenFile: {metricsfs.OpOpen, metricsfs.OpWriteFile},
+	metricsfs.OpWrite:    {metricsfs.OpWriteFile},
+	metricsfs.OpClose:    {metricsfs.OpWriteFile},
+}
+
+// Uninstrumented returns the operations with calls that have no recorded
+// count, sorted by name.
+func Uninstrumented(calls map[metricsfs.Op]int64, recorded map[string]int64) []metricsfs.Op {
+	var missing []metricsfs.Op
+	for op, n := range calls {
+		if n == 0 || recorded[string(op)] > 0 {
+			continue
+		}
+
+		found := false
+		for _, alias := range aliases[op] {
+			if recorded[string(alias)] > 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, op)
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	return missing
+}
+
+// Recorder wraps an absfs.FileSystem and counts the calls that reach it, and
+// the files it opens, by operation.
+type Recorder struct {
+	fs    absfs.FileSystem
+	mu    sync.Mutex
+	calls map[metricsfs.Op]int64
+}
+
+// NewRecorder creates a Recorder around fs.
+func NewRecorder(fs absfs.FileSystem) *Recorder {
+	return &Recorder{
+		fs:    fs,
+		calls: make(map[metricsfs.Op]int64),
+	}
+}
+
+// FS returns the filesystem to wrap with the wrapper under test. It supports
+// symbolic links only if the base filesystem does, so that wrappers take the
+// same code paths as they would on the base filesystem.
+func (r *Recorder) FS() absfs.FileSystem {
+	if _, ok := r.fs.(absfs.SymLinker); ok {
+		return &symlinkRecorderFS{recorderFS{r}}
+	}
+	return &recorderFS{r}
+}
+
+// Calls returns the number of calls per operation so far.
+func (r *Recorder) Calls() map[metricsfs.Op]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := make(map[metricsfs.Op]int64, len(r.calls))
+	for op, n := range r.calls {
+		calls[op] = n
+	}
+	return calls
+}
+
+// record counts a call to op.
+func (r *Recorder) record(op metricsfs.Op) {
+	r.mu.Lock()
+	r.calls[op]++
+	r.mu.Unlock()
+}
+
+// wrap wraps f so that its calls are counted, or returns nil for a nil f.
+func (r *Recorder) wrap(f absfs.File) absfs.File {
+	if f == nil {
+		return nil
+	}
+	return &recorderFile{file: f, recorder: r}
+}
+
+// recorderFS is the absfs.FileSystem returned by Recorder.FS.
+type recorderFS struct {
+	r *Recorder
+}
+
+func (rfs *recorderFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	rfs.r.record(metricsfs.OpOpenFile)
+	f, err := rfs.r.fs.OpenFile(name, flag, perm)
+	return rfs.r.wrap(f), err
+}
+
+func (rfs *recorderFS) Mkdir(name string, perm os.FileMode) error {
+	rfs.r.record(metricsfs.OpMkdir)
+	return rfs.r.fs.Mkdir(name, perm)
+}
+
+func (rfs *recorderFS) Remove(name string) error {
+	rfs.r.record(metricsfs.OpRemove)
+	return rfs.r.fs.Remove(name)
+}
+
+func (rfs *recorderFS) Rename(oldpath, newpath string) error {
+	rfs.r.record(metricsfs.OpRename)
+	return rfs.r.fs.Rename(oldpath, newpath)
+}
+
+func (rfs *recorderFS) Stat(name string) (os.FileInfo, error) {
+	rfs.r.record(metricsfs.OpStat)
+	return rfs.r.fs.Stat(name)
+}
+
+func (rfs *recorderFS) Chmod(name string, mode os.FileMode) error {
+	rfs.r.record(metricsfs.OpChmod)
+	return rfs.r.fs.Chmod(name, mode)
+}
+
+func (rfs *recorderFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	rfs.r.record(metricsfs.OpChtimes)
+	return rfs.r.fs.Chtimes(name, atime, mtime)
+}
+
+func (rfs *recorderFS) Chown(name string, uid, gid int) error {
+	rfs.r.record(metricsfs.OpChown)
+	return rfs.r.fs.Chown(name, uid, gid)
+}
+
+func (rfs *recorderFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	rfs.r.record(metricsfs.OpReaddir)
+	return rfs.r.fs.ReadDir(name)
+}
+
+func (rfs *recorderFS) ReadFile(name string) ([]byte, error) {
+	rfs.r.record(metricsfs.OpReadFile)
+	return rfs.r.fs.ReadFile(name)
+}
+
+func (rfs *recorderFS) Sub(dir string) (fs.FS, error) {
+	rfs.r.record(metricsfs.OpSub)
+	return rfs.r.fs.Sub(dir)
+}
+
+func (rfs *recorderFS) Chdir(dir string) error {
+	rfs.r.record(metricsfs.OpChdir)
+	return rfs.r.fs.Chdir(dir)
+}
+
+func (rfs *recorderFS) Getwd() (string, error) {
+	rfs.r.record(metricsfs.OpGetwd)
+	return rfs.r.fs.Getwd()
+}
+
+// TempDir is not an operation and is not counted.
+func (rfs *recorderFS) TempDir() string {
+	return rfs.r.fs.TempDir()
+}
+
+func (rfs *recorderFS) Open(name string) (absfs.File, error) {
+	rfs.r.record(metricsfs.OpOpen)
+	f, err := rfs.r.fs.Open(name)
+	return rfs.r.wrap(f), err
+}
+
+func (rfs *recorderFS) Create(name string) (absfs.File, error) {
+	rfs.r.record(metricsfs.OpCreate)
+	f, err := rfs.r.fs.Create(name)
+	return rfs.r.wrap(f), err
+}
+
+func (rfs *recorderFS) MkdirAll(name string, perm os.FileMode) error {
+	rfs.r.record(metricsfs.OpMkdirAll)
+	return rfs.r.fs.MkdirAll(name, perm)
+}
+
+func (rfs *recorderFS) RemoveAll(path string) error {
+	rfs.r.record(metricsfs.OpRemoveAll)
+	return rfs.r.fs.RemoveAll(path)
+}
+
+func (rfs *recorderFS) Truncate(name string, size int64) error {
+	rfs.r.record(metricsfs.OpTruncate)
+	return rfs.r.fs.Truncate(name, size)
+}
+
+// symlinkRecorderFS is returned by Recorder.FS for base filesystems that
+// support symbolic links.
+type symlinkRecorderFS struct {
+	recorderFS
+}
+
+func (rfs *symlinkRecorderFS) Lstat(name string) (os.FileInfo, error) {
+	rfs.r.record(metricsfs.OpLstat)
+	return rfs.r.fs.(absfs.SymLinker).Lstat(name)
+}
+
+func (rfs *symlinkRecorderFS) Lchown(name string, uid, gid int) error {
+	rfs.r.record(metricsfs.OpLchown)
+	return rfs.r.fs.(absfs.SymLinker).Lchown(name, uid, gid)
+}
+
+func (rfs *symlinkRecorderFS) Readlink(name string) (string, error) {
+	rfs.r.record(metricsfs.OpReadlink)
+	return rfs.r.fs.(absfs.SymLinker).Readlink(name)
+}
+
+func (rfs *symlinkRecorderFS) Symlink(oldname, newname string) error {
+	rfs.r.record(metricsfs.OpSymlink)
+	return rfs.r.fs.(absfs.SymLinker).Symlink(oldname, newname)
+}
+
+// recorderFile counts the calls that reach an opened file.
+type recorderFile struct {
+	file     absfs.File
+	recorder *Recorder
+}
+
+// Name is not an operation and is not counted.
+func (f *recorderFile) Name() string {
+	return f.file.Name()
+}
+
+func (f *recorderFile) Read(p []byte) (int, error) {
+	f.recorder.record(metricsfs.OpRead)
+	return f.file.Read(p)
+}
+
+func (f *recorderFile) ReadAt(p []byte, off int64) (int, error) {
+	f.recorder.record(metricsfs.OpRead)
+	return f.file.ReadAt(p, off)
+}
+
+func (f *recorderFile) Write(p []byte) (int, error) {
+	f.recorder.record(metricsfs.OpWrite)
+	return f.file.Write(p)
+}
+
+func (f *recorderFile) WriteAt(p []byte, off int64) (int, error) {
+	f.recorder.record(metricsfs.OpWrite)
+	return f.file.WriteAt(p, off)
+}
+
+func (f *recorderFile) WriteString(s string) (int, error) {
+	f.recorder.record(metricsfs.OpWrite)
+	return f.file.WriteString(s)
+}
+
+func (f *recorderFile) Seek(offset int64, whence int) (int64, error) {
+	f.recorder.record(metricsfs.OpSeek)
+	return f.file.Seek(offset, whence)
+}
+
+func (f *recorderFile) Close() error {
+	f.recorder.record(metricsfs.OpClose)
+	return f.file.Close()
+}
+
+func (f *recorderFile) Sync() error {
+	f.recorder.record(metricsfs.OpSync)
+	return f.file.Sync()
+}
+
+func (f *recorderFile) Stat() (os.FileInfo, error) {
+	f.recorder.record(metricsfs.OpStat)
+	return f.file.Stat()
+}
+
+func (f *recorderFile) Truncate(size int64) error {
+	f.recorder.record(metricsfs.OpTruncate)
+	return f.file.Truncate(size)
+}
+
+func (f *recorderFile) Readdir(n int) ([]os.FileInfo, error) {
+	f.recorder.record(metricsfs.OpReaddir)
+	return f.file.Readdir(n)
+}
+
+func (f *recorderFile) Readdirnames(n int) ([]string, error) {
+	f.recorder.record(metricsfs.OpReaddir)
+	return f.file.Readdirnames(n)
+}
+
+func (f *recorderFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.recorder.record(metricsfs.OpReaddir)
+	return f.file.ReadDir(n)
+}
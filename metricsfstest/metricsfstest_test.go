@@ -0,0 +1,136 @@
+package metricsfstest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/metricsfs"
+	"github.com/absfs/osfs"
+)
+
+func TestSuiteMetricsFS(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+
+	suite := &Suite{BaseFS: base}
+	suite.Run(t)
+}
+
+func TestSuiteMetricsFSWithConfig(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+
+	config := metricsfs.DefaultConfig()
+	config.EnableHandleKindDetection = true
+	config.EnablePathMetrics = true
+
+	suite := &Suite{
+		BaseFS: base,
+		Wrap: func(fs absfs.FileSystem) absfs.FileSystem {
+			return metricsfs.NewWithConfig(fs, config)
+		},
+	}
+	suite.Run(t)
+}
+
+// bypassFS is a wrapper with an uninstrumented ReadFile.
+type bypassFS struct {
+	*metricsfs.MetricsFS
+	base absfs.FileSystem
+}
+
+func (b *bypassFS) ReadFile(name string) ([]byte, error) {
+	return b.base.ReadFile(name)
+}
+
+func TestUninstrumentedDetectsBypass(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+
+	recorder := NewRecorder(base)
+	m := metricsfs.New(recorder.FS())
+	wrapper := &bypassFS{MetricsFS: m, base: recorder.FS()}
+
+	dir := t.TempDir()
+	f, err := wrapper.Create(dir + "/test.txt")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+	wrapper.ReadFile(dir + "/test.txt")
+
+	got := Uninstrumented(recorder.Calls(), statsRecorded(m)(wrapper))
+	if want := []metricsfs.Op{metricsfs.OpReadFile}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v to be uninstrumented, got %v", want, got)
+	}
+}
+
+func TestUninstrumentedAliases(t *testing.T) {
+	calls := map[metricsfs.Op]int64{
+		metricsfs.OpOpenFile: 2,
+		metricsfs.OpSeek:     1,
+		metricsfs.OpSync:     0,
+	}
+	recorded := map[string]int64{"open": 2}
+
+	got := Uninstrumented(calls, recorded)
+	if want := []metricsfs.Op{metricsfs.OpSeek}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestInstrumentedTempFS(t *testing.T) {
+	fs := NewInstrumentedTempFS(t)
+
+	f, err := fs.Create("data.txt")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+	fs.Stat("missing.txt")
+
+	if got := fs.Operations(metricsfs.OpCreate); got != 1 {
+		t.Errorf("Expected 1 create, got %v", got)
+	}
+	if got := fs.Errors(metricsfs.OpStat); got != 1 {
+		t.Errorf("Expected 1 failed stat, got %v", got)
+	}
+	if got := fs.Value("fs_bytes_written_total"); got != 5 {
+		t.Errorf("Expected 5 bytes written, got %v", got)
+	}
+	if got := fs.Value("fs_operation_duration_seconds", "operation", "write"); got != 1 {
+		t.Errorf("Expected 1 timed write, got %v", got)
+	}
+	if len(fs.Gather()) == 0 {
+		t.Error("Expected gathered metric families")
+	}
+}
+
+func TestInstrumentedFSConfig(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+
+	config := metricsfs.DefaultConfig()
+	config.Namespace = "storage"
+	fs := NewInstrumentedFS(t, base, config)
+
+	fs.Stat(t.TempDir())
+
+	if got := fs.Operations(metricsfs.OpStat); got != 1 {
+		t.Errorf("Expected 1 stat, got %v", got)
+	}
+	if got := fs.Value("storage_operations_total", "operation", "stat", "status", "success"); got != 1 {
+		t.Errorf("Expected 1 successful stat, got %v", got)
+	}
+}
@@ -0,0 +1,87 @@
+package metricsfs
+
+import (
+	"sync"
+	"time"
+)
+
+// Operating modes and their states, as recorded in ModeTransition.
+const (
+	// ModeCollector covers the collector's lifecycle: "reset" and "closed"
+	ModeCollector = "collector"
+
+	// ModePathLimit is "engaged" while paths are dropped from path metrics
+	// because MaxTrackedPaths is reached, and "released" once room is
+	// freed by cleanup or Reset
+	ModePathLimit = "path_limit"
+
+	// ModeProfiling is "started" and "finished" around ProfileFor windows
+	ModeProfiling = "profiling"
+)
+
+// maxModeTransitions is the number of most recent transitions kept.
+const maxModeTransitions = 64
+
+// ModeTransition records a change in how the instrumentation itself was
+// operating, so that gaps or changes in the metrics can be explained after
+// the fact.
+type ModeTransition struct {
+	// Time is when the transition happened
+	Time time.Time `json:"time"`
+
+	// Mode is the operating mode that changed, such as ModePathLimit
+	Mode string `json:"mode"`
+
+	// State is the state the mode entered
+	State string `json:"state"`
+}
+
+// ModeTransitions returns the most recent mode transitions, oldest first.
+// The history survives Reset and Close.
+func (c *Collector) ModeTransitions() []ModeTransition {
+	return c.transitions.list()
+}
+
+// recordModeTransition records that mode entered state. The transition is
+// counted only while the collector is open, but is always kept in the
+// history and passed to OnModeTransition.
+func (c *Collector) recordModeTransition(mode, state string) {
+	t := ModeTransition{Time: c.clock.Now(), Mode: mode, State: state}
+	c.transitions.add(t)
+
+	if !c.closed.Load() {
+		c.modeTransitionsTotal.WithLabelValues(mode, state).Inc()
+	}
+
+	if c.config.OnModeTransition != nil {
+		c.config.OnModeTransition(t)
+	}
+}
+
+// transitionLog is a bounded history of mode transitions.
+type transitionLog struct {
+	mu      sync.Mutex
+	entries []ModeTransition
+}
+
+// add appends t, dropping the oldest transition when the log is full.
+func (l *transitionLog) add(t ModeTransition) {
+	l.mu.Lock()
+	if len(l.entries) == maxModeTransitions {
+		copy(l.entries, l.entries[1:])
+		l.entries = l.entries[:len(l.entries)-1]
+	}
+	l.entries = append(l.entries, t)
+	l.mu.Unlock()
+}
+
+// list returns a copy of the logged transitions.
+func (l *transitionLog) list() []ModeTransition {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) == 0 {
+		return nil
+	}
+	return append([]ModeTransition(nil), l.entries...)
+}
@@ -0,0 +1,72 @@
+package metricsfs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestModeTransitionsPathLimit(t *testing.T) {
+	var seen []ModeTransition
+	config := DefaultConfig()
+	config.EnablePathMetrics = true
+	config.PathSampleRate = 1.0
+	config.MaxTrackedPaths = 2
+	config.TrackedStateTTL = time.Minute
+	config.OnModeTransition = func(t ModeTransition) { seen = append(seen, t) }
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.collector
+
+	for i := 0; i < 4; i++ {
+		fs.Stat(fmt.Sprintf("/file%d", i))
+	}
+
+	if v := testutil.ToFloat64(c.modeTransitionsTotal.WithLabelValues(ModePathLimit, "engaged")); v != 1 {
+		t.Errorf("Expected path limit to engage once, got %v", v)
+	}
+
+	c.cleanupIdleState(time.Now().Add(time.Hour))
+
+	if v := testutil.ToFloat64(c.modeTransitionsTotal.WithLabelValues(ModePathLimit, "released")); v != 1 {
+		t.Errorf("Expected path limit to be released once, got %v", v)
+	}
+
+	transitions := c.ModeTransitions()
+	if len(transitions) != 2 || transitions[0].State != "engaged" || transitions[1].State != "released" {
+		t.Fatalf("Expected engaged then released, got %v", transitions)
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected OnModeTransition to be called twice, got %d", len(seen))
+	}
+	if stats := c.Stats(); len(stats.Transitions) != 2 {
+		t.Errorf("Expected transitions in stats, got %v", stats.Transitions)
+	}
+}
+
+func TestModeTransitionsLifecycle(t *testing.T) {
+	c := NewCollector(DefaultConfig())
+
+	c.Reset()
+	if v := testutil.ToFloat64(c.modeTransitionsTotal.WithLabelValues(ModeCollector, "reset")); v != 1 {
+		t.Errorf("Expected 1 reset transition, got %v", v)
+	}
+
+	c.Close()
+	transitions := c.ModeTransitions()
+	if len(transitions) != 2 || transitions[1].Mode != ModeCollector || transitions[1].State != "closed" {
+		t.Errorf("Expected reset then closed, got %v", transitions)
+	}
+}
+
+func TestModeTransitionsBounded(t *testing.T) {
+	c := NewCollector(DefaultConfig())
+	for i := 0; i < maxModeTransitions+10; i++ {
+		c.Reset()
+	}
+
+	if n := len(c.ModeTransitions()); n != maxModeTransitions {
+		t.Errorf("Expected %d transitions to be kept, got %d", maxModeTransitions, n)
+	}
+}
@@ -0,0 +1,240 @@
+package metricsfs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Op identifies a filesystem operation. It is used as the "operation" label
+// on Prometheus metrics, the "operation" attribute on OpenTelemetry metrics,
+// and the Name of the Operation passed to callbacks.
+type Op string
+
+// Operations recorded by the built-in wrappers.
+const (
+	OpOpen      Op = "open"
+	OpOpenFile  Op = "openfile"
+	OpCreate    Op = "create"
+	OpRead      Op = "read"
+	OpWrite     Op = "write"
+	OpSeek      Op = "seek"
+	OpClose     Op = "close"
+	OpStat      Op = "stat"
+	OpLstat     Op = "lstat"
+	OpSync      Op = "sync"
+	OpTruncate  Op = "truncate"
+	OpReaddir   Op = "readdir"
+	OpReadFile  Op = "readfile"
+	OpMkdir     Op = "mkdir"
+	OpMkdirAll  Op = "mkdirall"
+	OpRemove    Op = "remove"
+	OpRemoveAll Op = "removeall"
+	OpRename    Op = "rename"
+	OpChmod     Op = "chmod"
+	OpChown     Op = "chown"
+	OpLchown    Op = "lchown"
+	OpChtimes   Op = "chtimes"
+	OpReadlink  Op = "readlink"
+	OpSymlink   Op = "symlink"
+	OpChdir     Op = "chdir"
+	OpGetwd     Op = "getwd"
+	OpSub       Op = "sub"
+	OpFastWalk  Op = "fastwalk"
+	OpWriteFile Op = "writefile"
+	OpWalkDir   Op = "walkdir"
+	OpLock      Op = "lock"
+	OpUnlock    Op = "unlock"
+	OpGlob      Op = "glob"
+
+	// OpOpenDir and OpCloseDir record the opens and closes of directory
+	// handles in place of OpOpen and OpClose, with
+	// EnableHandleKindDetection
+	OpOpenDir  Op = "opendir"
+	OpCloseDir Op = "closedir"
+)
+
+// builtinOperations lists the operations of the built-in wrappers, in the
+// order of their builtinIndex.
+var builtinOperations = [...]Op{
+	OpOpen, OpOpenFile, OpCreate, OpRead, OpWrite, OpSeek, OpClose,
+	OpStat, OpLstat, OpSync, OpTruncate, OpReaddir, OpReadFile, OpMkdir,
+	OpMkdirAll, OpRemove, OpRemoveAll, OpRename, OpChmod, OpChown, OpLchown,
+	OpChtimes, OpReadlink, OpSymlink, OpChdir, OpGetwd, OpSub, OpFastWalk,
+	OpWriteFile, OpWalkDir, OpLock, OpUnlock, OpGlob, OpOpenDir, OpCloseDir,
+}
+
+// builtinIndex returns the index of op in builtinOperations, or -1 for
+// custom operations. It is a switch rather than a map lookup because it runs
+// on every operation.
+func builtinIndex(op Op) int {
+	switch op {
+	case OpOpen:
+		return 0
+	case OpOpenFile:
+		return 1
+	case OpCreate:
+		return 2
+	case OpRead:
+		return 3
+	case OpWrite:
+		return 4
+	case OpSeek:
+		return 5
+	case OpClose:
+		return 6
+	case OpStat:
+		return 7
+	case OpLstat:
+		return 8
+	case OpSync:
+		return 9
+	case OpTruncate:
+		return 10
+	case OpReaddir:
+		return 11
+	case OpReadFile:
+		return 12
+	case OpMkdir:
+		return 13
+	case OpMkdirAll:
+		return 14
+	case OpRemove:
+		return 15
+	case OpRemoveAll:
+		return 16
+	case OpRename:
+		return 17
+	case OpChmod:
+		return 18
+	case OpChown:
+		return 19
+	case OpLchown:
+		return 20
+	case OpChtimes:
+		return 21
+	case OpReadlink:
+		return 22
+	case OpSymlink:
+		return 23
+	case OpChdir:
+		return 24
+	case OpGetwd:
+		return 25
+	case OpSub:
+		return 26
+	case OpFastWalk:
+		return 27
+	case OpWriteFile:
+		return 28
+	case OpWalkDir:
+		return 29
+	case OpLock:
+		return 30
+	case OpUnlock:
+		return 31
+	case OpGlob:
+		return 32
+	case OpOpenDir:
+		return 33
+	case OpCloseDir:
+		return 34
+	}
+	return -1
+}
+
+// transfersData reports whether op reads or writes file contents, counting
+// the bytes it transfers.
+func transfersData(op Op) bool {
+	return op == OpRead || op == OpWrite || op == OpReadFile || op == OpWriteFile
+}
+
+// transfersOut reports whether op writes file contents, so that the bytes it
+// transfers are counted as written rather than read.
+func transfersOut(op Op) bool {
+	return op == OpWrite || op == OpWriteFile
+}
+
+// String returns the operation name.
+func (o Op) String() string {
+	return string(o)
+}
+
+// Registered reports whether o is a built-in operation or has been added
+// with RegisterOperation.
+func (o Op) Registered() bool {
+	operationsMu.RLock()
+	defer operationsMu.RUnlock()
+	return operations[o]
+}
+
+// operationNamePattern restricts operation names to lowercase identifiers so
+// they are valid as both label values and attribute values.
+var operationNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+var (
+	operationsMu sync.RWMutex
+	operations   = func() map[Op]bool {
+		ops := make(map[Op]bool, len(builtinOperations))
+		for _, op := range builtinOperations {
+			ops[op] = true
+		}
+		return ops
+	}()
+)
+
+// RegisterOperation adds a custom operation to the registry so layers built
+// on top of metricsfs can record it with Collector.RecordOperation.
+// Registering an existing operation returns it unchanged.
+func RegisterOperation(name string) (Op, error) {
+	if !operationNamePattern.MatchString(name) {
+		return "", fmt.Errorf("metricsfs: invalid operation name %q", name)
+	}
+
+	op := Op(name)
+	operationsMu.Lock()
+	operations[op] = true
+	operationsMu.Unlock()
+	return op, nil
+}
+
+// MustRegisterOperation is like RegisterOperation but panics if name is
+// invalid. It is intended for package-level variables.
+func MustRegisterOperation(name string) Op {
+	op, err := RegisterOperation(name)
+	if err != nil {
+		panic(err)
+	}
+	return op
+}
+
+// Operations returns all registered operations, sorted by name.
+func Operations() []Op {
+	operationsMu.RLock()
+	ops := make([]Op, 0, len(operations))
+	for op := range operations {
+		ops = append(ops, op)
+	}
+	operationsMu.RUnlock()
+
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	return ops
+}
+
+// RecordOperation records an operation performed by a custom layer that
+// shares the collector. op must be registered, so a misspelled name cannot
+// create a phantom metric series.
+func (c *Collector) RecordOperation(ctx context.Context, op Op, path string, duration time.Duration, bytesTransferred int64, err error) error {
+	if !op.Registered() {
+		return fmt.Errorf("metricsfs: unregistered operation %q", op)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	c.recordOperation(ctx, op, path, duration, bytesTransferred, err)
+	return nil
+}
@@ -0,0 +1,95 @@
+package metricsfs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegisterOperation(t *testing.T) {
+	op, err := RegisterOperation("cache_lookup")
+	if err != nil {
+		t.Fatalf("RegisterOperation failed: %v", err)
+	}
+	if op != Op("cache_lookup") || !op.Registered() {
+		t.Errorf("Expected cache_lookup to be registered, got %q", op)
+	}
+
+	// Registering again is a no-op
+	if again, err := RegisterOperation("cache_lookup"); err != nil || again != op {
+		t.Errorf("Expected re-registration to return %q, got %q (%v)", op, again, err)
+	}
+
+	found := false
+	for _, registered := range Operations() {
+		if registered == op {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected Operations to include cache_lookup")
+	}
+
+	for _, name := range []string{"", "Read", "cache-lookup", "1op"} {
+		if _, err := RegisterOperation(name); err == nil {
+			t.Errorf("Expected error for invalid operation name %q", name)
+		}
+	}
+}
+
+func TestBuiltinOperationsRegistered(t *testing.T) {
+	for _, op := range []Op{OpOpen, OpRead, OpWrite, OpClose, OpStat, OpFastWalk} {
+		if !op.Registered() {
+			t.Errorf("Expected built-in operation %q to be registered", op)
+		}
+	}
+	if Op("raed").Registered() {
+		t.Error("Expected misspelled operation to be unregistered")
+	}
+}
+
+func TestCollectorRecordOperation(t *testing.T) {
+	fs := New(newMockFS())
+	collector := fs.Collector()
+
+	op := MustRegisterOperation("compress")
+	if err := collector.RecordOperation(context.Background(), op, "/test.txt", time.Millisecond, 0, nil); err != nil {
+		t.Fatalf("RecordOperation failed: %v", err)
+	}
+	if err := collector.RecordOperation(nil, op, "/test.txt", time.Millisecond, 0, os.ErrPermission); err != nil {
+		t.Fatalf("RecordOperation failed: %v", err)
+	}
+
+	if got := testutil.ToFloat64(collector.operationsTotal.WithLabelValues("compress", "success")); got != 1 {
+		t.Errorf("Expected 1 successful compress operation, got %v", got)
+	}
+	if got := testutil.ToFloat64(collector.errorsTotal.WithLabelValues("compress", "permission")); got != 1 {
+		t.Errorf("Expected 1 compress permission error, got %v", got)
+	}
+
+	err := collector.RecordOperation(context.Background(), Op("compres"), "", 0, 0, nil)
+	if err == nil {
+		t.Fatal("Expected error for unregistered operation")
+	}
+	if got := testutil.ToFloat64(collector.operationsTotal.WithLabelValues("compres", "success")); got != 0 {
+		t.Errorf("Expected unregistered operation not to be recorded, got %v", got)
+	}
+}
+
+func TestOnErrorReceivesOperationName(t *testing.T) {
+	var captured string
+	config := DefaultConfig()
+	config.OnError = func(operation string, err error) {
+		captured = operation
+	}
+
+	fs := NewWithConfig(&errorMockFS{}, config)
+	fs.Stat("/missing")
+
+	if captured != string(OpStat) {
+		t.Errorf("Expected %q, got %q", OpStat, captured)
+	}
+}
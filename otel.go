@@ -2,16 +2,24 @@ package metricsfs
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
 	"io/fs"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/absfs/absfs"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 )
 
 // OTelConfig holds configuration for OpenTelemetry integration.
@@ -31,8 +39,143 @@ type OTelConfig struct {
 	// EnableTracing enables distributed tracing for filesystem operations
 	EnableTracing bool
 
+	// FileLifecycleSpans, with EnableTracing, traces each opened file as one
+	// "File" span that starts at open and ends at Close. Reads and writes are
+	// recorded as "read" and "write" events on that span, carrying the byte
+	// count and offset, instead of as a span each; the span's attributes
+	// total them when it ends.
+	FileLifecycleSpans bool
+
+	// MinSpanDuration, when set, only traces operations that take at least
+	// this long. Spans are created after the operation ends, backdated to its
+	// start, so fast operations produce no spans at all. File lifecycle
+	// spans are not affected.
+	MinSpanDuration time.Duration
+
+	// AmbientSpanEvents, without EnableTracing, records each operation as
+	// an event on the span of its context, if any, instead of as a span of
+	// its own. Events are named after the operation and carry fs.bytes,
+	// fs.duration_us and, for failures, fs.error. This gives request traces
+	// filesystem visibility at the cost of an event per operation. Contexts
+	// are passed with the WithContext methods
+	AmbientSpanEvents bool
+
+	// HandleIDs, with EnableTracing, assigns each opened file a short random
+	// ID, set as the fs.handle.id attribute of its open span and of the
+	// spans of its operations, so that all activity of one handle can be
+	// correlated when the same path is open several times. See HandleID
+	HandleIDs bool
+
+	// Instance identifies the wrapped filesystem in all of its telemetry:
+	// the fs.instance attribute of every metric and span. When empty, an ID
+	// is generated for spans only, since metric streams would otherwise
+	// change on every restart. See OTelCollector.Instance
+	Instance string
+
 	// ConstAttributes are attributes that will be applied to all metrics and spans
 	ConstAttributes []attribute.KeyValue
+
+	// ContextAttributes extracts per-request attributes (e.g. tenant_id or
+	// job_name) from the context an operation was issued with. They are added
+	// to operation metrics recorded through the *WithContext methods.
+	ContextAttributes func(ctx context.Context) []attribute.KeyValue
+
+	// BaggageKeys are the OpenTelemetry baggage members (e.g. tenant or
+	// job_id) copied, under the same key, onto the metrics and spans of
+	// operations issued with a context carrying them. Members missing from
+	// the baggage are omitted. Values become metric attributes, so only list
+	// low-cardinality members
+	BaggageKeys []string
+
+	// PathAttributeFunc maps an operation's path to the value of the "path"
+	// attribute on its metrics; an empty result omits the attribute. Raw
+	// paths are unbounded, so by default (nil) metrics carry no path at all.
+	// Use DefaultPathGroup to group paths or HashPathAttribute to keep a
+	// fixed-size token. Spans always carry the full path
+	PathAttributeFunc func(path string) string
+
+	// MaxPathAttributeValues is the maximum number of distinct path
+	// attribute values; further values are recorded as "other".
+	// Only used when PathAttributeFunc is set (default: 100)
+	MaxPathAttributeValues int
+
+	// DurationBuckets are the explicit bucket boundaries advised for the
+	// operation duration histogram, in seconds
+	// (default: DefaultOTelDurationBuckets)
+	DurationBuckets []float64
+
+	// SizeBuckets are the explicit bucket boundaries advised for the read and
+	// write size histograms, in bytes (default: DefaultOTelSizeBuckets)
+	SizeBuckets []float64
+
+	// SemanticConventions names attributes after the OpenTelemetry semantic
+	// conventions instead of the historical ad-hoc keys: metrics carry
+	// fs.operation and file.path instead of operation and path, and success
+	// is the absence of error.type rather than status="success"; spans carry
+	// file.path, file.symbolic_link.target_path, file.mode (octal),
+	// file.owner.id, file.group.id and file.size instead of the fs.*
+	// equivalents. Defaults to false so that existing dashboards keep working.
+	SemanticConventions bool
+
+	// ExponentialHistogramSuffix, when set, records the duration and size
+	// histograms a second time under their name with this suffix appended
+	// (e.g. "fs.operation.duration.exp"). Configure an SDK view with a base2
+	// exponential aggregation for those instruments to compare both
+	// aggregations during a backend migration.
+	ExponentialHistogramSuffix string
+
+	// Clock times operations for the duration metrics, and file lifecycle
+	// spans and spans held back by MinSpanDuration (default: the system
+	// clock). Inject a fake clock to make them deterministic in tests. Other
+	// spans take their timestamps from the tracer
+	Clock Clock
+}
+
+// DefaultOTelDurationBuckets are the default duration histogram boundaries,
+// in seconds. Unlike the SDK defaults, which start at 5 (milliseconds or
+// seconds alike), they resolve the microsecond to millisecond latencies of
+// local filesystem operations.
+var DefaultOTelDurationBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05,
+	0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// DefaultOTelSizeBuckets are the default read and write size histogram
+// boundaries, in bytes: powers of 4 from 64B to 64MiB.
+var DefaultOTelSizeBuckets = []float64{
+	64, 256, 1024, 4096, 16384, 65536,
+	262144, 1048576, 4194304, 16777216, 67108864,
+}
+
+// HistogramBoundaries returns the explicit bucket boundaries of each
+// histogram instrument, keyed by instrument name, with defaults applied.
+// The boundaries are advised when the instruments are created; use this to
+// configure equivalent SDK views where advice is not honored, e.g.
+//
+//	for name, bounds := range config.HistogramBoundaries() {
+//		views = append(views, sdkmetric.NewView(
+//			sdkmetric.Instrument{Name: name},
+//			sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: bounds}},
+//		))
+//	}
+func (c OTelConfig) HistogramBoundaries() map[string][]float64 {
+	c.applyBucketDefaults()
+	return map[string][]float64{
+		"fs.operation.duration": c.DurationBuckets,
+		"fs.read.size":          c.SizeBuckets,
+		"fs.write.size":         c.SizeBuckets,
+	}
+}
+
+// applyBucketDefaults fills in the default histogram boundaries.
+func (c *OTelConfig) applyBucketDefaults() {
+	if len(c.DurationBuckets) == 0 {
+		c.DurationBuckets = DefaultOTelDurationBuckets
+	}
+	if len(c.SizeBuckets) == 0 {
+		c.SizeBuckets = DefaultOTelSizeBuckets
+	}
 }
 
 // OTelCollector collects filesystem metrics using OpenTelemetry.
@@ -41,13 +184,42 @@ type OTelCollector struct {
 	meter  metric.Meter
 	tracer trace.Tracer
 
+	// Distinct path attribute values, bounded by MaxPathAttributeValues
+	pathValues *boundedLabels
+
+	// Attribute keys, per SemanticConventions
+	keys otelAttributeKeys
+
+	// instance identifies the wrapped filesystem, see OTelConfig.Instance
+	instance string
+
+	// Metric attribute sets by attributeSetKey
+	attributeSets sync.Map
+
+	// Signals whose provider is a no-op, for which no attributes are built.
+	// See FastPath
+	noopMetrics bool
+	noopTracing bool
+
+	// ambientEvents records operations as events on the spans of their
+	// contexts, see OTelConfig.AmbientSpanEvents
+	ambientEvents bool
+
 	// Metric instruments
 	operationsCounter   metric.Int64Counter
 	bytesReadCounter    metric.Int64Counter
 	bytesWrittenCounter metric.Int64Counter
 	operationDuration   metric.Float64Histogram
+	readSize            metric.Int64Histogram
+	writeSize           metric.Int64Histogram
 	openFilesGauge      metric.Int64UpDownCounter
 	errorsCounter       metric.Int64Counter
+	inFlightGauge       metric.Int64UpDownCounter
+
+	// Histograms dual-emitted for exponential aggregation (if enabled)
+	operationDurationExp metric.Float64Histogram
+	readSizeExp          metric.Int64Histogram
+	writeSizeExp         metric.Int64Histogram
 }
 
 // NewOTelCollector creates a new OpenTelemetry metrics collector.
@@ -68,11 +240,38 @@ func NewOTelCollector(config OTelConfig) (*OTelCollector, error) {
 		config.TracerName = "github.com/absfs/metricsfs"
 	}
 
+	config.applyBucketDefaults()
+
+	if config.MaxPathAttributeValues == 0 {
+		config.MaxPathAttributeValues = 100
+	}
+
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	instance := config.Instance
+	if instance == "" {
+		instance = newInstanceID()
+	} else {
+		config.ConstAttributes = append(append([]attribute.KeyValue(nil), config.ConstAttributes...), instanceAttribute.String(instance))
+	}
+
 	c := &OTelCollector{
 		config: config,
 		meter:  config.MeterProvider.Meter(config.MeterName),
 		tracer: config.TracerProvider.Tracer(config.TracerName),
+
+		pathValues: newBoundedLabels(config.MaxPathAttributeValues),
+		keys:       legacyAttributeKeys,
+		instance:   instance,
+	}
+	if config.SemanticConventions {
+		c.keys = semconvAttributeKeys
 	}
+	_, c.noopMetrics = c.meter.(metricnoop.Meter)
+	_, c.noopTracing = c.tracer.(tracenoop.Tracer)
+	c.ambientEvents = config.AmbientSpanEvents && !config.EnableTracing
 
 	var err error
 
@@ -111,11 +310,63 @@ func NewOTelCollector(config OTelConfig) (*OTelCollector, error) {
 		"fs.operation.duration",
 		metric.WithDescription("Filesystem operation duration"),
 		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(config.DurationBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize read and write size histograms
+	c.readSize, err = c.meter.Int64Histogram(
+		"fs.read.size",
+		metric.WithDescription("Distribution of read sizes"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(config.SizeBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.writeSize, err = c.meter.Int64Histogram(
+		"fs.write.size",
+		metric.WithDescription("Distribution of write sizes"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(config.SizeBuckets...),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// Initialize exponential histogram counterparts (if enabled)
+	if suffix := config.ExponentialHistogramSuffix; suffix != "" {
+		c.operationDurationExp, err = c.meter.Float64Histogram(
+			"fs.operation.duration"+suffix,
+			metric.WithDescription("Filesystem operation duration (exponential aggregation)"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		c.readSizeExp, err = c.meter.Int64Histogram(
+			"fs.read.size"+suffix,
+			metric.WithDescription("Distribution of read sizes (exponential aggregation)"),
+			metric.WithUnit("By"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		c.writeSizeExp, err = c.meter.Int64Histogram(
+			"fs.write.size"+suffix,
+			metric.WithDescription("Distribution of write sizes (exponential aggregation)"),
+			metric.WithUnit("By"),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize open files gauge
 	c.openFilesGauge, err = c.meter.Int64UpDownCounter(
 		"fs.open_files",
@@ -136,69 +387,263 @@ func NewOTelCollector(config OTelConfig) (*OTelCollector, error) {
 		return nil, err
 	}
 
+	// Initialize in-flight operations gauge
+	c.inFlightGauge, err = c.meter.Int64UpDownCounter(
+		"fs.operations.in_flight",
+		metric.WithDescription("Filesystem operations currently in progress"),
+		metric.WithUnit("{operation}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize fast path gauge
+	_, err = c.meter.Int64ObservableGauge(
+		"fs.instrumentation.fast_path",
+		metric.WithDescription("Whether operations skip a signal whose provider is a no-op (1) or record it (0)"),
+		metric.WithInt64Callback(c.observeFastPath),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return c, nil
 }
 
+// startOperation marks op as in flight and returns its start time.
+func (c *OTelCollector) startOperation(ctx context.Context, op Op) time.Time {
+	if c.noopMetrics {
+		return c.config.Clock.Now()
+	}
+	c.inFlightGauge.Add(ctx, 1, metric.WithAttributeSet(c.inFlightSet(op)))
+	return c.config.Clock.Now()
+}
+
+// finishOperation marks op as no longer in flight and returns the time
+// elapsed since start.
+func (c *OTelCollector) finishOperation(ctx context.Context, op Op, start time.Time) time.Duration {
+	duration := c.config.Clock.Now().Sub(start)
+	if c.noopMetrics {
+		return duration
+	}
+	c.inFlightGauge.Add(ctx, -1, metric.WithAttributeSet(c.inFlightSet(op)))
+	return duration
+}
+
+// inFlightSet returns the attributes of op for the in-flight gauge.
+func (c *OTelCollector) inFlightSet(op Op) attribute.Set {
+	return c.cachedAttributeSet(attributeSetKey{op: op, inFlight: true})
+}
+
 // recordOperation records metrics for a filesystem operation.
-func (c *OTelCollector) recordOperation(ctx context.Context, op, path string, duration time.Duration, bytesTransferred int64, err error) {
-	attrs := c.buildAttributes(op, path, err)
+func (c *OTelCollector) recordOperation(ctx context.Context, op Op, path string, duration time.Duration, bytesTransferred int64, err error) {
+	// Benign sentinels such as io.EOF are not failures
+	err = operationError(err)
+
+	if c.ambientEvents {
+		addAmbientSpanEvent(ctx, op, duration, bytesTransferred, err)
+	}
+	if c.noopMetrics {
+		return
+	}
+
+	// The set of the operation is cached; per-request attributes, if any,
+	// are merged into a set of their own
+	set := c.attributeSet(op, path, err)
+	var extra []attribute.KeyValue
+	if c.config.ContextAttributes != nil {
+		extra = c.config.ContextAttributes(ctx)
+	}
+	extra = c.appendBaggageAttributes(ctx, extra)
+	if len(extra) > 0 {
+		set = attribute.NewSet(append(set.ToSlice(), extra...)...)
+	}
+	attrs := metric.WithAttributeSet(set)
 
 	// Record operation count
-	c.operationsCounter.Add(ctx, 1, metric.WithAttributes(attrs...))
+	c.operationsCounter.Add(ctx, 1, attrs)
 
 	// Record duration
-	c.operationDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(attrs...))
+	c.operationDuration.Record(ctx, duration.Seconds(), attrs)
+	if c.operationDurationExp != nil {
+		c.operationDurationExp.Record(ctx, duration.Seconds(), attrs)
+	}
 
 	// Record bytes transferred
 	if bytesTransferred > 0 {
 		switch op {
-		case "read":
-			c.bytesReadCounter.Add(ctx, bytesTransferred, metric.WithAttributes(attrs...))
-		case "write":
-			c.bytesWrittenCounter.Add(ctx, bytesTransferred, metric.WithAttributes(attrs...))
+		case OpRead, OpReadFile:
+			c.bytesReadCounter.Add(ctx, bytesTransferred, attrs)
+			c.readSize.Record(ctx, bytesTransferred, attrs)
+			if c.readSizeExp != nil {
+				c.readSizeExp.Record(ctx, bytesTransferred, attrs)
+			}
+		case OpWrite, OpWriteFile:
+			c.bytesWrittenCounter.Add(ctx, bytesTransferred, attrs)
+			c.writeSize.Record(ctx, bytesTransferred, attrs)
+			if c.writeSizeExp != nil {
+				c.writeSizeExp.Record(ctx, bytesTransferred, attrs)
+			}
 		}
 	}
 
 	// Record errors
 	if err != nil {
-		c.errorsCounter.Add(ctx, 1, metric.WithAttributes(attrs...))
+		c.errorsCounter.Add(ctx, 1, attrs)
 	}
 }
 
-// buildAttributes builds attributes for metrics and spans.
-func (c *OTelCollector) buildAttributes(op, path string, err error) []attribute.KeyValue {
+// appendBaggageAttributes appends the BaggageKeys members of ctx's baggage
+// to attrs.
+func (c *OTelCollector) appendBaggageAttributes(ctx context.Context, attrs []attribute.KeyValue) []attribute.KeyValue {
+	if len(c.config.BaggageKeys) == 0 {
+		return attrs
+	}
+
+	bag := baggage.FromContext(ctx)
+	for _, key := range c.config.BaggageKeys {
+		if member := bag.Member(key); member.Key() != "" {
+			attrs = append(attrs, attribute.String(key, member.Value()))
+		}
+	}
+	return attrs
+}
+
+// pathAttribute returns the path attribute value for path, or "" if the
+// attribute is omitted.
+func (c *OTelCollector) pathAttribute(path string) string {
+	if path == "" || c.config.PathAttributeFunc == nil {
+		return ""
+	}
+
+	value := c.config.PathAttributeFunc(path)
+	if value == "" {
+		return ""
+	}
+	return c.pathValues.label(value)
+}
+
+// HashPathAttribute returns a fixed-length hash of path, for use as
+// OTelConfig.PathAttributeFunc when paths must be told apart without
+// exposing them. Cardinality is still bounded by MaxPathAttributeValues.
+func HashPathAttribute(path string) string {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// attributeSetKey identifies the metric attributes of an operation.
+type attributeSetKey struct {
+	op        Op
+	path      string // path attribute value, "" if omitted
+	errorType string // "" for successful operations
+	inFlight  bool   // the in-flight gauge's attributes, of op alone
+}
+
+// attributeSet returns the metric attributes of op on path failing with
+// err. The sets are built once per operation, path attribute value and
+// error type, sparing an allocation per instrument on each operation.
+func (c *OTelCollector) attributeSet(op Op, path string, err error) attribute.Set {
+	return c.cachedAttributeSet(attributeSetKey{op: op, path: c.pathAttribute(path), errorType: categorizeError(err)})
+}
+
+// cachedAttributeSet returns the attribute set of key, building it on
+// first use.
+func (c *OTelCollector) cachedAttributeSet(key attributeSetKey) attribute.Set {
+	if set, ok := c.attributeSets.Load(key); ok {
+		return set.(attribute.Set)
+	}
+	set, _ := c.attributeSets.LoadOrStore(key, attribute.NewSet(c.attributes(key)...))
+	return set.(attribute.Set)
+}
+
+// buildAttributes builds attributes for metrics.
+func (c *OTelCollector) buildAttributes(op Op, path string, err error) []attribute.KeyValue {
+	return c.attributes(attributeSetKey{op: op, path: c.pathAttribute(path), errorType: categorizeError(err)})
+}
+
+// attributes builds the metric attributes identified by key.
+func (c *OTelCollector) attributes(key attributeSetKey) []attribute.KeyValue {
 	attrs := make([]attribute.KeyValue, 0, len(c.config.ConstAttributes)+3)
 	attrs = append(attrs, c.config.ConstAttributes...)
-	attrs = append(attrs, attribute.String("operation", op))
+	attrs = append(attrs, c.keys.operation.String(string(key.op)))
+	if key.inFlight {
+		return attrs
+	}
 
-	if path != "" {
-		attrs = append(attrs, attribute.String("path", path))
+	if key.path != "" {
+		attrs = append(attrs, c.keys.path.String(key.path))
 	}
 
-	if err != nil {
-		attrs = append(attrs, attribute.String("error.type", categorizeError(err)))
-	} else {
+	if key.errorType != "" {
+		attrs = append(attrs, attribute.String("error.type", key.errorType))
+	} else if c.keys.status {
 		attrs = append(attrs, attribute.String("status", "success"))
 	}
 
 	return attrs
 }
 
-// categorizeError categorizes errors into types.
-func categorizeError(err error) string {
-	if err == nil {
-		return ""
+// otelAttributeKeys are the attribute keys used on metrics and spans.
+type otelAttributeKeys struct {
+	// Metric attributes; status adds status="success" to successful operations
+	operation attribute.Key
+	path      attribute.Key
+	status    bool
+
+	// Span attributes
+	spanPath attribute.Key
+	target   attribute.Key
+	mode     attribute.Key
+	owner    attribute.Key
+	group    attribute.Key
+	size     attribute.Key
+}
+
+// legacyAttributeKeys are the historical attribute keys.
+var legacyAttributeKeys = otelAttributeKeys{
+	operation: "operation",
+	path:      "path",
+	status:    true,
+	spanPath:  "fs.path",
+	target:    "fs.target",
+	mode:      "fs.mode",
+	owner:     "fs.uid",
+	group:     "fs.gid",
+	size:      "fs.size",
+}
+
+// semconvAttributeKeys are the attribute keys of the OpenTelemetry semantic
+// conventions. Operations have no conventional attribute and keep the
+// namespaced fs.operation used on spans.
+var semconvAttributeKeys = otelAttributeKeys{
+	operation: "fs.operation",
+	path:      "file.path",
+	spanPath:  "file.path",
+	target:    "file.symbolic_link.target_path",
+	mode:      "file.mode",
+	owner:     "file.owner.id",
+	group:     "file.group.id",
+	size:      "file.size",
+}
+
+// modeAttribute returns the span attribute for mode.
+func (c *OTelCollector) modeAttribute(mode os.FileMode) attribute.KeyValue {
+	if c.config.SemanticConventions {
+		return c.keys.mode.String(fmt.Sprintf("%04o", mode.Perm()))
 	}
+	return c.keys.mode.String(mode.String())
+}
 
-	if os.IsNotExist(err) {
-		return "not_found"
-	} else if os.IsPermission(err) {
-		return "permission"
-	} else if os.IsTimeout(err) {
-		return "timeout"
+// ownerAttributes returns the span attributes for uid and gid.
+func (c *OTelCollector) ownerAttributes(uid, gid int) []attribute.KeyValue {
+	if c.config.SemanticConventions {
+		return []attribute.KeyValue{
+			c.keys.owner.String(strconv.Itoa(uid)),
+			c.keys.group.String(strconv.Itoa(gid)),
+		}
 	}
-
-	return "unknown"
+	return []attribute.KeyValue{c.keys.owner.Int(uid), c.keys.group.Int(gid)}
 }
 
 // OTelMetricsFS wraps an absfs.FileSystem with OpenTelemetry instrumentation.
@@ -235,11 +680,11 @@ func (m *OTelMetricsFS) OpenWithContext(ctx context.Context, name string) (absfs
 	ctx, span := m.startSpan(ctx, "Open", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpOpen)
 	f, err := m.fs.Open(name)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpOpen, start)
 
-	m.collector.recordOperation(ctx, "open", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpOpen, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -247,6 +692,7 @@ func (m *OTelMetricsFS) OpenWithContext(ctx context.Context, name string) (absfs
 		return nil, err
 	}
 
+	ctx = m.collector.withHandleID(ctx, span)
 	return newOTelMetricsFile(f, m.collector, name, ctx), nil
 }
 
@@ -260,11 +706,11 @@ func (m *OTelMetricsFS) OpenFileWithContext(ctx context.Context, name string, fl
 	ctx, span := m.startSpan(ctx, "OpenFile", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpOpenFile)
 	f, err := m.fs.OpenFile(name, flag, perm)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpOpenFile, start)
 
-	m.collector.recordOperation(ctx, "openfile", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpOpenFile, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -272,6 +718,7 @@ func (m *OTelMetricsFS) OpenFileWithContext(ctx context.Context, name string, fl
 		return nil, err
 	}
 
+	ctx = m.collector.withHandleID(ctx, span)
 	return newOTelMetricsFile(f, m.collector, name, ctx), nil
 }
 
@@ -285,11 +732,11 @@ func (m *OTelMetricsFS) StatWithContext(ctx context.Context, name string) (os.Fi
 	ctx, span := m.startSpan(ctx, "Stat", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpStat)
 	info, err := m.fs.Stat(name)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpStat, start)
 
-	m.collector.recordOperation(ctx, "stat", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpStat, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -301,16 +748,7 @@ func (m *OTelMetricsFS) StatWithContext(ctx context.Context, name string) (os.Fi
 
 // startSpan starts a new span for tracing if enabled.
 func (m *OTelMetricsFS) startSpan(ctx context.Context, operation, path string) (context.Context, trace.Span) {
-	if !m.collector.config.EnableTracing {
-		return ctx, trace.SpanFromContext(ctx)
-	}
-
-	return m.collector.tracer.Start(ctx, operation,
-		trace.WithAttributes(
-			attribute.String("fs.operation", operation),
-			attribute.String("fs.path", path),
-		),
-	)
+	return m.collector.startSpan(ctx, operation, path)
 }
 
 // Create creates a new file.
@@ -323,11 +761,11 @@ func (m *OTelMetricsFS) CreateWithContext(ctx context.Context, name string) (abs
 	ctx, span := m.startSpan(ctx, "Create", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpCreate)
 	f, err := m.fs.Create(name)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpCreate, start)
 
-	m.collector.recordOperation(ctx, "create", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpCreate, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -335,6 +773,7 @@ func (m *OTelMetricsFS) CreateWithContext(ctx context.Context, name string) (abs
 		return nil, err
 	}
 
+	ctx = m.collector.withHandleID(ctx, span)
 	return newOTelMetricsFile(f, m.collector, name, ctx), nil
 }
 
@@ -348,11 +787,11 @@ func (m *OTelMetricsFS) MkdirWithContext(ctx context.Context, name string, perm
 	ctx, span := m.startSpan(ctx, "Mkdir", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpMkdir)
 	err := m.fs.Mkdir(name, perm)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpMkdir, start)
 
-	m.collector.recordOperation(ctx, "mkdir", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpMkdir, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -372,11 +811,11 @@ func (m *OTelMetricsFS) MkdirAllWithContext(ctx context.Context, name string, pe
 	ctx, span := m.startSpan(ctx, "MkdirAll", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpMkdirAll)
 	err := m.fs.MkdirAll(name, perm)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpMkdirAll, start)
 
-	m.collector.recordOperation(ctx, "mkdirall", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpMkdirAll, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -396,11 +835,11 @@ func (m *OTelMetricsFS) RemoveWithContext(ctx context.Context, name string) erro
 	ctx, span := m.startSpan(ctx, "Remove", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpRemove)
 	err := m.fs.Remove(name)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpRemove, start)
 
-	m.collector.recordOperation(ctx, "remove", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpRemove, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -420,11 +859,11 @@ func (m *OTelMetricsFS) RemoveAllWithContext(ctx context.Context, name string) e
 	ctx, span := m.startSpan(ctx, "RemoveAll", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpRemoveAll)
 	err := m.fs.RemoveAll(name)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpRemoveAll, start)
 
-	m.collector.recordOperation(ctx, "removeall", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpRemoveAll, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -445,11 +884,11 @@ func (m *OTelMetricsFS) RenameWithContext(ctx context.Context, oldpath, newpath
 	span.SetAttributes(attribute.String("fs.newpath", newpath))
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpRename)
 	err := m.fs.Rename(oldpath, newpath)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpRename, start)
 
-	m.collector.recordOperation(ctx, "rename", oldpath, duration, 0, err)
+	m.collector.recordOperation(ctx, OpRename, oldpath, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -467,14 +906,14 @@ func (m *OTelMetricsFS) Chmod(name string, mode os.FileMode) error {
 // ChmodWithContext changes file permissions with context and tracing.
 func (m *OTelMetricsFS) ChmodWithContext(ctx context.Context, name string, mode os.FileMode) error {
 	ctx, span := m.startSpan(ctx, "Chmod", name)
-	span.SetAttributes(attribute.String("fs.mode", mode.String()))
+	span.SetAttributes(m.collector.modeAttribute(mode))
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpChmod)
 	err := m.fs.Chmod(name, mode)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpChmod, start)
 
-	m.collector.recordOperation(ctx, "chmod", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpChmod, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -492,17 +931,49 @@ func (m *OTelMetricsFS) Chown(name string, uid, gid int) error {
 // ChownWithContext changes file ownership with context and tracing.
 func (m *OTelMetricsFS) ChownWithContext(ctx context.Context, name string, uid, gid int) error {
 	ctx, span := m.startSpan(ctx, "Chown", name)
-	span.SetAttributes(
-		attribute.Int("fs.uid", uid),
-		attribute.Int("fs.gid", gid),
-	)
+	span.SetAttributes(m.collector.ownerAttributes(uid, gid)...)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpChown)
 	err := m.fs.Chown(name, uid, gid)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpChown, start)
 
-	m.collector.recordOperation(ctx, "chown", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpChown, name, duration, 0, err)
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
+	}
+
+	return err
+}
+
+// Lchown changes the ownership of a file without following symlinks.
+func (m *OTelMetricsFS) Lchown(name string, uid, gid int) error {
+	return m.LchownWithContext(context.Background(), name, uid, gid)
+}
+
+// LchownWithContext changes the ownership of a file without following
+// symlinks, with context and tracing.
+func (m *OTelMetricsFS) LchownWithContext(ctx context.Context, name string, uid, gid int) error {
+	ctx, span := m.startSpan(ctx, "Lchown", name)
+	span.SetAttributes(m.collector.ownerAttributes(uid, gid)...)
+	defer span.End()
+
+	start := m.collector.startOperation(ctx, OpLchown)
+
+	// Check if underlying filesystem supports Lchown
+	var err error
+	if sfs, ok := m.fs.(interface {
+		Lchown(name string, uid, gid int) error
+	}); ok {
+		err = sfs.Lchown(name, uid, gid)
+	} else {
+		err = os.ErrInvalid
+	}
+
+	duration := m.collector.finishOperation(ctx, OpLchown, start)
+	m.collector.recordOperation(ctx, OpLchown, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -522,11 +993,11 @@ func (m *OTelMetricsFS) ChtimesWithContext(ctx context.Context, name string, ati
 	ctx, span := m.startSpan(ctx, "Chtimes", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpChtimes)
 	err := m.fs.Chtimes(name, atime, mtime)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpChtimes, start)
 
-	m.collector.recordOperation(ctx, "chtimes", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpChtimes, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -546,7 +1017,7 @@ func (m *OTelMetricsFS) LstatWithContext(ctx context.Context, name string) (os.F
 	ctx, span := m.startSpan(ctx, "Lstat", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpLstat)
 
 	// Check if underlying filesystem supports Lstat
 	var info os.FileInfo
@@ -560,8 +1031,8 @@ func (m *OTelMetricsFS) LstatWithContext(ctx context.Context, name string) (os.F
 		info, err = m.fs.Stat(name)
 	}
 
-	duration := time.Since(start)
-	m.collector.recordOperation(ctx, "lstat", name, duration, 0, err)
+	duration := m.collector.finishOperation(ctx, OpLstat, start)
+	m.collector.recordOperation(ctx, OpLstat, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -581,7 +1052,7 @@ func (m *OTelMetricsFS) ReadlinkWithContext(ctx context.Context, name string) (s
 	ctx, span := m.startSpan(ctx, "Readlink", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpReadlink)
 
 	// Check if underlying filesystem supports Readlink
 	var target string
@@ -594,8 +1065,8 @@ func (m *OTelMetricsFS) ReadlinkWithContext(ctx context.Context, name string) (s
 		err = os.ErrInvalid
 	}
 
-	duration := time.Since(start)
-	m.collector.recordOperation(ctx, "readlink", name, duration, 0, err)
+	duration := m.collector.finishOperation(ctx, OpReadlink, start)
+	m.collector.recordOperation(ctx, OpReadlink, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -603,7 +1074,7 @@ func (m *OTelMetricsFS) ReadlinkWithContext(ctx context.Context, name string) (s
 		return "", err
 	}
 
-	span.SetAttributes(attribute.String("fs.target", target))
+	span.SetAttributes(m.collector.keys.target.String(target))
 	return target, nil
 }
 
@@ -615,10 +1086,10 @@ func (m *OTelMetricsFS) Symlink(oldname, newname string) error {
 // SymlinkWithContext creates a symbolic link with context and tracing.
 func (m *OTelMetricsFS) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
 	ctx, span := m.startSpan(ctx, "Symlink", newname)
-	span.SetAttributes(attribute.String("fs.target", oldname))
+	span.SetAttributes(m.collector.keys.target.String(oldname))
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpSymlink)
 
 	// Check if underlying filesystem supports Symlink
 	var err error
@@ -630,8 +1101,8 @@ func (m *OTelMetricsFS) SymlinkWithContext(ctx context.Context, oldname, newname
 		err = os.ErrInvalid
 	}
 
-	duration := time.Since(start)
-	m.collector.recordOperation(ctx, "symlink", newname, duration, 0, err)
+	duration := m.collector.finishOperation(ctx, OpSymlink, start)
+	m.collector.recordOperation(ctx, OpSymlink, newname, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -651,11 +1122,11 @@ func (m *OTelMetricsFS) ChdirWithContext(ctx context.Context, dir string) error
 	ctx, span := m.startSpan(ctx, "Chdir", dir)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpChdir)
 	err := m.fs.Chdir(dir)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpChdir, start)
 
-	m.collector.recordOperation(ctx, "chdir", dir, duration, 0, err)
+	m.collector.recordOperation(ctx, OpChdir, dir, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -675,11 +1146,11 @@ func (m *OTelMetricsFS) GetwdWithContext(ctx context.Context) (string, error) {
 	ctx, span := m.startSpan(ctx, "Getwd", "")
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpGetwd)
 	dir, err := m.fs.Getwd()
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpGetwd, start)
 
-	m.collector.recordOperation(ctx, "getwd", "", duration, 0, err)
+	m.collector.recordOperation(ctx, OpGetwd, "", duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -702,14 +1173,14 @@ func (m *OTelMetricsFS) Truncate(name string, size int64) error {
 // TruncateWithContext truncates the named file with context and tracing.
 func (m *OTelMetricsFS) TruncateWithContext(ctx context.Context, name string, size int64) error {
 	ctx, span := m.startSpan(ctx, "Truncate", name)
-	span.SetAttributes(attribute.Int64("fs.size", size))
+	span.SetAttributes(m.collector.keys.size.Int64(size))
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpTruncate)
 	err := m.fs.Truncate(name, size)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpTruncate, start)
 
-	m.collector.recordOperation(ctx, "truncate", name, duration, size, err)
+	m.collector.recordOperation(ctx, OpTruncate, name, duration, size, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -729,11 +1200,11 @@ func (m *OTelMetricsFS) ReadDirWithContext(ctx context.Context, name string) ([]
 	ctx, span := m.startSpan(ctx, "ReadDir", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpReaddir)
 	entries, err := m.fs.ReadDir(name)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpReaddir, start)
 
-	m.collector.recordOperation(ctx, "readdir", name, duration, 0, err)
+	m.collector.recordOperation(ctx, OpReaddir, name, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -753,11 +1224,11 @@ func (m *OTelMetricsFS) ReadFileWithContext(ctx context.Context, name string) ([
 	ctx, span := m.startSpan(ctx, "ReadFile", name)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpReadFile)
 	data, err := m.fs.ReadFile(name)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpReadFile, start)
 
-	m.collector.recordOperation(ctx, "readfile", name, duration, int64(len(data)), err)
+	m.collector.recordOperation(ctx, OpReadFile, name, duration, int64(len(data)), err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -767,6 +1238,45 @@ func (m *OTelMetricsFS) ReadFileWithContext(ctx context.Context, name string) ([
 	return data, err
 }
 
+// WalkDir walks the file tree rooted at root like fs.WalkDir, calling fn
+// for each file or directory in the tree, including root.
+func (m *OTelMetricsFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return m.WalkDirWithContext(context.Background(), root, fn)
+}
+
+// WalkDirWithContext walks the file tree rooted at root with context and
+// tracing. The walk is recorded as a single "walkdir" operation, under a
+// span that parents the spans of its Lstat and ReadDir calls and carries the
+// files and directories visited and the bytes of the files whose Info fn
+// read.
+func (m *OTelMetricsFS) WalkDirWithContext(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	ctx, span := m.startSpan(ctx, "WalkDir", root)
+	defer span.End()
+
+	var w walkStats
+	lstat := func(name string) (fs.FileInfo, error) { return m.LstatWithContext(ctx, name) }
+	readDir := func(name string) ([]fs.DirEntry, error) { return m.ReadDirWithContext(ctx, name) }
+
+	start := m.collector.startOperation(ctx, OpWalkDir)
+	err := walkDir(root, lstat, readDir, w.wrap(fn))
+	duration := m.collector.finishOperation(ctx, OpWalkDir, start)
+
+	m.collector.recordOperation(ctx, OpWalkDir, root, duration, 0, err)
+
+	span.SetAttributes(
+		attribute.Int64("fs.walk.files", w.files.Load()),
+		attribute.Int64("fs.walk.dirs", w.dirs.Load()),
+		attribute.Int64("fs.walk.bytes", w.bytes.Load()),
+		attribute.Int64("fs.walk.errors", w.errors.Load()),
+	)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
+	}
+
+	return err
+}
+
 // Sub returns a Filer corresponding to the subtree rooted at dir.
 func (m *OTelMetricsFS) Sub(dir string) (fs.FS, error) {
 	return m.SubWithContext(context.Background(), dir)
@@ -777,11 +1287,11 @@ func (m *OTelMetricsFS) SubWithContext(ctx context.Context, dir string) (fs.FS,
 	ctx, span := m.startSpan(ctx, "Sub", dir)
 	defer span.End()
 
-	start := time.Now()
+	start := m.collector.startOperation(ctx, OpSub)
 	sub, err := m.fs.Sub(dir)
-	duration := time.Since(start)
+	duration := m.collector.finishOperation(ctx, OpSub, start)
 
-	m.collector.recordOperation(ctx, "sub", dir, duration, 0, err)
+	m.collector.recordOperation(ctx, OpSub, dir, duration, 0, err)
 
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -792,136 +1302,258 @@ func (m *OTelMetricsFS) SubWithContext(ctx context.Context, dir string) (fs.FS,
 	return sub, nil
 }
 
+var _ absfs.File = (*otelMetricsFile)(nil)
+
 // otelMetricsFile wraps a file with OpenTelemetry instrumentation.
 type otelMetricsFile struct {
 	file      absfs.File
 	collector *OTelCollector
 	path      string
 	ctx       context.Context
+
+	// span is the file's lifecycle span when FileLifecycleSpans is set.
+	span       trace.Span
+	offset     atomic.Int64
+	reads      atomic.Int64
+	readBytes  atomic.Int64
+	writes     atomic.Int64
+	writeBytes atomic.Int64
 }
 
 // newOTelMetricsFile creates a new OpenTelemetry instrumented file wrapper.
 func newOTelMetricsFile(f absfs.File, collector *OTelCollector, path string, ctx context.Context) *otelMetricsFile {
 	collector.openFilesGauge.Add(ctx, 1)
 
-	return &otelMetricsFile{
+	file := &otelMetricsFile{
 		file:      f,
 		collector: collector,
 		path:      path,
 		ctx:       ctx,
 	}
+	if collector.config.EnableTracing && !collector.noopTracing && collector.config.FileLifecycleSpans {
+		attrs := collector.appendBaggageAttributes(ctx, []attribute.KeyValue{
+			collector.keys.spanPath.String(path),
+			instanceAttribute.String(collector.instance),
+		})
+		attrs = appendHandleIDAttribute(ctx, attrs)
+		file.ctx, file.span = collector.tracer.Start(ctx, "File",
+			trace.WithAttributes(attrs...),
+			trace.WithTimestamp(collector.config.Clock.Now()),
+		)
+	}
+	return file
 }
 
-// Read reads data from the file with metrics.
-func (f *otelMetricsFile) Read(p []byte) (n int, err error) {
-	ctx, span := f.startSpan("Read")
-	defer span.End()
+// addEvent records a read or write as an event on the lifecycle span.
+func (f *otelMetricsFile) addEvent(op Op, n int, off int64, err error) {
+	if f.span == nil {
+		return
+	}
 
-	start := time.Now()
-	n, err = f.file.Read(p)
-	duration := time.Since(start)
+	name := "read"
+	if op == OpWrite {
+		name = "write"
+		f.writes.Add(1)
+		f.writeBytes.Add(int64(n))
+	} else {
+		f.reads.Add(1)
+		f.readBytes.Add(int64(n))
+	}
+	f.span.AddEvent(name, trace.WithAttributes(
+		attribute.Int("fs.bytes", n),
+		attribute.Int64("fs.offset", off),
+	))
+	if err := operationError(err); err != nil {
+		f.span.RecordError(err)
+	}
+}
 
-	f.collector.recordOperation(ctx, "read", f.path, duration, int64(n), err)
+// endSpan ends the lifecycle span with the file's transfer totals.
+func (f *otelMetricsFile) endSpan(err error) {
+	if f.span == nil {
+		return
+	}
 
-	if err != nil && err != os.ErrClosed {
+	f.span.SetAttributes(
+		attribute.Int64("fs.read.count", f.reads.Load()),
+		attribute.Int64("fs.read.bytes", f.readBytes.Load()),
+		attribute.Int64("fs.write.count", f.writes.Load()),
+		attribute.Int64("fs.write.bytes", f.writeBytes.Load()),
+	)
+	if err := operationError(err); err != nil {
+		f.span.SetStatus(codes.Error, err.Error())
+		f.span.RecordError(err)
+	}
+	f.span.End(trace.WithTimestamp(f.collector.config.Clock.Now()))
+}
+
+// begin starts the span of op, named name, and marks op as in flight.
+func (f *otelMetricsFile) begin(name string, op Op) (context.Context, trace.Span, time.Time) {
+	ctx, span := f.startSpan(name)
+	return ctx, span, f.collector.startOperation(ctx, op)
+}
+
+// finish records op, started at start, and marks the span as failed if err
+// is a failure rather than a benign sentinel such as io.EOF.
+func (f *otelMetricsFile) finish(ctx context.Context, span trace.Span, op Op, start time.Time, n int64, err error) {
+	duration := f.collector.finishOperation(ctx, op, start)
+	f.collector.recordOperation(ctx, op, f.path, duration, n, err)
+	if err := operationError(err); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		span.RecordError(err)
 	}
+}
+
+// Read reads data from the file with metrics.
+func (f *otelMetricsFile) Read(p []byte) (n int, err error) {
+	ctx, span, start := f.begin("Read", OpRead)
+	defer span.End()
 
+	n, err = f.file.Read(p)
+	f.addEvent(OpRead, n, f.offset.Add(int64(n))-int64(n), err)
+	f.finish(ctx, span, OpRead, start, int64(n), err)
 	return n, err
 }
 
 // Write writes data to the file with metrics.
 func (f *otelMetricsFile) Write(p []byte) (n int, err error) {
-	ctx, span := f.startSpan("Write")
+	ctx, span, start := f.begin("Write", OpWrite)
 	defer span.End()
 
-	start := time.Now()
 	n, err = f.file.Write(p)
-	duration := time.Since(start)
-
-	f.collector.recordOperation(ctx, "write", f.path, duration, int64(n), err)
-
-	if err != nil {
-		span.RecordError(err)
-	}
-
+	f.addEvent(OpWrite, n, f.offset.Add(int64(n))-int64(n), err)
+	f.finish(ctx, span, OpWrite, start, int64(n), err)
 	return n, err
 }
 
 // Close closes the file.
 func (f *otelMetricsFile) Close() error {
-	ctx, span := f.startSpan("Close")
+	ctx, span, start := f.begin("Close", OpClose)
 	defer span.End()
 
-	start := time.Now()
 	err := f.file.Close()
-	duration := time.Since(start)
-
-	f.collector.recordOperation(ctx, "close", f.path, duration, 0, err)
+	f.finish(ctx, span, OpClose, start, 0, err)
 	f.collector.openFilesGauge.Add(ctx, -1)
-
-	if err != nil {
-		span.RecordError(err)
-	}
-
+	f.endSpan(err)
 	return err
 }
 
-// startSpan starts a new span for file operations.
+// startSpan starts a new span for file operations. Files traced with a
+// lifecycle span get no per-operation spans.
 func (f *otelMetricsFile) startSpan(operation string) (context.Context, trace.Span) {
-	if !f.collector.config.EnableTracing {
-		return f.ctx, trace.SpanFromContext(f.ctx)
+	if f.span != nil {
+		return f.ctx, tracenoop.Span{}
 	}
-
-	return f.collector.tracer.Start(f.ctx, operation,
-		trace.WithAttributes(
-			attribute.String("fs.operation", operation),
-			attribute.String("fs.path", f.path),
-		),
-	)
+	return f.collector.startSpan(f.ctx, operation, f.path)
 }
 
-// Delegate other methods to underlying file
+// ReadAt reads data at an offset with metrics.
 func (f *otelMetricsFile) ReadAt(p []byte, off int64) (n int, err error) {
-	return f.file.ReadAt(p, off)
+	ctx, span, start := f.begin("ReadAt", OpRead)
+	defer span.End()
+
+	n, err = f.file.ReadAt(p, off)
+	f.addEvent(OpRead, n, off, err)
+	f.finish(ctx, span, OpRead, start, int64(n), err)
+	return n, err
 }
 
+// WriteAt writes data at an offset with metrics.
 func (f *otelMetricsFile) WriteAt(p []byte, off int64) (n int, err error) {
-	return f.file.WriteAt(p, off)
+	ctx, span, start := f.begin("WriteAt", OpWrite)
+	defer span.End()
+
+	n, err = f.file.WriteAt(p, off)
+	f.addEvent(OpWrite, n, off, err)
+	f.finish(ctx, span, OpWrite, start, int64(n), err)
+	return n, err
 }
 
+// WriteString writes a string with metrics.
 func (f *otelMetricsFile) WriteString(s string) (n int, err error) {
-	return f.file.WriteString(s)
+	ctx, span, start := f.begin("WriteString", OpWrite)
+	defer span.End()
+
+	n, err = f.file.WriteString(s)
+	f.addEvent(OpWrite, n, f.offset.Add(int64(n))-int64(n), err)
+	f.finish(ctx, span, OpWrite, start, int64(n), err)
+	return n, err
 }
 
+// Seek seeks in the file with metrics.
 func (f *otelMetricsFile) Seek(offset int64, whence int) (int64, error) {
-	return f.file.Seek(offset, whence)
+	ctx, span, start := f.begin("Seek", OpSeek)
+	defer span.End()
+
+	ret, err := f.file.Seek(offset, whence)
+	if err == nil {
+		f.offset.Store(ret)
+	}
+	f.finish(ctx, span, OpSeek, start, 0, err)
+	return ret, err
 }
 
+// Stat returns file info with metrics.
 func (f *otelMetricsFile) Stat() (os.FileInfo, error) {
-	return f.file.Stat()
+	ctx, span, start := f.begin("Stat", OpStat)
+	defer span.End()
+
+	info, err := f.file.Stat()
+	f.finish(ctx, span, OpStat, start, 0, err)
+	return info, err
 }
 
+// Sync syncs the file with metrics.
 func (f *otelMetricsFile) Sync() error {
-	return f.file.Sync()
+	ctx, span, start := f.begin("Sync", OpSync)
+	defer span.End()
+
+	err := f.file.Sync()
+	f.finish(ctx, span, OpSync, start, 0, err)
+	return err
 }
 
+// Truncate truncates the file with metrics.
 func (f *otelMetricsFile) Truncate(size int64) error {
-	return f.file.Truncate(size)
+	ctx, span, start := f.begin("Truncate", OpTruncate)
+	defer span.End()
+
+	err := f.file.Truncate(size)
+	f.finish(ctx, span, OpTruncate, start, 0, err)
+	return err
 }
 
+// Readdir reads directory entries with metrics.
 func (f *otelMetricsFile) Readdir(n int) ([]os.FileInfo, error) {
-	return f.file.Readdir(n)
+	ctx, span, start := f.begin("Readdir", OpReaddir)
+	defer span.End()
+
+	infos, err := f.file.Readdir(n)
+	f.finish(ctx, span, OpReaddir, start, 0, err)
+	return infos, err
 }
 
+// Readdirnames reads directory entry names with metrics.
 func (f *otelMetricsFile) Readdirnames(n int) ([]string, error) {
-	return f.file.Readdirnames(n)
+	ctx, span, start := f.begin("Readdirnames", OpReaddir)
+	defer span.End()
+
+	names, err := f.file.Readdirnames(n)
+	f.finish(ctx, span, OpReaddir, start, 0, err)
+	return names, err
 }
 
+// Name returns the name of the file.
 func (f *otelMetricsFile) Name() string {
 	return f.file.Name()
 }
 
+// ReadDir reads directory entries with metrics.
 func (f *otelMetricsFile) ReadDir(n int) ([]fs.DirEntry, error) {
-	return f.file.ReadDir(n)
+	ctx, span, start := f.begin("ReadDir", OpReaddir)
+	defer span.End()
+
+	entries, err := f.file.ReadDir(n)
+	f.finish(ctx, span, OpReaddir, start, 0, err)
+	return entries, err
 }
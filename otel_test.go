@@ -2,11 +2,21 @@ package metricsfs
 
 import (
 	"context"
+	"io"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/absfs/osfs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
 	tracenoop "go.opentelemetry.io/otel/trace/noop"
 )
 
@@ -404,3 +414,538 @@ func TestOTelCategorizeError(t *testing.T) {
 	// Use the successful fs for other operations
 	_ = fs
 }
+
+func TestOTelFileReadEOF(t *testing.T) {
+	provider := newRecordingMeterProvider()
+	tp := &recordingTracerProvider{}
+	otelConfig := OTelConfig{
+		MeterProvider:  provider,
+		TracerProvider: tp,
+		EnableTracing:  true,
+	}
+
+	fs, err := NewWithOTel(&eofMockFS{}, otelConfig)
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	f, err := fs.Open("/test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Read(make([]byte, 8)); err != io.EOF {
+		t.Errorf("Expected io.EOF to be passed through, got %v", err)
+	}
+	if got := provider.meter.sum("fs.errors"); got != 0 {
+		t.Errorf("Expected io.EOF not to count as an error, got %d", got)
+	}
+	span := tp.spans[len(tp.spans)-1]
+	if span.name != "Read" || span.status == codes.Error || len(span.errors) != 0 {
+		t.Errorf("Expected an EOF read span without error, got %s with %v, %v", span.name, span.status, span.errors)
+	}
+
+	// A real error fails both the metric and the span
+	if _, err := f.ReadAt(make([]byte, 8), 0); err == nil {
+		t.Fatal("Expected ReadAt to fail")
+	}
+	if got := provider.meter.sum("fs.errors"); got != 1 {
+		t.Errorf("Expected 1 error, got %d", got)
+	}
+	span = tp.spans[len(tp.spans)-1]
+	if span.name != "ReadAt" || span.status != codes.Error || len(span.errors) != 1 {
+		t.Errorf("Expected a failed ReadAt span, got %s with %v, %v", span.name, span.status, span.errors)
+	}
+}
+
+func TestOTelContextAttributes(t *testing.T) {
+	var tenants []string
+	otelConfig := OTelConfig{
+		// A no-op meter would skip building attributes
+		MeterProvider:  newRecordingMeterProvider(),
+		TracerProvider: tracenoop.NewTracerProvider(),
+		ContextAttributes: func(ctx context.Context) []attribute.KeyValue {
+			tenant, _ := ctx.Value(tenantKey{}).(string)
+			tenants = append(tenants, tenant)
+			return []attribute.KeyValue{attribute.String("tenant_id", tenant)}
+		},
+	}
+
+	fs, err := NewWithOTel(newMockFS(), otelConfig)
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	if _, err := fs.StatWithContext(ctx, "/test.txt"); err != nil {
+		t.Fatalf("StatWithContext failed: %v", err)
+	}
+
+	if len(tenants) != 1 || tenants[0] != "acme" {
+		t.Errorf("Expected ContextAttributes to see tenant acme, got %v", tenants)
+	}
+}
+
+// recordingMeterProvider is a MeterProvider that counts histogram
+// recordings and keeps the advised bucket boundaries by instrument name.
+type recordingMeterProvider struct {
+	noop.MeterProvider
+	meter *recordingMeter
+}
+
+func newRecordingMeterProvider() *recordingMeterProvider {
+	return &recordingMeterProvider{meter: &recordingMeter{
+		records:    make(map[string]int),
+		sums:       make(map[string]int64),
+		boundaries: make(map[string][]float64),
+	}}
+}
+
+func (p *recordingMeterProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	return p.meter
+}
+
+type recordingMeter struct {
+	noop.Meter
+	mu         sync.Mutex
+	records    map[string]int
+	sums       map[string]int64
+	boundaries map[string][]float64
+}
+
+func (m *recordingMeter) record(name string) {
+	m.mu.Lock()
+	m.records[name]++
+	m.mu.Unlock()
+}
+
+func (m *recordingMeter) count(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.records[name]
+}
+
+// sum returns the total added to the counter name.
+func (m *recordingMeter) sum(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sums[name]
+}
+
+func (m *recordingMeter) Int64Counter(name string, opts ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &recordingInt64Counter{name: name, meter: m}, nil
+}
+
+func (m *recordingMeter) Float64Histogram(name string, opts ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	m.boundaries[name] = metric.NewFloat64HistogramConfig(opts...).ExplicitBucketBoundaries()
+	return &recordingFloat64Histogram{name: name, meter: m}, nil
+}
+
+func (m *recordingMeter) Int64Histogram(name string, opts ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	m.boundaries[name] = metric.NewInt64HistogramConfig(opts...).ExplicitBucketBoundaries()
+	return &recordingInt64Histogram{name: name, meter: m}, nil
+}
+
+type recordingFloat64Histogram struct {
+	noop.Float64Histogram
+	name  string
+	meter *recordingMeter
+}
+
+func (h *recordingFloat64Histogram) Record(ctx context.Context, value float64, opts ...metric.RecordOption) {
+	h.meter.record(h.name)
+}
+
+type recordingInt64Histogram struct {
+	noop.Int64Histogram
+	name  string
+	meter *recordingMeter
+}
+
+func (h *recordingInt64Histogram) Record(ctx context.Context, value int64, opts ...metric.RecordOption) {
+	h.meter.record(h.name)
+}
+
+type recordingInt64Counter struct {
+	noop.Int64Counter
+	name  string
+	meter *recordingMeter
+}
+
+func (c *recordingInt64Counter) Add(ctx context.Context, incr int64, opts ...metric.AddOption) {
+	c.meter.mu.Lock()
+	c.meter.sums[c.name] += incr
+	c.meter.mu.Unlock()
+}
+
+func TestOTelExponentialHistogramDualEmit(t *testing.T) {
+	provider := newRecordingMeterProvider()
+	otelConfig := OTelConfig{
+		MeterProvider:              provider,
+		TracerProvider:             tracenoop.NewTracerProvider(),
+		DurationBuckets:            []float64{0.001, 0.1, 1},
+		ExponentialHistogramSuffix: ".exp",
+	}
+
+	fs, err := NewWithOTel(newMockFS(), otelConfig)
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	f, err := fs.Create("/test.txt")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+
+	m := provider.meter
+	for _, name := range []string{"fs.operation.duration", "fs.operation.duration.exp"} {
+		if got := m.count(name); got != 3 {
+			t.Errorf("Expected 3 recordings for %s, got %d", name, got)
+		}
+	}
+	for _, name := range []string{"fs.write.size", "fs.write.size.exp"} {
+		if got := m.count(name); got != 1 {
+			t.Errorf("Expected 1 recording for %s, got %d", name, got)
+		}
+	}
+}
+
+func TestOTelExponentialHistogramDisabled(t *testing.T) {
+	provider := newRecordingMeterProvider()
+	fs, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:  provider,
+		TracerProvider: tracenoop.NewTracerProvider(),
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	fs.Stat("/test.txt")
+
+	if got := provider.meter.count("fs.operation.duration"); got != 1 {
+		t.Errorf("Expected 1 duration recording, got %d", got)
+	}
+	for name := range provider.meter.records {
+		if name != "fs.operation.duration" {
+			t.Errorf("Unexpected histogram recording for %s", name)
+		}
+	}
+}
+
+func TestOTelHistogramBoundaries(t *testing.T) {
+	provider := newRecordingMeterProvider()
+	config := OTelConfig{
+		MeterProvider:   provider,
+		TracerProvider:  tracenoop.NewTracerProvider(),
+		DurationBuckets: []float64{0.001, 0.1, 1},
+	}
+	if _, err := NewOTelCollector(config); err != nil {
+		t.Fatalf("NewOTelCollector failed: %v", err)
+	}
+
+	want := config.HistogramBoundaries()
+	if !reflect.DeepEqual(want["fs.operation.duration"], []float64{0.001, 0.1, 1}) {
+		t.Errorf("Expected configured duration boundaries, got %v", want["fs.operation.duration"])
+	}
+	if !reflect.DeepEqual(want["fs.read.size"], DefaultOTelSizeBuckets) {
+		t.Errorf("Expected default size boundaries, got %v", want["fs.read.size"])
+	}
+
+	for name, bounds := range want {
+		if got := provider.meter.boundaries[name]; !reflect.DeepEqual(got, bounds) {
+			t.Errorf("Instrument %s advised %v, want %v", name, got, bounds)
+		}
+	}
+}
+
+func TestOTelPathAttribute(t *testing.T) {
+	pathOf := func(attrs []attribute.KeyValue) (string, bool) {
+		for _, kv := range attrs {
+			if kv.Key == "path" {
+				return kv.Value.AsString(), true
+			}
+		}
+		return "", false
+	}
+
+	// Dropped by default
+	c, err := NewOTelCollector(OTelConfig{MeterProvider: noop.NewMeterProvider()})
+	if err != nil {
+		t.Fatalf("NewOTelCollector failed: %v", err)
+	}
+	if path, ok := pathOf(c.buildAttributes(OpStat, "/data/a.txt", nil)); ok {
+		t.Errorf("Expected no path attribute by default, got %q", path)
+	}
+
+	// Grouped and bounded
+	c, err = NewOTelCollector(OTelConfig{
+		MeterProvider:          noop.NewMeterProvider(),
+		PathAttributeFunc:      DefaultPathGroup,
+		MaxPathAttributeValues: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewOTelCollector failed: %v", err)
+	}
+	for _, tt := range []struct{ path, want string }{
+		{"/data/a.txt", "/data"},
+		{"/data/b.txt", "/data"},
+		{"/logs/c.txt", "other"},
+	} {
+		if got, _ := pathOf(c.buildAttributes(OpStat, tt.path, nil)); got != tt.want {
+			t.Errorf("path %s: expected attribute %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestHashPathAttribute(t *testing.T) {
+	a, b := HashPathAttribute("/data/a.txt"), HashPathAttribute("/data/b.txt")
+	if a == b || a != HashPathAttribute("/data/a.txt") {
+		t.Errorf("Expected stable, distinct hashes, got %q and %q", a, b)
+	}
+	if strings.Contains(a, "data") {
+		t.Errorf("Hash should not expose the path, got %q", a)
+	}
+}
+
+// recordingTracerProvider is a TracerProvider that keeps every span it
+// starts.
+type recordingTracerProvider struct {
+	tracenoop.TracerProvider
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (p *recordingTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{provider: p}
+}
+
+type recordingTracer struct {
+	tracenoop.Tracer
+	provider *recordingTracerProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &recordingSpan{name: name, attributes: make(map[string]attribute.Value)}
+	config := trace.NewSpanStartConfig(opts...)
+	for _, kv := range config.Attributes() {
+		span.attributes[string(kv.Key)] = kv.Value
+	}
+	t.provider.mu.Lock()
+	t.provider.spans = append(t.provider.spans, span)
+	t.provider.mu.Unlock()
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordingSpan struct {
+	tracenoop.Span
+	name       string
+	attributes map[string]attribute.Value
+	events     []trace.EventConfig
+	status     codes.Code
+	errors     []error
+	ended      bool
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attributes[string(a.Key)] = a.Value
+	}
+}
+
+func (s *recordingSpan) AddEvent(name string, opts ...trace.EventOption) {
+	s.events = append(s.events, trace.NewEventConfig(opts...))
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, description string) {
+	s.status = code
+}
+
+func (s *recordingSpan) RecordError(err error, opts ...trace.EventOption) {
+	s.errors = append(s.errors, err)
+}
+
+func (s *recordingSpan) End(opts ...trace.SpanEndOption) {
+	s.ended = true
+}
+
+func TestOTelFileLifecycleSpans(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	tp := &recordingTracerProvider{}
+	fs, err := NewWithOTel(base, OTelConfig{
+		MeterProvider:      noop.NewMeterProvider(),
+		TracerProvider:     tp,
+		EnableTracing:      true,
+		FileLifecycleSpans: true,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	path := t.TempDir() + "/data"
+	f, err := fs.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Write([]byte("hello "))
+	f.WriteString("world")
+	f.Seek(0, io.SeekStart)
+	buf := make([]byte, 4)
+	f.Read(buf)
+	f.Read(buf)
+	f.Close()
+
+	var names []string
+	var file *recordingSpan
+	for _, span := range tp.spans {
+		names = append(names, span.name)
+		if span.name == "File" {
+			file = span
+		}
+	}
+	if want := []string{"Create", "File"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected spans %v, got %v", want, names)
+	}
+	if !file.ended {
+		t.Error("Expected the File span to end at Close")
+	}
+	if len(file.events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(file.events))
+	}
+	if off := file.events[3].Attributes()[1].Value.AsInt64(); off != 4 {
+		t.Errorf("Expected the second read at offset 4, got %d", off)
+	}
+	for key, want := range map[string]int64{
+		"fs.read.count":  2,
+		"fs.read.bytes":  8,
+		"fs.write.count": 2,
+		"fs.write.bytes": 11,
+	} {
+		if got := file.attributes[key].AsInt64(); got != want {
+			t.Errorf("Expected %s = %d, got %d", key, want, got)
+		}
+	}
+}
+
+func TestOTelSemanticConventions(t *testing.T) {
+	tp := &recordingTracerProvider{}
+	fs, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:       noop.NewMeterProvider(),
+		TracerProvider:      tp,
+		EnableTracing:       true,
+		SemanticConventions: true,
+		PathAttributeFunc:   func(path string) string { return path },
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+	c := fs.Collector()
+
+	attrs := attribute.NewSet(c.buildAttributes(OpStat, "/data", nil)...)
+	if v, ok := attrs.Value("fs.operation"); !ok || v.AsString() != "stat" {
+		t.Errorf("Expected fs.operation=stat, got %v", attrs.Encoded(attribute.DefaultEncoder()))
+	}
+	if v, ok := attrs.Value("file.path"); !ok || v.AsString() != "/data" {
+		t.Errorf("Expected file.path=/data, got %v", attrs.Encoded(attribute.DefaultEncoder()))
+	}
+	for _, key := range []attribute.Key{"operation", "path", "status"} {
+		if attrs.HasValue(key) {
+			t.Errorf("Expected no %s attribute", key)
+		}
+	}
+
+	fs.Chmod("/data", 0640)
+	fs.Chown("/data", 1000, 100)
+	for key, want := range map[string]string{
+		"file.path":     "/data",
+		"file.mode":     "0640",
+		"file.owner.id": "1000",
+		"file.group.id": "100",
+	} {
+		found := false
+		for _, span := range tp.spans {
+			if v, ok := span.attributes[key]; ok {
+				found = true
+				if v.AsString() != want {
+					t.Errorf("Expected %s=%s, got %s", key, want, v.AsString())
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected a span with %s", key)
+		}
+	}
+}
+
+func TestOTelAttributeSetCache(t *testing.T) {
+	c, err := NewOTelCollector(OTelConfig{
+		MeterProvider:     noop.NewMeterProvider(),
+		PathAttributeFunc: DefaultPathGroup,
+		ConstAttributes:   []attribute.KeyValue{attribute.String("region", "eu")},
+	})
+	if err != nil {
+		t.Fatalf("NewOTelCollector failed: %v", err)
+	}
+
+	// Paths of one group share a set
+	a := c.attributeSet(OpRead, "/data/a.txt", nil)
+	b := c.attributeSet(OpRead, "/data/b.txt", nil)
+	if !a.Equals(&b) {
+		t.Errorf("Expected one set for /data, got %v and %v", a.Encoded(attribute.DefaultEncoder()), b.Encoded(attribute.DefaultEncoder()))
+	}
+	want := attribute.NewSet(c.buildAttributes(OpRead, "/data/a.txt", nil)...)
+	if !a.Equals(&want) {
+		t.Errorf("Expected the cached set to match the built attributes, got %v", a.Encoded(attribute.DefaultEncoder()))
+	}
+
+	failed := c.attributeSet(OpRead, "/data/a.txt", os.ErrNotExist)
+	if v, ok := failed.Value("error.type"); !ok || v.AsString() != "not_found" {
+		t.Errorf("Expected error.type=not_found, got %v", failed.Encoded(attribute.DefaultEncoder()))
+	}
+	inFlight := c.inFlightSet(OpRead)
+	if inFlight.Len() != 2 {
+		t.Errorf("Expected the in-flight set to hold region and operation only, got %v", inFlight.Encoded(attribute.DefaultEncoder()))
+	}
+
+	sets := 0
+	c.attributeSets.Range(func(any, any) bool { sets++; return true })
+	if sets != 3 {
+		t.Errorf("Expected 3 cached sets, got %d", sets)
+	}
+}
+
+func TestOTelBaggageKeys(t *testing.T) {
+	tp := &recordingTracerProvider{}
+	fs, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:  noop.NewMeterProvider(),
+		TracerProvider: tp,
+		EnableTracing:  true,
+		BaggageKeys:    []string{"tenant", "job_id"},
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	tenant, _ := baggage.NewMember("tenant", "acme")
+	user, _ := baggage.NewMember("user", "alice")
+	bag, _ := baggage.New(tenant, user)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+
+	attrs := attribute.NewSet(fs.Collector().appendBaggageAttributes(ctx, nil)...)
+	if v, ok := attrs.Value("tenant"); !ok || v.AsString() != "acme" {
+		t.Errorf("Expected tenant=acme, got %v", attrs.Encoded(attribute.DefaultEncoder()))
+	}
+	if attrs.HasValue("job_id") || attrs.HasValue("user") {
+		t.Errorf("Expected only listed members present in the baggage, got %v", attrs.Encoded(attribute.DefaultEncoder()))
+	}
+
+	fs.StatWithContext(ctx, "/data")
+	if len(tp.spans) != 1 || tp.spans[0].attributes["tenant"].AsString() != "acme" {
+		t.Errorf("Expected the Stat span to carry tenant=acme")
+	}
+}
@@ -0,0 +1,34 @@
+package metricsfs
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// FastPath reports which signals operations skip because their provider is
+// a no-op, such as the providers of go.opentelemetry.io/otel/metric/noop
+// and go.opentelemetry.io/otel/trace/noop. Skipped signals cost no attribute
+// construction per operation. The global providers are never skipped, as an
+// SDK may be installed after the collector is created.
+func (c *OTelCollector) FastPath() (metrics, traces bool) {
+	return c.noopMetrics, c.noopTracing
+}
+
+// observeFastPath reports FastPath on the fs.instrumentation.fast_path
+// gauge. A no-op meter never exports the gauge, so it only shows the metrics
+// signal as recorded; use FastPath to check that metrics are skipped.
+func (c *OTelCollector) observeFastPath(ctx context.Context, o metric.Int64Observer) error {
+	metrics, traces := c.FastPath()
+	o.Observe(boolToInt64(metrics), metric.WithAttributes(attribute.String("signal", "metrics")))
+	o.Observe(boolToInt64(traces), metric.WithAttributes(attribute.String("signal", "traces")))
+	return nil
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
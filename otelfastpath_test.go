@@ -0,0 +1,106 @@
+package metricsfs
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+	"go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestOTelFastPath(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         OTelConfig
+		metrics, trace bool
+	}{
+		{
+			name: "noop",
+			config: OTelConfig{
+				MeterProvider:  noop.NewMeterProvider(),
+				TracerProvider: tracenoop.NewTracerProvider(),
+			},
+			metrics: true,
+			trace:   true,
+		},
+		{
+			name: "recording",
+			config: OTelConfig{
+				MeterProvider:  newRecordingMeterProvider(),
+				TracerProvider: &recordingTracerProvider{},
+			},
+		},
+		{
+			name: "noop tracer",
+			config: OTelConfig{
+				MeterProvider:  newRecordingMeterProvider(),
+				TracerProvider: tracenoop.NewTracerProvider(),
+			},
+			trace: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewOTelCollector(tt.config)
+			if err != nil {
+				t.Fatalf("NewOTelCollector failed: %v", err)
+			}
+			metrics, traces := c.FastPath()
+			if metrics != tt.metrics || traces != tt.trace {
+				t.Errorf("Expected FastPath() = %v, %v, got %v, %v", tt.metrics, tt.trace, metrics, traces)
+			}
+		})
+	}
+}
+
+func TestOTelFastPathSkipsRecording(t *testing.T) {
+	tracer := &recordingTracerProvider{}
+	fs, err := NewWithOTel(newMockFS(), OTelConfig{
+		MeterProvider:  noop.NewMeterProvider(),
+		TracerProvider: tracer,
+		EnableTracing:  true,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	fs.Stat("/test.txt")
+	if got := len(tracer.spans); got != 1 {
+		t.Errorf("Expected the recording tracer to get 1 span, got %d", got)
+	}
+}
+
+func TestOTelFastPathGauge(t *testing.T) {
+	provider := newRecordingMeterProvider()
+	c, err := NewOTelCollector(OTelConfig{
+		MeterProvider:  provider,
+		TracerProvider: tracenoop.NewTracerProvider(),
+	})
+	if err != nil {
+		t.Fatalf("NewOTelCollector failed: %v", err)
+	}
+
+	o := &recordingInt64Observer{values: make(map[string]int64)}
+	if err := c.observeFastPath(context.Background(), o); err != nil {
+		t.Fatalf("observeFastPath failed: %v", err)
+	}
+	if o.values["metrics"] != 0 || o.values["traces"] != 1 {
+		t.Errorf("Expected metrics=0 traces=1, got %v", o.values)
+	}
+}
+
+// recordingInt64Observer keeps observed values by their signal attribute.
+type recordingInt64Observer struct {
+	embedded.Int64Observer
+	values map[string]int64
+}
+
+func (o *recordingInt64Observer) Observe(value int64, opts ...metric.ObserveOption) {
+	attrs := metric.NewObserveConfig(opts).Attributes()
+	signal, _ := attrs.Value(attribute.Key("signal"))
+	o.values[signal.AsString()] = value
+}
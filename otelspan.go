@@ -0,0 +1,130 @@
+package metricsfs
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// startSpan starts the span for one filesystem operation. With
+// MinSpanDuration set, the span is only started once the operation has
+// ended and taken at least that long; see slowSpan.
+func (c *OTelCollector) startSpan(ctx context.Context, operation, path string) (context.Context, trace.Span) {
+	if !c.config.EnableTracing {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	if c.noopTracing {
+		// The span would record nothing: skip building its attributes
+		return ctx, tracenoop.Span{}
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("fs.operation", operation),
+		c.keys.spanPath.String(path),
+		instanceAttribute.String(c.instance),
+	}
+	attrs = c.appendBaggageAttributes(ctx, attrs)
+	attrs = appendHandleIDAttribute(ctx, attrs)
+	if c.config.MinSpanDuration > 0 {
+		return ctx, &slowSpan{
+			tracer: c.tracer,
+			min:    c.config.MinSpanDuration,
+			ctx:    ctx,
+			name:   operation,
+			clock:  c.config.Clock,
+			start:  c.config.Clock.Now(),
+			attrs:  attrs,
+		}
+	}
+	return c.tracer.Start(ctx, operation, trace.WithAttributes(attrs...))
+}
+
+// instanceAttribute is the attribute identifying the wrapped filesystem.
+const instanceAttribute = attribute.Key("fs.instance")
+
+// Instance returns the identifier of the wrapped filesystem in the
+// collector's telemetry: OTelConfig.Instance, or an ID generated when the
+// collector was created.
+func (c *OTelCollector) Instance() string {
+	return c.instance
+}
+
+// handleIDAttribute is the span attribute holding the ID of the handle an
+// operation was issued on.
+const handleIDAttribute = attribute.Key("fs.handle.id")
+
+// withHandleID returns ctx carrying a new handle ID, also set on span, the
+// span of the open, when handle IDs are enabled, and ctx unchanged otherwise.
+func (c *OTelCollector) withHandleID(ctx context.Context, span trace.Span) context.Context {
+	if !c.config.HandleIDs || !c.config.EnableTracing {
+		return ctx
+	}
+	ctx = withHandleID(ctx)
+	span.SetAttributes(handleIDAttribute.String(HandleID(ctx)))
+	return ctx
+}
+
+// appendHandleIDAttribute appends the handle ID carried by ctx, if any, to
+// attrs.
+func appendHandleIDAttribute(ctx context.Context, attrs []attribute.KeyValue) []attribute.KeyValue {
+	if id := HandleID(ctx); id != "" {
+		attrs = append(attrs, handleIDAttribute.String(id))
+	}
+	return attrs
+}
+
+// slowSpan buffers what an operation records on its span. When it ends,
+// the span is created with the operation's start and end timestamps if the
+// operation took at least min, and dropped otherwise, so fast operations
+// never reach the tracer. The operation's context does not carry the span.
+type slowSpan struct {
+	tracenoop.Span
+
+	tracer trace.Tracer
+	min    time.Duration
+	ctx    context.Context
+	name   string
+	clock  Clock
+	start  time.Time
+
+	attrs       []attribute.KeyValue
+	errs        []error
+	status      codes.Code
+	description string
+}
+
+func (s *slowSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.attrs = append(s.attrs, kv...)
+}
+
+func (s *slowSpan) RecordError(err error, options ...trace.EventOption) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *slowSpan) SetStatus(code codes.Code, description string) {
+	s.status = code
+	s.description = description
+}
+
+func (s *slowSpan) End(options ...trace.SpanEndOption) {
+	end := s.clock.Now()
+	if end.Sub(s.start) < s.min {
+		return
+	}
+
+	_, span := s.tracer.Start(s.ctx, s.name,
+		trace.WithTimestamp(s.start),
+		trace.WithAttributes(s.attrs...),
+	)
+	for _, err := range s.errs {
+		span.RecordError(err, trace.WithTimestamp(end))
+	}
+	if s.status != codes.Unset {
+		span.SetStatus(s.status, s.description)
+	}
+	span.End(trace.WithTimestamp(end))
+}
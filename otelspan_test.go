@@ -0,0 +1,36 @@
+package metricsfs
+
+import (
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestOTelMinSpanDuration(t *testing.T) {
+	tp := &recordingTracerProvider{}
+	fs, err := NewWithOTel(&busyStatMockFS{newMockFS()}, OTelConfig{
+		MeterProvider:   noop.NewMeterProvider(),
+		TracerProvider:  tp,
+		EnableTracing:   true,
+		MinSpanDuration: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	// Mkdir returns immediately; Stat busy-waits for 20ms.
+	fs.Mkdir("/dir", 0755)
+	fs.Stat("/dir")
+
+	if len(tp.spans) != 1 {
+		t.Fatalf("Expected only the slow operation to be traced, got %d spans", len(tp.spans))
+	}
+	span := tp.spans[0]
+	if span.name != "Stat" || !span.ended {
+		t.Errorf("Expected an ended Stat span, got %q (ended %v)", span.name, span.ended)
+	}
+	if got := span.attributes["fs.path"].AsString(); got != "/dir" {
+		t.Errorf("Expected fs.path /dir, got %q", got)
+	}
+}
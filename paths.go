@@ -0,0 +1,93 @@
+package metricsfs
+
+import (
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// isAbsPath reports whether name is absolute, either as a slash-separated
+// absfs path or as a path of the host operating system.
+func isAbsPath(name string) bool {
+	return path.IsAbs(name) || filepath.IsAbs(name)
+}
+
+// metricPath records whether name, passed to op, is absolute or relative,
+// and returns the path to record op under: name resolved against the
+// working directory with ResolveRelativePaths, and name itself otherwise.
+func (m *MetricsFS) metricPath(op Op, name string) string {
+	if name == "" || !m.collector.measured(op) {
+		return name
+	}
+
+	abs := isAbsPath(name)
+	m.collector.recordPathKind(op, abs)
+	if abs {
+		return name
+	}
+	return m.resolvePath(name)
+}
+
+// resolvePath returns name resolved against the working directory of the
+// wrapped filesystem when ResolveRelativePaths is set. Paths are returned
+// unchanged when they are absolute or the working directory is unknown.
+func (m *MetricsFS) resolvePath(name string) string {
+	if !m.collector.config.ResolveRelativePaths || name == "" || isAbsPath(name) {
+		return name
+	}
+
+	dir := m.wd.get(m.fs)
+	switch {
+	case dir == "":
+		return name
+	case path.IsAbs(dir):
+		return path.Join(dir, name)
+	default:
+		return filepath.Join(dir, name)
+	}
+}
+
+// workingDir caches the working directory of a wrapped filesystem. It is
+// shared by the copies of a MetricsFS made with WithContext, which share the
+// filesystem and therefore its working directory.
+type workingDir struct {
+	mu    sync.Mutex
+	dir   string
+	valid bool
+}
+
+// get returns the working directory of fs, asking fs only when the cached
+// value was invalidated. It returns "" if fs does not implement Getwd.
+func (w *workingDir) get(fs any) string {
+	if w == nil {
+		return ""
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.valid {
+		w.dir = ""
+		if g, ok := fs.(interface {
+			Getwd() (string, error)
+		}); ok {
+			if dir, err := g.Getwd(); err == nil {
+				w.dir = dir
+			}
+		}
+		w.valid = true
+	}
+	return w.dir
+}
+
+// invalidate makes the next get ask the filesystem again, after the
+// working directory changed.
+func (w *workingDir) invalidate() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	w.valid = false
+	w.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+package metricsfs
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPathKindMetrics(t *testing.T) {
+	fs := New(newMockFS())
+
+	fs.Stat("/abs/file.txt")
+	fs.Stat("rel/file.txt")
+	fs.Stat("./file.txt")
+
+	c := fs.collector
+	if v := testutil.ToFloat64(c.pathKindTotal.WithLabelValues("stat", "absolute")); v != 1 {
+		t.Errorf("Expected 1 absolute stat, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.pathKindTotal.WithLabelValues("stat", "relative")); v != 2 {
+		t.Errorf("Expected 2 relative stats, got %v", v)
+	}
+}
+
+func TestResolveRelativePaths(t *testing.T) {
+	var paths []string
+	config := DefaultConfig()
+	config.ResolveRelativePaths = true
+	config.OnOperation = func(op Operation) { paths = append(paths, op.Path) }
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.Stat("data/a.txt")
+	fs.Chdir("/srv")
+	fs.Stat("data/a.txt")
+	fs.Stat("/srv/data/a.txt")
+
+	f, err := fs.Open("b.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Read(make([]byte, 1))
+
+	want := []string{"/data/a.txt", "/srv", "/srv/data/a.txt", "/srv/data/a.txt", "/srv/b.txt", "/srv/b.txt"}
+	if len(paths) != len(want) {
+		t.Fatalf("Expected paths %v, got %v", want, paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("Operation %d: expected path %q, got %q", i, want[i], paths[i])
+		}
+	}
+}
+
+func TestRelativePathsUnresolvedByDefault(t *testing.T) {
+	var path string
+	config := DefaultConfig()
+	config.OnOperation = func(op Operation) { path = op.Path }
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.Stat("data/a.txt")
+	if path != "data/a.txt" {
+		t.Errorf("Expected relative path to be kept, got %q", path)
+	}
+}
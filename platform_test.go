@@ -0,0 +1,64 @@
+package metricsfs
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCapacityMetrics(t *testing.T) {
+	if _, _, ok := diskCapacity(t.TempDir()); !ok {
+		t.Skipf("filesystem capacity is not supported on %s", runtime.GOOS)
+	}
+
+	config := DefaultConfig()
+	config.CapacityPath = t.TempDir()
+	c := NewCollector(config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	if n, err := testutil.GatherAndCount(registry, "fs_capacity_bytes", "fs_free_bytes"); err != nil || n != 2 {
+		t.Fatalf("Expected capacity and free bytes, got %d metrics (%v)", n, err)
+	}
+
+	total, free, _ := diskCapacity(config.CapacityPath)
+	if total == 0 || free > total {
+		t.Errorf("Implausible capacity: total %d, free %d", total, free)
+	}
+}
+
+func TestCapacityMetricsDisabled(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewCollector(DefaultConfig()))
+
+	if n, err := testutil.GatherAndCount(registry, "fs_capacity_bytes", "fs_free_bytes"); err != nil || n != 0 {
+		t.Errorf("Expected no capacity metrics without CapacityPath, got %d (%v)", n, err)
+	}
+}
+
+func TestOpenFilesLimit(t *testing.T) {
+	limit, ok := openFilesLimit()
+	if !ok {
+		t.Skipf("open file limit is not supported on %s", runtime.GOOS)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewCollector(DefaultConfig()))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == "fs_open_files_limit" {
+			if v := mf.GetMetric()[0].GetGauge().GetValue(); v != float64(limit) {
+				t.Errorf("Expected limit %d, got %v", limit, v)
+			}
+			return
+		}
+	}
+	t.Error("fs_open_files_limit not exported")
+}
@@ -0,0 +1,272 @@
+package metricsfs
+
+import (
+	"errors"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrProfileActive is returned by ProfileFor while another profiling window
+// is in progress.
+var ErrProfileActive = errors.New("metricsfs: profiling window already active")
+
+// Bounds on what a profiling window keeps; entries beyond them are counted
+// in ProfileReport.Dropped.
+const (
+	maxProfilePaths   = 1000
+	maxProfileHandles = 1000
+	maxProfileStacks  = 100
+)
+
+// ProfileReport is the detail collected during a ProfileFor window,
+// independently of the collector's configuration.
+type ProfileReport struct {
+	// Start and End delimit the window
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Instance identifies the filesystem profiled. See Collector.Instance
+	Instance string `json:"instance,omitempty"`
+
+	// Operations holds totals per operation name
+	Operations map[string]ProfileStats `json:"operations"`
+
+	// Paths holds totals per path, most accessed first
+	Paths []ProfilePath `json:"paths"`
+
+	// Handles holds the files opened during the window, in open order.
+	// Handles still open when the window ended have a zero Closed time
+	Handles []ProfileHandle `json:"handles"`
+
+	// Dropped is the number of paths and handles not kept because the
+	// report's bounds were reached
+	Dropped int64 `json:"dropped"`
+}
+
+// ProfileStats holds the totals for an operation or path.
+type ProfileStats struct {
+	// Count is the number of operations
+	Count int64 `json:"count"`
+
+	// Errors is the number of operations that failed
+	Errors int64 `json:"errors"`
+
+	// Bytes is the number of bytes transferred
+	Bytes int64 `json:"bytes"`
+
+	// Duration is the total time spent in the operations
+	Duration time.Duration `json:"duration"`
+
+	// MaxDuration is the duration of the slowest operation
+	MaxDuration time.Duration `json:"max_duration"`
+}
+
+// ProfilePath holds the totals for one path.
+type ProfilePath struct {
+	Path string `json:"path"`
+	ProfileStats
+}
+
+// ProfileHandle describes a file handle opened during a profiling window.
+type ProfileHandle struct {
+	// ID is the handle's ID, with Config.EnableHandleIDs
+	ID string `json:"id,omitempty"`
+
+	// Path is the path the handle was opened with
+	Path string `json:"path"`
+
+	// Opened and Closed are when the handle was opened and closed
+	Opened time.Time `json:"opened"`
+	Closed time.Time `json:"closed"`
+
+	// Reads and Writes count the reads and writes that transferred data
+	Reads  int64 `json:"reads"`
+	Writes int64 `json:"writes"`
+
+	// BytesRead and BytesWritten are the bytes transferred by the handle
+	BytesRead    int64 `json:"bytes_read"`
+	BytesWritten int64 `json:"bytes_written"`
+
+	// Stack is the stack trace of the open. Only the first opens of a
+	// window have one
+	Stack string `json:"stack,omitempty"`
+}
+
+// ProfileFor collects maximum-detail data for duration, whatever the
+// collector is configured to record: per-operation and per-path totals,
+// per-handle transfer counts and the stack traces of opens. It blocks until
+// the window ends, or the collector is closed, and returns the report; the
+// cost of the collection is only paid during the window. Only one window
+// may be active at a time.
+func (c *Collector) ProfileFor(duration time.Duration) (ProfileReport, error) {
+	p := &profileWindow{
+		clock:      c.clock,
+		start:      c.clock.Now(),
+		operations: make(map[string]*ProfileStats),
+		paths:      make(map[string]*ProfileStats),
+	}
+	if !c.profile.CompareAndSwap(nil, p) {
+		return ProfileReport{}, ErrProfileActive
+	}
+	c.recordModeTransition(ModeProfiling, "started")
+
+	timer := time.NewTimer(duration)
+	select {
+	case <-timer.C:
+	case <-c.done:
+		timer.Stop()
+	}
+
+	c.profile.Store(nil)
+	c.recordModeTransition(ModeProfiling, "finished")
+	report := p.report()
+	report.Instance = c.instance
+	return report, nil
+}
+
+// profileWindow accumulates the data of an active ProfileFor window.
+type profileWindow struct {
+	clock Clock
+	start time.Time
+
+	mu         sync.Mutex
+	operations map[string]*ProfileStats
+	paths      map[string]*ProfileStats
+	handles    []*profileHandle
+	dropped    int64
+}
+
+// record adds an operation to the window.
+func (p *profileWindow) record(op Op, path string, duration time.Duration, bytes int64, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.operations[string(op)]
+	if stats == nil {
+		stats = &ProfileStats{}
+		p.operations[string(op)] = stats
+	}
+	stats.add(duration, bytes, err)
+
+	if path == "" {
+		return
+	}
+	stats = p.paths[path]
+	if stats == nil {
+		if len(p.paths) >= maxProfilePaths {
+			p.dropped++
+			return
+		}
+		stats = &ProfileStats{}
+		p.paths[path] = stats
+	}
+	stats.add(duration, bytes, err)
+}
+
+// openHandle starts tracking a handle opened on path, or returns nil once
+// maxProfileHandles are tracked.
+func (p *profileWindow) openHandle(id, path string) *profileHandle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.handles) >= maxProfileHandles {
+		p.dropped++
+		return nil
+	}
+	h := &profileHandle{id: id, path: path, opened: p.clock.Now()}
+	if len(p.handles) < maxProfileStacks {
+		h.stack = string(debug.Stack())
+	}
+	p.handles = append(p.handles, h)
+	return h
+}
+
+// report returns the window's data as a ProfileReport.
+func (p *profileWindow) report() ProfileReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	report := ProfileReport{
+		Start:      p.start,
+		End:        p.clock.Now(),
+		Operations: make(map[string]ProfileStats, len(p.operations)),
+		Paths:      make([]ProfilePath, 0, len(p.paths)),
+		Handles:    make([]ProfileHandle, 0, len(p.handles)),
+		Dropped:    p.dropped,
+	}
+	for op, stats := range p.operations {
+		report.Operations[op] = *stats
+	}
+	for path, stats := range p.paths {
+		report.Paths = append(report.Paths, ProfilePath{Path: path, ProfileStats: *stats})
+	}
+	sort.Slice(report.Paths, func(i, j int) bool {
+		if report.Paths[i].Count != report.Paths[j].Count {
+			return report.Paths[i].Count > report.Paths[j].Count
+		}
+		return report.Paths[i].Path < report.Paths[j].Path
+	})
+	for _, h := range p.handles {
+		report.Handles = append(report.Handles, h.snapshot())
+	}
+	return report
+}
+
+// add adds one operation to s.
+func (s *ProfileStats) add(duration time.Duration, bytes int64, err error) {
+	s.Count++
+	if err != nil {
+		s.Errors++
+	}
+	s.Bytes += bytes
+	s.Duration += duration
+	if duration > s.MaxDuration {
+		s.MaxDuration = duration
+	}
+}
+
+// profileHandle tracks the transfers of one handle during a window. Its
+// counters are updated by the handle and read when the report is built.
+type profileHandle struct {
+	id     string
+	path   string
+	opened time.Time
+	stack  string
+
+	closed       atomic.Int64 // unix nanoseconds, 0 while open
+	reads        atomic.Int64
+	writes       atomic.Int64
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+}
+
+// record adds a read or write of n bytes.
+func (h *profileHandle) record(op Op, n int) {
+	if op == OpWrite {
+		h.writes.Add(1)
+		h.bytesWritten.Add(int64(n))
+	} else {
+		h.reads.Add(1)
+		h.bytesRead.Add(int64(n))
+	}
+}
+
+func (h *profileHandle) snapshot() ProfileHandle {
+	handle := ProfileHandle{
+		ID:           h.id,
+		Path:         h.path,
+		Opened:       h.opened,
+		Reads:        h.reads.Load(),
+		Writes:       h.writes.Load(),
+		BytesRead:    h.bytesRead.Load(),
+		BytesWritten: h.bytesWritten.Load(),
+		Stack:        h.stack,
+	}
+	if closed := h.closed.Load(); closed != 0 {
+		handle.Closed = time.Unix(0, closed)
+	}
+	return handle
+}
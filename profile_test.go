@@ -0,0 +1,111 @@
+package metricsfs
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/absfs/osfs"
+)
+
+func TestProfileFor(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	fs := New(base)
+	collector := fs.Collector()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data")
+
+	// Operations before the window are not part of the report
+	fs.Stat(dir)
+
+	reports := make(chan ProfileReport)
+	go func() {
+		report, err := collector.ProfileFor(100 * time.Millisecond)
+		if err != nil {
+			t.Errorf("ProfileFor failed: %v", err)
+		}
+		reports <- report
+	}()
+	for collector.profile.Load() == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := collector.ProfileFor(time.Millisecond); !errors.Is(err, ErrProfileActive) {
+		t.Errorf("Expected ErrProfileActive for a concurrent window, got %v", err)
+	}
+
+	f, err := fs.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+	fs.Stat(path)
+	fs.Stat(filepath.Join(dir, "missing"))
+
+	report := <-reports
+
+	if got := report.Operations[string(OpStat)]; got.Count != 2 || got.Errors != 1 {
+		t.Errorf("Expected 2 stats with 1 error, got %+v", got)
+	}
+	if got := report.Operations[string(OpWrite)]; got.Count != 1 || got.Bytes != 5 {
+		t.Errorf("Expected 1 write of 5 bytes, got %+v", got)
+	}
+	if len(report.Paths) == 0 || report.Paths[0].Path != path {
+		t.Fatalf("Expected %s to be the most accessed path, got %+v", path, report.Paths)
+	}
+	for _, p := range report.Paths {
+		if p.Path == dir {
+			t.Errorf("Expected the stat before the window to be excluded")
+		}
+	}
+
+	if len(report.Handles) != 1 {
+		t.Fatalf("Expected 1 handle, got %d", len(report.Handles))
+	}
+	h := report.Handles[0]
+	if h.Path != path || h.Writes != 1 || h.BytesWritten != 5 || h.Closed.IsZero() {
+		t.Errorf("Unexpected handle %+v", h)
+	}
+	if !strings.Contains(h.Stack, "TestProfileFor") {
+		t.Errorf("Expected the open's stack to include the test, got %q", h.Stack)
+	}
+
+	var states []string
+	for _, tr := range collector.ModeTransitions() {
+		if tr.Mode == ModeProfiling {
+			states = append(states, tr.State)
+		}
+	}
+	if strings.Join(states, ",") != "started,finished" {
+		t.Errorf("Expected profiling started and finished transitions, got %v", states)
+	}
+	if collector.profile.Load() != nil {
+		t.Error("Expected profiling to end with the window")
+	}
+}
+
+func TestProfileForClose(t *testing.T) {
+	collector := New(newMockFS()).Collector()
+
+	done := make(chan struct{})
+	go func() {
+		collector.ProfileFor(time.Hour)
+		close(done)
+	}()
+	for collector.profile.Load() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	collector.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to end the profiling window")
+	}
+}
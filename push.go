@@ -0,0 +1,117 @@
+package metricsfs
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PushConfig configures a pusher started with Collector.StartPusher.
+type PushConfig struct {
+	// URL is the address of the Pushgateway, e.g. "http://pushgateway:9091"
+	URL string
+
+	// Job is the job label of the pushed group (default: "metricsfs")
+	Job string
+
+	// Instance is the instance label of the pushed group
+	// (default: Config.Instance, or none if empty)
+	Instance string
+
+	// Grouping holds further labels of the pushed group, e.g. a batch ID
+	Grouping map[string]string
+
+	// Interval is the interval between pushes (default: 15s)
+	Interval time.Duration
+
+	// Timeout bounds each push, including the final one, which Close and
+	// stop wait for (default: 10s)
+	Timeout time.Duration
+
+	// Client sends the pushes (default: http.DefaultClient)
+	Client *http.Client
+
+	// OnError is called with the error of each failed push
+	OnError func(err error)
+}
+
+// applyDefaults fills in the zero fields of config.
+func (config *PushConfig) applyDefaults(c *Collector) {
+	if config.Job == "" {
+		config.Job = "metricsfs"
+	}
+	if config.Instance == "" {
+		config.Instance = c.config.Instance
+	}
+	if config.Interval <= 0 {
+		config.Interval = 15 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+}
+
+// StartPusher pushes the collector's metrics to the Pushgateway at
+// config.URL every interval, replacing the group of its job, instance and
+// grouping labels, until the returned stop function is called or the
+// collector is closed. Short-lived programs such as batch jobs, which are
+// never scraped, are pushed once more when the pusher stops, so that their
+// totals are not lost. Both stop and Close wait for the final push.
+func (c *Collector) StartPusher(config PushConfig) (stop func()) {
+	config.applyDefaults(c)
+
+	pusher := push.New(config.URL, config.Job).Collector(c).Client(config.Client)
+	if config.Instance != "" {
+		pusher = pusher.Grouping("instance", config.Instance)
+	}
+	for name, value := range config.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	var once sync.Once
+
+	if !c.addBackground() {
+		return func() {}
+	}
+	go func() {
+		defer c.background.Done()
+		defer close(stopped)
+
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		emit := func() {
+			ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+			defer cancel()
+			if err := pusher.PushContext(ctx); err != nil && config.OnError != nil {
+				config.OnError(err)
+			}
+		}
+
+		for {
+			select {
+			case <-ticker.C:
+				emit()
+			case <-stopCh:
+				emit()
+				return
+			case <-c.done:
+				emit()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stopCh) })
+		<-stopped
+	}
+}
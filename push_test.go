@@ -0,0 +1,125 @@
+package metricsfs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pushRecorder is a Pushgateway recording the pushes it receives.
+type pushRecorder struct {
+	mu     sync.Mutex
+	pushes []string // method and path
+	bodies []string
+}
+
+func (p *pushRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	p.mu.Lock()
+	p.pushes = append(p.pushes, r.Method+" "+r.URL.Path)
+	p.bodies = append(p.bodies, string(body))
+	p.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p *pushRecorder) received() ([]string, []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.pushes...), append([]string(nil), p.bodies...)
+}
+
+func TestPusherFinalPushOnStop(t *testing.T) {
+	gateway := &pushRecorder{}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	fs := New(newMockFS())
+	stop := fs.Collector().StartPusher(PushConfig{
+		URL:      server.URL,
+		Job:      "backup",
+		Instance: "host-1",
+		Grouping: map[string]string{"batch": "42"},
+		Interval: time.Hour,
+	})
+	fs.Stat("/a.txt")
+	stop()
+	stop() // idempotent
+
+	pushes, bodies := gateway.received()
+	if len(pushes) != 1 {
+		t.Fatalf("Expected 1 push, got %v", pushes)
+	}
+	// The Pushgateway client orders grouping labels at random
+	method, grouping, ok := strings.Cut(pushes[0], " /metrics/job/backup/")
+	segments := strings.Split(grouping, "/")
+	if !ok || method != "PUT" || len(segments) != 4 {
+		t.Fatalf("push = %q, want PUT of job backup with 2 grouping labels", pushes[0])
+	}
+	labels := map[string]string{segments[0]: segments[1], segments[2]: segments[3]}
+	if labels["instance"] != "host-1" || labels["batch"] != "42" {
+		t.Errorf("grouping = %v, want instance host-1 and batch 42", labels)
+	}
+	if !strings.Contains(bodies[0], "fs_operations_total") {
+		t.Error("Expected the collector's metrics to be pushed")
+	}
+}
+
+func TestPusherPushesPeriodicallyAndOnClose(t *testing.T) {
+	gateway := &pushRecorder{}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	c := NewCollector(DefaultConfig())
+	c.StartPusher(PushConfig{URL: server.URL, Interval: 10 * time.Millisecond})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if pushes, _ := gateway.received(); len(pushes) >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected periodic pushes")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	before, _ := gateway.received()
+	c.Close()
+	after, _ := gateway.received()
+	if len(after) <= len(before) {
+		t.Error("Expected a final push on Close")
+	}
+	if after[0] != "PUT /metrics/job/metricsfs" {
+		t.Errorf("push = %q, want the default job without instance", after[0])
+	}
+}
+
+func TestPusherReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var errs []error
+	c := NewCollector(DefaultConfig())
+	stop := c.StartPusher(PushConfig{
+		URL:      server.URL,
+		Interval: time.Hour,
+		OnError:  func(err error) { errs = append(errs, err) },
+	})
+	stop()
+
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 push error, got %v", errs)
+	}
+}
+
+func TestPusherAfterClose(t *testing.T) {
+	c := NewCollector(DefaultConfig())
+	c.Close()
+	c.StartPusher(PushConfig{URL: "http://127.0.0.1:0"})()
+}
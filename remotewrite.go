@@ -0,0 +1,258 @@
+package metricsfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWriteConfig configures a remote writer started with
+// Collector.StartRemoteWriter.
+type RemoteWriteConfig struct {
+	// URL is the remote_write endpoint, e.g.
+	// "http://prometheus:9090/api/v1/write"
+	URL string
+
+	// ExternalLabels are added to every series written, e.g. a job label
+	ExternalLabels map[string]string
+
+	// Headers are set on every request, e.g. an Authorization header
+	Headers map[string]string
+
+	// Interval is the interval between writes (default: 15s)
+	Interval time.Duration
+
+	// Timeout bounds each write, including the final one, which Close and
+	// stop wait for (default: 10s)
+	Timeout time.Duration
+
+	// Client sends the writes (default: http.DefaultClient)
+	Client *http.Client
+
+	// OnError is called with the error of each failed write
+	OnError func(err error)
+}
+
+// applyDefaults fills in the zero fields of config.
+func (config *RemoteWriteConfig) applyDefaults() {
+	if config.Interval <= 0 {
+		config.Interval = 15 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+}
+
+// StartRemoteWriter sends the collector's metrics to the Prometheus
+// remote_write endpoint at config.URL every interval, until the returned stop
+// function is called or the collector is closed. It serves workers that are
+// neither scraped nor able to reach a Pushgateway. As with StartPusher, a
+// final write is sent when the writer stops, and both stop and Close wait
+// for it.
+func (c *Collector) StartRemoteWriter(config RemoteWriteConfig) (stop func()) {
+	config.applyDefaults()
+
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	var once sync.Once
+
+	if !c.addBackground() {
+		return func() {}
+	}
+	go func() {
+		defer c.background.Done()
+		defer close(stopped)
+
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		emit := func() {
+			ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+			defer cancel()
+			if err := c.remoteWrite(ctx, config); err != nil && config.OnError != nil {
+				config.OnError(err)
+			}
+		}
+
+		for {
+			select {
+			case <-ticker.C:
+				emit()
+			case <-stopCh:
+				emit()
+				return
+			case <-c.done:
+				emit()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stopCh) })
+		<-stopped
+	}
+}
+
+// remoteWrite sends a single write request with the collector's current
+// metrics.
+func (c *Collector) remoteWrite(ctx context.Context, config RemoteWriteConfig) error {
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		return fmt.Errorf("metricsfs: remote write: %w", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return fmt.Errorf("metricsfs: remote write: %w", err)
+	}
+
+	body := encodeWriteRequest(families, config.ExternalLabels, time.Now().UnixMilli())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(snappyEncode(body)))
+	if err != nil {
+		return fmt.Errorf("metricsfs: remote write: %w", err)
+	}
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("metricsfs: remote write: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("metricsfs: remote write: unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes families as a remote-write WriteRequest
+// protobuf message with every sample at timestamp ms. Histograms and
+// summaries are flattened into their _bucket, _sum and _count (or quantile)
+// series, as they are in the text exposition format.
+func encodeWriteRequest(families []*dto.MetricFamily, external map[string]string, ms int64) []byte {
+	var buf []byte
+	series := func(name string, labels []*dto.LabelPair, value float64, extra ...string) {
+		pairs := map[string]string{"__name__": name}
+		for k, v := range external {
+			pairs[k] = v
+		}
+		for _, l := range labels {
+			pairs[l.GetName()] = l.GetValue()
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			pairs[extra[i]] = extra[i+1]
+		}
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, encodeTimeSeries(pairs, value, ms))
+	}
+
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			labels := m.GetLabel()
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series(name, labels, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				series(name, labels, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				series(name, labels, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					series(name+"_bucket", labels, float64(b.GetCumulativeCount()), "le", formatFloat(b.GetUpperBound()))
+				}
+				series(name+"_bucket", labels, float64(h.GetSampleCount()), "le", "+Inf")
+				series(name+"_sum", labels, h.GetSampleSum())
+				series(name+"_count", labels, float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					series(name, labels, q.GetValue(), "quantile", formatFloat(q.GetQuantile()))
+				}
+				series(name+"_sum", labels, s.GetSampleSum())
+				series(name+"_count", labels, float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return buf
+}
+
+// encodeTimeSeries encodes a remote-write TimeSeries message with a single
+// sample. Labels are written sorted by name, as the protocol requires.
+func encodeTimeSeries(labels map[string]string, value float64, ms int64) []byte {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, labels[name])
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, label)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(ms))
+	buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+	return protowire.AppendBytes(buf, sample)
+}
+
+// formatFloat formats a bucket bound or quantile as Prometheus does.
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// snappyEncode encodes src in the snappy block format remote write requires.
+// The data is stored as literals only, which every snappy decoder accepts;
+// write requests are small and sent rarely enough that compressing them is
+// not worth a dependency.
+func snappyEncode(src []byte) []byte {
+	dst := protowire.AppendVarint(nil, uint64(len(src)))
+	const maxLiteral = 1 << 16
+	for len(src) > 0 {
+		n := min(len(src), maxLiteral)
+		switch {
+		case n <= 60:
+			dst = append(dst, byte(n-1)<<2)
+		case n <= 1<<8:
+			dst = append(dst, 60<<2, byte(n-1))
+		default:
+			dst = append(dst, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+	return dst
+}
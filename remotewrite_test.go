@@ -0,0 +1,227 @@
+package metricsfs
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// writeSeries is a decoded remote-write TimeSeries with a single sample.
+type writeSeries struct {
+	labels map[string]string
+	value  float64
+	ms     int64
+}
+
+// writeRecorder is a remote_write endpoint recording the series it
+// receives.
+type writeRecorder struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	series   [][]writeSeries
+}
+
+func (w *writeRecorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	series := decodeWriteRequest(snappyDecode(body))
+	w.mu.Lock()
+	w.requests = append(w.requests, r)
+	w.series = append(w.series, series)
+	w.mu.Unlock()
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (w *writeRecorder) received() ([]*http.Request, [][]writeSeries) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]*http.Request(nil), w.requests...), append([][]writeSeries(nil), w.series...)
+}
+
+// snappyDecode decodes the literal-only snappy blocks written by
+// snappyEncode.
+func snappyDecode(src []byte) []byte {
+	n, l := protowire.ConsumeVarint(src)
+	src = src[l:]
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := int(src[0] >> 2)
+		src = src[1:]
+		switch tag {
+		case 60:
+			tag = int(src[0])
+			src = src[1:]
+		case 61:
+			tag = int(src[0]) | int(src[1])<<8
+			src = src[2:]
+		}
+		dst = append(dst, src[:tag+1]...)
+		src = src[tag+1:]
+	}
+	return dst
+}
+
+// decodeWriteRequest decodes the series of a WriteRequest message.
+func decodeWriteRequest(b []byte) []writeSeries {
+	var result []writeSeries
+	for len(b) > 0 {
+		_, _, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		ts, n := protowire.ConsumeBytes(b)
+		b = b[n:]
+
+		s := writeSeries{labels: map[string]string{}}
+		for len(ts) > 0 {
+			num, _, n := protowire.ConsumeTag(ts)
+			ts = ts[n:]
+			msg, n := protowire.ConsumeBytes(ts)
+			ts = ts[n:]
+			if num == 1 {
+				var name, value string
+				for len(msg) > 0 {
+					field, _, n := protowire.ConsumeTag(msg)
+					msg = msg[n:]
+					v, n := protowire.ConsumeString(msg)
+					msg = msg[n:]
+					if field == 1 {
+						name = v
+					} else {
+						value = v
+					}
+				}
+				s.labels[name] = value
+				continue
+			}
+			_, _, n = protowire.ConsumeTag(msg)
+			bits, n2 := protowire.ConsumeFixed64(msg[n:])
+			msg = msg[n+n2:]
+			_, _, n = protowire.ConsumeTag(msg)
+			ms, _ := protowire.ConsumeVarint(msg[n:])
+			s.value = math.Float64frombits(bits)
+			s.ms = int64(ms)
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+func TestRemoteWriterFinalWriteOnStop(t *testing.T) {
+	endpoint := &writeRecorder{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	fs := New(newMockFS())
+	stop := fs.Collector().StartRemoteWriter(RemoteWriteConfig{
+		URL:            server.URL + "/api/v1/write",
+		ExternalLabels: map[string]string{"job": "worker"},
+		Headers:        map[string]string{"Authorization": "Bearer token"},
+		Interval:       time.Hour,
+	})
+	fs.Stat("/a.txt")
+	stop()
+	stop() // idempotent
+
+	requests, writes := endpoint.received()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 write, got %d", len(requests))
+	}
+	req := requests[0]
+	if req.Method != http.MethodPost || req.URL.Path != "/api/v1/write" {
+		t.Errorf("write = %s %s", req.Method, req.URL.Path)
+	}
+	for name, want := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+		"Authorization":                     "Bearer token",
+	} {
+		if got := req.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	var stat, buckets bool
+	for _, s := range writes[0] {
+		if s.labels["job"] != "worker" || s.ms == 0 {
+			t.Errorf("unexpected series %+v", s)
+		}
+		switch s.labels["__name__"] {
+		case "fs_operations_total":
+			if s.labels["operation"] == "stat" && s.labels["status"] == "success" && s.value == 1 {
+				stat = true
+			}
+		case "fs_operation_duration_seconds_bucket":
+			if s.labels["operation"] == "stat" && s.labels["le"] == "+Inf" && s.value == 1 {
+				buckets = true
+			}
+		}
+	}
+	if !stat {
+		t.Error("Expected the stat counter to be written")
+	}
+	if !buckets {
+		t.Error("Expected the stat latency histogram to be written")
+	}
+}
+
+func TestRemoteWriterWritesPeriodicallyAndOnClose(t *testing.T) {
+	endpoint := &writeRecorder{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	c := NewCollector(DefaultConfig())
+	c.StartRemoteWriter(RemoteWriteConfig{URL: server.URL, Interval: 10 * time.Millisecond})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if requests, _ := endpoint.received(); len(requests) >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected periodic writes")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	before, _ := endpoint.received()
+	c.Close()
+	if after, _ := endpoint.received(); len(after) <= len(before) {
+		t.Error("Expected a final write on Close")
+	}
+}
+
+func TestRemoteWriterReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var errs []error
+	c := NewCollector(DefaultConfig())
+	stop := c.StartRemoteWriter(RemoteWriteConfig{
+		URL:      server.URL,
+		Interval: time.Hour,
+		OnError:  func(err error) { errs = append(errs, err) },
+	})
+	stop()
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "out of order sample") {
+		t.Errorf("Expected 1 write error with the response body, got %v", errs)
+	}
+}
+
+func TestSnappyEncode(t *testing.T) {
+	for _, n := range []int{0, 1, 60, 61, 256, 257, 1 << 16, 1<<16 + 1, 200000} {
+		src := bytes.Repeat([]byte("metricsfs"), n/9+1)[:n]
+		if got := snappyDecode(snappyEncode(src)); !bytes.Equal(got, src) {
+			t.Errorf("round trip of %d bytes failed", n)
+		}
+	}
+}
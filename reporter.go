@@ -0,0 +1,93 @@
+package metricsfs
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ReportFunc receives the current stats and the change since the previous
+// report. For the first report, delta is relative to when the reporter was
+// started.
+type ReportFunc func(current, delta Stats)
+
+// StartReporter calls report every interval with the collector's stats until
+// the returned stop function is called or the collector is closed. A final
+// report is emitted when the reporter stops, so short-lived programs such as
+// CLIs and batch jobs always report their totals. Both stop and Close wait
+// for the final report.
+func (c *Collector) StartReporter(interval time.Duration, report ReportFunc) (stop func()) {
+	if !c.addBackground() {
+		return func() {}
+	}
+
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	var once sync.Once
+
+	previous := c.Stats()
+
+	go func() {
+		defer c.background.Done()
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		emit := func() {
+			current := c.Stats()
+			report(current, current.Delta(previous))
+			previous = current
+		}
+
+		for {
+			select {
+			case <-ticker.C:
+				emit()
+			case <-stopCh:
+				emit()
+				return
+			case <-c.done:
+				emit()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stopCh) })
+		<-stopped
+	}
+}
+
+// TextReporter returns a ReportFunc that writes a one-line summary of each
+// interval to w.
+func TextReporter(w io.Writer) ReportFunc {
+	return func(current, delta Stats) {
+		var ops, errs int64
+		for _, op := range delta.Operations {
+			ops += op.Count
+			errs += op.Errors
+		}
+		fmt.Fprintf(w, "%s ops=%d errors=%d read=%dB written=%dB open_files=%d in_flight=%d\n",
+			current.Timestamp.Format(time.RFC3339), ops, errs,
+			delta.BytesRead, delta.BytesWritten, current.OpenFiles, current.InFlight)
+	}
+}
+
+// Delta returns the change in counters from previous to s. Gauges such as
+// OpenFiles and InFlight are taken from s.
+func (s Stats) Delta(previous Stats) Stats {
+	delta := s
+	delta.Operations = make(map[string]OperationStats, len(s.Operations))
+	for name, op := range s.Operations {
+		prev := previous.Operations[name]
+		if d := (OperationStats{Count: op.Count - prev.Count, Errors: op.Errors - prev.Errors}); d.Count != 0 || d.Errors != 0 {
+			delta.Operations[name] = d
+		}
+	}
+	delta.BytesRead -= previous.BytesRead
+	delta.BytesWritten -= previous.BytesWritten
+	return delta
+}
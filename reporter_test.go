@@ -0,0 +1,96 @@
+package metricsfs
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartReporter(t *testing.T) {
+	fs := New(newMockFS())
+
+	var mu sync.Mutex
+	var deltas []Stats
+	stop := fs.Collector().StartReporter(time.Hour, func(current, delta Stats) {
+		mu.Lock()
+		deltas = append(deltas, delta)
+		mu.Unlock()
+	})
+
+	fs.Stat("/a")
+	fs.Stat("/b")
+	stop()
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(deltas) != 1 {
+		t.Fatalf("Expected 1 final report, got %d", len(deltas))
+	}
+	if deltas[0].Operations["stat"].Count != 2 {
+		t.Errorf("Expected 2 stats in delta, got %d", deltas[0].Operations["stat"].Count)
+	}
+}
+
+func TestReporterStopsOnClose(t *testing.T) {
+	fs := New(newMockFS())
+
+	var buf bytes.Buffer
+	fs.Collector().StartReporter(time.Hour, TextReporter(&buf))
+	fs.Stat("/a")
+	fs.Collector().Close()
+
+	if out := buf.String(); !strings.Contains(out, "ops=1") {
+		t.Errorf("Expected final report with 1 operation, got %q", out)
+	}
+}
+
+func TestReporterStartedDuringClose(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		c := NewCollector(DefaultConfig())
+		var closed atomic.Bool
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.StartReporter(time.Hour, func(current, delta Stats) {
+				if closed.Load() {
+					t.Error("Expected the final report before Close returned")
+				}
+			})
+		}()
+		c.Close()
+		closed.Store(true)
+		wg.Wait()
+	}
+}
+
+func TestStatsDelta(t *testing.T) {
+	previous := Stats{
+		Operations: map[string]OperationStats{"read": {Count: 2}},
+		BytesRead:  10,
+	}
+	current := Stats{
+		Operations: map[string]OperationStats{"read": {Count: 5, Errors: 1}, "stat": {Count: 1}},
+		BytesRead:  25,
+		OpenFiles:  3,
+	}
+
+	delta := current.Delta(previous)
+	if delta.Operations["read"] != (OperationStats{Count: 3, Errors: 1}) {
+		t.Errorf("Unexpected read delta: %+v", delta.Operations["read"])
+	}
+	if delta.Operations["stat"].Count != 1 {
+		t.Errorf("Unexpected stat delta: %+v", delta.Operations["stat"])
+	}
+	if delta.BytesRead != 15 {
+		t.Errorf("Expected 15 bytes read, got %d", delta.BytesRead)
+	}
+	if delta.OpenFiles != 3 {
+		t.Errorf("Expected gauge to be carried over, got %d", delta.OpenFiles)
+	}
+}
@@ -0,0 +1,136 @@
+package metricsfs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Scope groups the operations of a unit of work that spans several files,
+// such as an upload of many files, so that they can be summarized together.
+// Operations issued through the scope's FS, and files opened through it, are
+// tallied in the scope until End.
+type Scope struct {
+	name  string
+	fs    *MetricsFS
+	start time.Time
+
+	mu           sync.Mutex
+	files        map[string]struct{}
+	operations   int64
+	errors       int64
+	bytesRead    int64
+	bytesWritten int64
+	summary      *ScopeSummary
+}
+
+// ScopeSummary is the summary of an ended Scope.
+type ScopeSummary struct {
+	// Name is the name the scope was begun with
+	Name string `json:"name"`
+
+	// Start is when the scope was begun
+	Start time.Time `json:"start"`
+
+	// Duration is the time from BeginScope to End
+	Duration time.Duration `json:"duration"`
+
+	// Files is the number of distinct paths touched
+	Files int `json:"files"`
+
+	// Operations is the number of operations issued in the scope
+	Operations int64 `json:"operations"`
+
+	// Errors is the number of those operations that failed
+	Errors int64 `json:"errors"`
+
+	// BytesRead and BytesWritten are the bytes transferred in the scope
+	BytesRead    int64 `json:"bytes_read"`
+	BytesWritten int64 `json:"bytes_written"`
+}
+
+// scopeKey is the context key holding the active Scope.
+type scopeKey struct{}
+
+// BeginScope begins a scope named name, which should be low-cardinality
+// (e.g. "album-upload") as it is used as the scope label of the scope
+// metrics. Issue the unit of work's operations through the scope's FS, then
+// call End to record its summary. The scope inherits m's context; call
+// WithContext before BeginScope, not on the scope's FS, which would leave the
+// scope.
+func (m *MetricsFS) BeginScope(name string) *Scope {
+	s := &Scope{
+		name:  name,
+		start: m.collector.clock.Now(),
+		files: make(map[string]struct{}),
+	}
+	fs := *m
+	fs.ctx = context.WithValue(m.ctx, scopeKey{}, s)
+	s.fs = &fs
+	return s
+}
+
+// FS returns the view of the filesystem whose operations are tallied in the
+// scope.
+func (s *Scope) FS() *MetricsFS {
+	return s.fs
+}
+
+// End ends the scope and records its summary in the scope metrics and
+// Config.OnScopeEnd. Operations issued through the scope after End are no
+// longer tallied. Calling End again returns the same summary without
+// recording it again.
+func (s *Scope) End() ScopeSummary {
+	s.mu.Lock()
+	if s.summary != nil {
+		defer s.mu.Unlock()
+		return *s.summary
+	}
+	s.summary = &ScopeSummary{
+		Name:         s.name,
+		Start:        s.start,
+		Duration:     s.fs.collector.clock.Now().Sub(s.start),
+		Files:        len(s.files),
+		Operations:   s.operations,
+		Errors:       s.errors,
+		BytesRead:    s.bytesRead,
+		BytesWritten: s.bytesWritten,
+	}
+	summary := *s.summary
+	s.mu.Unlock()
+
+	s.fs.collector.recordScope(summary)
+	return summary
+}
+
+// record tallies an operation in the scope.
+func (s *Scope) record(op Op, path string, bytesTransferred int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.summary != nil {
+		return
+	}
+	s.operations++
+	if err != nil {
+		s.errors++
+	}
+	switch op {
+	case OpRead:
+		s.bytesRead += bytesTransferred
+	case OpWrite:
+		s.bytesWritten += bytesTransferred
+	}
+	if path != "" {
+		s.files[path] = struct{}{}
+	}
+}
+
+// scopeFromContext returns the Scope carried by ctx, if any.
+func scopeFromContext(ctx context.Context) *Scope {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(scopeKey{}).(*Scope)
+	return s
+}
@@ -0,0 +1,87 @@
+package metricsfs
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestScope(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+
+	var ended []ScopeSummary
+	config := DefaultConfig()
+	config.OnScopeEnd = func(s ScopeSummary) { ended = append(ended, s) }
+	fs := NewWithConfig(base, config)
+	dir := t.TempDir()
+
+	scope := fs.BeginScope("album-upload")
+	sfs := scope.FS()
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		f, err := sfs.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		f.Write([]byte("photo"))
+		f.Close()
+	}
+	f, err := sfs.Open(filepath.Join(dir, "a.jpg"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	io.ReadAll(f)
+	f.Close()
+	sfs.Stat(filepath.Join(dir, "missing.jpg"))
+
+	// Operations outside the scope are not tallied
+	fs.Stat(dir)
+
+	summary := scope.End()
+	if summary.Name != "album-upload" || summary.Files != 3 || summary.Errors != 1 ||
+		summary.BytesRead != 5 || summary.BytesWritten != 10 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+
+	// Ending again neither records nor changes the summary
+	sfs.Stat(dir)
+	if again := scope.End(); again != summary {
+		t.Errorf("Expected the same summary, got %+v", again)
+	}
+	if len(ended) != 1 {
+		t.Errorf("Expected OnScopeEnd to be called once, got %d", len(ended))
+	}
+
+	c := fs.Collector()
+	if got := testutil.ToFloat64(c.scopesTotal.WithLabelValues("album-upload", "error")); got != 1 {
+		t.Errorf("Expected 1 failed scope, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.scopeBytesTotal.WithLabelValues("album-upload", "write")); got != 10 {
+		t.Errorf("Expected 10 bytes written in scopes, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.scopeBytesTotal.WithLabelValues("album-upload", "read")); got != 5 {
+		t.Errorf("Expected 5 bytes read in scopes, got %v", got)
+	}
+}
+
+func TestScopeNameLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxTrackedScopes = 1
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.BeginScope("first").End()
+	fs.BeginScope("second").End()
+
+	c := fs.Collector()
+	if got := testutil.ToFloat64(c.scopesTotal.WithLabelValues("first", "success")); got != 1 {
+		t.Errorf("Expected 1 scope named first, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.scopesTotal.WithLabelValues("other", "success")); got != 1 {
+		t.Errorf("Expected scope names beyond the limit to be recorded as other, got %v", got)
+	}
+}
@@ -0,0 +1,38 @@
+package metricsfs
+
+import "sync/atomic"
+
+// opSeries caches the series of a metric vector for each built-in
+// operation, so that recording them skips the vector's label hashing and
+// lookup. Series are resolved on first use, leaving series of operations
+// never performed unexported. Custom operations are looked up on each use.
+type opSeries[T any] struct {
+	lookup func(op Op) T
+	cache  [len(builtinOperations)]atomic.Pointer[T]
+}
+
+// newOpSeries returns a cache of the series returned by lookup.
+func newOpSeries[T any](lookup func(op Op) T) *opSeries[T] {
+	return &opSeries[T]{lookup: lookup}
+}
+
+// get returns the series of op.
+func (s *opSeries[T]) get(op Op) T {
+	i := builtinIndex(op)
+	if i < 0 {
+		return s.lookup(op)
+	}
+	if series := s.cache[i].Load(); series != nil {
+		return *series
+	}
+	series := s.lookup(op)
+	s.cache[i].Store(&series)
+	return series
+}
+
+// reset drops the cached series, after their vector was reset.
+func (s *opSeries[T]) reset() {
+	for i := range s.cache {
+		s.cache[i].Store(nil)
+	}
+}
@@ -0,0 +1,49 @@
+package metricsfs
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestBuiltinIndex(t *testing.T) {
+	for i, op := range builtinOperations {
+		if got := builtinIndex(op); got != i {
+			t.Errorf("builtinIndex(%q) = %d, want %d", op, got, i)
+		}
+	}
+	if got := builtinIndex(Op("compress")); got != -1 {
+		t.Errorf("Expected -1 for a custom operation, got %d", got)
+	}
+}
+
+func TestOpSeriesReset(t *testing.T) {
+	fs := New(newMockFS())
+	c := fs.Collector()
+
+	fs.Stat("/test.txt")
+	// Series of operations never performed are not exported
+	if got := testutil.CollectAndCount(c.operationsTotal); got != 1 {
+		t.Errorf("Expected 1 operations_total series, got %d", got)
+	}
+
+	// Cached series are resolved again after a reset
+	c.Reset()
+	fs.Stat("/test.txt")
+	fs.Stat("/test.txt")
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success")); got != 2 {
+		t.Errorf("Expected 2 stats after reset, got %v", got)
+	}
+	var m dto.Metric
+	if err := c.statDuration.WithLabelValues().(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("Expected 2 stat durations after reset, got %d", got)
+	}
+	if got := c.Stats().Operations["stat"].Count; got != 2 {
+		t.Errorf("Expected stats to report 2 stats, got %d", got)
+	}
+}
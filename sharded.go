@@ -0,0 +1,177 @@
+package metricsfs
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+)
+
+// shardedCounters spreads the counters updated by every operation over
+// shards picked at random, so that goroutines recording operations in
+// parallel rarely write to the same cache line. The shards are drained into
+// the metric vectors when they are read: on Collect, Stats and health
+// checks. See Config.EnableShardedCounters.
+type shardedCounters struct {
+	mask   uint32
+	shards []counterShard
+
+	// touched marks the operations whose in-flight series exists
+	touched [len(builtinOperations)]atomic.Bool
+}
+
+// counterShard holds one shard of the counters of the built-in operations.
+type counterShard struct {
+	success      [len(builtinOperations)]atomic.Uint64
+	errors       [len(builtinOperations)]atomic.Uint64
+	inFlight     [len(builtinOperations)]atomic.Int64
+	bytesRead    atomic.Uint64
+	bytesWritten atomic.Uint64
+
+	// Keeps the counters of neighbouring shards off each other's cache lines
+	_ [64]byte
+}
+
+// newShardedCounters creates counters with a shard per processor, rounded
+// up to a power of two.
+func newShardedCounters() *shardedCounters {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	return &shardedCounters{mask: uint32(n - 1), shards: make([]counterShard, n)}
+}
+
+// shard returns a shard picked at random.
+func (s *shardedCounters) shard() *counterShard {
+	return &s.shards[rand.Uint32()&s.mask]
+}
+
+// addInFlight adds delta to the operations of op in flight. It reports false
+// when op is not counted in shards.
+func (s *shardedCounters) addInFlight(op Op, delta int64) bool {
+	if s == nil {
+		return false
+	}
+	i := builtinIndex(op)
+	if i < 0 {
+		return false
+	}
+	if !s.touched[i].Load() {
+		s.touched[i].Store(true)
+	}
+	s.shard().inFlight[i].Add(delta)
+	return true
+}
+
+// count counts a completed operation. It reports false when op is not
+// counted in shards.
+func (s *shardedCounters) count(op Op, failed bool) bool {
+	if s == nil {
+		return false
+	}
+	i := builtinIndex(op)
+	if i < 0 {
+		return false
+	}
+	if failed {
+		s.shard().errors[i].Add(1)
+	} else {
+		s.shard().success[i].Add(1)
+	}
+	return true
+}
+
+// addBytes counts n bytes read or written by op. It reports false when the
+// bytes are not counted in shards.
+func (s *shardedCounters) addBytes(op Op, n int64) bool {
+	if s == nil {
+		return false
+	}
+	if transfersOut(op) {
+		s.shard().bytesWritten.Add(uint64(n))
+	} else {
+		s.shard().bytesRead.Add(uint64(n))
+	}
+	return true
+}
+
+// inFlight returns the number of operations in flight counted in shards.
+func (s *shardedCounters) inFlight() int64 {
+	if s == nil {
+		return 0
+	}
+	var n int64
+	for i := range s.shards {
+		for j := range s.shards[i].inFlight {
+			n += s.shards[i].inFlight[j].Load()
+		}
+	}
+	return n
+}
+
+// flushShards drains the sharded counters into the metric vectors and the
+// aggregate health counters, and sets the in-flight gauges.
+func (c *Collector) flushShards() {
+	s := c.sharded
+	if s == nil || c.closed.Load() {
+		return
+	}
+
+	var bytesRead, bytesWritten uint64
+	for i, op := range builtinOperations {
+		var success, errors uint64
+		var inFlight int64
+		for j := range s.shards {
+			shard := &s.shards[j]
+			success += shard.success[i].Swap(0)
+			errors += shard.errors[i].Swap(0)
+			inFlight += shard.inFlight[i].Load()
+			if i == 0 {
+				bytesRead += shard.bytesRead.Swap(0)
+				bytesWritten += shard.bytesWritten.Swap(0)
+			}
+		}
+
+		if success > 0 {
+			c.successSeries.get(op).Add(float64(success))
+		}
+		if errors > 0 {
+			c.errorSeries.get(op).Add(float64(errors))
+		}
+		c.totalOperations.Add(int64(success + errors))
+		c.totalErrors.Add(int64(errors))
+		if s.touched[i].Load() {
+			c.inFlightSeries.get(op).Set(float64(inFlight))
+		}
+	}
+
+	if bytesRead > 0 {
+		c.bytesSeries.get(OpRead).Add(float64(bytesRead))
+	}
+	if bytesWritten > 0 {
+		c.bytesSeries.get(OpWrite).Add(float64(bytesWritten))
+	}
+}
+
+// discardShards drops the completed operations counted in shards, when the
+// collector is reset. Operations in flight stay counted.
+func (c *Collector) discardShards() {
+	s := c.sharded
+	if s == nil {
+		return
+	}
+	for j := range s.shards {
+		shard := &s.shards[j]
+		for i := range shard.success {
+			shard.success[i].Store(0)
+			shard.errors[i].Store(0)
+		}
+		shard.bytesRead.Store(0)
+		shard.bytesWritten.Store(0)
+	}
+}
+
+// inFlightCount returns the number of operations in flight.
+func (c *Collector) inFlightCount() int64 {
+	return c.inFlight.Load() + c.sharded.inFlight()
+}
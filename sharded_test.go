@@ -0,0 +1,120 @@
+package metricsfs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestShardedCounters(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableShardedCounters = true
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+	if c.sharded == nil {
+		t.Fatal("Expected sharded counters to be enabled")
+	}
+
+	f, err := fs.Create("/data")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				fs.Stat("/data")
+				f.Write([]byte("abcd"))
+			}
+		}()
+	}
+	wg.Wait()
+	f.Close()
+	c.recordOperation(context.Background(), OpRemove, "/missing", 0, 0, errors.New("boom"))
+
+	// Nothing reaches the vectors until they are collected
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success")); got != 0 {
+		t.Errorf("Expected stat counts to wait for Collect, got %v", got)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	if _, err := reg.Gather(); err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success")); got != 800 {
+		t.Errorf("Expected 800 stats, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("write", "success")); got != 800 {
+		t.Errorf("Expected 800 writes, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("remove", "error")); got != 1 {
+		t.Errorf("Expected 1 failed remove, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.bytesWrittenTotal); got != 3200 {
+		t.Errorf("Expected 3200 bytes written, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.operationsInFlight.WithLabelValues("stat")); got != 0 {
+		t.Errorf("Expected no stats in flight, got %v", got)
+	}
+	if ops, errs := c.totalOperations.Load(), c.totalErrors.Load(); ops != 1603 || errs != 1 {
+		t.Errorf("Expected 1603 operations and 1 error, got %d and %d", ops, errs)
+	}
+
+	// Stats reads drained counters too
+	fs.Stat("/data")
+	if got := c.Stats().Operations["stat"].Count; got != 801 {
+		t.Errorf("Expected 801 stats in Stats, got %d", got)
+	}
+}
+
+func TestShardedCountersInFlight(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableShardedCounters = true
+	c := NewCollector(config)
+
+	start := c.startOperation(OpRead)
+	if got := c.inFlightCount(); got != 1 {
+		t.Errorf("Expected 1 operation in flight, got %d", got)
+	}
+	c.flushShards()
+	if got := testutil.ToFloat64(c.operationsInFlight.WithLabelValues("read")); got != 1 {
+		t.Errorf("Expected 1 read in flight, got %v", got)
+	}
+
+	c.finishOperation(OpRead, start)
+	c.flushShards()
+	if got := testutil.ToFloat64(c.operationsInFlight.WithLabelValues("read")); got != 0 {
+		t.Errorf("Expected no reads in flight, got %v", got)
+	}
+}
+
+func TestShardedCountersDynamicLabels(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableShardedCounters = true
+	config.EnableInstanceLabel = true
+	c := NewCollector(config)
+	if c.sharded != nil {
+		t.Error("Expected sharded counters to be disabled with dynamic labels")
+	}
+}
+
+func TestShardedCountersReset(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableShardedCounters = true
+	c := NewCollector(config)
+
+	c.recordOperation(context.Background(), OpStat, "/a", 0, 0, nil)
+	c.Reset()
+	c.flushShards()
+	if got := testutil.ToFloat64(c.operationsTotal.WithLabelValues("stat", "success")); got != 0 {
+		t.Errorf("Expected Reset to discard sharded counts, got %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package metricsfs
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrFileTooLarge is returned by writes and truncations that would grow a
+// file beyond the maximum size of its path group. See Config.MaxFileSizes.
+var ErrFileTooLarge = errors.New("metricsfs: file size limit exceeded")
+
+// maxFileSize returns the maximum size of files at path, or 0 if they have
+// none.
+func (c *Collector) maxFileSize(path string) int64 {
+	if len(c.config.MaxFileSizes) == 0 {
+		return 0
+	}
+	if size, ok := c.config.MaxFileSizes[c.config.PathGroupFunc(path)]; ok {
+		return size
+	}
+	return c.config.MaxFileSizes["*"]
+}
+
+// recordSizeLimitViolation counts a write refused for growing a file at path
+// beyond its maximum size.
+func (c *Collector) recordSizeLimitViolation(path string) {
+	if c.closed.Load() {
+		return
+	}
+	c.sizeLimitViolationsTotal.WithLabelValues(c.pathGroup(path)).Inc()
+}
+
+// checkSize returns ErrFileTooLarge if writing n bytes at off would grow the
+// file beyond its maximum size. An off of -1 means the current position, or
+// the end of the file for appends. Files that cannot be inspected are not
+// limited.
+func (f *MetricsFile) checkSize(off int64, n int) error {
+	if f.maxSize <= 0 || n <= 0 {
+		return nil
+	}
+
+	info, err := f.file.Stat()
+	if err != nil {
+		return nil
+	}
+	size := info.Size()
+
+	if off < 0 {
+		if f.appending {
+			off = size
+		} else if off, err = f.file.Seek(0, io.SeekCurrent); err != nil {
+			return nil
+		}
+	}
+
+	// Files already beyond the limit may still be rewritten in place
+	if end := off + int64(n); end > f.maxSize && end > size {
+		f.collector.recordSizeLimitViolation(f.path)
+		return ErrFileTooLarge
+	}
+	return nil
+}
+
+// checkTruncate returns ErrFileTooLarge if truncating the file at path to
+// size would grow it beyond maxSize; stat returns the file's current size.
+// Shrinking a file, even one beyond the limit, is always allowed.
+func (c *Collector) checkTruncate(path string, maxSize, size int64, stat func() (os.FileInfo, error)) error {
+	if maxSize <= 0 || size <= maxSize {
+		return nil
+	}
+	info, err := stat()
+	if err != nil {
+		return nil
+	}
+	if size > info.Size() {
+		c.recordSizeLimitViolation(path)
+		return ErrFileTooLarge
+	}
+	return nil
+}
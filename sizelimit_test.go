@@ -0,0 +1,130 @@
+package metricsfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMaxFileSizes(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	dir := t.TempDir()
+	for _, sub := range []string{"logs", "data"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatalf("Mkdir failed: %v", err)
+		}
+	}
+
+	config := DefaultConfig()
+	config.PathGroupFunc = func(path string) string { return filepath.Base(filepath.Dir(path)) }
+	config.MaxFileSizes = map[string]int64{"logs": 8, "*": 16}
+	fs := NewWithConfig(base, config)
+	c := fs.Collector()
+
+	log, err := fs.Create(filepath.Join(dir, "logs", "app.log"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer log.Close()
+
+	if _, err := log.Write([]byte("12345")); err != nil {
+		t.Fatalf("Write under the limit failed: %v", err)
+	}
+	if n, err := log.Write([]byte("6789")); !errors.Is(err, ErrFileTooLarge) || n != 0 {
+		t.Errorf("Expected ErrFileTooLarge writing past 8 bytes, got %d, %v", n, err)
+	}
+	if _, err := log.WriteString("678"); err != nil {
+		t.Errorf("Write up to the limit failed: %v", err)
+	}
+	// Rewriting within the file is allowed
+	if _, err := log.WriteAt([]byte("ab"), 0); err != nil {
+		t.Errorf("WriteAt within the file failed: %v", err)
+	}
+	if _, err := log.WriteAt([]byte("ab"), 7); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge writing past the end, got %v", err)
+	}
+
+	// Appends are checked against the end of the file
+	data := filepath.Join(dir, "data", "blob")
+	if err := os.WriteFile(data, make([]byte, 12), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	blob, err := fs.OpenFile(data, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer blob.Close()
+	if _, err := blob.Write(make([]byte, 5)); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge appending past 16 bytes, got %v", err)
+	}
+
+	if got := testutil.ToFloat64(c.sizeLimitViolationsTotal.WithLabelValues("logs")); got != 2 {
+		t.Errorf("Expected 2 violations in logs, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.sizeLimitViolationsTotal.WithLabelValues("data")); got != 1 {
+		t.Errorf("Expected 1 violation in data, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.errorsTotal.WithLabelValues("write", "size_limit")); got != 3 {
+		t.Errorf("Expected 3 size_limit write errors, got %v", got)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "logs", "app.log")); info.Size() != 8 {
+		t.Errorf("Expected the log to stop at 8 bytes, got %d", info.Size())
+	}
+}
+
+func TestMaxFileSizesTruncate(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	dir := t.TempDir()
+
+	config := DefaultConfig()
+	config.PathGroupFunc = func(string) string { return "logs" }
+	config.MaxFileSizes = map[string]int64{"logs": 8}
+	fs := NewWithConfig(base, config)
+	c := fs.Collector()
+
+	name := filepath.Join(dir, "app.log")
+	f, err := fs.Create(name)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(8); err != nil {
+		t.Errorf("Truncate up to the limit failed: %v", err)
+	}
+	if err := f.Truncate(64); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge growing the file past 8 bytes, got %v", err)
+	}
+	if err := fs.Truncate(name, 1<<20); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge growing the file by name, got %v", err)
+	}
+	if info, _ := os.Stat(name); info.Size() != 8 {
+		t.Errorf("Expected the file to stay at 8 bytes, got %d", info.Size())
+	}
+
+	// Files already beyond the limit may still be shrunk
+	big := filepath.Join(dir, "big")
+	if err := os.WriteFile(big, make([]byte, 32), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := fs.Truncate(big, 16); err != nil {
+		t.Errorf("Shrinking a file beyond the limit failed: %v", err)
+	}
+
+	if got := testutil.ToFloat64(c.sizeLimitViolationsTotal.WithLabelValues("logs")); got != 2 {
+		t.Errorf("Expected 2 violations, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.errorsTotal.WithLabelValues("truncate", "size_limit")); got != 2 {
+		t.Errorf("Expected 2 size_limit truncate errors, got %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package metricsfs
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// apdexToleratingFactor is the multiple of the threshold up to which an
+// operation is tolerated rather than frustrating, as defined by Apdex.
+const apdexToleratingFactor = 4
+
+// initSLO creates the SLO threshold and Apdex counters. They are created
+// even without SLOThresholds, so that UpdateConfig can set thresholds.
+func (c *Collector) initSLO(config Config) {
+	c.sloOperationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   config.Subsystem,
+			Name:        "slo_operations_total",
+			Help:        "Operations with an SLO threshold, by operation and result (under or over the threshold)",
+			ConstLabels: config.constLabelsFor("slo_operations_total"),
+		},
+		[]string{"operation", "result"},
+	)
+
+	if config.EnableApdex {
+		c.apdexOperationsTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "apdex_operations_total",
+				Help:        "Operations with an SLO threshold T, by operation and Apdex zone (satisfied within T, tolerating within 4T, frustrated beyond or failed)",
+				ConstLabels: config.constLabelsFor("apdex_operations_total"),
+			},
+			[]string{"operation", "zone"},
+		)
+	}
+}
+
+// recordSLO counts op against its threshold in thresholds, if it has one.
+func (c *Collector) recordSLO(thresholds map[Op]time.Duration, op Op, duration time.Duration, err error) {
+	threshold, ok := thresholds[op]
+	if !ok {
+		return
+	}
+
+	result := "under"
+	if duration > threshold {
+		result = "over"
+	}
+	c.sloOperationsTotal.WithLabelValues(string(op), result).Inc()
+
+	if c.apdexOperationsTotal != nil {
+		zone := "satisfied"
+		switch {
+		case err != nil || duration > apdexToleratingFactor*threshold:
+			zone = "frustrated"
+		case duration > threshold:
+			zone = "tolerating"
+		}
+		c.apdexOperationsTotal.WithLabelValues(string(op), zone).Inc()
+	}
+}
@@ -0,0 +1,70 @@
+package metricsfs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSLOThresholds(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = &stepClock{now: time.Unix(1000, 0), step: 25 * time.Millisecond}
+	config.SLOThresholds = map[string]time.Duration{
+		"stat":  10 * time.Millisecond,
+		"open":  50 * time.Millisecond,
+		"mkdir": 5 * time.Millisecond,
+	}
+	config.EnableApdex = true
+	fs := NewWithConfig(newMockFS(), config)
+	c := fs.Collector()
+
+	// Each operation takes 25ms by the clock
+	fs.Stat("/a")
+	fs.Open("/a")
+	fs.Mkdir("/dir", 0755)
+	fs.Remove("/a")
+
+	for _, tt := range []struct {
+		op, result string
+		want       float64
+	}{
+		{"stat", "over", 1},
+		{"open", "under", 1},
+		{"mkdir", "over", 1},
+	} {
+		if got := testutil.ToFloat64(c.sloOperationsTotal.WithLabelValues(tt.op, tt.result)); got != tt.want {
+			t.Errorf("Expected %v %s operations %s the threshold, got %v", tt.want, tt.op, tt.result, got)
+		}
+	}
+	for _, tt := range []struct{ op, zone string }{
+		{"stat", "tolerating"},
+		{"open", "satisfied"},
+		{"mkdir", "frustrated"},
+	} {
+		if got := testutil.ToFloat64(c.apdexOperationsTotal.WithLabelValues(tt.op, tt.zone)); got != 1 {
+			t.Errorf("Expected a %s %s operation, got %v", tt.zone, tt.op, got)
+		}
+	}
+
+	// Operations without a threshold are not counted
+	if got := testutil.CollectAndCount(c.sloOperationsTotal); got != 3 {
+		t.Errorf("Expected 3 SLO series, got %d", got)
+	}
+}
+
+func TestApdexFailedOperations(t *testing.T) {
+	config := DefaultConfig()
+	config.SLOThresholds = map[string]time.Duration{"stat": time.Hour}
+	config.EnableApdex = true
+	c := NewCollector(config)
+
+	c.recordSLO(c.live.Load().sloThresholds, OpStat, time.Millisecond, os.ErrNotExist)
+	if got := testutil.ToFloat64(c.apdexOperationsTotal.WithLabelValues("stat", "frustrated")); got != 1 {
+		t.Errorf("Expected a failed stat to frustrate, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.sloOperationsTotal.WithLabelValues("stat", "under")); got != 1 {
+		t.Errorf("Expected a fast failed stat to be under the threshold, got %v", got)
+	}
+}
@@ -0,0 +1,80 @@
+package metricsfs
+
+import (
+	"sync"
+	"time"
+)
+
+// maxSlowOperations is the number of most recent slow operations kept.
+const maxSlowOperations = 32
+
+// SlowOperation is an operation that took at least SlowOperationThreshold.
+type SlowOperation struct {
+	// Time is when the operation finished
+	Time time.Time `json:"time"`
+
+	// Operation is the operation name, e.g. "read"
+	Operation string `json:"operation"`
+
+	// Path is the path the operation was on, if any
+	Path string `json:"path,omitempty"`
+
+	// Duration is how long the operation took
+	Duration time.Duration `json:"duration_ns"`
+
+	// Error is the operation's error message, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// SlowOperations returns the most recent operations that took at least
+// SlowOperationThreshold, oldest first. Reset clears them.
+func (c *Collector) SlowOperations() []SlowOperation {
+	return c.slowOps.list()
+}
+
+// recordSlowOperation logs op if it took at least threshold.
+func (c *Collector) recordSlowOperation(threshold time.Duration, op Op, path string, duration time.Duration, err error) {
+	if duration < threshold {
+		return
+	}
+	slow := SlowOperation{Time: c.clock.Now(), Operation: string(op), Path: path, Duration: duration}
+	if err != nil {
+		slow.Error = err.Error()
+	}
+	c.slowOps.add(slow)
+}
+
+// slowLog is a bounded history of slow operations.
+type slowLog struct {
+	mu      sync.Mutex
+	entries []SlowOperation
+}
+
+// add appends op, dropping the oldest operation when the log is full.
+func (l *slowLog) add(op SlowOperation) {
+	l.mu.Lock()
+	if len(l.entries) == maxSlowOperations {
+		copy(l.entries, l.entries[1:])
+		l.entries = l.entries[:len(l.entries)-1]
+	}
+	l.entries = append(l.entries, op)
+	l.mu.Unlock()
+}
+
+// list returns a copy of the logged operations.
+func (l *slowLog) list() []SlowOperation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) == 0 {
+		return nil
+	}
+	return append([]SlowOperation(nil), l.entries...)
+}
+
+// reset forgets the logged operations.
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	l.entries = nil
+	l.mu.Unlock()
+}
@@ -0,0 +1,57 @@
+package metricsfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSlowOperations(t *testing.T) {
+	config := DefaultConfig()
+	config.SlowOperationThreshold = 10 * time.Millisecond
+	c := NewCollector(config)
+	ctx := context.Background()
+
+	c.recordOperation(ctx, OpRead, "/fast", time.Millisecond, 0, nil)
+	c.recordOperation(ctx, OpRead, "/slow", 20*time.Millisecond, 0, nil)
+	c.recordOperation(ctx, OpStat, "/failed", time.Second, 0, errors.New("boom"))
+
+	slow := c.SlowOperations()
+	if len(slow) != 2 {
+		t.Fatalf("Expected 2 slow operations, got %+v", slow)
+	}
+	if slow[0].Path != "/slow" || slow[0].Duration != 20*time.Millisecond || slow[0].Error != "" {
+		t.Errorf("unexpected first operation %+v", slow[0])
+	}
+	if slow[1].Operation != "stat" || slow[1].Error != "boom" {
+		t.Errorf("unexpected second operation %+v", slow[1])
+	}
+
+	// The log keeps the most recent operations
+	for range maxSlowOperations {
+		c.recordOperation(ctx, OpWrite, "/w", time.Second, 0, nil)
+	}
+	if slow := c.SlowOperations(); len(slow) != maxSlowOperations || slow[0].Operation != "write" {
+		t.Errorf("Expected %d writes, got %d operations starting with %+v", maxSlowOperations, len(slow), slow[0])
+	}
+
+	c.Reset()
+	if slow := c.SlowOperations(); slow != nil {
+		t.Errorf("Expected Reset to clear slow operations, got %+v", slow)
+	}
+}
+
+func TestSlowOperationsDisabled(t *testing.T) {
+	c := NewCollector(DefaultConfig())
+	c.recordOperation(context.Background(), OpRead, "/a", time.Hour, 0, nil)
+	if slow := c.SlowOperations(); slow != nil {
+		t.Errorf("Expected no slow operations without a threshold, got %+v", slow)
+	}
+
+	c.UpdateConfig(func(config *Config) { config.SlowOperationThreshold = time.Minute })
+	c.recordOperation(context.Background(), OpRead, "/a", time.Hour, 0, nil)
+	if slow := c.SlowOperations(); len(slow) != 1 {
+		t.Errorf("Expected UpdateConfig to enable the log, got %+v", slow)
+	}
+}
@@ -0,0 +1,156 @@
+package metricsfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SnapshotSchemaVersion is the version of the Snapshot format written by
+// SaveSnapshot. LoadSnapshot rejects snapshots of other versions.
+const SnapshotSchemaVersion = 1
+
+// Snapshot holds the counter values of a collector, so that cumulative
+// totals such as bytes migrated survive process restarts. See
+// Collector.SaveSnapshot.
+type Snapshot struct {
+	// SchemaVersion is the SnapshotSchemaVersion the snapshot was written with
+	SchemaVersion int `json:"schema_version"`
+
+	// Timestamp is when the snapshot was taken
+	Timestamp time.Time `json:"timestamp"`
+
+	// Instance identifies the filesystem the snapshot is of. See
+	// Collector.Instance
+	Instance string `json:"instance,omitempty"`
+
+	// Counters holds the value of each counter series
+	Counters []CounterSample `json:"counters"`
+}
+
+// CounterSample is the value of a counter series in a Snapshot.
+type CounterSample struct {
+	// Name is the full name of the metric, as in "fs_operations_total"
+	Name string `json:"name"`
+
+	// Labels are the variable labels of the series; const labels are not
+	// saved, so that they may change between runs
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Value is the value of the counter
+	Value float64 `json:"value"`
+}
+
+// SaveSnapshot writes the value of every counter series of the collector to
+// w as a JSON Snapshot. Gauges and histograms are not saved.
+func (c *Collector) SaveSnapshot(w io.Writer) error {
+	c.flushShards()
+	snapshot := Snapshot{
+		SchemaVersion: SnapshotSchemaVersion,
+		Timestamp:     c.clock.Now(),
+		Instance:      c.instance,
+		Counters:      []CounterSample{},
+	}
+
+	vecs := c.counterVecs()
+	for _, name := range slices.Sorted(maps.Keys(vecs)) {
+		constLabels := c.config.constLabelsFor(c.shortName(name))
+		first := len(snapshot.Counters)
+		collectValues(vecs[name], func(labels map[string]string, value float64) {
+			for k := range constLabels {
+				delete(labels, k)
+			}
+			if len(labels) == 0 {
+				labels = nil
+			}
+			snapshot.Counters = append(snapshot.Counters, CounterSample{Name: name, Labels: labels, Value: value})
+		})
+		series := snapshot.Counters[first:]
+		sort.Slice(series, func(i, j int) bool {
+			return fmt.Sprint(series[i].Labels) < fmt.Sprint(series[j].Labels)
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snapshot)
+}
+
+// LoadSnapshot reads a Snapshot written by SaveSnapshot from r and adds its
+// counter values to the collector's, typically once at startup before any
+// operation is performed. Series of metrics the collector does not export,
+// such as those of features disabled since, or whose labels no longer
+// match, are skipped.
+func (c *Collector) LoadSnapshot(r io.Reader) error {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("metricsfs: decoding snapshot: %w", err)
+	}
+	if snapshot.SchemaVersion != SnapshotSchemaVersion {
+		return fmt.Errorf("metricsfs: unsupported snapshot schema version %d", snapshot.SchemaVersion)
+	}
+	if c.closed.Load() {
+		return nil
+	}
+
+	vecs := c.counterVecs()
+	for _, sample := range snapshot.Counters {
+		vec, ok := vecs[sample.Name]
+		if !ok || sample.Value <= 0 {
+			continue
+		}
+		counter, err := vec.GetMetricWith(prometheus.Labels(sample.Labels))
+		if err != nil {
+			continue
+		}
+		counter.Add(sample.Value)
+	}
+	return nil
+}
+
+// counterVecs returns the counter vectors of the collector by full name.
+func (c *Collector) counterVecs() map[string]*prometheus.CounterVec {
+	vecs := make(map[string]*prometheus.CounterVec)
+	for _, vec := range c.metricVecs() {
+		counter, ok := vec.(*prometheus.CounterVec)
+		if !ok {
+			continue
+		}
+		if name := vecName(counter); name != "" {
+			vecs[name] = counter
+		}
+	}
+	return vecs
+}
+
+// fqNamePattern extracts the full name of a metric from the description of
+// its Desc.
+var fqNamePattern = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// vecName returns the full name of the metric of vec.
+func vecName(vec prometheus.Collector) string {
+	ch := make(chan *prometheus.Desc, 1)
+	vec.Describe(ch)
+	close(ch)
+	for desc := range ch {
+		if m := fqNamePattern.FindStringSubmatch(desc.String()); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// shortName returns the name of the metric named name under the collector's
+// namespace and subsystem.
+func (c *Collector) shortName(name string) string {
+	prefix := strings.TrimSuffix(prometheus.BuildFQName(c.config.Namespace, c.config.Subsystem, "_"), "_")
+	return strings.TrimPrefix(name, prefix)
+}
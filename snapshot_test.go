@@ -0,0 +1,101 @@
+package metricsfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	config := DefaultConfig()
+	config.ConstLabels = prometheus.Labels{"service": "migrator"}
+	fs := NewWithConfig(newMockFS(), config)
+
+	f, err := fs.OpenFile("/a.txt", 0, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte("hello"))
+	f.Close()
+
+	var buf bytes.Buffer
+	if err := fs.Collector().SaveSnapshot(&buf); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(buf.Bytes(), &snapshot); err != nil {
+		t.Fatalf("invalid snapshot: %v", err)
+	}
+	if snapshot.SchemaVersion != SnapshotSchemaVersion || len(snapshot.Counters) == 0 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	for _, sample := range snapshot.Counters {
+		if _, ok := sample.Labels["service"]; ok {
+			t.Errorf("const label saved in %+v", sample)
+		}
+	}
+
+	// A restarted process with another const label resumes the totals
+	config.ConstLabels = prometheus.Labels{"service": "migrator-v2"}
+	restarted := NewWithConfig(newMockFS(), config)
+	c := restarted.Collector()
+	if err := c.LoadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("write", "success")); v != 1 {
+		t.Errorf("Expected 1 restored write, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.bytesWrittenTotal); v != 5 {
+		t.Errorf("Expected 5 restored bytes written, got %v", v)
+	}
+	if stats := c.Stats(); stats.BytesWritten != 5 || stats.Operations["open"].Count != 1 {
+		t.Errorf("Expected the restored totals in Stats, got %+v", stats)
+	}
+
+	// Later operations add to the restored totals
+	f, _ = restarted.OpenFile("/a.txt", 0, 0)
+	f.Write([]byte("hello"))
+	f.Close()
+	if v := testutil.ToFloat64(c.bytesWrittenTotal); v != 10 {
+		t.Errorf("Expected 10 bytes written, got %v", v)
+	}
+}
+
+func TestSnapshotSkipsDisabledMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableRenameMetrics = true
+	fs := NewWithConfig(newMockFS(), config)
+	if err := fs.Rename("/a", "/b"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := fs.Collector().SaveSnapshot(&buf); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	// A collector without rename metrics skips renames_total
+	c := NewCollector(DefaultConfig())
+	if err := c.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("rename", "success")); v != 1 {
+		t.Errorf("Expected 1 restored rename, got %v", v)
+	}
+}
+
+func TestLoadSnapshotErrors(t *testing.T) {
+	c := NewCollector(DefaultConfig())
+	if err := c.LoadSnapshot(strings.NewReader("not json")); err == nil {
+		t.Error("Expected an error for an invalid snapshot")
+	}
+	if err := c.LoadSnapshot(strings.NewReader(`{"schema_version": 99}`)); err == nil {
+		t.Error("Expected an error for an unsupported schema version")
+	}
+}
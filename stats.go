@@ -0,0 +1,177 @@
+package metricsfs
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// StatsSchemaVersion is the version of the Stats format served by
+// StatsHandler and published by PublishExpvar. It is incremented whenever a
+// field is renamed, removed, or changes meaning; adding fields does not
+// change it.
+const StatsSchemaVersion = 1
+
+// modulePath is the import path used to look up the package version.
+const modulePath = "github.com/absfs/metricsfs"
+
+var (
+	versionOnce sync.Once
+	version     string
+)
+
+// Version returns the version of the metricsfs module linked into the
+// binary, as recorded in its build info. It returns "(devel)" when the
+// version is unknown, such as when running the module's own tests.
+func Version() string {
+	versionOnce.Do(func() {
+		version = "(devel)"
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		if info.Main.Path == modulePath && info.Main.Version != "" {
+			version = info.Main.Version
+			return
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+				if dep.Replace != nil && dep.Replace.Version != "" {
+					version = dep.Replace.Version
+				}
+				return
+			}
+		}
+	})
+	return version
+}
+
+// Stats is a point-in-time summary of the metrics recorded by a Collector.
+// It is computed on demand and does not require a Prometheus registry.
+type Stats struct {
+	// SchemaVersion is the StatsSchemaVersion the stats were encoded with
+	SchemaVersion int `json:"schema_version"`
+
+	// Version is the metricsfs version that produced the stats
+	Version string `json:"version"`
+
+	// Timestamp is when the stats were taken
+	Timestamp time.Time `json:"timestamp"`
+
+	// Instance identifies the filesystem the stats are of. See
+	// Collector.Instance
+	Instance string `json:"instance,omitempty"`
+
+	// Operations holds counts per operation name
+	Operations map[string]OperationStats `json:"operations"`
+
+	// BytesRead is the total number of bytes read
+	BytesRead int64 `json:"bytes_read"`
+
+	// BytesWritten is the total number of bytes written
+	BytesWritten int64 `json:"bytes_written"`
+
+	// OpenFiles is the number of currently open files
+	OpenFiles int64 `json:"open_files"`
+
+	// OpenFilesMax is the maximum number of concurrently open files observed
+	OpenFilesMax int64 `json:"open_files_max"`
+
+	// InFlight is the number of operations currently in progress
+	InFlight int64 `json:"in_flight"`
+
+	// Transitions are the most recent changes in the operating mode of the
+	// instrumentation, oldest first. See Collector.ModeTransitions
+	Transitions []ModeTransition `json:"transitions,omitempty"`
+
+	// Allocations are the heap allocations the instrumentation makes per
+	// operation, with EnableAllocationMetrics.
+	// See Collector.OperationAllocations
+	Allocations map[string]float64 `json:"allocations,omitempty"`
+}
+
+// OperationStats holds counts for a single operation.
+type OperationStats struct {
+	// Count is the total number of operations
+	Count int64 `json:"count"`
+
+	// Errors is the number of operations that failed
+	Errors int64 `json:"errors"`
+
+	// MinLatency and MaxLatency are the shortest and longest durations
+	// since the previous scrape or Reset, and LastLatency the duration of
+	// the most recent operation, with EnableLatencyExtremes
+	MinLatency  time.Duration `json:"min_latency_ns,omitempty"`
+	MaxLatency  time.Duration `json:"max_latency_ns,omitempty"`
+	LastLatency time.Duration `json:"last_latency_ns,omitempty"`
+}
+
+// Stats returns a summary of the metrics recorded so far. Metrics that carry
+// additional labels (instances, context labels) are summed.
+func (c *Collector) Stats() Stats {
+	c.flushShards()
+	stats := Stats{
+		SchemaVersion: StatsSchemaVersion,
+		Version:       Version(),
+		Timestamp:     c.clock.Now(),
+		Instance:      c.instance,
+		Operations:    make(map[string]OperationStats),
+		OpenFiles:     c.openFiles.Load(),
+		OpenFilesMax:  c.openFilesMax.Load(),
+		InFlight:      c.inFlightCount(),
+		Transitions:   c.ModeTransitions(),
+		Allocations:   c.OperationAllocations(),
+	}
+
+	collectValues(c.operationsTotal, func(labels map[string]string, value float64) {
+		op := stats.Operations[labels["operation"]]
+		op.Count += int64(value)
+		if labels["status"] == "error" {
+			op.Errors += int64(value)
+		}
+		stats.Operations[labels["operation"]] = op
+	})
+	c.latencyExtremesStats(stats.Operations)
+
+	collectValues(c.bytesReadTotal, func(labels map[string]string, value float64) {
+		stats.BytesRead += int64(value)
+	})
+	collectValues(c.bytesWrittenTotal, func(labels map[string]string, value float64) {
+		stats.BytesWritten += int64(value)
+	})
+
+	return stats
+}
+
+// collectValues calls fn with the labels and value of every counter or gauge
+// series exposed by collector.
+func collectValues(collector prometheus.Collector, fn func(labels map[string]string, value float64)) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collector.Collect(ch)
+		close(ch)
+	}()
+
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			continue
+		}
+
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, lp := range m.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+
+		switch {
+		case m.Counter != nil:
+			fn(labels, m.GetCounter().GetValue())
+		case m.Gauge != nil:
+			fn(labels, m.GetGauge().GetValue())
+		}
+	}
+}
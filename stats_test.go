@@ -0,0 +1,85 @@
+package metricsfs
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCollectorStats(t *testing.T) {
+	fs := New(&errorMockFS{})
+
+	f, _ := fs.Create("/test.txt")
+	f.Write([]byte("hello"))
+	fs.Stat("/test.txt")
+
+	stats := fs.Collector().Stats()
+
+	if got := stats.Operations["write"]; got.Count != 1 || got.Errors != 0 {
+		t.Errorf("Unexpected write stats: %+v", got)
+	}
+	if got := stats.Operations["stat"]; got.Count != 1 || got.Errors != 1 {
+		t.Errorf("Unexpected stat stats: %+v", got)
+	}
+	if stats.BytesWritten != 5 {
+		t.Errorf("Expected 5 bytes written, got %d", stats.BytesWritten)
+	}
+	if stats.OpenFiles != 1 {
+		t.Errorf("Expected 1 open file, got %d", stats.OpenFiles)
+	}
+
+	f.Close()
+}
+
+func TestPublishExpvar(t *testing.T) {
+	fs := New(newMockFS())
+	// Publishing under a name again replaces the collector, so the test can
+	// run more than once in a process
+	if err := fs.Collector().PublishExpvar("metricsfs_test"); err != nil {
+		t.Fatalf("PublishExpvar failed: %v", err)
+	}
+
+	fs.Stat("/test.txt")
+
+	v := expvar.Get("metricsfs_test")
+	if v == nil {
+		t.Fatal("Expected expvar to be published")
+	}
+
+	var stats Stats
+	if err := json.Unmarshal([]byte(v.String()), &stats); err != nil {
+		t.Fatalf("Failed to decode expvar: %v", err)
+	}
+	if stats.Operations["stat"].Count != 1 {
+		t.Errorf("Expected 1 stat in expvar, got %d", stats.Operations["stat"].Count)
+	}
+
+	// Closing the collector withdraws it from the variable
+	fs.Collector().Close()
+	if got := v.String(); got != "null" {
+		t.Errorf("Expected null after Close, got %s", got)
+	}
+
+	// A new collector takes over the name
+	replacement := New(newMockFS())
+	if err := replacement.Collector().PublishExpvar("metricsfs_test"); err != nil {
+		t.Fatalf("Republishing failed: %v", err)
+	}
+	if got := v.String(); got == "null" {
+		t.Error("Expected the replacement's stats")
+	}
+	replacement.Collector().Close()
+}
+
+var expvarConflicts atomic.Int64
+
+func TestPublishExpvarConflict(t *testing.T) {
+	name := fmt.Sprintf("%s_%d", t.Name(), expvarConflicts.Add(1))
+	expvar.NewInt(name)
+
+	if err := New(newMockFS()).Collector().PublishExpvar(name); err == nil {
+		t.Error("Expected an error for a name in use")
+	}
+}
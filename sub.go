@@ -0,0 +1,118 @@
+package metricsfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// rootLabel is the label that identifies the sub-filesystem an operation was
+// issued through. See Config.EnableRootLabel.
+const rootLabel = "root"
+
+// rootKey is the context key holding the directory of a sub-filesystem.
+type rootKey struct{}
+
+// rootFromContext returns the sub-filesystem directory carried by ctx, or ""
+// if it has none.
+func rootFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	root, _ := ctx.Value(rootKey{}).(string)
+	return root
+}
+
+// sub returns the instrumented view of the subtree rooted at dir. Relative
+// directories are resolved against the working directory of the underlying
+// filesystem, so that the view does not move with later Chdir calls.
+func (m *MetricsFS) sub(dir string) (fs.FS, error) {
+	root := path.Clean(dir)
+	if !path.IsAbs(root) {
+		if wd, err := m.fs.Getwd(); err == nil {
+			root = path.Join(wd, root)
+		}
+	}
+
+	info, err := m.fs.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errors.New("not a directory")}
+	}
+
+	view := *m
+	view.ctx = context.WithValue(m.ctx, rootKey{}, root)
+	return &subFS{fs: &view, root: root}, nil
+}
+
+// subFS is a read-only fs.FS over the subtree of a MetricsFS, whose
+// operations are recorded by the MetricsFS with the subtree as their root.
+type subFS struct {
+	fs   *MetricsFS
+	root string
+}
+
+var (
+	_ fs.SubFS      = (*subFS)(nil)
+	_ fs.ReadDirFS  = (*subFS)(nil)
+	_ fs.ReadFileFS = (*subFS)(nil)
+	_ fs.StatFS     = (*subFS)(nil)
+)
+
+// path returns the path of name in the underlying filesystem, or an error
+// if name is not a valid fs.FS path.
+func (s *subFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(s.root, name), nil
+}
+
+// Open opens the named file for reading.
+func (s *subFS) Open(name string) (fs.File, error) {
+	p, err := s.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fs.Open(p)
+}
+
+// Sub returns the subtree of this subtree rooted at dir, recorded with it
+// as root.
+func (s *subFS) Sub(dir string) (fs.FS, error) {
+	p, err := s.path("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return s.fs.Sub(p)
+}
+
+// ReadDir reads the named directory.
+func (s *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := s.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fs.ReadDir(p)
+}
+
+// ReadFile reads the named file.
+func (s *subFS) ReadFile(name string) ([]byte, error) {
+	p, err := s.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fs.ReadFile(p)
+}
+
+// Stat returns file information for the named file.
+func (s *subFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := s.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fs.Stat(p)
+}
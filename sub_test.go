@@ -0,0 +1,140 @@
+package metricsfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newSubTestFS returns a MetricsFS over the OS filesystem and the slash path
+// of a temporary directory holding a.txt and dir/b.txt.
+func newSubTestFS(t *testing.T, config Config) (*MetricsFS, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "dir/b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	return NewWithConfig(base, config), filepath.ToSlash(dir)
+}
+
+func TestSubIsInstrumented(t *testing.T) {
+	mfs, root := newSubTestFS(t, DefaultConfig())
+
+	sub, err := mfs.Sub(root)
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+
+	data, err := fs.ReadFile(sub, "a.txt")
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+	if _, err := fs.Stat(sub, "dir/b.txt"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	f, err := sub.Open("dir/b.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Close()
+
+	c := mfs.collector
+	for _, op := range []string{"sub", "readfile", "stat", "open", "close"} {
+		if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues(op, "success")); v != 1 {
+			t.Errorf("Expected 1 %s operation, got %v", op, v)
+		}
+	}
+	if v := testutil.ToFloat64(c.bytesReadTotal); v != 5 {
+		t.Errorf("Expected 5 bytes read, got %v", v)
+	}
+}
+
+func TestSubConformance(t *testing.T) {
+	mfs, root := newSubTestFS(t, DefaultConfig())
+
+	sub, err := mfs.Sub(root)
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	if err := fstest.TestFS(sub, "a.txt", "dir/b.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubErrors(t *testing.T) {
+	mfs, root := newSubTestFS(t, DefaultConfig())
+
+	if _, err := mfs.Sub(root + "/a.txt"); err == nil {
+		t.Error("Sub of a file should fail")
+	}
+	if _, err := mfs.Sub(root + "/missing"); err == nil {
+		t.Error("Sub of a missing directory should fail")
+	}
+	if v := testutil.ToFloat64(mfs.collector.operationsTotal.WithLabelValues("sub", "error")); v != 2 {
+		t.Errorf("Expected 2 failed sub operations, got %v", v)
+	}
+
+	sub, err := mfs.Sub(root)
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	if _, err := sub.Open("../a.txt"); err == nil {
+		t.Error("Open outside of the subtree should fail")
+	}
+}
+
+func TestSubRootLabel(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableRootLabel = true
+	mfs, root := newSubTestFS(t, config)
+
+	if _, err := mfs.Stat(root + "/a.txt"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	sub, err := mfs.Sub(root)
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	if _, err := fs.Stat(sub, "a.txt"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	nested, err := fs.Sub(sub, "dir")
+	if err != nil {
+		t.Fatalf("nested Sub failed: %v", err)
+	}
+	if _, err := fs.ReadFile(nested, "b.txt"); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	c := mfs.collector
+	tests := []struct {
+		op, root string
+	}{
+		{"stat", ""},
+		{"stat", root},
+		{"readfile", root + "/dir"},
+	}
+	for _, tt := range tests {
+		if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues(tt.op, "success", tt.root)); v != 1 {
+			t.Errorf("Expected 1 %s operation with root %q, got %v", tt.op, tt.root, v)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package metricsfs
+
+import (
+	"context"
+	"os"
+
+	"github.com/absfs/absfs"
+)
+
+// Compile-time interface compliance check
+var _ absfs.SymlinkFileSystem = (*SymlinkMetricsFS)(nil)
+
+// SymlinkMetricsFS is a MetricsFS over an absfs.SymlinkFileSystem. Its
+// symbolic link operations call the underlying filesystem directly, where
+// those of a MetricsFS depend on the methods it happens to have, falling
+// back to Stat or failing with os.ErrInvalid.
+type SymlinkMetricsFS struct {
+	*MetricsFS
+
+	sfs absfs.SymlinkFileSystem
+}
+
+// NewSymlink creates a new SymlinkMetricsFS that wraps fs, with default
+// configuration. For custom configuration, use NewSymlinkWithConfig.
+func NewSymlink(fs absfs.SymlinkFileSystem) *SymlinkMetricsFS {
+	return NewSymlinkWithConfig(fs, DefaultConfig())
+}
+
+// NewSymlinkWithConfig creates a new SymlinkMetricsFS with custom
+// configuration.
+func NewSymlinkWithConfig(fs absfs.SymlinkFileSystem, config Config) *SymlinkMetricsFS {
+	return &SymlinkMetricsFS{MetricsFS: NewWithConfig(fs, config), sfs: fs}
+}
+
+// WithContext returns a shallow copy of s whose operations are recorded with
+// ctx. See MetricsFS.WithContext.
+func (s *SymlinkMetricsFS) WithContext(ctx context.Context) *SymlinkMetricsFS {
+	return &SymlinkMetricsFS{MetricsFS: s.MetricsFS.WithContext(ctx), sfs: s.sfs}
+}
+
+// Lstat returns file information without following symlinks.
+func (s *SymlinkMetricsFS) Lstat(name string) (os.FileInfo, error) {
+	return s.lstat(s.sfs.Lstat, name)
+}
+
+// Lchown changes the ownership of a file without following symlinks.
+func (s *SymlinkMetricsFS) Lchown(name string, uid, gid int) error {
+	return s.lchown(s.sfs.Lchown, name, uid, gid)
+}
+
+// Readlink reads the target of a symbolic link.
+func (s *SymlinkMetricsFS) Readlink(name string) (string, error) {
+	return s.readlink(s.sfs.Readlink, name)
+}
+
+// Symlink creates a symbolic link.
+func (s *SymlinkMetricsFS) Symlink(oldname, newname string) error {
+	return s.symlink(s.sfs.Symlink, oldname, newname)
+}
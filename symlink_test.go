@@ -0,0 +1,64 @@
+package metricsfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewSymlink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "target"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	sfs := NewSymlink(base)
+
+	// Usable where a typed SymlinkFileSystem is required
+	var fs absfs.SymlinkFileSystem = sfs
+
+	root := filepath.ToSlash(dir)
+	if err := fs.Symlink(root+"/target", root+"/link"); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	target, err := fs.Readlink(root + "/link")
+	if err != nil || target != root+"/target" {
+		t.Errorf("Readlink() = %q, %v, want %q", target, err, root+"/target")
+	}
+	info, err := fs.Lstat(root + "/link")
+	if err != nil {
+		t.Fatalf("Lstat failed: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat returned mode %v, want a symbolic link", info.Mode())
+	}
+
+	c := sfs.collector
+	for _, op := range []string{"symlink", "readlink", "lstat"} {
+		if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues(op, "success")); v != 1 {
+			t.Errorf("Expected 1 %s operation, got %v", op, v)
+		}
+	}
+}
+
+func TestSymlinkWithContext(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	sfs := NewSymlink(base)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	if got := sfs.WithContext(ctx).Context(); got.Value(tenantKey{}) != "acme" {
+		t.Error("WithContext did not bind the context")
+	}
+}
@@ -0,0 +1,413 @@
+package metricsfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/absfs/absfs"
+)
+
+// ErrTrashDisabled is returned by the trash methods of a MetricsFS whose
+// Config.Trash has no Dir.
+var ErrTrashDisabled = errors.New("metricsfs: trash not enabled")
+
+// ErrContainsTrash is returned by RemoveAll for a directory containing the
+// trash directory, which cannot be moved into itself.
+var ErrContainsTrash = errors.New("metricsfs: directory contains the trash")
+
+// TrashConfig configures the trash of a MetricsFS, into which Remove and
+// RemoveAll move entries instead of deleting them. See Config.Trash.
+type TrashConfig struct {
+	// Dir is the absolute path of the directory removed entries are moved
+	// to. It must be on the same underlying filesystem as the entries, since
+	// they are moved with Rename. Entries under Dir are deleted for good,
+	// and RemoveAll of a directory containing Dir fails with
+	// ErrContainsTrash. The trash is disabled when empty
+	Dir string
+
+	// Retention is how long entries are kept in the trash before the
+	// background purger deletes them (default: 0, kept until PurgeTrash)
+	Retention time.Duration
+
+	// PurgeInterval is the interval at which the background purger runs
+	// (default: Retention / 10, at least one minute)
+	PurgeInterval time.Duration
+}
+
+// applyDefaults fills in default values for unset trash options.
+func (t *TrashConfig) applyDefaults() {
+	if t.Retention > 0 && t.PurgeInterval == 0 {
+		t.PurgeInterval = max(t.Retention/10, time.Minute)
+	}
+}
+
+// TrashEntry describes an entry in the trash.
+type TrashEntry struct {
+	// ID identifies the entry for MetricsFS.Restore
+	ID string `json:"id"`
+
+	// Path is the absolute path the entry was removed from
+	Path string `json:"path"`
+
+	// Deleted is when the entry was moved to the trash
+	Deleted time.Time `json:"deleted"`
+
+	// Size is the total size in bytes of the entry, including the files
+	// below it for a directory
+	Size int64 `json:"size"`
+}
+
+// trash moves removed entries into TrashConfig.Dir. The entries are kept
+// in Dir/files under their ID, and described by a JSON TrashEntry in
+// Dir/info.
+type trash struct {
+	config TrashConfig
+	dir    string
+}
+
+// newTrash returns the trash configured by config, or nil if disabled.
+func newTrash(config TrashConfig) *trash {
+	if config.Dir == "" {
+		return nil
+	}
+	config.applyDefaults()
+	return &trash{config: config, dir: cleanPath(config.Dir)}
+}
+
+// join joins elem to the trash directory.
+func (t *trash) join(elem ...string) string {
+	if path.IsAbs(t.dir) {
+		return path.Join(append([]string{t.dir}, elem...)...)
+	}
+	return filepath.Join(append([]string{t.dir}, elem...)...)
+}
+
+// contains reports whether the absolute path name is the trash directory
+// or below it.
+func (t *trash) contains(name string) bool {
+	name = cleanPath(name)
+	return name == t.dir ||
+		strings.HasPrefix(name, t.dir+"/") ||
+		strings.HasPrefix(name, t.dir+string(filepath.Separator))
+}
+
+// within reports whether the trash directory is below the absolute path
+// name.
+func (t *trash) within(name string) bool {
+	name = cleanPath(name)
+	for _, sep := range []string{"/", string(filepath.Separator)} {
+		if strings.HasPrefix(t.dir, strings.TrimSuffix(name, sep)+sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPath cleans name as a slash or an OS path.
+func cleanPath(name string) string {
+	if path.IsAbs(name) {
+		return path.Clean(name)
+	}
+	return filepath.Clean(name)
+}
+
+// absPath returns name resolved against the working directory.
+func (m *MetricsFS) absPath(name string) string {
+	if isAbsPath(name) {
+		return name
+	}
+	dir := m.wd.get(m.fs)
+	switch {
+	case dir == "":
+		return name
+	case path.IsAbs(dir):
+		return path.Join(dir, name)
+	default:
+		return filepath.Join(dir, name)
+	}
+}
+
+// remove implements Remove and RemoveAll, moving name to the trash when
+// enabled. Remove moves files and symlinks only, since removing an empty
+// directory loses no data.
+func (m *MetricsFS) remove(op Op, name string) error {
+	removeBase := m.fs.Remove
+	if op == OpRemoveAll {
+		removeBase = m.fs.RemoveAll
+	}
+
+	t := m.trash
+	if t == nil || t.contains(m.absPath(name)) {
+		return removeBase(name)
+	}
+	info, err := lstat(m.fs, name)
+	if err != nil || (op == OpRemove && info.IsDir()) {
+		// Let the filesystem report the error, or remove the directory
+		return removeBase(name)
+	}
+	if t.within(m.absPath(name)) {
+		return &os.PathError{Op: string(op), Path: name, Err: ErrContainsTrash}
+	}
+
+	entry, err := t.put(m.fs, name, m.absPath(name), info, m.collector.clock.Now())
+	if err == nil {
+		m.collector.recordTrashed(op, entry.Size)
+	}
+	return err
+}
+
+// put moves name, whose absolute path is abs, into the trash.
+func (t *trash) put(fsys absfs.FileSystem, name, abs string, info os.FileInfo, now time.Time) (TrashEntry, error) {
+	entry := TrashEntry{
+		ID:      fmt.Sprintf("%d-%08x", now.UnixNano(), rand.Uint32()),
+		Path:    abs,
+		Deleted: now,
+		Size:    treeSize(fsys, name, info),
+	}
+
+	for _, dir := range []string{t.join("files"), t.join("info")} {
+		if err := fsys.MkdirAll(dir, 0700); err != nil {
+			return TrashEntry{}, err
+		}
+	}
+	if err := writeTrashInfo(fsys, t.join("info", entry.ID), entry); err != nil {
+		return TrashEntry{}, err
+	}
+	if err := fsys.Rename(name, t.join("files", entry.ID)); err != nil {
+		fsys.Remove(t.join("info", entry.ID))
+		return TrashEntry{}, err
+	}
+	return entry, nil
+}
+
+// writeTrashInfo writes entry to name.
+func writeTrashInfo(fsys absfs.FileSystem, name string, entry TrashEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// entries lists the entries in the trash, oldest first. Entries whose
+// description cannot be read are skipped.
+func (t *trash) entries(fsys absfs.FileSystem) ([]TrashEntry, error) {
+	dir, err := fsys.Open(t.join("info"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]TrashEntry, 0, len(names))
+	for _, id := range names {
+		if entry, err := t.entry(fsys, id); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Deleted.Before(entries[j].Deleted) })
+	return entries, nil
+}
+
+// entry reads the description of the entry id.
+func (t *trash) entry(fsys absfs.FileSystem, id string) (TrashEntry, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return TrashEntry{}, fmt.Errorf("metricsfs: invalid trash entry %q: %w", id, os.ErrNotExist)
+	}
+	f, err := fsys.Open(t.join("info", id))
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	var entry TrashEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return TrashEntry{}, fmt.Errorf("metricsfs: trash entry %q: %w", id, err)
+	}
+	return entry, nil
+}
+
+// treeSize returns the size of name and, for a directory, of everything
+// below it, as read from the underlying filesystem.
+func treeSize(fsys absfs.FileSystem, name string, info os.FileInfo) int64 {
+	if !info.IsDir() {
+		return info.Size()
+	}
+	dir, err := fsys.Open(name)
+	if err != nil {
+		return 0
+	}
+	children, _ := dir.Readdir(-1)
+	dir.Close()
+
+	var size int64
+	for _, child := range children {
+		childName := filepath.Join(name, child.Name())
+		if path.IsAbs(name) {
+			childName = path.Join(name, child.Name())
+		}
+		size += treeSize(fsys, childName, child)
+	}
+	return size
+}
+
+// lstat stats name without following symlinks when fsys supports it.
+func lstat(fsys absfs.FileSystem, name string) (os.FileInfo, error) {
+	if sfs, ok := fsys.(interface {
+		Lstat(name string) (os.FileInfo, error)
+	}); ok {
+		return sfs.Lstat(name)
+	}
+	return fsys.Stat(name)
+}
+
+// TrashEntries lists the entries in the trash, oldest first.
+func (m *MetricsFS) TrashEntries() ([]TrashEntry, error) {
+	if m.trash == nil {
+		return nil, ErrTrashDisabled
+	}
+	return m.trash.entries(m.fs)
+}
+
+// Restore moves the trash entry id back to the path it was removed from.
+// It fails with an error wrapping os.ErrExist if that path exists again.
+func (m *MetricsFS) Restore(id string) error {
+	t := m.trash
+	if t == nil {
+		return ErrTrashDisabled
+	}
+
+	entry, err := t.entry(m.fs, id)
+	if err == nil {
+		if _, statErr := lstat(m.fs, entry.Path); statErr == nil {
+			err = &os.PathError{Op: "restore", Path: entry.Path, Err: os.ErrExist}
+		}
+	}
+	if err == nil {
+		err = m.fs.Rename(t.join("files", id), entry.Path)
+	}
+	if err == nil {
+		m.fs.Remove(t.join("info", id))
+	}
+
+	m.collector.recordRestore(err)
+	return err
+}
+
+// PurgeTrash deletes the trash entries moved to the trash more than
+// olderThan ago, or all entries when olderThan is 0, and returns how many
+// were deleted.
+func (m *MetricsFS) PurgeTrash(olderThan time.Duration) (int, error) {
+	if m.trash == nil {
+		return 0, ErrTrashDisabled
+	}
+	return m.purgeTrash(m.collector.clock.Now().Add(-olderThan), "manual")
+}
+
+// purgeTrash deletes the trash entries deleted before cutoff, recording
+// the purge under trigger.
+func (m *MetricsFS) purgeTrash(cutoff time.Time, trigger string) (int, error) {
+	t := m.trash
+	entries, err := t.entries(m.fs)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	purged := 0
+	for _, entry := range entries {
+		if !entry.Deleted.Before(cutoff) {
+			break
+		}
+		if err := m.fs.RemoveAll(t.join("files", entry.ID)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.fs.Remove(t.join("info", entry.ID))
+		m.collector.recordPurge(trigger, entry.Size)
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+// runTrashPurger periodically purges trash entries older than Retention
+// until the collector is closed.
+func (m *MetricsFS) runTrashPurger() {
+	defer m.collector.background.Done()
+
+	ticker := time.NewTicker(m.trash.config.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.collector.done:
+			return
+		case <-ticker.C:
+			m.purgeTrash(m.collector.clock.Now().Add(-m.trash.config.Retention), "retention")
+		}
+	}
+}
+
+// startTrashPurger starts the background purger if Retention is set and the
+// collector runs none yet, and returns m.
+func (m *MetricsFS) startTrashPurger() *MetricsFS {
+	if m.trash != nil && m.trash.config.Retention > 0 && m.collector.addBackgroundOnce("trash") {
+		go m.runTrashPurger()
+	}
+	return m
+}
+
+// recordTrashed records an entry of size bytes moved to the trash by op.
+func (c *Collector) recordTrashed(op Op, size int64) {
+	if c.closed.Load() || c.trashedTotal == nil {
+		return
+	}
+	c.trashedTotal.WithLabelValues(string(op)).Inc()
+	c.trashedBytesTotal.WithLabelValues(string(op)).Add(float64(size))
+}
+
+// recordRestore records the restore of a trash entry.
+func (c *Collector) recordRestore(err error) {
+	if c.closed.Load() || c.trashRestoresTotal == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	c.trashRestoresTotal.WithLabelValues(result).Inc()
+}
+
+// recordPurge records the purge of an entry of size bytes.
+func (c *Collector) recordPurge(trigger string, size int64) {
+	if c.closed.Load() || c.trashPurgedTotal == nil {
+		return
+	}
+	c.trashPurgedTotal.WithLabelValues(trigger).Inc()
+	c.trashPurgedBytesTotal.WithLabelValues(trigger).Add(float64(size))
+}
@@ -0,0 +1,235 @@
+package metricsfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTrashFS returns a MetricsFS over a temporary directory with its trash
+// in the directory's .trash.
+func newTrashFS(t *testing.T, trash TrashConfig) (*MetricsFS, string) {
+	t.Helper()
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	dir := t.TempDir()
+	trash.Dir = filepath.Join(dir, ".trash")
+	config := DefaultConfig()
+	config.Trash = trash
+	fs := NewWithConfig(base, config)
+	t.Cleanup(func() { fs.Collector().Close() })
+	return fs, dir
+}
+
+func TestTrashRemoveAndRestore(t *testing.T) {
+	fs, dir := newTrashFS(t, TrashConfig{})
+	c := fs.Collector()
+
+	file := filepath.Join(dir, "file")
+	tree := filepath.Join(dir, "tree")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tree, "sub"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tree, "sub", "a"), []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := fs.Remove(file); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := fs.RemoveAll(tree); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	for _, name := range []string{file, tree} {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected %s to be gone, got %v", name, err)
+		}
+	}
+
+	entries, err := fs.TrashEntries()
+	if err != nil {
+		t.Fatalf("TrashEntries failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != file || entries[0].Size != 5 || entries[1].Path != tree || entries[1].Size != 10 {
+		t.Fatalf("Unexpected trash entries %+v", entries)
+	}
+	if got := testutil.ToFloat64(c.trashedBytesTotal.WithLabelValues("removeall")); got != 10 {
+		t.Errorf("Expected 10 bytes trashed by RemoveAll, got %v", got)
+	}
+
+	if err := fs.Restore(entries[1].ID); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(tree, "sub", "a")); err != nil || string(data) != "0123456789" {
+		t.Errorf("Expected the tree to be restored, got %q, %v", data, err)
+	}
+
+	// A file recreated at the original path is not overwritten
+	if err := os.WriteFile(file, []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := fs.Restore(entries[0].ID); !errors.Is(err, os.ErrExist) {
+		t.Errorf("Expected restore over an existing file to fail with ErrExist, got %v", err)
+	}
+
+	if got := testutil.ToFloat64(c.trashRestoresTotal.WithLabelValues("success")); got != 1 {
+		t.Errorf("Expected 1 restore, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.trashRestoresTotal.WithLabelValues("error")); got != 1 {
+		t.Errorf("Expected 1 failed restore, got %v", got)
+	}
+}
+
+func TestTrashRemoveDirectory(t *testing.T) {
+	fs, dir := newTrashFS(t, TrashConfig{})
+
+	// Empty directories are removed, non-empty ones fail as without a trash
+	empty := filepath.Join(dir, "empty")
+	full := filepath.Join(dir, "full")
+	os.Mkdir(empty, 0755)
+	os.Mkdir(full, 0755)
+	os.WriteFile(filepath.Join(full, "a"), nil, 0644)
+
+	if err := fs.Remove(empty); err != nil {
+		t.Errorf("Remove of an empty directory failed: %v", err)
+	}
+	if err := fs.Remove(full); err == nil {
+		t.Error("Expected Remove of a non-empty directory to fail")
+	}
+	if err := fs.Remove(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected Remove of a missing file to fail with ErrNotExist, got %v", err)
+	}
+
+	if entries, _ := fs.TrashEntries(); len(entries) != 0 {
+		t.Errorf("Expected an empty trash, got %+v", entries)
+	}
+}
+
+func TestTrashRemoveAllContainingTrash(t *testing.T) {
+	fs, dir := newTrashFS(t, TrashConfig{})
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("hello"), 0644)
+	if err := fs.Remove(file); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	os.WriteFile(file, []byte("hello"), 0644)
+
+	if err := fs.RemoveAll(dir); !errors.Is(err, ErrContainsTrash) {
+		t.Fatalf("Expected ErrContainsTrash, got %v", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("Expected %s to be kept, got %v", file, err)
+	}
+	if entries, _ := fs.TrashEntries(); len(entries) != 1 {
+		t.Errorf("Expected the trash to be kept, got %+v", entries)
+	}
+}
+
+func TestTrashClock(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	dir := t.TempDir()
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	config := DefaultConfig()
+	config.Clock = clock
+	config.Trash = TrashConfig{Dir: filepath.Join(dir, ".trash")}
+	fs := NewWithConfig(base, config)
+	defer fs.Collector().Close()
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("hello"), 0644)
+	if err := fs.Remove(file); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	entries, err := fs.TrashEntries()
+	if err != nil || len(entries) != 1 || !entries[0].Deleted.Equal(time.Unix(1000, 0)) {
+		t.Fatalf("Expected an entry deleted at the clock's time, got %+v, %v", entries, err)
+	}
+
+	if n, err := fs.PurgeTrash(time.Hour); err != nil || n != 0 {
+		t.Errorf("Expected no entries older than an hour, purged %d, %v", n, err)
+	}
+	clock.advance(2 * time.Hour)
+	if n, err := fs.PurgeTrash(time.Hour); err != nil || n != 1 {
+		t.Errorf("Expected 1 entry purged two clock hours later, purged %d, %v", n, err)
+	}
+}
+
+func TestTrashPurge(t *testing.T) {
+	fs, dir := newTrashFS(t, TrashConfig{})
+	c := fs.Collector()
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("hello"), 0644)
+	if err := fs.Remove(file); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	if n, err := fs.PurgeTrash(time.Hour); err != nil || n != 0 {
+		t.Errorf("Expected no entries older than an hour, purged %d, %v", n, err)
+	}
+	if n, err := fs.PurgeTrash(0); err != nil || n != 1 {
+		t.Errorf("Expected 1 entry purged, purged %d, %v", n, err)
+	}
+	if entries, _ := fs.TrashEntries(); len(entries) != 0 {
+		t.Errorf("Expected an empty trash, got %+v", entries)
+	}
+	if got := testutil.ToFloat64(c.trashPurgedBytesTotal.WithLabelValues("manual")); got != 5 {
+		t.Errorf("Expected 5 bytes purged, got %v", got)
+	}
+
+	// Removing from the trash itself deletes for good
+	os.WriteFile(file, []byte("hello"), 0644)
+	fs.Remove(file)
+	if err := fs.RemoveAll(filepath.Join(dir, ".trash")); err != nil {
+		t.Fatalf("RemoveAll of the trash failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".trash")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the trash to be deleted, got %v", err)
+	}
+}
+
+func TestTrashDisabled(t *testing.T) {
+	fs := New(newMockFS())
+	if _, err := fs.TrashEntries(); !errors.Is(err, ErrTrashDisabled) {
+		t.Errorf("Expected ErrTrashDisabled, got %v", err)
+	}
+	if err := fs.Restore("x"); !errors.Is(err, ErrTrashDisabled) {
+		t.Errorf("Expected ErrTrashDisabled, got %v", err)
+	}
+}
+
+func TestTrashPurger(t *testing.T) {
+	fs, dir := newTrashFS(t, TrashConfig{Retention: time.Millisecond, PurgeInterval: 10 * time.Millisecond})
+	c := fs.Collector()
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("hello"), 0644)
+	if err := fs.Remove(file); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(c.trashPurgedTotal.WithLabelValues("retention")) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the purger to delete the entry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if entries, _ := fs.TrashEntries(); len(entries) != 0 {
+		t.Errorf("Expected an empty trash, got %+v", entries)
+	}
+}
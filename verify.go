@@ -0,0 +1,192 @@
+package metricsfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"sync"
+)
+
+// Manifest supplies the expected checksums of files, for Config.Verify.
+type Manifest interface {
+	// Checksum returns the expected checksum of the file at path, as
+	// computed by VerifyConfig.NewHash, and whether the manifest lists path.
+	// It is called when the file is opened.
+	Checksum(path string) (sum []byte, ok bool)
+}
+
+// VerifyConfig configures the verification of files read in full against a
+// Manifest. See Config.Verify.
+type VerifyConfig struct {
+	// Manifest supplies the expected checksums. Verification is disabled
+	// when nil
+	Manifest Manifest
+
+	// PathGroups limits verification to files whose PathGroupFunc group,
+	// e.g. "/media", is one of these (default: all files listed in the
+	// manifest)
+	PathGroups []string
+
+	// NewHash creates the hash files are checksummed with
+	// (default: sha256.New)
+	NewHash func() hash.Hash
+}
+
+// ChecksumMismatch describes a file read in full whose contents did not
+// match its checksum in the manifest. See Config.OnChecksumMismatch.
+type ChecksumMismatch struct {
+	// Path is the path the file was opened or read with
+	Path string
+
+	// Expected is the checksum listed in the manifest
+	Expected []byte
+
+	// Actual is the checksum of the contents read
+	Actual []byte
+
+	// Bytes is the number of bytes read
+	Bytes int64
+}
+
+// verifier holds the compiled VerifyConfig.
+type verifier struct {
+	config VerifyConfig
+	groups map[string]bool // nil means all groups
+}
+
+// newVerifier compiles config.
+func newVerifier(config VerifyConfig) *verifier {
+	if config.NewHash == nil {
+		config.NewHash = sha256.New
+	}
+	v := &verifier{config: config}
+	if len(config.PathGroups) > 0 {
+		v.groups = make(map[string]bool, len(config.PathGroups))
+		for _, group := range config.PathGroups {
+			v.groups[group] = true
+		}
+	}
+	return v
+}
+
+// expectedChecksum returns the manifest checksum of path when path is to be
+// verified.
+func (c *Collector) expectedChecksum(path string) ([]byte, bool) {
+	v := c.verify
+	if v == nil || path == "" || c.closed.Load() {
+		return nil, false
+	}
+	if v.groups != nil && !v.groups[c.config.PathGroupFunc(path)] {
+		return nil, false
+	}
+	return v.config.Manifest.Checksum(path)
+}
+
+// verifyContents verifies data, the full contents of the file at path.
+func (c *Collector) verifyContents(path string, data []byte) {
+	expected, ok := c.expectedChecksum(path)
+	if !ok {
+		return
+	}
+	h := c.verify.config.NewHash()
+	h.Write(data)
+	c.recordVerification(path, expected, h.Sum(nil), int64(len(data)))
+}
+
+// recordVerification records the verification of a file read in full.
+func (c *Collector) recordVerification(path string, expected, actual []byte, n int64) {
+	if c.closed.Load() {
+		return
+	}
+
+	result := "match"
+	if !bytes.Equal(expected, actual) {
+		result = "mismatch"
+	}
+	c.checksumVerificationsTotal.WithLabelValues(c.pathGroup(path), result).Inc()
+
+	if result == "mismatch" && c.config.OnChecksumMismatch != nil {
+		mismatch := ChecksumMismatch{Path: path, Expected: expected, Actual: actual, Bytes: n}
+		c.dispatch("checksum_mismatch", func() { c.config.OnChecksumMismatch(mismatch) })
+	}
+}
+
+// fileVerifier checksums the contents of an open file as it is read from
+// start to end. Verification is abandoned when the file is read out of
+// order, moved within or written to.
+type fileVerifier struct {
+	expected []byte
+
+	mu       sync.Mutex
+	hash     hash.Hash // nil once verified or abandoned
+	hashed   int64     // bytes hashed, the offset the next read must start at
+	position int64     // offset of the next Read
+}
+
+// newFileVerifier returns a verifier for the file opened on path, or nil if
+// it is not to be verified.
+func (c *Collector) newFileVerifier(path string) *fileVerifier {
+	expected, ok := c.expectedChecksum(path)
+	if !ok {
+		return nil
+	}
+	return &fileVerifier{expected: expected, hash: c.verify.config.NewHash()}
+}
+
+// read hashes p, n bytes read at off, or at the current position if off is
+// -1, and reports the sum once err marks the end of the file.
+func (v *fileVerifier) read(p []byte, off int64, n int, err error) (sum []byte, size int64, done bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.hash == nil {
+		return nil, 0, false
+	}
+	if off < 0 {
+		off = v.position
+		v.position += int64(n)
+	}
+	if off != v.hashed || (err != nil && !errors.Is(err, io.EOF)) {
+		v.hash = nil
+		return nil, 0, false
+	}
+	v.hash.Write(p[:n])
+	v.hashed += int64(n)
+	if err == nil {
+		return nil, 0, false
+	}
+
+	sum = v.hash.Sum(nil)
+	v.hash = nil
+	return sum, v.hashed, true
+}
+
+// seek records a move of the file's position to offset.
+func (v *fileVerifier) seek(offset int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.position = offset
+	if offset != v.hashed {
+		v.hash = nil
+	}
+}
+
+// abandon stops the verification, after the file was modified.
+func (v *fileVerifier) abandon() {
+	v.mu.Lock()
+	v.hash = nil
+	v.mu.Unlock()
+}
+
+// verifyRead verifies a read on f if its contents are being verified.
+func (f *MetricsFile) verifyRead(p []byte, off int64, n int, err error) {
+	if f.verifier == nil {
+		return
+	}
+	if sum, size, done := f.verifier.read(p, off, max(n, 0), err); done {
+		f.collector.recordVerification(f.path, f.verifier.expected, sum, size)
+	}
+}
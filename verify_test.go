@@ -0,0 +1,122 @@
+package metricsfs
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// mapManifest is a Manifest of SHA-256 checksums keyed by path.
+type mapManifest map[string][]byte
+
+func (m mapManifest) Checksum(path string) ([]byte, bool) {
+	sum, ok := m[path]
+	return sum, ok
+}
+
+func sha256Sum(data string) []byte {
+	sum := sha256.Sum256([]byte(data))
+	return sum[:]
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	bad := filepath.Join(dir, "bad")
+	partial := filepath.Join(dir, "partial")
+	for _, name := range []string{good, bad, partial} {
+		if err := os.WriteFile(name, []byte("hello, world"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	var mismatches []ChecksumMismatch
+	config := DefaultConfig()
+	config.PathGroupFunc = func(string) string { return "content" }
+	config.Verify = VerifyConfig{Manifest: mapManifest{
+		good:    sha256Sum("hello, world"),
+		bad:     sha256Sum("hello, there"),
+		partial: sha256Sum("something else"),
+	}}
+	config.OnChecksumMismatch = func(m ChecksumMismatch) { mismatches = append(mismatches, m) }
+	fs := NewWithConfig(base, config)
+	c := fs.Collector()
+
+	readAll := func(name string) {
+		f, err := fs.Open(name)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer f.Close()
+		buf := make([]byte, 5)
+		for {
+			if _, err := f.Read(buf); err == io.EOF {
+				return
+			} else if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+		}
+	}
+	readAll(good)
+	readAll(bad)
+	if _, err := fs.ReadFile(good); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	// Files not read from start to end are not verified
+	f, err := fs.Open(partial)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Seek(7, io.SeekStart)
+	io.ReadAll(f)
+	f.Close()
+
+	if got := testutil.ToFloat64(c.checksumVerificationsTotal.WithLabelValues("content", "match")); got != 2 {
+		t.Errorf("Expected 2 matches, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.checksumVerificationsTotal.WithLabelValues("content", "mismatch")); got != 1 {
+		t.Errorf("Expected 1 mismatch, got %v", got)
+	}
+
+	if len(mismatches) != 1 {
+		t.Fatalf("Expected 1 mismatch callback, got %d", len(mismatches))
+	}
+	m := mismatches[0]
+	if m.Path != bad || m.Bytes != 12 || string(m.Actual) != string(sha256Sum("hello, world")) {
+		t.Errorf("Unexpected mismatch %+v", m)
+	}
+}
+
+func TestVerifyPathGroups(t *testing.T) {
+	var looked []string
+	config := DefaultConfig()
+	config.Verify = VerifyConfig{
+		Manifest:   manifestFunc(func(path string) ([]byte, bool) { looked = append(looked, path); return nil, false }),
+		PathGroups: []string{"/media"},
+	}
+	fs := NewWithConfig(newMockFS(), config)
+
+	fs.Open("/media/a.jpg")
+	fs.Open("/logs/b.txt")
+
+	if len(looked) != 1 || looked[0] != "/media/a.jpg" {
+		t.Errorf("Expected only /media/a.jpg to be looked up, got %v", looked)
+	}
+}
+
+// manifestFunc adapts a function to Manifest.
+type manifestFunc func(path string) ([]byte, bool)
+
+func (f manifestFunc) Checksum(path string) ([]byte, bool) {
+	return f(path)
+}
@@ -0,0 +1,164 @@
+package metricsfs
+
+import (
+	"io/fs"
+	"path"
+	"sync/atomic"
+)
+
+// FastWalker is implemented by filesystems that provide an optimized,
+// possibly parallel, directory tree traversal. Implementations may invoke
+// fn concurrently from multiple goroutines.
+type FastWalker interface {
+	FastWalk(root string, fn fs.WalkDirFunc) error
+}
+
+// FastWalk walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root.
+//
+// If the underlying filesystem implements FastWalker its traversal is used
+// directly and fn may be called concurrently. Otherwise FastWalk falls back to
+// a serial walk built on the instrumented ReadDir. In both cases the walk is
+// recorded as a "fastwalk" operation together with the number of entries
+// visited, errors, entries per second and the peak callback parallelism.
+func (m *MetricsFS) FastWalk(root string, fn fs.WalkDirFunc) error {
+	walker := "generic"
+	var w walkStats
+
+	start := m.collector.startOperation(OpFastWalk)
+	var err error
+	if fw, ok := m.fs.(FastWalker); ok {
+		walker = "fast"
+		err = fw.FastWalk(root, w.wrap(fn))
+	} else {
+		err = walkDir(root, m.Lstat, m.ReadDir, w.wrap(fn))
+	}
+	duration := m.collector.finishOperation(OpFastWalk, start)
+
+	m.collector.recordOperation(m.ctx, OpFastWalk, root, duration, 0, err)
+	m.collector.recordWalk(walker, duration, &w)
+
+	return err
+}
+
+// WalkDir walks the file tree rooted at root like fs.WalkDir, calling fn
+// for each file or directory in the tree, including root. The whole walk is
+// recorded as a single "walkdir" operation, and in the walk metrics with the
+// files and directories visited and the bytes of the files whose Info fn
+// read. The Lstat and ReadDir calls of the walk are recorded as usual.
+func (m *MetricsFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	var w walkStats
+
+	start := m.collector.startOperation(OpWalkDir)
+	err := walkDir(root, m.Lstat, m.ReadDir, w.wrap(fn))
+	duration := m.collector.finishOperation(OpWalkDir, start)
+
+	m.collector.recordOperation(m.ctx, OpWalkDir, root, duration, 0, err)
+	m.collector.recordWalk("generic", duration, &w)
+
+	return err
+}
+
+// walkDir is a serial fs.WalkDir equivalent over lstat and readDir.
+func walkDir(root string, lstat func(string) (fs.FileInfo, error), readDir func(string) ([]fs.DirEntry, error), fn fs.WalkDirFunc) error {
+	info, err := lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDirEntry(root, fs.FileInfoToDirEntry(info), readDir, fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDirEntry recursively walks name, mirroring the semantics of fs.WalkDir.
+func walkDirEntry(name string, d fs.DirEntry, readDir func(string) ([]fs.DirEntry, error), fn fs.WalkDirFunc) error {
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := readDir(name)
+	if err != nil {
+		err = fn(name, d, err)
+		if err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := walkDirEntry(path.Join(name, entry.Name()), entry, readDir, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// walkStats accumulates statistics for a single walk. It is safe for
+// concurrent use by parallel walkers.
+type walkStats struct {
+	entries   atomic.Int64
+	files     atomic.Int64
+	dirs      atomic.Int64
+	bytes     atomic.Int64
+	errors    atomic.Int64
+	active    atomic.Int64
+	maxActive atomic.Int64
+}
+
+// wrap returns a WalkDirFunc that records statistics before delegating to fn.
+func (w *walkStats) wrap(fn fs.WalkDirFunc) fs.WalkDirFunc {
+	return func(path string, d fs.DirEntry, err error) error {
+		active := w.active.Add(1)
+		defer w.active.Add(-1)
+
+		for {
+			max := w.maxActive.Load()
+			if active <= max || w.maxActive.CompareAndSwap(max, active) {
+				break
+			}
+		}
+
+		// A directory that cannot be read is passed to fn a second time,
+		// with the error; it is only counted as an entry the first time
+		if err != nil {
+			w.errors.Add(1)
+		} else {
+			w.entries.Add(1)
+			if d != nil {
+				if d.IsDir() {
+					w.dirs.Add(1)
+				} else {
+					w.files.Add(1)
+					d = &statCountingEntry{DirEntry: d, bytes: &w.bytes}
+				}
+			}
+		}
+
+		return fn(path, d, err)
+	}
+}
+
+// statCountingEntry adds the size of the files whose Info is read to bytes.
+type statCountingEntry struct {
+	fs.DirEntry
+	bytes *atomic.Int64
+}
+
+func (e *statCountingEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err == nil && info.Mode().IsRegular() {
+		e.bytes.Add(info.Size())
+	}
+	return info, err
+}
@@ -0,0 +1,254 @@
+package metricsfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/absfs/osfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// fastWalkerMockFS implements FastWalker by visiting a fixed set of paths
+// concurrently.
+type fastWalkerMockFS struct {
+	mockFS
+	paths []string
+}
+
+func (f *fastWalkerMockFS) FastWalk(root string, fn fs.WalkDirFunc) error {
+	var wg sync.WaitGroup
+	for _, p := range f.paths {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			fn(p, fs.FileInfoToDirEntry(&mockFileInfo{name: p}), nil)
+		}(p)
+	}
+	wg.Wait()
+	return nil
+}
+
+func TestFastWalkUsesBaseWalker(t *testing.T) {
+	base := &fastWalkerMockFS{paths: []string{"/a", "/b", "/c"}}
+	mfs := New(base)
+
+	var mu sync.Mutex
+	var visited []string
+	err := mfs.FastWalk("/", func(path string, d fs.DirEntry, err error) error {
+		mu.Lock()
+		visited = append(visited, path)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FastWalk failed: %v", err)
+	}
+
+	if len(visited) != 3 {
+		t.Errorf("Expected 3 visited paths, got %d", len(visited))
+	}
+
+	c := mfs.collector
+	if v := testutil.ToFloat64(c.walksTotal.WithLabelValues("fast")); v != 1 {
+		t.Errorf("Expected 1 fast walk, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.walkEntriesTotal.WithLabelValues("fast")); v != 3 {
+		t.Errorf("Expected 3 walk entries, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("fastwalk", "success")); v != 1 {
+		t.Errorf("Expected 1 fastwalk operation, got %v", v)
+	}
+}
+
+func TestFastWalkGenericFallback(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub", "skip"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/skip/c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	mfs := New(base)
+
+	root := filepath.ToSlash(dir)
+	var visited []string
+	err = mfs.FastWalk(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "skip" {
+			return fs.SkipDir
+		}
+		visited = append(visited, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FastWalk failed: %v", err)
+	}
+
+	// root, a.txt, sub, sub/b.txt
+	if len(visited) != 4 {
+		t.Errorf("Expected 4 visited paths, got %d: %v", len(visited), visited)
+	}
+
+	c := mfs.collector
+	if v := testutil.ToFloat64(c.walksTotal.WithLabelValues("generic")); v != 1 {
+		t.Errorf("Expected 1 generic walk, got %v", v)
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("readdir", "success")); v != 2 {
+		t.Errorf("Expected 2 instrumented readdirs, got %v", v)
+	}
+}
+
+func TestWalkDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"a.txt": 10, "sub/b.txt": 20, "sub/c.txt": 30} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	mfs := New(base)
+
+	// Only the sizes of the files whose Info is read are counted
+	err = mfs.WalkDir(filepath.ToSlash(dir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() != "c.txt" {
+			d.Info()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+
+	c := mfs.collector
+	for _, tt := range []struct {
+		name string
+		vec  *prometheus.CounterVec
+		want float64
+	}{
+		{"files", c.walkFilesTotal, 3},
+		{"dirs", c.walkDirsTotal, 2},
+		{"bytes", c.walkBytesTotal, 30},
+	} {
+		if got := testutil.ToFloat64(tt.vec.WithLabelValues("generic")); got != tt.want {
+			t.Errorf("Expected %v walk %s, got %v", tt.want, tt.name, got)
+		}
+	}
+	if v := testutil.ToFloat64(c.operationsTotal.WithLabelValues("walkdir", "success")); v != 1 {
+		t.Errorf("Expected 1 walkdir operation, got %v", v)
+	}
+	if got := testutil.CollectAndCount(c.walkDuration); got != 1 {
+		t.Errorf("Expected 1 walk duration series, got %d", got)
+	}
+}
+
+func TestWalkDirUnreadableDirectory(t *testing.T) {
+	infos := map[string]fs.FileInfo{
+		"/root":        &dirFileInfo{mockFileInfo{name: "root"}},
+		"/root/locked": &dirFileInfo{mockFileInfo{name: "locked"}},
+		"/root/a.txt":  &mockFileInfo{name: "a.txt"},
+	}
+	lstat := func(name string) (fs.FileInfo, error) { return infos[name], nil }
+	readDir := func(name string) ([]fs.DirEntry, error) {
+		if name == "/root/locked" {
+			return nil, fs.ErrPermission
+		}
+		return []fs.DirEntry{
+			fs.FileInfoToDirEntry(infos["/root/a.txt"]),
+			fs.FileInfoToDirEntry(infos["/root/locked"]),
+		}, nil
+	}
+
+	var w walkStats
+	var calls int
+	err := walkDir("/root", lstat, readDir, w.wrap(func(path string, d fs.DirEntry, err error) error {
+		calls++
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("walkDir failed: %v", err)
+	}
+
+	// locked is passed to fn twice, but visited once
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+	if got := w.entries.Load(); got != 3 {
+		t.Errorf("Expected 3 entries, got %d", got)
+	}
+	if got := w.errors.Load(); got != 1 {
+		t.Errorf("Expected 1 error, got %d", got)
+	}
+	if dirs, files := w.dirs.Load(), w.files.Load(); dirs+files != w.entries.Load() || dirs != 2 {
+		t.Errorf("Expected 2 dirs and 1 file, got %d and %d", dirs, files)
+	}
+}
+
+func TestOTelWalkDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 5), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("failed to create base filesystem: %v", err)
+	}
+	tp := &recordingTracerProvider{}
+	ofs, err := NewWithOTel(base, OTelConfig{
+		MeterProvider:  noop.NewMeterProvider(),
+		TracerProvider: tp,
+		EnableTracing:  true,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOTel failed: %v", err)
+	}
+
+	err = ofs.WalkDir(filepath.ToSlash(dir), func(path string, d fs.DirEntry, err error) error {
+		if err == nil {
+			d.Info()
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+
+	var walk *recordingSpan
+	for _, span := range tp.spans {
+		if span.name == "WalkDir" {
+			walk = span
+		}
+	}
+	if walk == nil {
+		t.Fatal("Expected a WalkDir span")
+	}
+	for key, want := range map[string]int64{"fs.walk.files": 1, "fs.walk.dirs": 1, "fs.walk.bytes": 5} {
+		if got := walk.attributes[key].AsInt64(); got != want {
+			t.Errorf("Expected %s %d, got %d", key, want, got)
+		}
+	}
+}
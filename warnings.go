@@ -0,0 +1,114 @@
+package metricsfs
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/absfs/absfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConfigWarning reports an enabled feature that has no effect, or a reduced
+// one, with the configuration or base filesystem it was enabled for, so that
+// misconfigurations do not go unnoticed.
+type ConfigWarning struct {
+	// Feature is the Config field concerned, such as "CapacityPath"
+	Feature string `json:"feature"`
+
+	// Message explains what is wrong
+	Message string `json:"message"`
+}
+
+// String returns the warning as "Feature: Message".
+func (w ConfigWarning) String() string {
+	return w.Feature + ": " + w.Message
+}
+
+// Warnings returns the configuration warnings found when the collector and
+// the filesystems recording into it were created. Each is also exported as
+// config_warning_info{feature} with a value of 1.
+func (c *Collector) Warnings() []ConfigWarning {
+	c.warningsMu.Lock()
+	defer c.warningsMu.Unlock()
+	return slices.Clone(c.warnings)
+}
+
+// Warnings returns the configuration warnings of the collector, including
+// those about the filesystem m wraps. See Collector.Warnings.
+func (m *MetricsFS) Warnings() []ConfigWarning {
+	return m.collector.Warnings()
+}
+
+// warn adds a warning about feature, unless it was already given.
+func (c *Collector) warn(feature, format string, args ...any) {
+	w := ConfigWarning{Feature: feature, Message: fmt.Sprintf(format, args...)}
+
+	c.warningsMu.Lock()
+	defer c.warningsMu.Unlock()
+	if !slices.Contains(c.warnings, w) {
+		c.warnings = append(c.warnings, w)
+	}
+}
+
+// checkConfig warns about the features of config that have no effect with
+// the rest of it.
+func (c *Collector) checkConfig(config Config) {
+	c.configWarningInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(config.Namespace, config.Subsystem, "config_warning_info"),
+		"Configuration warnings, by the Config field concerned. See Collector.Warnings",
+		[]string{"feature"},
+		config.constLabelsFor("config_warning_info"),
+	)
+
+	if !config.EnablePathMetrics {
+		if config.EnablePathLatencyMetrics {
+			c.warn("EnablePathLatencyMetrics", "has no effect until EnablePathMetrics is set")
+		}
+		if config.GroupPathMetrics {
+			c.warn("GroupPathMetrics", "has no effect until EnablePathMetrics is set")
+		}
+	}
+	if config.EnableApdex && len(config.SLOThresholds) == 0 {
+		c.warn("EnableApdex", "has no effect until SLOThresholds are set")
+	}
+	if config.EnableCPUMetrics {
+		if _, ok := threadCPUTime(); !ok {
+			c.warn("EnableCPUMetrics", "thread CPU time is not supported on this platform")
+		} else if config.LatencySampleRate > 1 {
+			c.warn("LatencySampleRate", "is ignored with EnableCPUMetrics")
+		}
+	}
+	if config.CloseLeakedFiles && !config.EnableLeakDetection {
+		c.warn("CloseLeakedFiles", "has no effect without EnableLeakDetection")
+	}
+	if config.CapacityPath != "" {
+		if _, _, ok := diskCapacity(config.CapacityPath); !ok {
+			c.warn("CapacityPath", "the capacity of %q cannot be read, capacity metrics are not exported", config.CapacityPath)
+		}
+	}
+}
+
+// checkBase warns about the features of the collector's configuration that
+// fsys cannot support.
+func (c *Collector) checkBase(fsys absfs.FileSystem) {
+	if c.config.ResolveRelativePaths {
+		if _, err := fsys.Getwd(); err != nil {
+			c.warn("ResolveRelativePaths", "the working directory of the base filesystem is unknown: %v", err)
+		}
+	}
+	if c.config.EnableOverwriteDetection {
+		if _, ok := fsys.(interface {
+			Lstat(name string) (os.FileInfo, error)
+		}); !ok {
+			c.warn("EnableOverwriteDetection", "the base filesystem has no Lstat, symbolic links are inspected through their targets")
+		}
+	}
+}
+
+// collectWarnings exports the configuration warnings.
+func (c *Collector) collectWarnings(ch chan<- prometheus.Metric) {
+	for _, w := range c.Warnings() {
+		ch <- prometheus.MustNewConstMetric(c.configWarningInfo, prometheus.GaugeValue, 1, w.Feature)
+	}
+}
@@ -0,0 +1,109 @@
+package metricsfs
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/absfs/absfs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// noLstatFS hides the Lstat of the filesystem it wraps.
+type noLstatFS struct {
+	absfs.FileSystem
+}
+
+func hasWarning(warnings []ConfigWarning, feature string) bool {
+	for _, w := range warnings {
+		if w.Feature == feature {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWarningsNone(t *testing.T) {
+	fs := NewWithConfig(newMockFS(), DefaultConfig())
+	if warnings := fs.Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() = %v, want none", warnings)
+	}
+}
+
+func TestWarningsConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.EnablePathMetrics = false
+	config.EnablePathLatencyMetrics = true
+	config.GroupPathMetrics = true
+	config.EnableApdex = true
+	config.CapacityPath = filepath.Join(t.TempDir(), "missing")
+
+	warnings := NewWithConfig(newMockFS(), config).Warnings()
+	for _, feature := range []string{"EnablePathLatencyMetrics", "GroupPathMetrics", "EnableApdex", "CapacityPath"} {
+		if !hasWarning(warnings, feature) {
+			t.Errorf("no warning about %s in %v", feature, warnings)
+		}
+	}
+}
+
+func TestWarningsBase(t *testing.T) {
+	config := DefaultConfig()
+	config.ResolveRelativePaths = true
+	config.EnableOverwriteDetection = true
+
+	if warnings := NewWithConfig(newMockFS(), config).Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() = %v, want none", warnings)
+	}
+
+	warnings := NewWithConfig(&errorChdirMockFS{}, config).Warnings()
+	if !hasWarning(warnings, "ResolveRelativePaths") {
+		t.Errorf("no warning about ResolveRelativePaths in %v", warnings)
+	}
+
+	warnings = NewWithConfig(noLstatFS{newMockFS()}, config).Warnings()
+	if !hasWarning(warnings, "EnableOverwriteDetection") {
+		t.Errorf("no warning about EnableOverwriteDetection in %v", warnings)
+	}
+}
+
+func TestWarningsSharedCollector(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableOverwriteDetection = true
+	collector := NewCollector(config)
+
+	fs := NewWithCollector(noLstatFS{newMockFS()}, collector, "a")
+	NewWithCollector(noLstatFS{newMockFS()}, collector, "b")
+
+	if warnings := fs.Warnings(); len(warnings) != 1 {
+		t.Errorf("Warnings() = %v, want 1 warning", warnings)
+	}
+}
+
+func TestWarningsMetric(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableApdex = true
+	fs := NewWithConfig(newMockFS(), config)
+	collector := fs.Collector()
+
+	expected := `
+		# HELP fs_config_warning_info Configuration warnings, by the Config field concerned. See Collector.Warnings
+		# TYPE fs_config_warning_info gauge
+		fs_config_warning_info{feature="EnableApdex"} 1
+	`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "fs_config_warning_info"); err != nil {
+		t.Error(err)
+	}
+
+	// Warnings outlive Reset
+	collector.Reset()
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "fs_config_warning_info"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConfigWarningString(t *testing.T) {
+	w := ConfigWarning{Feature: "EnableApdex", Message: "has no effect without SLOThresholds"}
+	if got, want := w.String(), "EnableApdex: has no effect without SLOThresholds"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
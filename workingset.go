@@ -0,0 +1,124 @@
+package metricsfs
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// HyperLogLog precision: 2^12 one-byte registers per sketch, for a standard
+// error of about 1.6% in the estimated number of distinct paths.
+const (
+	workingSetPrecision = 12
+	workingSetRegisters = 1 << workingSetPrecision
+)
+
+// workingSetSlots is the number of sketches the window is divided into.
+// The window slides one slot at a time, so the estimate covers between
+// (workingSetSlots-1)/workingSetSlots of the window and the full window.
+const workingSetSlots = 6
+
+// WorkingSetSize returns the approximate number of distinct paths accessed
+// within the last WorkingSetWindow. It returns 0 unless
+// EnableWorkingSetMetrics is set.
+func (c *Collector) WorkingSetSize() int64 {
+	if c.workingSet == nil {
+		return 0
+	}
+	return c.workingSet.estimate(c.clock.Now())
+}
+
+// workingSet estimates the number of distinct paths accessed within a
+// sliding window in bounded memory. The window is divided into slots, each
+// with a HyperLogLog sketch of the paths accessed during it; the estimate
+// merges the sketches of the slots still inside the window.
+type workingSet struct {
+	mu    sync.Mutex
+	seed  maphash.Seed
+	slot  time.Duration
+	slots [workingSetSlots]workingSetSlot
+}
+
+// workingSetSlot is the sketch of the paths accessed during one slot.
+type workingSetSlot struct {
+	epoch     int64 // index of the slot since the Unix epoch
+	registers [workingSetRegisters]uint8
+}
+
+// newWorkingSet creates a working set estimator over window.
+func newWorkingSet(window time.Duration) *workingSet {
+	slot := window / workingSetSlots
+	if slot <= 0 {
+		slot = 1
+	}
+	return &workingSet{seed: maphash.MakeSeed(), slot: slot}
+}
+
+// add records an access to path at now.
+func (w *workingSet) add(path string, now time.Time) {
+	sum := maphash.String(w.seed, path)
+	register := sum >> (64 - workingSetPrecision)
+	// Position of the first set bit in the remaining bits, bounded by the
+	// guard bit
+	rank := uint8(bits.LeadingZeros64(sum<<workingSetPrecision|1<<(workingSetPrecision-1)) + 1)
+
+	epoch := now.UnixNano() / int64(w.slot)
+	w.mu.Lock()
+	s := &w.slots[epoch%workingSetSlots]
+	if s.epoch != epoch {
+		s.epoch = epoch
+		s.registers = [workingSetRegisters]uint8{}
+	}
+	if rank > s.registers[register] {
+		s.registers[register] = rank
+	}
+	w.mu.Unlock()
+}
+
+// estimate returns the approximate number of distinct paths accessed in the
+// window ending at now.
+func (w *workingSet) estimate(now time.Time) int64 {
+	epoch := now.UnixNano() / int64(w.slot)
+
+	var merged [workingSetRegisters]uint8
+	w.mu.Lock()
+	for i := range w.slots {
+		s := &w.slots[i]
+		if s.epoch <= epoch-workingSetSlots || s.epoch > epoch {
+			continue
+		}
+		for j, rank := range s.registers {
+			merged[j] = max(merged[j], rank)
+		}
+	}
+	w.mu.Unlock()
+
+	sum, zeros := 0.0, 0
+	for _, rank := range merged {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	const m = float64(workingSetRegisters)
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small sets
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(e))
+}
+
+// reset clears all sketches.
+func (w *workingSet) reset() {
+	w.mu.Lock()
+	w.slots = [workingSetSlots]workingSetSlot{}
+	w.mu.Unlock()
+}
+
+// sizeEstimate approximates the memory used by the estimator.
+func (w *workingSet) sizeEstimate() int64 {
+	return workingSetSlots * workingSetRegisters
+}
@@ -0,0 +1,90 @@
+package metricsfs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWorkingSetEstimate(t *testing.T) {
+	w := newWorkingSet(6 * time.Minute)
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < 20000; i++ {
+		w.add(fmt.Sprintf("/data/%d", i), now)
+	}
+	// Repeated accesses do not grow the working set
+	for i := 0; i < 20000; i++ {
+		w.add(fmt.Sprintf("/data/%d", i%100), now)
+	}
+
+	if got := w.estimate(now); got < 18500 || got > 21500 {
+		t.Errorf("Expected about 20000 distinct paths, got %d", got)
+	}
+}
+
+func TestWorkingSetWindow(t *testing.T) {
+	w := newWorkingSet(6 * time.Minute)
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < 100; i++ {
+		w.add(fmt.Sprintf("/old/%d", i), now)
+	}
+	later := now.Add(3 * time.Minute)
+	for i := 0; i < 50; i++ {
+		w.add(fmt.Sprintf("/new/%d", i), later)
+	}
+
+	if got := w.estimate(later); got < 140 || got > 160 {
+		t.Errorf("Expected about 150 paths within the window, got %d", got)
+	}
+
+	// Once the window has passed the old accesses, only the new ones count
+	if got := w.estimate(now.Add(7 * time.Minute)); got < 45 || got > 55 {
+		t.Errorf("Expected about 50 paths after the old ones left the window, got %d", got)
+	}
+	if got := w.estimate(later.Add(7 * time.Minute)); got != 0 {
+		t.Errorf("Expected an empty working set, got %d", got)
+	}
+}
+
+func TestWorkingSetMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableWorkingSetMetrics = true
+	fs := NewWithConfig(newMockFS(), config)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(fs.Collector())
+
+	fs.Stat("/a")
+	fs.Stat("/b")
+	fs.Stat("/b")
+	fs.Stat("/c")
+
+	expected := `
+# HELP fs_working_set_paths Approximate number of distinct paths accessed within the working set window
+# TYPE fs_working_set_paths gauge
+fs_working_set_paths 3
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "fs_working_set_paths"); err != nil {
+		t.Error(err)
+	}
+
+	fs.Collector().Reset()
+	if got := fs.Collector().WorkingSetSize(); got != 0 {
+		t.Errorf("Expected an empty working set after Reset, got %d", got)
+	}
+}
+
+func TestWorkingSetDisabled(t *testing.T) {
+	fs := New(newMockFS())
+	fs.Stat("/a")
+
+	if got := fs.Collector().WorkingSetSize(); got != 0 {
+		t.Errorf("Expected 0 when disabled, got %d", got)
+	}
+}